@@ -25,7 +25,11 @@ var RequireAuthMiddleware = &Middleware{
 			// If an anonymous user tries to access an API endpoint that requires authentication,
 			// prevent access.
 			if !actor.FromContext(r.Context()).IsAuthenticated() && !AllowAnonymousRequest(r) {
-				// Report HTTP 401 Unauthorized for API requests.
+				// Report HTTP 401 Unauthorized for API requests. Git clients (which clone
+				// repositories from the API) only send credentials when challenged.
+				if strings.HasPrefix(r.UserAgent(), "git/") {
+					w.Header().Set("WWW-Authenticate", `Basic realm="Sourcegraph"`)
+				}
 				http.Error(w, "Private mode requires authentication.", http.StatusUnauthorized)
 				return
 			}
@@ -101,6 +105,17 @@ func AllowAnonymousRequest(req *http.Request) bool {
 		return true
 	}
 
+	// Permission is checked later by repo-updater by validating the code host
+	// webhook signature.
+	if strings.HasPrefix(req.URL.Path, "/.api/webhooks/") {
+		return true
+	}
+
+	// Permission is checked later by validating the SCIM access token.
+	if strings.HasPrefix(req.URL.Path, "/.api/scim/") {
+		return true
+	}
+
 	apiRouteName := matchedRouteName(req, router.Router())
 	if apiRouteName == router.UI {
 		// Test against UI router. (Some of its handlers inject private data into the title or meta tags.)
@@ -42,6 +42,9 @@ func TestAllowAnonymousRequest(t *testing.T) {
 		{req: req("GET", "/doesnt/exist"), want: false},
 		{req: req("POST", "/doesnt/exist"), want: false},
 		{req: req("POST", "/.api/telemetry/log/v1/production"), want: true},
+		{req: req("POST", "/.api/webhooks/github"), want: true},
+		{req: req("GET", "/.api/scim/v2/Users"), want: true},
+		{req: req("POST", "/.api/graphql"), want: false},
 	}
 	for _, test := range tests {
 		t.Run(fmt.Sprintf("%s %s", test.req.Method, test.req.URL), func(t *testing.T) {
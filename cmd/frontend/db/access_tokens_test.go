@@ -302,3 +302,38 @@ func TestAccessTokens_Lookup_deletedUser(t *testing.T) {
 		}
 	})
 }
+
+func TestAccessTokens_Lookup_deactivatedUser(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	ctx := dbtesting.TestContext(t)
+
+	subject, err := Users.Create(ctx, NewUser{
+		Email:                 "u1@example.com",
+		Username:              "u1",
+		Password:              "p1",
+		EmailVerificationCode: "c1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, tv0, err := AccessTokens.Create(ctx, subject.ID, []string{"a"}, "n0", subject.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Users.SetDeactivated(ctx, subject.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AccessTokens.Lookup(ctx, tv0, "a"); err == nil {
+		t.Fatal("Lookup: want error looking up token for deactivated subject user")
+	}
+
+	if err := Users.SetDeactivated(ctx, subject.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AccessTokens.Lookup(ctx, tv0, "a"); err != nil {
+		t.Fatalf("Lookup: want token of reactivated subject user, got error %v", err)
+	}
+}
@@ -198,6 +198,11 @@ func (*userExternalAccounts) Delete(ctx context.Context, id int32) error {
 type ExternalAccountsListOptions struct {
 	UserID                           int32
 	ServiceType, ServiceID, ClientID string
+	// AnyClientID makes the ServiceType and ServiceID options match the accounts of any client
+	// ID, instead of only those of ClientID.
+	AnyClientID bool
+	// AccountID, if set, only matches the accounts with this ID on their external service.
+	AccountID string
 	*LimitOffset
 }
 
@@ -312,9 +317,14 @@ func (*userExternalAccounts) listSQL(opt ExternalAccountsListOptions) (conds []*
 	if opt.UserID != 0 {
 		conds = append(conds, sqlf.Sprintf("user_id=%d", opt.UserID))
 	}
-	if opt.ServiceType != "" || opt.ServiceID != "" || opt.ClientID != "" {
+	if opt.AnyClientID {
+		conds = append(conds, sqlf.Sprintf("(service_type=%s AND service_id=%s)", opt.ServiceType, opt.ServiceID))
+	} else if opt.ServiceType != "" || opt.ServiceID != "" || opt.ClientID != "" {
 		conds = append(conds, sqlf.Sprintf("(service_type=%s AND service_id=%s AND client_id=%s)", opt.ServiceType, opt.ServiceID, opt.ClientID))
 	}
+	if opt.AccountID != "" {
+		conds = append(conds, sqlf.Sprintf("account_id=%s", opt.AccountID))
+	}
 	return conds
 }
 
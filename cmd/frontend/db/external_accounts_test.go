@@ -111,6 +111,49 @@ func TestExternalAccounts_CreateUserAndSave(t *testing.T) {
 	}
 }
 
+func TestExternalAccounts_List(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	ctx := dbtesting.TestContext(t)
+
+	spec := extsvc.ExternalAccountSpec{
+		ServiceType: "xa",
+		ServiceID:   "xb",
+		ClientID:    "xc",
+		AccountID:   "xd",
+	}
+	userID, err := ExternalAccounts.CreateUserAndSave(ctx, NewUser{Username: "u"}, spec, extsvc.ExternalAccountData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opt  ExternalAccountsListOptions
+		want int
+	}{
+		{"user", ExternalAccountsListOptions{UserID: userID}, 1},
+		{"other user", ExternalAccountsListOptions{UserID: userID + 1}, 0},
+		{"service and client", ExternalAccountsListOptions{ServiceType: "xa", ServiceID: "xb", ClientID: "xc"}, 1},
+		{"service without client", ExternalAccountsListOptions{ServiceType: "xa", ServiceID: "xb"}, 0},
+		{"service with any client", ExternalAccountsListOptions{ServiceType: "xa", ServiceID: "xb", AnyClientID: true}, 1},
+		{"other service with any client", ExternalAccountsListOptions{ServiceType: "xa", ServiceID: "xz", AnyClientID: true}, 0},
+		{"account", ExternalAccountsListOptions{ServiceType: "xa", ServiceID: "xb", AnyClientID: true, AccountID: "xd"}, 1},
+		{"other account", ExternalAccountsListOptions{ServiceType: "xa", ServiceID: "xb", AnyClientID: true, AccountID: "xz"}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			accounts, err := ExternalAccounts.List(ctx, tc.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(accounts) != tc.want {
+				t.Errorf("got len(accounts) == %d, want %d", len(accounts), tc.want)
+			}
+		})
+	}
+}
+
 func simplifyExternalAccount(account *extsvc.ExternalAccount) {
 	account.CreatedAt = time.Time{}
 	account.UpdatedAt = time.Time{}
@@ -30,6 +30,7 @@ type ExternalServicesStore struct {
 	GitHubValidators          []func(*schema.GitHubConnection) error
 	GitLabValidators          []func(*schema.GitLabConnection, []schema.AuthProviders) error
 	BitbucketServerValidators []func(*schema.BitbucketServerConnection, []schema.AuthProviders) error
+	BitbucketCloudValidators  []func(*schema.BitbucketCloudConnection) error
 }
 
 // ExternalServiceKinds contains a map of all supported kinds of
@@ -135,6 +136,13 @@ func (e *ExternalServicesStore) ValidateConfig(kind, config string, ps []schema.
 		}
 		err = e.validateBitbucketServerConnection(&c, ps)
 
+	case "BITBUCKETCLOUD":
+		var c schema.BitbucketCloudConnection
+		if err = json.Unmarshal(normalized, &c); err != nil {
+			return err
+		}
+		err = e.validateBitbucketCloudConnection(&c)
+
 	case "OTHER":
 		var c schema.OtherExternalServiceConnection
 		if err = json.Unmarshal(normalized, &c); err != nil {
@@ -209,6 +217,14 @@ func (e *ExternalServicesStore) validateBitbucketServerConnection(c *schema.Bitb
 	return err.ErrorOrNil()
 }
 
+func (e *ExternalServicesStore) validateBitbucketCloudConnection(c *schema.BitbucketCloudConnection) error {
+	err := new(multierror.Error)
+	for _, validate := range e.BitbucketCloudValidators {
+		err = multierror.Append(err, validate(c))
+	}
+	return err.ErrorOrNil()
+}
+
 // Create creates a external service.
 //
 // Since this method is used before the configuration server has started
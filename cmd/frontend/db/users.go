@@ -510,6 +510,31 @@ func (u *users) SetIsSiteAdmin(ctx context.Context, id int32, isSiteAdmin bool)
 	return err
 }
 
+// SetDeactivated deactivates or reactivates the user. Unlike deleting them, deactivating a user
+// keeps their username, email addresses, external accounts and access tokens, but they can't sign
+// in or use their access tokens until they are reactivated.
+func (u *users) SetDeactivated(ctx context.Context, id int32, deactivated bool) error {
+	if Mocks.Users.SetDeactivated != nil {
+		return Mocks.Users.SetDeactivated(id, deactivated)
+	}
+	q := "UPDATE users SET deactivated_at=NULL WHERE id=$1 AND deleted_at IS NULL"
+	if deactivated {
+		q = "UPDATE users SET deactivated_at=COALESCE(deactivated_at, now()) WHERE id=$1 AND deleted_at IS NULL"
+	}
+	res, err := dbconn.Global.ExecContext(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	nrows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if nrows == 0 {
+		return userNotFoundErr{args: []interface{}{id}}
+	}
+	return nil
+}
+
 // CheckAndDecrementInviteQuota should be called before the user (identified
 // by userID) is allowed to invite any other user. If ok is false, then the
 // user is not allowed to invite any other user (either because they've
@@ -660,7 +685,7 @@ func (u *users) getOneBySQL(ctx context.Context, query string, args ...interface
 
 // getBySQL returns users matching the SQL query, if any exist.
 func (*users) getBySQL(ctx context.Context, query string, args ...interface{}) ([]*types.User, error) {
-	rows, err := dbconn.Global.QueryContext(ctx, "SELECT u.id, u.username, u.display_name, u.avatar_url, u.created_at, u.updated_at, u.site_admin, u.tags FROM users u "+query, args...)
+	rows, err := dbconn.Global.QueryContext(ctx, "SELECT u.id, u.username, u.display_name, u.avatar_url, u.created_at, u.updated_at, u.site_admin, u.tags, u.deactivated_at FROM users u "+query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -670,7 +695,7 @@ func (*users) getBySQL(ctx context.Context, query string, args ...interface{}) (
 	for rows.Next() {
 		var u types.User
 		var displayName, avatarURL sql.NullString
-		err := rows.Scan(&u.ID, &u.Username, &displayName, &avatarURL, &u.CreatedAt, &u.UpdatedAt, &u.SiteAdmin, pq.Array(&u.Tags))
+		err := rows.Scan(&u.ID, &u.Username, &displayName, &avatarURL, &u.CreatedAt, &u.UpdatedAt, &u.SiteAdmin, pq.Array(&u.Tags), &u.DeactivatedAt)
 		if err != nil {
 			return nil, err
 		}
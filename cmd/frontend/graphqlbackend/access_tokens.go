@@ -53,8 +53,9 @@ func (r *schemaResolver) CreateAccessToken(ctx context.Context, args *createAcce
 		switch scope {
 		case authz.ScopeUserAll:
 			hasUserAllScope = true
-		case authz.ScopeSiteAdminSudo:
-			// 🚨 SECURITY: Only site admins may create a token with the "site-admin:sudo" scope.
+		case authz.ScopeSiteAdminSudo, authz.ScopeSiteAdminSCIM:
+			// 🚨 SECURITY: Only site admins may create a token with the "site-admin:sudo" or
+			// "site-admin:scim" scope.
 			if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
 				return nil, err
 			}
@@ -67,8 +68,11 @@ func (r *schemaResolver) CreateAccessToken(ctx context.Context, args *createAcce
 		}
 		seenScope[scope] = struct{}{}
 	}
-	if !hasUserAllScope {
-		return nil, fmt.Errorf("all access tokens must have scope %q", authz.ScopeUserAll)
+	// SCIM clients only need the "site-admin:scim" scope, which can't be used outside of the
+	// SCIM API, so a SCIM token doesn't have to grant all the privileges of its user.
+	isSCIMToken := len(args.Scopes) == 1 && args.Scopes[0] == authz.ScopeSiteAdminSCIM
+	if !hasUserAllScope && !isSCIMToken {
+		return nil, fmt.Errorf("all access tokens must have scope %q, except those with only scope %q", authz.ScopeUserAll, authz.ScopeSiteAdminSCIM)
 	}
 
 	id, token, err := db.AccessTokens.Create(ctx, userID, args.Scopes, args.Note, actor.FromContext(ctx).UID)
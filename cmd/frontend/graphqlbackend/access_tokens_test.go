@@ -129,6 +129,49 @@ func TestMutation_CreateAccessToken(t *testing.T) {
 		})
 	})
 
+	t.Run("authenticated as site admin, using only the SCIM scope", func(t *testing.T) {
+		resetMocks()
+		mockAccessTokensCreate(t, 1, []string{authz.ScopeSiteAdminSCIM})
+		db.Mocks.Users.GetByCurrentAuthUser = func(ctx context.Context) (*types.User, error) {
+			return &types.User{ID: 1, SiteAdmin: true}, nil
+		}
+		defer func() { db.Mocks.Users.GetByCurrentAuthUser = nil }()
+
+		ctx := actor.WithActor(context.Background(), &actor.Actor{UID: 1})
+		result, err := (&schemaResolver{}).CreateAccessToken(ctx, &createAccessTokenInput{
+			User:   uid1GQLID,
+			Scopes: []string{authz.ScopeSiteAdminSCIM},
+			Note:   "n",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil {
+			t.Error("got nil result")
+		}
+	})
+
+	t.Run("authenticated as site admin, using only the sudo scope", func(t *testing.T) {
+		resetMocks()
+		db.Mocks.Users.GetByCurrentAuthUser = func(ctx context.Context) (*types.User, error) {
+			return &types.User{ID: 1, SiteAdmin: true}, nil
+		}
+		defer func() { db.Mocks.Users.GetByCurrentAuthUser = nil }()
+
+		ctx := actor.WithActor(context.Background(), &actor.Actor{UID: 1})
+		result, err := (&schemaResolver{}).CreateAccessToken(ctx, &createAccessTokenInput{
+			User:   uid1GQLID,
+			Scopes: []string{authz.ScopeSiteAdminSudo},
+			Note:   "n",
+		})
+		if err == nil {
+			t.Error("err == nil")
+		}
+		if result != nil {
+			t.Errorf("got result %v, want nil", result)
+		}
+	})
+
 	t.Run("authenticated as different user who is a site-admin", func(t *testing.T) {
 		resetMocks()
 		const differentSiteAdminUID = 234
@@ -195,8 +195,8 @@ func performCodemod(ctx context.Context, args *search.Args) ([]searchResultResol
 
 	var results []searchResultResolver
 	for _, ur := range unflattened {
-		for _, resolver := range ur {
-			results = append(results, &resolver)
+		for i := range ur {
+			results = append(results, &ur[i])
 		}
 	}
 
@@ -309,6 +309,7 @@ func callCodemodInRepo(ctx context.Context, repoRevs *search.RepositoryRevisions
 			commit: &GitCommitResolver{
 				repo:     &RepositoryResolver{repo: repoRevs.Repo},
 				inputRev: &repoRevs.Revs[0].RevSpec,
+				oid:      GitObjectID(commit),
 			},
 			path:    raw.URI,
 			fileURL: fileURL,
@@ -0,0 +1,246 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/goroutine"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+// codemodRefPrefix is the prefix of the refs that applied codemods are
+// committed to. They are not created under refs/heads/ because gitserver
+// mirrors the branches of the code host: it fetches with --prune, which
+// deletes branches that don't exist on the code host, so a codemod branch
+// would disappear the next time the repository is updated.
+const codemodRefPrefix = "refs/codemod/"
+
+// maxConcurrentCodemodCommits is the maximum number of commits created on
+// gitserver at the same time by ApplyCodemod.
+const maxConcurrentCodemodCommits = 8
+
+var validCodemodBranch = regexp.MustCompile(`^[a-zA-Z0-9_.\-/]+$`)
+
+// validateCodemodBranch returns a non-nil error if branch can't be used as
+// the name of the ref an applied codemod is committed to.
+func validateCodemodBranch(branch string) error {
+	if !validCodemodBranch.MatchString(branch) {
+		return errors.New("branch names may only contain alphanumeric characters and '_', '.', '-' or '/'")
+	}
+	if strings.HasPrefix(branch, "-") || strings.HasPrefix(branch, "/") || strings.HasSuffix(branch, "/") ||
+		strings.HasSuffix(branch, ".") || strings.HasSuffix(branch, ".lock") ||
+		strings.Contains(branch, "..") || strings.Contains(branch, "//") || strings.Contains(branch, "/.") {
+		return errors.Errorf("invalid branch name %q", branch)
+	}
+	return nil
+}
+
+// codemodPatch returns a patch which can be applied with `git apply` for
+// the codemod results of a single repository.
+func codemodPatch(results []*codemodResultResolver) (string, error) {
+	var b strings.Builder
+	for _, result := range results {
+		i := strings.Index(result.diff, "@@")
+		if i < 0 {
+			return "", errors.Errorf("invalid diff for %s does not contain expected @@", result.path)
+		}
+		hunks := result.diff[i:]
+		fmt.Fprintf(&b, "diff --git a/%[1]s b/%[1]s\n--- a/%[1]s\n+++ b/%[1]s\n", result.path)
+		b.WriteString(hunks)
+		if !strings.HasSuffix(hunks, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), nil
+}
+
+// ApplyCodemod commits the changes of the codemod described by a search
+// query with a replace: field to a new branch in each matched repository.
+func (r *schemaResolver) ApplyCodemod(ctx context.Context, args *struct {
+	Query   string
+	Branch  string
+	Message *string
+}) ([]*codemodCommitResultResolver, error) {
+	// 🚨 SECURITY: Only site admins may create commits in repositories.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := validateCodemodBranch(args.Branch); err != nil {
+		return nil, err
+	}
+
+	q, err := query.ParseAndCheck(args.Query)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.Values(query.FieldReplace)) == 0 {
+		return nil, errors.New("the query must contain a 'replace:' field")
+	}
+
+	// The results are the same as those of the search used to preview the
+	// codemod.
+	sr := &searchResolver{query: q, zoekt: IndexedSearch()}
+	rr, err := sr.doResults(ctx, "codemod")
+	if err != nil {
+		return nil, err
+	}
+	if rr.alert != nil {
+		return nil, errors.New(rr.alert.title)
+	}
+	// Applying only some of the results would silently leave the other
+	// repositories and files unchanged.
+	if rr.LimitHit() {
+		return nil, errors.New("the codemod matches more results than can be applied at once: narrow the query (for example with repo: filters) or increase its count:")
+	}
+
+	message := "Apply codemod\n\n" + args.Query
+	if args.Message != nil && *args.Message != "" {
+		message = *args.Message
+	}
+	info := protocol.PatchCommitInfo{Message: message, Date: time.Now()}
+	if user, err := db.Users.GetByCurrentAuthUser(ctx); err == nil {
+		info.AuthorName = user.Username
+		if user.DisplayName != "" {
+			info.AuthorName = user.DisplayName
+		}
+		if email, _, err := db.UserEmails.GetPrimaryEmail(ctx, user.ID); err == nil {
+			info.AuthorEmail = email
+		}
+	}
+
+	// Group the results by repository, keeping the order they were returned
+	// in.
+	var (
+		repos  []*types.Repo
+		byRepo = map[api.RepoID][]*codemodResultResolver{}
+	)
+	for _, result := range rr.results {
+		res, ok := result.ToCodemodResult()
+		if !ok {
+			continue
+		}
+		repo := res.commit.repo.repo
+		if _, ok := byRepo[repo.ID]; !ok {
+			repos = append(repos, repo)
+		}
+		byRepo[repo.ID] = append(byRepo[repo.ID], res)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, maxConcurrentCodemodCommits)
+		results = make([]*codemodCommitResultResolver, len(repos))
+	)
+	for i, repo := range repos {
+		i, repo := i, repo
+		wg.Add(1)
+		sem <- struct{}{}
+		goroutine.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			commit, err := commitCodemodInRepo(ctx, repo, byRepo[repo.ID], codemodRefPrefix+args.Branch, info)
+			results[i] = &codemodCommitResultResolver{
+				repo:      &RepositoryResolver{repo: repo},
+				commit:    commit,
+				fileCount: int32(len(byRepo[repo.ID])),
+				err:       err,
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, repo := range rr.timedout {
+		results = append(results, &codemodCommitResultResolver{
+			repo: &RepositoryResolver{repo: repo},
+			err:  errors.New("timed out while computing the codemod"),
+		})
+	}
+	for _, repo := range rr.cloning {
+		results = append(results, &codemodCommitResultResolver{
+			repo: &RepositoryResolver{repo: repo},
+			err:  errors.New("repository is still being cloned"),
+		})
+	}
+	return results, nil
+}
+
+// commitCodemodInRepo creates a commit on targetRef in repo which applies
+// the changes of the given codemod results to the commit they were computed
+// for.
+func commitCodemodInRepo(ctx context.Context, repo *types.Repo, results []*codemodResultResolver, targetRef string, info protocol.PatchCommitInfo) (*GitCommitResolver, error) {
+	base := results[0].commit.oid
+	for _, res := range results {
+		if res.commit.oid != base {
+			return nil, errors.New("codemod results are for several revisions")
+		}
+	}
+
+	patch, err := codemodPatch(results)
+	if err != nil {
+		return nil, err
+	}
+
+	cachedRepo, err := backend.CachedGitRepo(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	// We never overwrite an existing ref, since it may contain the result of
+	// a previous codemod.
+	_, err = git.ResolveRevision(ctx, *cachedRepo, nil, targetRef, &git.ResolveRevisionOptions{NoEnsureRevision: true})
+	if err == nil {
+		return nil, errors.Errorf("%s already exists", targetRef)
+	} else if !gitserver.IsRevisionNotFound(err) {
+		return nil, err
+	}
+
+	_, err = gitserver.DefaultClient.CreateCommitFromPatch(ctx, protocol.CreateCommitFromPatchRequest{
+		Repo:       repo.Name,
+		BaseCommit: api.CommitID(base),
+		TargetRef:  targetRef,
+		Patch:      patch,
+		CommitInfo: info,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &RepositoryResolver{repo: repo}
+	return r.Commit(ctx, &repositoryCommitArgs{Rev: targetRef})
+}
+
+// codemodCommitResultResolver is a resolver for the GraphQL type
+// `CodemodCommitResult`
+type codemodCommitResultResolver struct {
+	repo      *RepositoryResolver
+	commit    *GitCommitResolver
+	fileCount int32
+	err       error
+}
+
+func (r *codemodCommitResultResolver) Repository() *RepositoryResolver { return r.repo }
+
+func (r *codemodCommitResultResolver) Commit() *GitCommitResolver { return r.commit }
+
+func (r *codemodCommitResultResolver) FileCount() int32 { return r.fileCount }
+
+func (r *codemodCommitResultResolver) Error() *string {
+	if r.err == nil {
+		return nil
+	}
+	s := r.err.Error()
+	return &s
+}
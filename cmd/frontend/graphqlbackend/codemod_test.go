@@ -39,3 +39,48 @@ func TestCodemod_resolver(t *testing.T) {
 		t.Fatalf("Expected error %q", err)
 	}
 }
+
+func TestCodemod_validateCodemodBranch(t *testing.T) {
+	for _, branch := range []string{"codemod", "codemod/fmt-sprint", "v1.2_fix"} {
+		if err := validateCodemodBranch(branch); err != nil {
+			t.Errorf("expected branch %q to be valid, got %s", branch, err)
+		}
+	}
+	for _, branch := range []string{"", "-f", "/a", "a/", "a..b", "a//b", "a/.b", "a.lock", "a.", "a b", "a~1", "a:b"} {
+		if err := validateCodemodBranch(branch); err == nil {
+			t.Errorf("expected branch %q to be invalid", branch)
+		}
+	}
+}
+
+func TestCodemod_codemodPatch(t *testing.T) {
+	results := []*codemodResultResolver{
+		{path: "a.go", diff: "--- a.go\n+++ a.go\n@@ -1,1 +1,1 @@\n-foo\n+bar"},
+		{path: "dir/b.go", diff: "@@ -2,1 +2,1 @@\n-x\n+y\n"},
+	}
+	got, err := codemodPatch(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,1 +1,1 @@
+-foo
++bar
+diff --git a/dir/b.go b/dir/b.go
+--- a/dir/b.go
++++ b/dir/b.go
+@@ -2,1 +2,1 @@
+-x
++y
+`
+	if got != want {
+		t.Errorf("got patch:\n%s\nwant:\n%s", got, want)
+	}
+
+	_, err = codemodPatch([]*codemodResultResolver{{path: "a.go", diff: "Not a valid diff"}})
+	if err == nil {
+		t.Fatal("expected error for invalid diff")
+	}
+}
@@ -0,0 +1,158 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend/graphqlutil"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// ExplicitPermissions is the store of the repository permissions set explicitly by site
+// admins. If it is not set at runtime, a "not implemented" error is returned to API
+// clients who set such permissions, and no users are explicitly permitted on any
+// repository.
+//
+// This is contributed by enterprise.
+var ExplicitPermissions ExplicitPermissionsResolver
+
+// ExplicitPermissionsResolver is the interface of the store of explicit repository
+// permissions used by the GraphQL API.
+type ExplicitPermissionsResolver interface {
+	// SetRepositoryPermissionsForUsers replaces the set of users who can read the given
+	// repository with the given users.
+	SetRepositoryPermissionsForUsers(ctx context.Context, repoID api.RepoID, userIDs []int32) error
+	// RepositoryPermittedUserIDs returns the IDs of the users who can read the given
+	// repository, in ascending order.
+	RepositoryPermittedUserIDs(ctx context.Context, repoID api.RepoID) ([]int32, error)
+}
+
+var errExplicitPermissionsNotImplemented = errors.New("explicit repository permissions are not implemented")
+
+type repositoryPermissionsUserInput struct {
+	Username        *string
+	Email           *string
+	ExternalAccount *externalAccountInput
+}
+
+type externalAccountInput struct {
+	ServiceType string
+	ServiceID   string
+	AccountID   string
+}
+
+// userID returns the ID of the user matched by the input.
+func (in *repositoryPermissionsUserInput) userID(ctx context.Context) (int32, error) {
+	set := 0
+	for _, ok := range []bool{in.Username != nil, in.Email != nil, in.ExternalAccount != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return 0, errors.New("exactly one of username, email and externalAccount must be set")
+	}
+
+	switch {
+	case in.Username != nil:
+		user, err := db.Users.GetByUsername(ctx, *in.Username)
+		if err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+
+	case in.Email != nil:
+		user, err := db.Users.GetByVerifiedEmail(ctx, *in.Email)
+		if err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+
+	default:
+		acct := in.ExternalAccount
+		accts, err := db.ExternalAccounts.List(ctx, db.ExternalAccountsListOptions{
+			ServiceType: acct.ServiceType,
+			ServiceID:   acct.ServiceID,
+			AnyClientID: true,
+			AccountID:   acct.AccountID,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(accts) == 0 {
+			return 0, fmt.Errorf("no user found with external account %q on %s %s", acct.AccountID, acct.ServiceType, acct.ServiceID)
+		}
+		for _, a := range accts[1:] {
+			if a.UserID != accts[0].UserID {
+				return 0, fmt.Errorf("multiple users found with external account %q on %s %s", acct.AccountID, acct.ServiceType, acct.ServiceID)
+			}
+		}
+		return accts[0].UserID, nil
+	}
+}
+
+func (*schemaResolver) SetRepositoryPermissionsForUsers(ctx context.Context, args *struct {
+	Repository graphql.ID
+	Users      []*repositoryPermissionsUserInput
+}) (*EmptyResponse, error) {
+	// 🚨 SECURITY: Only site admins can set repository permissions.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if ExplicitPermissions == nil {
+		return nil, errExplicitPermissionsNotImplemented
+	}
+
+	repoID, err := unmarshalRepositoryID(args.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure the repository exists.
+	if _, err := db.Repos.Get(ctx, repoID); err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int32, 0, len(args.Users))
+	for _, u := range args.Users {
+		id, err := u.userID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+
+	if err := ExplicitPermissions.SetRepositoryPermissionsForUsers(ctx, repoID, userIDs); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
+
+func (r *RepositoryResolver) ExplicitlyPermittedUsers(ctx context.Context, args *struct {
+	graphqlutil.ConnectionArgs
+}) (*userConnectionResolver, error) {
+	// 🚨 SECURITY: Only site admins are allowed to see who was explicitly granted access
+	// to the repository.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	// A non-nil empty list of user IDs matches no users.
+	userIDs := []int32{}
+	if ExplicitPermissions != nil {
+		ids, err := ExplicitPermissions.RepositoryPermittedUserIDs(ctx, r.repo.ID)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, ids...)
+	}
+
+	opt := db.UsersListOptions{UserIDs: userIDs}
+	args.ConnectionArgs.Set(&opt.LimitOffset)
+	return &userConnectionResolver{opt: opt}, nil
+}
@@ -0,0 +1,112 @@
+package graphqlbackend
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/gqltesting"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+)
+
+type fakeExplicitPermissions map[api.RepoID][]int32
+
+func (f fakeExplicitPermissions) SetRepositoryPermissionsForUsers(_ context.Context, repoID api.RepoID, userIDs []int32) error {
+	f[repoID] = userIDs
+	return nil
+}
+
+func (f fakeExplicitPermissions) RepositoryPermittedUserIDs(_ context.Context, repoID api.RepoID) ([]int32, error) {
+	return f[repoID], nil
+}
+
+// 🚨 SECURITY: This tests that only site admins can set explicit repository permissions.
+func TestMutation_SetRepositoryPermissionsForUsers(t *testing.T) {
+	perms := fakeExplicitPermissions{}
+	ExplicitPermissions = perms
+	defer func() { ExplicitPermissions = nil }()
+
+	repoGQLID := string(marshalRepositoryID(1))
+
+	t.Run("non-site-admin", func(t *testing.T) {
+		resetMocks()
+		db.Mocks.Users.GetByCurrentAuthUser = func(context.Context) (*types.User, error) {
+			return &types.User{ID: 1}, nil
+		}
+
+		ctx := actor.WithActor(context.Background(), &actor.Actor{UID: 1})
+		_, err := (&schemaResolver{}).SetRepositoryPermissionsForUsers(ctx, &struct {
+			Repository graphql.ID
+			Users      []*repositoryPermissionsUserInput
+		}{Repository: graphql.ID(repoGQLID)})
+		if err != backend.ErrMustBeSiteAdmin {
+			t.Errorf("got err %v, want %v", err, backend.ErrMustBeSiteAdmin)
+		}
+	})
+
+	t.Run("site admin", func(t *testing.T) {
+		resetMocks()
+		db.Mocks.Users.GetByCurrentAuthUser = func(context.Context) (*types.User, error) {
+			return &types.User{ID: 1, SiteAdmin: true}, nil
+		}
+		db.Mocks.Repos.Get = func(_ context.Context, id api.RepoID) (*types.Repo, error) {
+			return &types.Repo{ID: id}, nil
+		}
+		db.Mocks.Users.GetByUsername = func(_ context.Context, username string) (*types.User, error) {
+			return &types.User{ID: 2, Username: username}, nil
+		}
+		db.Mocks.Users.GetByVerifiedEmail = func(context.Context, string) (*types.User, error) {
+			return &types.User{ID: 3}, nil
+		}
+		db.Mocks.ExternalAccounts.List = func(opt db.ExternalAccountsListOptions) ([]*extsvc.ExternalAccount, error) {
+			want := db.ExternalAccountsListOptions{ServiceType: "saml", ServiceID: "https://idp.example.com", AnyClientID: true, AccountID: "bob"}
+			if !reflect.DeepEqual(opt, want) {
+				t.Errorf("got options %+v, want %+v", opt, want)
+			}
+			return []*extsvc.ExternalAccount{{UserID: 4}}, nil
+		}
+
+		gqltesting.RunTests(t, []*gqltesting.Test{
+			{
+				Context: actor.WithActor(context.Background(), &actor.Actor{UID: 1}),
+				Schema:  GraphQLSchema,
+				Query: `
+				mutation {
+					setRepositoryPermissionsForUsers(repository: "` + repoGQLID + `", users: [
+						{username: "alice"},
+						{email: "carol@example.com"},
+						{externalAccount: {serviceType: "saml", serviceID: "https://idp.example.com", accountID: "bob"}},
+					]) {
+						alwaysNil
+					}
+				}
+			`,
+				ExpectedResult: `
+				{
+					"setRepositoryPermissionsForUsers": {
+						"alwaysNil": null
+					}
+				}
+			`,
+			},
+		})
+
+		if want := []int32{2, 3, 4}; !reflect.DeepEqual(perms[1], want) {
+			t.Errorf("got users %v, want %v", perms[1], want)
+		}
+	})
+
+	t.Run("ambiguous user", func(t *testing.T) {
+		username, email := "alice", "alice@example.com"
+		in := &repositoryPermissionsUserInput{Username: &username, Email: &email}
+		if _, err := in.userID(context.Background()); err == nil {
+			t.Error("got nil error, want error")
+		}
+	})
+}
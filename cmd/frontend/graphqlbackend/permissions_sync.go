@@ -0,0 +1,131 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/usagestats"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// PermissionsSyncer is the background syncer of the repository permissions of users and
+// repositories. If it is not set at runtime, the permissions sync fields are null and a
+// "not implemented" error is returned to API clients who invoke the permissions sync
+// mutations.
+//
+// This is contributed by enterprise.
+var PermissionsSyncer PermissionsSyncerResolver
+
+// PermissionsSyncerResolver is the interface of the background syncer of repository
+// permissions used by the GraphQL API.
+type PermissionsSyncerResolver interface {
+	// UserPermissionsLastSyncedAt returns the last time the repository permissions of the
+	// given user were synced, or nil if they never were.
+	UserPermissionsLastSyncedAt(ctx context.Context, userID int32) (*time.Time, error)
+	// RepoPermissionsLastSyncedAt returns the last time the permissions of the users of the
+	// given repository's code host on it were synced, or nil if they never were.
+	RepoPermissionsLastSyncedAt(ctx context.Context, repoID api.RepoID) (*time.Time, error)
+	// ScheduleUserPermissionsSync schedules the repository permissions of the given user to
+	// be synced as soon as possible.
+	ScheduleUserPermissionsSync(ctx context.Context, userID int32) error
+	// ScheduleRepoPermissionsSync schedules the permissions of the users of the given
+	// repository's code host to be synced as soon as possible.
+	ScheduleRepoPermissionsSync(ctx context.Context, repoID api.RepoID) error
+}
+
+var errPermissionsSyncerNotImplemented = errors.New("permissions syncing is not implemented")
+
+// ListUsersActiveToday returns the IDs of the registered users who were active since today
+// at 00:00 UTC. The enterprise permissions syncer syncs their permissions in the background.
+func ListUsersActiveToday() ([]int32, error) {
+	users, err := usagestats.ListUsersToday()
+	if err != nil {
+		return nil, err
+	}
+	return sliceAtoi(users.Registered)
+}
+
+func (r *UserResolver) PermissionsLastSyncedAt(ctx context.Context) (*DateTime, error) {
+	// 🚨 SECURITY: Only the user and site admins are allowed to see when the user's
+	// permissions were synced.
+	if err := backend.CheckSiteAdminOrSameUser(ctx, r.user.ID); err != nil {
+		return nil, err
+	}
+
+	if PermissionsSyncer == nil {
+		return nil, nil
+	}
+
+	syncedAt, err := PermissionsSyncer.UserPermissionsLastSyncedAt(ctx, r.user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return DateTimeOrNil(syncedAt), nil
+}
+
+func (r *RepositoryResolver) PermissionsLastSyncedAt(ctx context.Context) (*DateTime, error) {
+	// 🚨 SECURITY: Only site admins are allowed to see when the repository's permissions
+	// were synced.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if PermissionsSyncer == nil {
+		return nil, nil
+	}
+
+	syncedAt, err := PermissionsSyncer.RepoPermissionsLastSyncedAt(ctx, r.repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	return DateTimeOrNil(syncedAt), nil
+}
+
+func (*schemaResolver) ScheduleUserPermissionsSync(ctx context.Context, args *struct {
+	User graphql.ID
+}) (*EmptyResponse, error) {
+	// 🚨 SECURITY: Only site admins can schedule permissions syncs.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if PermissionsSyncer == nil {
+		return nil, errPermissionsSyncerNotImplemented
+	}
+
+	userID, err := UnmarshalUserID(args.User)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := PermissionsSyncer.ScheduleUserPermissionsSync(ctx, userID); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
+
+func (*schemaResolver) ScheduleRepositoryPermissionsSync(ctx context.Context, args *struct {
+	Repository graphql.ID
+}) (*EmptyResponse, error) {
+	// 🚨 SECURITY: Only site admins can schedule permissions syncs.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if PermissionsSyncer == nil {
+		return nil, errPermissionsSyncerNotImplemented
+	}
+
+	repoID, err := unmarshalRepositoryID(args.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := PermissionsSyncer.ScheduleRepoPermissionsSync(ctx, repoID); err != nil {
+		return nil, err
+	}
+	return &EmptyResponse{}, nil
+}
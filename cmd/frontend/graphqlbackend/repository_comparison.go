@@ -12,6 +12,7 @@ import (
 	"github.com/sourcegraph/go-diff/diff"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/graphqlbackend/graphqlutil"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 )
@@ -134,11 +135,20 @@ type fileDiffConnectionResolver struct {
 
 func (r *fileDiffConnectionResolver) compute(ctx context.Context) ([]*diff.FileDiff, error) {
 	do := func() ([]*diff.FileDiff, error) {
+		cachedRepo, err := backend.CachedGitRepo(ctx, r.cmp.repo.repo)
+		if err != nil {
+			return nil, err
+		}
+
 		var rangeSpec string
 		hOid := r.cmp.head.OID()
 		if r.cmp.base == nil {
 			// Rare case: the base is the empty tree, in which case we need ".." not "..." because the latter only works for commits.
 			rangeSpec = string(r.cmp.baseRevspec) + ".." + string(hOid)
+		} else if ok, err := git.IsAncestor(ctx, *cachedRepo, api.CommitID(r.cmp.base.OID()), api.CommitID(hOid)); err == nil && ok {
+			// The merge base of a commit and its descendant is the commit
+			// itself, so diff them directly instead of having git compute it.
+			rangeSpec = string(r.cmp.base.OID()) + ".." + string(hOid)
 		} else {
 			rangeSpec = string(r.cmp.base.OID()) + "..." + string(hOid)
 		}
@@ -148,10 +158,6 @@ func (r *fileDiffConnectionResolver) compute(ctx context.Context) ([]*diff.FileD
 			// flags or refer to a file.
 			return nil, fmt.Errorf("invalid diff range argument: %q", rangeSpec)
 		}
-		cachedRepo, err := backend.CachedGitRepo(ctx, r.cmp.repo.repo)
-		if err != nil {
-			return nil, err
-		}
 		rdr, err := git.ExecReader(ctx, *cachedRepo, []string{
 			"diff",
 			"--find-renames",
@@ -114,6 +114,22 @@ func (r *repositoryMirrorInfoResolver) UpdatedAt(ctx context.Context) (*DateTime
 	return DateTimeOrNil(info.LastFetched), nil
 }
 
+func (r *repositoryMirrorInfoResolver) ByteSize(ctx context.Context) (BigInt, error) {
+	info, err := r.gitserverRepoInfo(ctx)
+	if err != nil {
+		return BigInt{}, err
+	}
+	return BigInt{Int: info.Size}, nil
+}
+
+func (r *repositoryMirrorInfoResolver) TooLarge(ctx context.Context) (bool, error) {
+	info, err := r.gitserverRepoInfo(ctx)
+	if err != nil {
+		return false, err
+	}
+	return info.TooLarge, nil
+}
+
 func (r *repositoryMirrorInfoResolver) UpdateSchedule(ctx context.Context) (*updateScheduleResolver, error) {
 	info, err := r.repoUpdateSchedulerInfo(ctx)
 	if err != nil {
@@ -137,6 +153,26 @@ func (r *updateScheduleResolver) Due() DateTime {
 	return DateTime{Time: r.schedule.Due}
 }
 
+func (r *updateScheduleResolver) LastUpdated() *DateTime {
+	return DateTimeOrNil(r.schedule.LastUpdated)
+}
+
+func (r *updateScheduleResolver) LastChanged() *DateTime {
+	return DateTimeOrNil(r.schedule.LastChanged)
+}
+
+func (r *updateScheduleResolver) LastError(ctx context.Context) (*string, error) {
+	// 🚨 SECURITY: The error might contain secret credentials of the remote URL, so
+	// only allow site admins to see it.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if r.schedule.LastError == "" {
+		return nil, nil
+	}
+	return &r.schedule.LastError, nil
+}
+
 func (r *updateScheduleResolver) Index() int32 {
 	return int32(r.schedule.Index)
 }
@@ -161,6 +161,11 @@ type Mutation {
     # - "user:all": Full control of all resources accessible to the user account.
     # - "site-admin:sudo": Ability to perform any action as any other user. (Only site admins may create tokens
     #   with this scope.)
+    # - "site-admin:scim": Ability to provision users and organizations with the SCIM API. (Only site admins may
+    #   create tokens with this scope.)
+    #
+    # All access tokens must have the "user:all" scope, except for those with only the "site-admin:scim" scope,
+    # which can only be used with the SCIM API.
     #
     # Only the user or site admins may perform this mutation.
     createAccessToken(user: ID!, scopes: [String!]!, note: String!): CreateAccessTokenResult!
@@ -174,6 +179,23 @@ type Mutation {
     #
     # Only site admins or the user who is associated with the external account may perform this mutation.
     deleteExternalAccount(externalAccount: ID!): EmptyResponse!
+    # Schedules the repository permissions of the user to be synced from the code hosts. The permissions of
+    # recently active users are synced in the background, so this should not normally be needed.
+    #
+    # Only site admins may perform this mutation.
+    scheduleUserPermissionsSync(user: ID!): EmptyResponse!
+    # Schedules the permissions of the users of the repository's code host to be synced from it. The
+    # permissions of recently updated repositories are synced in the background, so this should not normally
+    # be needed.
+    #
+    # Only site admins may perform this mutation.
+    scheduleRepositoryPermissionsSync(repository: ID!): EmptyResponse!
+    # Sets the users who can read the repository to exactly the given users, replacing those previously set.
+    # These permissions are only enforced on the repositories of Gitolite and other external services whose
+    # configuration has "authorization" set. An error is returned if any of the given users is not found.
+    #
+    # Only site admins may perform this mutation.
+    setRepositoryPermissionsForUsers(repository: ID!, users: [RepositoryPermissionsUserInput!]!): EmptyResponse!
     # Invite the user with the given username to join the organization. The invited user account must already
     # exist.
     #
@@ -262,6 +284,25 @@ type Mutation {
         # When the diff was created.
         date: String
     ): GitCommit
+    # Commits the changes of a codemod to each repository matched by the search query, which must contain a
+    # replace: field. Searching for the same query previews the changes. The commits are based on the searched
+    # revision and are stored in the ref "refs/codemod/" followed by the given branch name, which must not
+    # already exist. They are not stored in a branch ("refs/heads/") of Sourcegraph's mirror of the repository,
+    # because the branches of mirrors are replaced with those of the code host whenever the repository is
+    # updated.
+    #
+    # Fails without creating any commits if the search hits its result limit, since the codemod would only be
+    # applied to some of the matches.
+    #
+    # Only site admins may perform this mutation.
+    applyCodemod(
+        # The search query describing the codemod, for example: "fmt.Sprintf(:[x])" replace:"fmt.Sprint(:[x])".
+        query: String!
+        # The name of the branch to create in each repository, under "refs/codemod/".
+        branch: String!
+        # The commit message. Defaults to a message containing the query.
+        message: String
+    ): [CodemodCommitResult!]!
     # Logs a user event.
     logUserEvent(event: UserEvent!, userCookieID: String!): EmptyResponse
     # Sends a test notification for the saved search. Be careful: this will send a notifcation (email and other
@@ -363,6 +404,26 @@ input UpdateExternalServiceInput {
     config: String
 }
 
+# A user to set repository permissions for, matched by exactly one of the fields.
+input RepositoryPermissionsUserInput {
+    # The username of the user.
+    username: String
+    # A verified email address of the user.
+    email: String
+    # An external account of the user.
+    externalAccount: ExternalAccountInput
+}
+
+# An external account of a user on an external service.
+input ExternalAccountInput {
+    # The type of the external service (e.g., "gitlab").
+    serviceType: String!
+    # The ID of the external service (e.g., "https://gitlab.example.com/").
+    serviceID: String!
+    # The ID of the account on the external service.
+    accountID: String!
+}
+
 # A selection within a file.
 input DiscussionThreadTargetRepoSelectionInput {
     # The line that the selection started on (zero-based, inclusive).
@@ -977,7 +1038,8 @@ type SearchFilter {
     count: Int!
     # Whether the results returned are incomplete.
     limitHit: Boolean!
-    # The kind of filter. Should be "file" or "repo".
+    # The kind of filter. Should be "file", "repo", "lang", "symbol", "case", or, for commit and diff search
+    # results, "author", "committer" or "date".
     kind: String!
 }
 
@@ -1077,6 +1139,18 @@ type CodemodResult implements GenericSearchResultInterface {
     matches: [SearchResultMatch!]!
 }
 
+# The result of applying a codemod to a repository.
+type CodemodCommitResult {
+    # The repository the codemod was applied to.
+    repository: Repository!
+    # The commit containing the changes of the codemod, or null if it could not be created.
+    commit: GitCommit
+    # The number of files changed by the codemod.
+    fileCount: Int!
+    # The error encountered while applying the codemod to the repository, if any.
+    error: String
+}
+
 # A search result that is a diff between two diffable Git objects.
 type DiffSearchResult {
     # The diff that matched the search query.
@@ -1198,6 +1272,19 @@ type Repository implements Node & GenericSearchResultInterface {
     #
     # The date when this repository's metadata was last updated on Sourcegraph.
     updatedAt: DateTime
+    # The last time the permissions of the users of this repository's code host on it were synced in the
+    # background, or null if they never were.
+    #
+    # Only site admins can access this field.
+    permissionsLastSyncedAt: DateTime
+    # The users who were explicitly granted read access to this repository with the
+    # setRepositoryPermissionsForUsers mutation.
+    #
+    # Only site admins can access this field.
+    explicitlyPermittedUsers(
+        # Returns the first n users from the list.
+        first: Int
+    ): UserConnection!
     # Returns information about the given commit in the repository, or null if no commit exists with the given rev.
     commit(
         # The Git revision specifier (revspec) for the commit.
@@ -1321,6 +1408,13 @@ type MirrorRepositoryInfo {
     cloned: Boolean!
     # When the repository was last successfully updated from the remote source repository..
     updatedAt: DateTime
+    # The size of the repository on disk in bytes, as of when it was last cloned or updated.
+    # For a repository which is too large (see tooLarge), this is the size it had when it was found to be
+    # too large.
+    byteSize: BigInt!
+    # Whether the repository is not cloned because it is larger than the gitMaxRepoSizeMB site
+    # configuration option allows.
+    tooLarge: Boolean!
     # The state of this repository in the update schedule.
     updateSchedule: UpdateSchedule
     # The state of this repository in the update queue.
@@ -1333,6 +1427,13 @@ type UpdateSchedule {
     intervalSeconds: Int!
     # The next time that the repo will be inserted into the update queue.
     due: DateTime!
+    # The last time that an update of the repo finished, if it has been updated since it was
+    # added to the update scheduler.
+    lastUpdated: DateTime
+    # The last time that the repo changed, as observed by the last update.
+    lastChanged: DateTime
+    # The error of the last update, if it failed. Only site admins may view this field.
+    lastError: String
     # The index of the repo in the schedule.
     index: Int!
     # The total number of repos in the schedule.
@@ -2102,6 +2203,15 @@ type LineMatch {
     offsetAndLengths: [[Int!]!]!
     # Whether or not the limit was hit.
     limitHit: Boolean!
+    # For a match which may span several lines (such as a match of a regexp containing \n), the line
+    # number on which the match ends. The preview then contains every line of the match, and
+    # offsetAndLengths contains a single tuple whose length is measured across those lines. Null for
+    # matches which are split into one line match per line.
+    endLineNumber: Int
+    # For a match which may span several lines, the offset in characters (not bytes) in the line
+    # endLineNumber at which the match ends. Null for matches which are split into one line match per
+    # line.
+    endOffset: Int
 }
 
 # A hunk.
@@ -2166,6 +2276,11 @@ type User implements Node & SettingsSubject & Namespace {
     createdAt: DateTime!
     # The date when the user account was last updated on Sourcegraph.
     updatedAt: DateTime
+    # The last time the user's repository permissions were synced from the code hosts in the background, or
+    # null if they never were.
+    #
+    # Only the user and site admins can access this field.
+    permissionsLastSyncedAt: DateTime
     # Whether the user is a site admin.
     #
     # Only the user and site admins can access this field.
@@ -3729,4 +3844,8 @@ type StatusMessage {
 # JavaScript Date using Date.parse. To produce this value from a JavaScript Date instance, use
 # Date#toISOString.
 scalar DateTime
+
+# A signed 64-bit integer, represented as a string because it may not fit in a JavaScript number
+# (which only represents integers up to 2^53 exactly).
+scalar BigInt
 `
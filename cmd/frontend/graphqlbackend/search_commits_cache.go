@@ -0,0 +1,105 @@
+package graphqlbackend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/rcache"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// maxCommitSearchCacheEntrySize is the maximum size in bytes of the cached
+// results of a commit or diff search in a single repository. Larger results
+// are not cached.
+const maxCommitSearchCacheEntrySize = 1 << 20 // 1MB
+
+// commitSearchCache caches the results of commit and diff searches in a
+// single repository. The results are keyed by the commits the searched
+// revisions resolve to, so they only expire to bound the staleness of the
+// refs the commits are decorated with.
+var commitSearchCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, b []byte)
+} = rcache.NewWithTTL("commit_search", 24*3600) // 1 day
+
+// rawLogDiffSearchCached is like git.RawLogDiffSearch, but uses cached
+// results if the same search was already run on the commits the revisions
+// of repoRevs resolve to. Only complete results are cached.
+func rawLogDiffSearchCached(ctx context.Context, repoRevs *search.RepositoryRevisions, opt git.RawLogDiffSearchOptions) (results []*git.LogCommitSearchResult, complete bool, err error) {
+	key := commitSearchCacheKey(ctx, repoRevs, opt)
+	if key != "" {
+		if b, ok := commitSearchCache.Get(key); ok {
+			err := json.Unmarshal(b, &results)
+			if err == nil {
+				return results, true, nil
+			}
+			log15.Warn("Ignoring invalid cached commit search results.", "repo", repoRevs.Repo.Name, "error", err)
+		}
+	}
+
+	results, complete, err = git.RawLogDiffSearch(ctx, repoRevs.GitserverRepo(), opt)
+	if err != nil || !complete || key == "" {
+		return results, complete, err
+	}
+	if b, err := json.Marshal(results); err == nil && len(b) <= maxCommitSearchCacheEntrySize {
+		commitSearchCache.Set(key, b)
+	}
+	return results, complete, nil
+}
+
+// commitSearchCacheKey returns the cache key of the results of a commit or
+// diff search. It is empty if the results depend on more than the commits
+// the revisions resolve to: if the revisions include ref globs (which can
+// match new refs) or can't be resolved to a single commit (e.g. ranges), or
+// if the search has --since or --until arguments (which may be relative to
+// the current time, e.g. "1 week ago").
+func commitSearchCacheKey(ctx context.Context, repoRevs *search.RepositoryRevisions, opt git.RawLogDiffSearchOptions) string {
+	for _, arg := range opt.Args {
+		if strings.HasPrefix(arg, "--since=") || strings.HasPrefix(arg, "--until=") {
+			return ""
+		}
+	}
+
+	var specs []string
+	for _, rev := range repoRevs.Revs {
+		if rev.RefGlob != "" || rev.ExcludeRefGlob != "" {
+			return ""
+		}
+		if rev.RevSpec != "" {
+			specs = append(specs, rev.RevSpec)
+		}
+	}
+	if len(specs) == 0 {
+		// git log searches HEAD if no revisions are given.
+		specs = []string{"HEAD"}
+	}
+
+	commits := make([]api.CommitID, len(specs))
+	for i, spec := range specs {
+		commit, err := git.ResolveRevision(ctx, repoRevs.GitserverRepo(), nil, spec, &git.ResolveRevisionOptions{NoEnsureRevision: true})
+		if err != nil {
+			// The search itself reports the error if it is not a valid
+			// revision.
+			return ""
+		}
+		commits[i] = commit
+	}
+
+	b, err := json.Marshal(struct {
+		Repo    api.RepoName
+		Specs   []string
+		Commits []api.CommitID
+		Options git.RawLogDiffSearchOptions
+	}{repoRevs.Repo.Name, specs, commits, opt})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -17,6 +17,8 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 )
 
@@ -46,7 +48,11 @@ func TestSearchCommitsInRepo(t *testing.T) {
 			},
 		}, true, nil
 	}
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		return "c1", nil
+	}
 	defer git.ResetMocks()
+	defer mockCommitSearchCache()()
 
 	query, err := query.ParseAndCheck("p")
 	if err != nil {
@@ -101,6 +107,88 @@ func (r *commitSearchResultResolver) String() string {
 	return fmt.Sprintf("{commit: %+v diffPreview: %+v messagePreview: %+v}", r.commit, r.diffPreview, r.messagePreview)
 }
 
+func TestRawLogDiffSearchCached(t *testing.T) {
+	ctx := context.Background()
+	defer mockCommitSearchCache()()
+
+	heads := map[string]api.CommitID{"rev": "c1"}
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		if commit, ok := heads[spec]; ok {
+			return commit, nil
+		}
+		return "", &gitserver.RevisionNotFoundError{Repo: "repo", Spec: spec}
+	}
+	var searches int
+	complete := true
+	git.Mocks.RawLogDiffSearch = func(opt git.RawLogDiffSearchOptions) ([]*git.LogCommitSearchResult, bool, error) {
+		searches++
+		return []*git.LogCommitSearchResult{{Commit: git.Commit{ID: heads["rev"]}, Diff: &git.Diff{Raw: "x"}}}, complete, nil
+	}
+	defer git.ResetMocks()
+
+	repoRevs := func(revs ...search.RevisionSpecifier) *search.RepositoryRevisions {
+		return &search.RepositoryRevisions{Repo: &types.Repo{ID: 1, Name: "repo"}, Revs: revs}
+	}
+	opt := git.RawLogDiffSearchOptions{Query: git.TextSearchOptions{Pattern: "p"}, Diff: true, Args: []string{"--no-prefix"}}
+	check := func(name string, repoRevs *search.RepositoryRevisions, opt git.RawLogDiffSearchOptions, wantSearches int) {
+		t.Helper()
+		results, _, err := rawLogDiffSearchCached(ctx, repoRevs, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Commit.ID != heads["rev"] {
+			t.Errorf("%s: got results %+v", name, results)
+		}
+		if searches != wantSearches {
+			t.Errorf("%s: got %d searches, want %d", name, searches, wantSearches)
+		}
+	}
+
+	check("first search", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), opt, 1)
+	check("same search", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), opt, 1)
+
+	otherOpt := opt
+	otherOpt.Query.Pattern = "q"
+	check("other query", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), otherOpt, 2)
+
+	heads["rev"] = "c2"
+	check("new commit", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), opt, 3)
+	check("new commit again", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), opt, 3)
+
+	// Searches which can't be cached.
+	check("ref glob", repoRevs(search.RevisionSpecifier{RefGlob: "refs/heads/*"}), opt, 4)
+	check("ref glob again", repoRevs(search.RevisionSpecifier{RefGlob: "refs/heads/*"}), opt, 5)
+	check("range", repoRevs(search.RevisionSpecifier{RevSpec: "a..rev"}), opt, 6)
+	check("range again", repoRevs(search.RevisionSpecifier{RevSpec: "a..rev"}), opt, 7)
+	sinceOpt := opt
+	sinceOpt.Args = append([]string{"--since=1 week ago"}, opt.Args...)
+	check("relative date", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), sinceOpt, 8)
+	check("relative date again", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), sinceOpt, 9)
+
+	// Incomplete results are not cached.
+	complete = false
+	heads["rev"] = "c3"
+	check("incomplete", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), opt, 10)
+	check("incomplete again", repoRevs(search.RevisionSpecifier{RevSpec: "rev"}), opt, 11)
+}
+
+// mockCommitSearchCache replaces commitSearchCache with an in-memory cache
+// and returns a func which restores it.
+func mockCommitSearchCache() func() {
+	orig := commitSearchCache
+	commitSearchCache = mapCache{}
+	return func() { commitSearchCache = orig }
+}
+
+type mapCache map[string][]byte
+
+func (c mapCache) Get(key string) ([]byte, bool) {
+	b, ok := c[key]
+	return b, ok
+}
+
+func (c mapCache) Set(key string, b []byte) { c[key] = b }
+
 func TestExpandUsernamesToEmails(t *testing.T) {
 	resetMocks()
 	db.Mocks.Users.GetByUsername = func(ctx context.Context, username string) (*types.User, error) {
@@ -7,6 +7,7 @@ import (
 	"math"
 	"path"
 	"regexp"
+	"regexp/syntax"
 	"sort"
 	"strconv"
 	"strings"
@@ -197,9 +198,48 @@ var commonFileFilters = []struct {
 	},
 }
 
+// commitDateFilters are the time buckets proposed by DynamicFilters for commit
+// and diff search results, from newest to oldest. Each bucket contains the
+// commits older than the previous bucket and at most Since old, so that the
+// buckets don't overlap and each commit is counted once. Commits older than
+// the last bucket are in a final "over 1 year ago" bucket.
+var commitDateFilters = []struct {
+	Since time.Duration
+	Ago   string // Since in the syntax of the after: and before: fields
+	Label string
+}{
+	{Since: 24 * time.Hour, Ago: "1 day ago", Label: "Last day"},
+	{Since: 7 * 24 * time.Hour, Ago: "1 week ago", Label: "1 day to 1 week ago"},
+	{Since: 30 * 24 * time.Hour, Ago: "1 month ago", Label: "1 week to 1 month ago"},
+	{Since: 365 * 24 * time.Hour, Ago: "1 year ago", Label: "1 month to 1 year ago"},
+}
+
+// commitDateFilter returns the value and label of the filter of the
+// commitDateFilters bucket of a commit made at date. It returns an empty
+// value if date is unknown.
+func commitDateFilter(now, date time.Time) (value, label string) {
+	if date.IsZero() {
+		return "", ""
+	}
+	var newer string
+	for _, df := range commitDateFilters {
+		if now.Sub(date) <= df.Since {
+			value = fmt.Sprintf(`after:"%s"`, df.Ago)
+			if newer != "" {
+				value += fmt.Sprintf(` before:"%s"`, newer)
+			}
+			return value, df.Label
+		}
+		newer = df.Ago
+	}
+	return fmt.Sprintf(`before:"%s"`, newer), "Over " + newer
+}
+
 func (sr *searchResultsResolver) DynamicFilters() []*searchFilterResolver {
 	filters := map[string]*searchFilterResolver{}
 	repoToMatchCount := make(map[string]int)
+	commitFilterCount := make(map[string]int)
+	now := time.Now()
 	add := func(value string, label string, count int, limitHit bool, kind string) {
 		sf, ok := filters[value]
 		if !ok {
@@ -257,6 +297,37 @@ func (sr *searchResultsResolver) DynamicFilters() []*searchFilterResolver {
 		}
 	}
 
+	// addPersonFilter adds an author: or committer: filter (depending on
+	// field) matching the email of person, labeled with their name.
+	addPersonFilter := func(field string, person *personResolver) {
+		if person == nil || person.email == "" {
+			return
+		}
+		value := fmt.Sprintf(`%s:%s`, field, regexp.QuoteMeta(person.email))
+		label := person.name
+		if label == "" {
+			label = person.email
+		}
+		commitFilterCount[value]++
+		add(value, label, commitFilterCount[value], false, field)
+	}
+
+	addCommitFilters := func(commit *GitCommitResolver) {
+		addPersonFilter("author", commit.author.person)
+
+		// git log --since compares against the committer date, so use it for
+		// the date filters if it is known.
+		date := commit.author.date
+		if commit.committer != nil {
+			addPersonFilter("committer", commit.committer.person)
+			date = commit.committer.date
+		}
+		if value, label := commitDateFilter(now, date); value != "" {
+			commitFilterCount[value]++
+			add(value, label, commitFilterCount[value], false, "date")
+		}
+	}
+
 	for _, result := range sr.results {
 		if fm, ok := result.ToFileMatch(); ok {
 			rev := ""
@@ -275,6 +346,9 @@ func (sr *searchResultsResolver) DynamicFilters() []*searchFilterResolver {
 			// can only be used with the 'repo:' scope. In that case,
 			// we shouldn't be getting any repositoy name matches back.
 			addRepoFilter(r.Name(), "", 1)
+		} else if c, ok := result.ToCommitSearchResult(); ok && c.commit != nil {
+			addRepoFilter(c.commit.repo.Name(), "", 1)
+			addCommitFilters(c.commit)
 		}
 		// Add `case:yes` filter to offer easier access to search results matching with case sensitive set to yes
 		// We use count == 0 and limitHit == false since we can't determine that information without
@@ -313,13 +387,15 @@ type searchFilterResolver struct {
 	// the string to be displayed in the UI
 	label string
 
-	// the number of matches in a particular repository. Only used for `repo:` filters.
+	// the number of matches in a particular repository for `repo:` filters, or
+	// the number of matching commits for commit filters.
 	count int32
 
 	// whether the results returned for a repository are incomplete
 	limitHit bool
 
-	// the kind of filter. Should be "repo", "file", or "lang".
+	// the kind of filter. Should be "repo", "file", "lang", "symbol", "case",
+	// "author", "committer", or "date".
 	kind string
 
 	// score is used to select potential filters
@@ -647,6 +723,14 @@ type getPatternInfoOptions struct {
 
 // getPatternInfo gets the search pattern info for the query in the resolver.
 func (r *searchResolver) getPatternInfo(opts *getPatternInfoOptions) (*search.PatternInfo, error) {
+	structural, err := r.isStructuralSearch()
+	if err != nil {
+		return nil, err
+	}
+	if structural && (opts == nil || !opts.forceFileSearch) {
+		return r.getStructuralPatternInfo()
+	}
+
 	var patternsToCombine []string
 	if opts == nil || !opts.forceFileSearch {
 		for _, v := range r.query.Values(query.FieldDefault) {
@@ -683,18 +767,65 @@ func (r *searchResolver) getPatternInfo(opts *getPatternInfoOptions) (*search.Pa
 	}
 
 	// Handle lang: and -lang: filters.
-	langIncludePatterns, langExcludePatterns, err := langIncludeExcludePatterns(r.query.StringValues(query.FieldLang))
+	includePatterns, excludePatterns, err = r.addLangPatterns(includePatterns, excludePatterns)
 	if err != nil {
 		return nil, err
 	}
-	includePatterns = append(includePatterns, langIncludePatterns...)
-	excludePatterns = append(excludePatterns, langExcludePatterns...)
 
+	pattern := regexpPatternMatchingExprsInOrder(patternsToCombine)
 	patternInfo := &search.PatternInfo{
 		IsRegExp:                     true,
 		IsCaseSensitive:              r.query.IsCaseSensitive(),
+		IsMultiline:                  regexpMatchesNewline(pattern),
+		FileMatchLimit:               r.maxResults(),
+		Pattern:                      pattern,
+		IncludePatterns:              includePatterns,
+		FilePatternsReposMustInclude: filePatternsReposMustInclude,
+		FilePatternsReposMustExclude: filePatternsReposMustExclude,
+		PathPatternsAreRegExps:       true,
+		PathPatternsAreCaseSensitive: r.query.IsCaseSensitive(),
+	}
+	if len(excludePatterns) > 0 {
+		patternInfo.ExcludePattern = unionRegExps(excludePatterns)
+	}
+	return patternInfo, nil
+}
+
+// isStructuralSearch reports whether the query is a structural search
+// (patterntype:structural) rather than a regexp search.
+func (r *searchResolver) isStructuralSearch() (bool, error) {
+	patternType, _ := r.query.StringValue(query.FieldPatternType)
+	switch patternType {
+	case "", "regexp":
+		return false, nil
+	case "structural":
+		return true, nil
+	default:
+		return false, &badRequestError{fmt.Errorf("invalid patterntype:%q (valid values are: regexp, structural)", patternType)}
+	}
+}
+
+// getStructuralPatternInfo returns the search.PatternInfo for a structural
+// search. The terms of the query, which the query parser takes literally for
+// structural searches, are joined with spaces to form the structural pattern.
+func (r *searchResolver) getStructuralPatternInfo() (*search.PatternInfo, error) {
+	var terms []string
+	for _, v := range r.query.Values(query.FieldDefault) {
+		terms = append(terms, asString(v))
+	}
+
+	includePatterns, excludePatterns := r.query.RegexpPatterns(query.FieldFile)
+	filePatternsReposMustInclude, filePatternsReposMustExclude := r.query.RegexpPatterns(query.FieldRepoHasFile)
+	includePatterns, excludePatterns, err := r.addLangPatterns(includePatterns, excludePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	patternInfo := &search.PatternInfo{
+		IsStructuralPat:              true,
+		IsCaseSensitive:              true,
 		FileMatchLimit:               r.maxResults(),
-		Pattern:                      regexpPatternMatchingExprsInOrder(patternsToCombine),
+		Pattern:                      strings.Join(terms, " "),
 		IncludePatterns:              includePatterns,
 		FilePatternsReposMustInclude: filePatternsReposMustInclude,
 		FilePatternsReposMustExclude: filePatternsReposMustExclude,
@@ -707,6 +838,16 @@ func (r *searchResolver) getPatternInfo(opts *getPatternInfoOptions) (*search.Pa
 	return patternInfo, nil
 }
 
+// addLangPatterns appends the file path patterns for the query's lang: and
+// -lang: filters to includePatterns and excludePatterns.
+func (r *searchResolver) addLangPatterns(includePatterns, excludePatterns []string) ([]string, []string, error) {
+	langIncludePatterns, langExcludePatterns, err := langIncludeExcludePatterns(r.query.StringValues(query.FieldLang))
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(includePatterns, langIncludePatterns...), append(excludePatterns, langExcludePatterns...), nil
+}
+
 var (
 	// The default timeout to use for queries.
 	defaultTimeout = 10 * time.Second
@@ -801,6 +942,9 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		resultTypes = []string{forceOnlyResultType}
 	} else if len(r.query.Values(query.FieldReplace)) > 0 {
 		resultTypes = []string{"codemod"}
+	} else if p.IsStructuralPat {
+		// Structural patterns only match file contents.
+		resultTypes = []string{"file"}
 	} else {
 		resultTypes, _ = r.query.StringValues(query.FieldType)
 		if len(resultTypes) == 0 {
@@ -1108,6 +1252,33 @@ func sortResults(r []searchResultResolver) {
 	sort.Slice(r, func(i, j int) bool { return compareSearchResults(r[i], r[j]) })
 }
 
+// regexpMatchesNewline reports whether pattern explicitly matches a newline
+// (e.g. with \n), in which case its matches may span several lines. Character
+// classes like \s which happen to contain a newline are not considered.
+func regexpMatchesNewline(pattern string) bool {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	var walk func(re *syntax.Regexp) bool
+	walk = func(re *syntax.Regexp) bool {
+		if re.Op == syntax.OpLiteral {
+			for _, r := range re.Rune {
+				if r == '\n' {
+					return true
+				}
+			}
+		}
+		for _, sub := range re.Sub {
+			if walk(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(re)
+}
+
 // regexpPatternMatchingExprsInOrder returns a regexp that matches lines that contain
 // non-overlapping matches for each pattern in order.
 func regexpPatternMatchingExprsInOrder(patterns []string) string {
@@ -437,6 +437,32 @@ func TestSearchResolver_getPatternInfo(t *testing.T) {
 			PathPatternsAreRegExps: true,
 			ExcludePattern:         `f|(\.graphql$|\.gql$)`,
 		},
+		`func\s+\w+\(\)\s*\{\n\s*return`: {
+			Pattern:                `func\s+\w+\(\)\s*\{\n\s*return`,
+			IsRegExp:               true,
+			IsMultiline:            true,
+			PathPatternsAreRegExps: true,
+		},
+		`patterntype:structural "foo(:[args], nil)" file:f`: {
+			Pattern:                "foo(:[args], nil)",
+			IsStructuralPat:        true,
+			IsCaseSensitive:        true,
+			PathPatternsAreRegExps: true,
+			IncludePatterns:        []string{"f"},
+		},
+		"patterntype:structural foo(:[args], nil) file:f": {
+			Pattern:                "foo(:[args], nil)",
+			IsStructuralPat:        true,
+			IsCaseSensitive:        true,
+			PathPatternsAreRegExps: true,
+			IncludePatterns:        []string{"f"},
+		},
+		"patterntype:structural foo bar": {
+			Pattern:                "foo bar",
+			IsStructuralPat:        true,
+			IsCaseSensitive:        true,
+			PathPatternsAreRegExps: true,
+		},
 	}
 	for queryStr, want := range tests {
 		t.Run(queryStr, func(t *testing.T) {
@@ -458,6 +484,35 @@ func TestSearchResolver_getPatternInfo(t *testing.T) {
 	}
 }
 
+func TestRegexpMatchesNewline(t *testing.T) {
+	tests := map[string]bool{
+		`foo`:        false,
+		`foo\s+bar`:  false,
+		`foo\nbar`:   true,
+		`(a|b\n)c`:   true,
+		`[\n]`:       true,
+		`[^a]`:       false,
+		`foo\\nbar`:  false,
+		`invalid(\n`: false,
+	}
+	for pattern, want := range tests {
+		if got := regexpMatchesNewline(pattern); got != want {
+			t.Errorf("regexpMatchesNewline(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestSearchResolver_getPatternInfo_invalidPatternType(t *testing.T) {
+	q, err := query.ParseAndCheck("patterntype:foo p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := searchResolver{query: q}
+	if _, err := sr.getPatternInfo(nil); err == nil {
+		t.Fatal("expected error for invalid patterntype")
+	}
+}
+
 func TestSearchResolver_DynamicFilters(t *testing.T) {
 	repo := &types.Repo{Name: "testRepo"}
 
@@ -487,6 +542,20 @@ func TestSearchResolver_DynamicFilters(t *testing.T) {
 		inputRev: &rev,
 	}
 
+	commitMatch := &commitSearchResultResolver{
+		commit: &GitCommitResolver{
+			repo: repoMatch,
+			author: signatureResolver{
+				person: &personResolver{name: "Alice", email: "alice@example.com"},
+				date:   time.Now().Add(-30 * 24 * time.Hour),
+			},
+			committer: &signatureResolver{
+				person: &personResolver{name: "Bob", email: "bob@example.com"},
+				date:   time.Now().Add(-2 * 24 * time.Hour),
+			},
+		},
+	}
+
 	type testCase struct {
 		descr                     string
 		searchResults             []searchResultResolver
@@ -542,6 +611,18 @@ func TestSearchResolver_DynamicFilters(t *testing.T) {
 			},
 		},
 
+		{
+			descr:         "commit match, using the committer date",
+			searchResults: []searchResultResolver{commitMatch},
+			expectedDynamicFilterStrs: map[string]struct{}{
+				`repo:^testRepo$`:                       {},
+				`author:alice@example\.com`:             {},
+				`committer:bob@example\.com`:            {},
+				`after:"1 week ago" before:"1 day ago"`: {},
+				`case:yes`:                              {},
+			},
+		},
+
 		// If there are no search results, no filters should be displayed.
 		{
 			descr:                     "no results",
@@ -1021,3 +1102,25 @@ func Test_searchResultsResolver_ApproximateResultCount(t *testing.T) {
 		})
 	}
 }
+
+func TestCommitDateFilter(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		age       time.Duration
+		wantValue string
+	}{
+		{age: time.Hour, wantValue: `after:"1 day ago"`},
+		{age: 2 * 24 * time.Hour, wantValue: `after:"1 week ago" before:"1 day ago"`},
+		{age: 10 * 24 * time.Hour, wantValue: `after:"1 month ago" before:"1 week ago"`},
+		{age: 100 * 24 * time.Hour, wantValue: `after:"1 year ago" before:"1 month ago"`},
+		{age: 400 * 24 * time.Hour, wantValue: `before:"1 year ago"`},
+	}
+	for _, test := range tests {
+		if value, _ := commitDateFilter(now, now.Add(-test.age)); value != test.wantValue {
+			t.Errorf("age %s: got %q, want %q", test.age, value, test.wantValue)
+		}
+	}
+	if value, _ := commitDateFilter(now, time.Time{}); value != "" {
+		t.Errorf("unknown date: got %q, want no filter", value)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"net/http"
@@ -27,6 +28,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	searcherprotocol "github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/errcode"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
@@ -143,6 +145,12 @@ type lineMatch struct {
 	JOffsetAndLengths [][2]int32 `json:"OffsetAndLengths"`
 	JLineNumber       int32      `json:"LineNumber"`
 	JLimitHit         bool       `json:"LimitHit"`
+	JEndLineNumber    int32      `json:"EndLineNumber"`
+	JEndOffset        int32      `json:"EndOffset"`
+
+	// multiline is true if this is a single match which may span several
+	// lines, in which case JEndLineNumber and JEndOffset are set.
+	multiline bool
 }
 
 func (lm *lineMatch) Preview() string {
@@ -165,13 +173,44 @@ func (lm *lineMatch) LimitHit() bool {
 	return lm.JLimitHit
 }
 
+func (lm *lineMatch) EndLineNumber() *int32 {
+	if !lm.multiline {
+		return nil
+	}
+	return &lm.JEndLineNumber
+}
+
+func (lm *lineMatch) EndOffset() *int32 {
+	if !lm.multiline {
+		return nil
+	}
+	return &lm.JEndOffset
+}
+
 var mockTextSearch func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error)
 
 // textSearch searches repo@commit with p.
 // Note: the returned matches do not set fileMatch.uri
 func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+	limitHit, err = textSearchStream(ctx, repo, commit, p, fetchTimeout, func(fm *fileMatchResolver) {
+		matches = append(matches, fm)
+	})
+	if err != nil && !errcode.IsTimeout(err) {
+		return nil, false, err
+	}
+	return matches, limitHit, err
+}
+
+// textSearchStream searches repo@commit with p, passing each file match to
+// send as soon as searcher finds it.
+// Note: the matches do not set fileMatch.uri
+func textSearchStream(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration, send func(*fileMatchResolver)) (limitHit bool, err error) {
 	if mockTextSearch != nil {
-		return mockTextSearch(ctx, repo, commit, p, fetchTimeout)
+		matches, limitHit, err := mockTextSearch(ctx, repo, commit, p, fetchTimeout)
+		for _, fm := range matches {
+			send(fm)
+		}
+		return limitHit, err
 	}
 
 	tr, ctx := trace.New(ctx, "searcher.client", fmt.Sprintf("%s@%s", repo.Name, commit))
@@ -205,7 +244,7 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	if deadline, ok := ctx.Deadline(); ok {
 		t, err := deadline.MarshalText()
 		if err != nil {
-			return nil, false, err
+			return false, err
 		}
 		q.Set("Deadline", string(t))
 	}
@@ -219,6 +258,12 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	if p.IsCaseSensitive {
 		q.Set("IsCaseSensitive", "true")
 	}
+	if p.IsStructuralPat {
+		q.Set("IsStructuralPat", "true")
+	}
+	if p.IsMultiline {
+		q.Set("IsMultiline", "true")
+	}
 	if p.PathPatternsAreRegExps {
 		q.Set("PathPatternsAreRegExps", "true")
 	}
@@ -229,6 +274,10 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	// these fields from old frontends that do not (and provide a default in the latter case).
 	q.Set("PatternMatchesContent", strconv.FormatBool(p.PatternMatchesContent))
 	q.Set("PatternMatchesPath", strconv.FormatBool(p.PatternMatchesPath))
+	// Ask searcher to stream file matches back as they are found. Searchers
+	// which do not support streaming ignore this and respond with a single
+	// JSON object, which textSearchURL also understands.
+	q.Set("Stream", "true")
 	rawQuery := q.Encode()
 
 	// Searcher caches the file contents for repo@commit since it is
@@ -242,13 +291,16 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 		excludedSearchURLs = map[string]bool{}
 		attempt            = 0
 		maxAttempts        = 2
+
+		// sent is the number of matches passed to send so far.
+		sent = 0
 	)
 	for {
 		attempt++
 
 		searcherURL, err := SearcherURLs().Get(consistentHashKey, excludedSearchURLs)
 		if err != nil {
-			return nil, false, err
+			return false, err
 		}
 
 		// Fallback to a bad host if nothing is left
@@ -256,28 +308,32 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 			tr.LazyPrintf("failed to find endpoint, trying again without excludes")
 			searcherURL, err = SearcherURLs().Get(consistentHashKey, nil)
 			if err != nil {
-				return nil, false, err
+				return false, err
 			}
 		}
 
 		url := searcherURL + "?" + rawQuery
 		tr.LazyPrintf("attempt %d: %s", attempt, url)
-		matches, limitHit, err = textSearchURL(ctx, url)
+		limitHit, err = textSearchURL(ctx, url, func(fm *fileMatchResolver) {
+			sent++
+			send(fm)
+		})
 		// Useful trace for debugging:
 		//
-		// tr.LazyPrintf("%d matches, limitHit=%v, err=%v, ctx.Err()=%v", len(matches), limitHit, err, ctx.Err())
+		// tr.LazyPrintf("%d matches, limitHit=%v, err=%v, ctx.Err()=%v", sent, limitHit, err, ctx.Err())
 		if err == nil || errcode.IsTimeout(err) {
-			return matches, limitHit, err
+			return limitHit, err
 		}
 
 		// If we are canceled, return that error.
 		if err := ctx.Err(); err != nil {
-			return nil, false, err
+			return false, err
 		}
 
-		// If not temporary or our last attempt then don't try again.
-		if !errcode.IsTemporary(err) || attempt == maxAttempts {
-			return nil, false, err
+		// If not temporary or our last attempt then don't try again. Retrying
+		// after some matches were sent would send them twice.
+		if !errcode.IsTemporary(err) || attempt == maxAttempts || sent > 0 {
+			return false, err
 		}
 
 		tr.LazyPrintf("transient error %s", err.Error())
@@ -286,10 +342,12 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	}
 }
 
-func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool, error) {
+// textSearchURL sends the search request at url to searcher and passes each
+// file match in the response to send.
+func textSearchURL(ctx context.Context, url string, send func(*fileMatchResolver)) (limitHit bool, err error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, false, err
+		return false, err
 	}
 	req = req.WithContext(ctx)
 
@@ -308,15 +366,19 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 		if ctx.Err() != nil {
 			err = ctx.Err()
 		}
-		return nil, false, errors.Wrap(err, "searcher request failed")
+		return false, errors.Wrap(err, "searcher request failed")
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return nil, false, err
+			return false, err
 		}
-		return nil, false, errors.WithStack(&searcherError{StatusCode: resp.StatusCode, Message: string(body)})
+		return false, errors.WithStack(&searcherError{StatusCode: resp.StatusCode, Message: string(body)})
+	}
+
+	if resp.Header.Get("Content-Type") == searcherprotocol.StreamContentType {
+		return decodeSearcherStream(ctx, resp.Body, send)
 	}
 
 	r := struct {
@@ -326,12 +388,54 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 	}{}
 	err = json.NewDecoder(resp.Body).Decode(&r)
 	if err != nil {
-		return nil, false, errors.Wrap(err, "searcher response invalid")
+		return false, errors.Wrap(err, "searcher response invalid")
+	}
+	for _, fm := range r.Matches {
+		send(fm)
 	}
 	if r.DeadlineHit {
 		err = context.DeadlineExceeded
 	}
-	return r.Matches, r.LimitHit, err
+	return r.LimitHit, err
+}
+
+// searcherStreamEvent is a searcherprotocol.StreamEvent whose file match is
+// decoded directly into a resolver.
+type searcherStreamEvent struct {
+	searcherprotocol.StreamEvent
+	FileMatch *fileMatchResolver
+}
+
+// decodeSearcherStream reads file matches from a streaming searcher response
+// and passes each to send as soon as it arrives, without buffering the
+// response. If the stream is cut short because ctx is done, ctx.Err() is
+// returned; the matches received until then have already been sent, so that
+// callers can still show partial results.
+func decodeSearcherStream(ctx context.Context, body io.Reader, send func(*fileMatchResolver)) (limitHit bool, err error) {
+	dec := json.NewDecoder(body)
+	for {
+		var ev searcherStreamEvent
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			return false, errors.Wrap(err, "searcher response invalid")
+		}
+		if !ev.Done {
+			if ev.FileMatch != nil {
+				send(ev.FileMatch)
+			}
+			continue
+		}
+
+		if ev.Error != "" {
+			return false, errors.Errorf("searcher: %s", ev.Error)
+		}
+		if ev.DeadlineHit {
+			err = context.DeadlineExceeded
+		}
+		return ev.LimitHit, err
+	}
 }
 
 type searcherError struct {
@@ -353,9 +457,15 @@ func (e *searcherError) Error() string {
 
 var mockSearchFilesInRepo func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error)
 
-func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+// searchFilesInRepo searches a single repository, passing each file match to
+// send as soon as searcher finds it.
+func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration, send func(*fileMatchResolver)) (limitHit bool, err error) {
 	if mockSearchFilesInRepo != nil {
-		return mockSearchFilesInRepo(ctx, repo, gitserverRepo, rev, info, fetchTimeout)
+		matches, limitHit, err := mockSearchFilesInRepo(ctx, repo, gitserverRepo, rev, info, fetchTimeout)
+		for _, fm := range matches {
+			send(fm)
+		}
+		return limitHit, err
 	}
 
 	// Do not trigger a repo-updater lookup (e.g.,
@@ -364,28 +474,30 @@ func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gits
 	// repo is not on gitserver.
 	commit, err := git.ResolveRevision(ctx, gitserverRepo, nil, rev, &git.ResolveRevisionOptions{NoEnsureRevision: true})
 	if err != nil {
-		return nil, false, err
+		return false, err
 	}
 
 	shouldBeSearched, err := repoShouldBeSearched(ctx, info, gitserverRepo, commit, fetchTimeout)
 	if err != nil {
-		return nil, false, err
+		return false, err
 	}
 	if !shouldBeSearched {
-		return matches, false, err
+		return false, nil
 	}
 
-	matches, limitHit, err = textSearch(ctx, gitserverRepo, commit, info, fetchTimeout)
-
 	workspace := fileMatchURI(repo.Name, rev, "")
-	for _, fm := range matches {
+	return textSearchStream(ctx, gitserverRepo, commit, info, fetchTimeout, func(fm *fileMatchResolver) {
 		fm.uri = workspace + fm.JPath
 		fm.repo = repo
 		fm.commitID = commit
 		fm.inputRev = &rev
-	}
-
-	return matches, limitHit, err
+		if info.IsMultiline {
+			for _, lm := range fm.JLineMatches {
+				lm.multiline = true
+			}
+		}
+		send(fm)
+	})
 }
 
 // repoShouldBeSearched determines whether a repository should be searched in, based on whether the repository
@@ -954,6 +1066,14 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		return nil, common, nil
 	}
 
+	if args.Pattern.IsStructuralPat || args.Pattern.IsMultiline {
+		// Zoekt does not support structural search, and only reports the
+		// lines of multiline matches without their end positions, so
+		// searcher searches indexed repos as well.
+		searcherRepos = append(searcherRepos, zoektRepos...)
+		zoektRepos = nil
+	}
+
 	// Support index:yes (default), index:only, and index:no in search query.
 	index, _ := args.Query.StringValues(query.FieldIndex)
 	if len(index) > 0 {
@@ -992,26 +1112,28 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		overLimitCanceled bool // canceled because we were over the limit
 	)
 
-	// addMatches assumes the caller holds mu.
+	// countMatches assumes the caller holds mu.
+	countMatches := func(n int) {
+		common.resultCount += int32(n)
+		flattenedSize += n
+
+		// Stop searching once we have found enough matches. This does
+		// lead to potentially unstable result ordering, but is worth
+		// it for the performance benefit.
+		if flattenedSize > int(args.Pattern.FileMatchLimit) && !overLimitCanceled {
+			tr.LazyPrintf("cancel due to result size: %d > %d", flattenedSize, args.Pattern.FileMatchLimit)
+			overLimitCanceled = true
+			common.limitHit = true
+			cancel()
+		}
+	}
+
+	// addMatches adds the matches of a search of one or more repositories.
+	// It assumes the caller holds mu.
 	addMatches := func(matches []*fileMatchResolver) {
 		if len(matches) > 0 {
-			common.resultCount += int32(len(matches))
-			sort.Slice(matches, func(i, j int) bool {
-				a, b := matches[i].uri, matches[j].uri
-				return a > b
-			})
 			unflattened = append(unflattened, matches)
-			flattenedSize += len(matches)
-
-			// Stop searching once we have found enough matches. This does
-			// lead to potentially unstable result ordering, but is worth
-			// it for the performance benefit.
-			if flattenedSize > int(args.Pattern.FileMatchLimit) {
-				tr.LazyPrintf("cancel due to result size: %d > %d", flattenedSize, args.Pattern.FileMatchLimit)
-				overLimitCanceled = true
-				common.limitHit = true
-				cancel()
-			}
+			countMatches(len(matches))
 		}
 	}
 
@@ -1097,8 +1219,22 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 			defer wg.Done()
 			defer done()
 
+			// Matches are added as searcher sends them, so that we stop
+			// searching as soon as we have found enough of them.
+			repoIndex := -1
+			send := func(fm *fileMatchResolver) {
+				mu.Lock()
+				defer mu.Unlock()
+				if repoIndex < 0 {
+					unflattened = append(unflattened, nil)
+					repoIndex = len(unflattened) - 1
+				}
+				unflattened[repoIndex] = append(unflattened[repoIndex], fm)
+				countMatches(1)
+			}
+
 			rev := repoRev.RevSpecs()[0] // TODO(sqs): search multiple revs
-			matches, repoLimitHit, searchErr := searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo(), rev, args.Pattern, fetchTimeout)
+			repoLimitHit, searchErr := searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo(), rev, args.Pattern, fetchTimeout, send)
 			if searchErr != nil {
 				tr.LogFields(otlog.String("repo", string(repoRev.Repo.Name)), otlog.String("searchErr", searchErr.Error()), otlog.Bool("timeout", errcode.IsTimeout(searchErr)), otlog.Bool("temporary", errcode.IsTemporary(searchErr)))
 				log15.Warn("searchFilesInRepo failed", "error", searchErr, "repo", repoRev.Repo.Name)
@@ -1108,6 +1244,14 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 			if ctx.Err() == nil {
 				common.searched = append(common.searched, repoRev.Repo)
 			}
+			if searchErr != nil && ctx.Err() == nil && repoIndex >= 0 && !errcode.IsTimeout(searchErr) && !errcode.IsTemporary(searchErr) {
+				// The matches sent before the search failed are not reported
+				// next to the error (e.g. the repo is missing or cloning).
+				n := len(unflattened[repoIndex])
+				common.resultCount -= int32(n)
+				flattenedSize -= n
+				unflattened[repoIndex] = nil
+			}
 			if repoLimitHit {
 				// We did not return all results in this repository.
 				common.partial[repoRev.Repo.Name] = struct{}{}
@@ -1116,7 +1260,7 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 			if fatalErr := handleRepoSearchResult(common, repoRev, repoLimitHit, false, searchErr); fatalErr != nil {
 				if ctx.Err() == context.Canceled {
 					// Our request has been canceled (either because another one of searcherRepos
-					// had a fatal error, or otherwise), so we can just ignore these results. We
+					// had a fatal error, or otherwise), so we can just ignore this error. We
 					// handle this here, not in handleRepoSearchResult, because different callers of
 					// handleRepoSearchResult (for different result types) currently all need to
 					// handle cancellations differently.
@@ -1126,7 +1270,6 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 				tr.LazyPrintf("cancel due to error: %v", err)
 				cancel()
 			}
-			addMatches(matches)
 		}(limitCtx, limitDone, repoRev)
 	}
 
@@ -1135,7 +1278,19 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		return nil, common, err
 	}
 
-	flattened := flattenFileMatches(unflattened, int(args.Pattern.FileMatchLimit))
+	// Skip the repos whose matches were dropped because their search failed.
+	nonEmpty := unflattened[:0]
+	for _, matches := range unflattened {
+		if len(matches) == 0 {
+			continue
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			a, b := matches[i].uri, matches[j].uri
+			return a > b
+		})
+		nonEmpty = append(nonEmpty, matches)
+	}
+	flattened := flattenFileMatches(nonEmpty, int(args.Pattern.FileMatchLimit))
 	return flattened, common, nil
 }
 
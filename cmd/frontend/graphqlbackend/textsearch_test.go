@@ -3,6 +3,7 @@ package graphqlbackend
 import (
 	"context"
 	"fmt"
+	"io"
 	"reflect"
 	"sort"
 	"strings"
@@ -243,7 +244,12 @@ func TestSearchFilesInRepos(t *testing.T) {
 		case "foo/cloning":
 			return nil, false, &vcs.RepoNotExistError{Repo: repoName, CloneInProgress: true}
 		case "foo/missing":
-			return nil, false, &vcs.RepoNotExistError{Repo: repoName}
+			// Matches sent before the search failed are dropped.
+			return []*fileMatchResolver{
+				{
+					uri: "git://" + string(repoName) + "?" + rev + "#" + "main.go",
+				},
+			}, false, &vcs.RepoNotExistError{Repo: repoName}
 		case "foo/missing-db":
 			return nil, false, &errcode.Mock{Message: "repo not found: foo/missing-db", IsNotFound: true}
 		case "foo/timedout":
@@ -766,3 +772,68 @@ func init() {
 	zoektAddr = "127.0.0.1:101010"
 	searcherURL = "http://127.0.0.1:101010"
 }
+
+func Test_decodeSearcherStream(t *testing.T) {
+	stream := `{"FileMatch":{"Path":"a.go","LineMatches":[{"Preview":"foo","LineNumber":1,"OffsetAndLengths":[[0,3]]}]}}
+{"FileMatch":{"Path":"b.go"}}
+{"Done":true,"LimitHit":true}
+`
+	var matches []*fileMatchResolver
+	limitHit, err := decodeSearcherStream(context.Background(), strings.NewReader(stream), func(fm *fileMatchResolver) {
+		matches = append(matches, fm)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !limitHit {
+		t.Error("expected limitHit")
+	}
+	var paths []string
+	for _, fm := range matches {
+		paths = append(paths, fm.JPath)
+	}
+	if want := []string{"a.go", "b.go"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got paths %v, want %v", paths, want)
+	}
+	if got := matches[0].JLineMatches[0].JPreview; got != "foo" {
+		t.Errorf("got preview %q, want %q", got, "foo")
+	}
+
+	// Matches are sent as they are decoded, before the stream ends.
+	r, w := io.Pipe()
+	sent := make(chan *fileMatchResolver)
+	done := make(chan error)
+	go func() {
+		_, err := decodeSearcherStream(context.Background(), r, func(fm *fileMatchResolver) { sent <- fm })
+		done <- err
+	}()
+	go w.Write([]byte(`{"FileMatch":{"Path":"a.go"}}` + "\n"))
+	if fm := <-sent; fm.JPath != "a.go" {
+		t.Errorf("got path %q, want %q", fm.JPath, "a.go")
+	}
+	w.Write([]byte(`{"Done":true}` + "\n"))
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	ignore := func(*fileMatchResolver) {}
+
+	// A deadline reported by searcher is surfaced as a timeout.
+	_, err = decodeSearcherStream(context.Background(), strings.NewReader(`{"Done":true,"DeadlineHit":true}`), ignore)
+	if err != context.DeadlineExceeded {
+		t.Errorf("got err %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// An error after matches were sent fails the search.
+	_, err = decodeSearcherStream(context.Background(), strings.NewReader(`{"FileMatch":{"Path":"a.go"}}
+{"Done":true,"Error":"boom"}`), ignore)
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	// A truncated stream is invalid.
+	_, err = decodeSearcherStream(context.Background(), strings.NewReader(`{"FileMatch":{"Path":"a.go"}}`), ignore)
+	if err == nil {
+		t.Error("expected error for truncated stream")
+	}
+}
@@ -61,6 +61,9 @@ func AccessTokenAuthMiddleware(next http.Handler) http.Handler {
 			//
 			// 🚨 SECURITY: It's important we check for the correct scopes to know what this token
 			// is allowed to do.
+			//
+			// Tokens with only the "site-admin:scim" scope fail this check, so they can only be
+			// used with the SCIM API (see authenticateSCIMRequest).
 			var requiredScope string
 			if sudoUser == "" {
 				requiredScope = authz.ScopeUserAll
@@ -101,6 +104,10 @@ func AccessTokenAuthMiddleware(next http.Handler) http.Handler {
 					http.Error(w, message, http.StatusForbidden)
 					return
 				}
+				if user.DeactivatedAt != nil {
+					http.Error(w, "Unable to sudo to deactivated user.", http.StatusForbidden)
+					return
+				}
 				actorUserID = user.ID
 				log15.Debug("HTTP request used sudo token.", "requestURI", r.URL.RequestURI(), "tokenSubjectUserID", subjectUserID, "actorUserID", actorUserID, "actorUsername", user.Username)
 			}
@@ -0,0 +1,49 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/handlerutil"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+// serveGitHTTP returns a handler which proxies a request of the read-only git
+// smart HTTP protocol (the given path, e.g. "/info/refs") for a repository to
+// the repository's gitserver. It lets users `git clone` and `git fetch`
+// repositories mirrored by Sourcegraph from
+// /.api/repos/{repo}/-/git. Pushes are not supported.
+func serveGitHTTP(path string) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		// 🚨 SECURITY: GetRepo only returns repositories the user has access
+		// to, so this is where the repository permissions are checked.
+		repo, err := handlerutil.GetRepo(r.Context(), mux.Vars(r))
+		if err != nil {
+			return err
+		}
+
+		u := gitserver.DefaultClient.GitHTTPURL(r.Context(), repo.Name)
+		u.Path += path
+		u.RawQuery = r.URL.RawQuery
+
+		// The response headers come from gitserver.
+		w.Header().Del("Content-Type")
+
+		proxy := &httputil.ReverseProxy{
+			Director: func(req *http.Request) {
+				req.URL = u
+				req.Host = u.Host
+				// 🚨 SECURITY: Don't pass the user's credentials on to
+				// gitserver.
+				req.Header.Del("Authorization")
+				req.Header.Del("Cookie")
+			},
+			// Stream the progress of git upload-pack to the client.
+			FlushInterval: 100 * time.Millisecond,
+		}
+		proxy.ServeHTTP(w, r)
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+func TestGitHTTP(t *testing.T) {
+	c := newTest()
+
+	var gotURL, gotAuth string
+	gs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		w.Write([]byte("refs"))
+	}))
+	defer gs.Close()
+
+	orig := gitserver.DefaultClient.Addrs
+	gitserver.DefaultClient.Addrs = func(ctx context.Context) []string {
+		return []string{strings.TrimPrefix(gs.URL, "http://")}
+	}
+	defer func() { gitserver.DefaultClient.Addrs = orig }()
+
+	backend.Mocks.Repos.GetByName = func(ctx context.Context, name api.RepoName) (*types.Repo, error) {
+		if name == "github.com/gorilla/mux" {
+			return &types.Repo{ID: 2, Name: name}, nil
+		}
+		return nil, &errcode.Mock{Message: "repo not found", IsNotFound: true}
+	}
+	defer func() { backend.Mocks.Repos.GetByName = nil }()
+
+	req, _ := http.NewRequest("GET", "/repos/github.com/gorilla/mux/-/git/info/refs?service=git-upload-pack", nil)
+	req.SetBasicAuth("token", "")
+	resp, err := c.DoOK(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "refs" {
+		t.Errorf("got body %q, want %q", body, "refs")
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-git-upload-pack-advertisement" {
+		t.Errorf("got Content-Type %q", ct)
+	}
+	if want := "/git/github.com/gorilla/mux/info/refs?service=git-upload-pack"; gotURL != want {
+		t.Errorf("got gitserver URL %q, want %q", gotURL, want)
+	}
+	if gotAuth != "" {
+		t.Errorf("Authorization header was passed to gitserver: %q", gotAuth)
+	}
+
+	// Repositories the user can't access are not proxied.
+	gotURL = ""
+	req, _ = http.NewRequest("POST", "/repos/github.com/private/repo/-/git/git-upload-pack", strings.NewReader("0000"))
+	resp, err = c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("got status 200 for an inaccessible repository")
+	}
+	if gotURL != "" {
+		t.Errorf("request for an inaccessible repository was proxied to %q", gotURL)
+	}
+}
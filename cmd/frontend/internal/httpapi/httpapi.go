@@ -18,6 +18,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/handlerutil"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/registry"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
 	"github.com/sourcegraph/sourcegraph/pkg/trace"
 	log15 "gopkg.in/inconshreveable/log15.v2"
 )
@@ -41,6 +42,9 @@ func NewHandler(m *mux.Router) http.Handler {
 
 	m.Get(apirouter.RepoRefresh).Handler(trace.TraceRoute(handler(serveRepoRefresh)))
 
+	m.Get(apirouter.RepoGitInfoRefs).Handler(trace.TraceRoute(handler(serveGitHTTP("/info/refs"))))
+	m.Get(apirouter.RepoGitUploadPack).Handler(trace.TraceRoute(handler(serveGitHTTP("/git-upload-pack"))))
+
 	m.Get(apirouter.Telemetry).Handler(trace.TraceRoute(telemetryHandler))
 
 	if envvar.SourcegraphDotComMode() {
@@ -60,8 +64,21 @@ func NewHandler(m *mux.Router) http.Handler {
 		m.Get(apirouter.LSIF).Handler(trace.TraceRoute(http.HandlerFunc(lsifProxyHandler(proxy))))
 	}
 
+	repoUpdaterURL, err := url.Parse(repoupdater.DefaultClient.URL)
+	if err != nil {
+		log15.Error("skipping initialization of the code host webhooks because the environment variable REPO_UPDATER_URL is not a valid URL", "parse_error", err, "value", repoupdater.DefaultClient.URL)
+	} else {
+		m.Get(apirouter.Webhooks).Handler(trace.TraceRoute(http.HandlerFunc(webhooksProxyHandler(httputil.NewSingleHostReverseProxy(repoUpdaterURL)))))
+	}
+
 	m.Get(apirouter.Registry).Handler(trace.TraceRoute(handler(registry.HandleRegistry)))
 
+	m.Get(apirouter.SCIMServiceProviderConfig).Handler(trace.TraceRoute(scimHandler(serveSCIMServiceProviderConfig)))
+	m.Get(apirouter.SCIMUsers).Handler(trace.TraceRoute(scimHandler(serveSCIMUsers)))
+	m.Get(apirouter.SCIMUser).Handler(trace.TraceRoute(scimHandler(serveSCIMUser)))
+	m.Get(apirouter.SCIMGroups).Handler(trace.TraceRoute(scimHandler(serveSCIMGroups)))
+	m.Get(apirouter.SCIMGroup).Handler(trace.TraceRoute(scimHandler(serveSCIMGroup)))
+
 	m.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("API no route: %s %s from %s", r.Method, r.URL, r.Referer())
 		http.Error(w, "no route", http.StatusNotFound)
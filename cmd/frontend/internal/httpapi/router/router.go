@@ -15,9 +15,18 @@ const (
 
 	Registry = "registry"
 
-	RepoShield  = "repo.shield"
-	RepoRefresh = "repo.refresh"
-	Telemetry   = "telemetry"
+	RepoShield        = "repo.shield"
+	RepoRefresh       = "repo.refresh"
+	RepoGitInfoRefs   = "repo.git.info-refs"
+	RepoGitUploadPack = "repo.git.upload-pack"
+	Telemetry         = "telemetry"
+	Webhooks          = "webhooks"
+
+	SCIMServiceProviderConfig = "scim.service-provider-config"
+	SCIMUsers                 = "scim.users"
+	SCIMUser                  = "scim.user"
+	SCIMGroups                = "scim.groups"
+	SCIMGroup                 = "scim.group"
 
 	SavedQueriesListAll    = "internal.saved-queries.list-all"
 	SavedQueriesGetInfo    = "internal.saved-queries.get-info"
@@ -64,6 +73,14 @@ func New(base *mux.Router) *mux.Router {
 	base.Path("/lsif/challenge").Methods("GET").Name(LSIFChallenge)
 	base.Path("/lsif/verify").Methods("GET").Name(LSIFVerify)
 	base.Path("/lsif/{rest:.*}").Methods("POST").Name(LSIF)
+	base.Path("/webhooks/{kind:github|gitlab|bitbucket-server}").Methods("POST").Name(Webhooks)
+
+	scim := base.PathPrefix("/scim/v2").Subrouter()
+	scim.Path("/ServiceProviderConfig").Methods("GET").Name(SCIMServiceProviderConfig)
+	scim.Path("/Users").Methods("GET", "POST").Name(SCIMUsers)
+	scim.Path("/Users/{id}").Methods("GET", "PUT", "PATCH", "DELETE").Name(SCIMUser)
+	scim.Path("/Groups").Methods("GET", "POST").Name(SCIMGroups)
+	scim.Path("/Groups/{id}").Methods("GET", "PUT", "PATCH", "DELETE").Name(SCIMGroup)
 
 	// repo contains routes that are NOT specific to a revision. In these routes, the URL may not contain a revspec after the repo (that is, no "github.com/foo/bar@myrevspec").
 	repoPath := `/repos/` + routevar.Repo
@@ -73,6 +90,8 @@ func New(base *mux.Router) *mux.Router {
 	repo := base.PathPrefix(repoPath + "/" + routevar.RepoPathDelim + "/").Subrouter()
 	repo.Path("/shield").Methods("GET").Name(RepoShield)
 	repo.Path("/refresh").Methods("POST").Name(RepoRefresh)
+	repo.Path("/git/info/refs").Methods("GET").Name(RepoGitInfoRefs)
+	repo.Path("/git/git-upload-pack").Methods("POST").Name(RepoGitUploadPack)
 
 	return base
 }
@@ -0,0 +1,292 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// This file implements the protocol of the SCIM 2.0 API (https://tools.ietf.org/html/rfc7644),
+// which identity providers use to provision users and groups. SCIM users are Sourcegraph users and
+// SCIM groups are Sourcegraph organizations.
+
+const (
+	scimSchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimSchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimSchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimSchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimSchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimSchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	// scimMaxResults is the maximum number of resources returned in a list response.
+	scimMaxResults = 100
+)
+
+// scimError is an error returned to SCIM clients (https://tools.ietf.org/html/rfc7644#section-3.12).
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *scimError) Error() string { return e.detail }
+
+func scimBadRequest(scimType, format string, args ...interface{}) error {
+	return &scimError{status: http.StatusBadRequest, scimType: scimType, detail: fmt.Sprintf(format, args...)}
+}
+
+// scimHandler is a wrapper func for SCIM API handlers. It authenticates the request and writes
+// errors in the format of the SCIM protocol.
+func scimHandler(h func(http.ResponseWriter, *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := authenticateSCIMRequest(r)
+		if err == nil {
+			err = h(w, r.WithContext(ctx))
+		}
+		if err != nil {
+			writeSCIMError(w, r, err)
+		}
+	})
+}
+
+// authenticateSCIMRequest returns the context of the given SCIM request with the actor of its
+// access token.
+//
+// 🚨 SECURITY: SCIM clients authenticate with an access token passed as a bearer token, which
+// must have the "site-admin:scim" scope and whose subject must still be a site admin.
+func authenticateSCIMRequest(r *http.Request) (context.Context, error) {
+	unauthorized := func(detail string) error {
+		return &scimError{status: http.StatusUnauthorized, detail: detail}
+	}
+
+	if allow := conf.AccessTokensAllow(); allow != conf.AccessTokensAll && allow != conf.AccessTokensAdmin {
+		return nil, unauthorized("Access token authorization is disabled.")
+	}
+
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || strings.TrimSpace(parts[1]) == "" {
+		return nil, unauthorized("An access token with the " + authz.ScopeSiteAdminSCIM + " scope is required as a bearer token.")
+	}
+
+	ctx := r.Context()
+	subjectUserID, err := db.AccessTokens.Lookup(ctx, strings.TrimSpace(parts[1]), authz.ScopeSiteAdminSCIM)
+	if err != nil {
+		log15.Error("Invalid SCIM access token.", "err", err)
+		return nil, unauthorized("Invalid access token.")
+	}
+
+	if err := backend.CheckUserIsSiteAdmin(ctx, subjectUserID); err != nil {
+		log15.Error("SCIM access token's subject is not a site admin.", "subjectUserID", subjectUserID, "err", err)
+		return nil, &scimError{status: http.StatusForbidden, detail: "The subject user of a SCIM access token must be a site admin."}
+	}
+
+	return actor.WithActor(ctx, &actor.Actor{UID: subjectUserID}), nil
+}
+
+func writeSCIMError(w http.ResponseWriter, r *http.Request, err error) {
+	cause := errors.Cause(err)
+	e, ok := cause.(*scimError)
+	switch {
+	case ok:
+	case errcode.IsNotFound(cause):
+		e = &scimError{status: http.StatusNotFound, detail: "Resource not found."}
+	case db.IsUsernameExists(cause), db.IsEmailExists(cause):
+		e = &scimError{status: http.StatusConflict, scimType: "uniqueness", detail: cause.Error()}
+	default:
+		log15.Error("SCIM API error response", "method", r.Method, "request_uri", r.URL.RequestURI(), "error", err)
+		e = &scimError{status: http.StatusInternalServerError, detail: "Internal error."}
+	}
+
+	w.Header().Set("cache-control", "no-cache, max-age=0")
+	_ = writeSCIM(w, e.status, struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		SCIMType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail"`
+	}{
+		Schemas:  []string{scimSchemaError},
+		Status:   strconv.Itoa(e.status),
+		SCIMType: e.scimType,
+		Detail:   e.detail,
+	})
+}
+
+// writeSCIM writes the given SCIM resource or message with the given status code.
+func writeSCIM(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// readSCIM decodes the SCIM resource or message in the body of the request into v.
+func readSCIM(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return scimBadRequest("invalidSyntax", "Invalid request body: %s", err)
+	}
+	return nil
+}
+
+// scimResourceID returns the Sourcegraph ID of the resource in the request's URL.
+func scimResourceID(r *http.Request) (int32, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		return 0, &scimError{status: http.StatusNotFound, detail: "Resource not found."}
+	}
+	return int32(id), nil
+}
+
+type scimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// scimListParams are the parameters of a SCIM list request
+// (https://tools.ietf.org/html/rfc7644#section-3.4.2).
+type scimListParams struct {
+	// filterAttr and filterValue are set if the resources are filtered with an "eq" filter,
+	// which is the only filter supported.
+	filterAttr, filterValue string
+
+	// startIndex is the 1-based index of the first result.
+	startIndex, count int
+}
+
+// scimEqFilter matches filters of the form `attr eq "value"`.
+var scimEqFilter = regexp.MustCompile(`^\s*([A-Za-z.]+)\s+(?i:eq)\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+func parseSCIMListParams(r *http.Request) (*scimListParams, error) {
+	q := r.URL.Query()
+	p := &scimListParams{startIndex: 1, count: scimMaxResults}
+
+	if filter := q.Get("filter"); filter != "" {
+		m := scimEqFilter.FindStringSubmatch(filter)
+		if m == nil {
+			return nil, scimBadRequest("invalidFilter", "Unsupported filter %q. Only filters of the form 'attribute eq \"value\"' are supported.", filter)
+		}
+		value, err := strconv.Unquote(`"` + m[2] + `"`)
+		if err != nil {
+			return nil, scimBadRequest("invalidFilter", "Invalid filter value %q.", m[2])
+		}
+		p.filterAttr, p.filterValue = m[1], value
+	}
+
+	if s := q.Get("startIndex"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, scimBadRequest("invalidValue", "Invalid startIndex %q.", s)
+		}
+		if n > 1 {
+			p.startIndex = n
+		}
+	}
+
+	if s := q.Get("count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, scimBadRequest("invalidValue", "Invalid count %q.", s)
+		}
+		if n < 0 {
+			n = 0
+		}
+		if n < scimMaxResults {
+			p.count = n
+		}
+	}
+
+	return p, nil
+}
+
+// limitOffset returns the limit and offset of the resources listed by p.
+func (p *scimListParams) limitOffset() *db.LimitOffset {
+	return &db.LimitOffset{Limit: p.count, Offset: p.startIndex - 1}
+}
+
+// scimPatchOp is a SCIM PATCH request (https://tools.ietf.org/html/rfc7644#section-3.5.2).
+type scimPatchOp struct {
+	Schemas    []string `json:"schemas"`
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+// scimPatchOperation is a single operation of a SCIM PATCH request, with its op normalized to
+// lower case.
+type scimPatchOperation struct {
+	op, path string
+	value    json.RawMessage
+}
+
+// operations returns the operations of the PATCH request. Operations without a path whose
+// value is an object of attributes are split into one operation per attribute.
+func (p *scimPatchOp) operations() ([]scimPatchOperation, error) {
+	var ops []scimPatchOperation
+	for _, o := range p.Operations {
+		op := strings.ToLower(o.Op)
+		switch op {
+		case "add", "replace", "remove":
+		default:
+			return nil, scimBadRequest("invalidSyntax", "Unsupported PATCH operation %q.", o.Op)
+		}
+
+		if o.Path != "" || op == "remove" {
+			ops = append(ops, scimPatchOperation{op: op, path: o.Path, value: o.Value})
+			continue
+		}
+
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(o.Value, &attrs); err != nil {
+			return nil, scimBadRequest("invalidValue", "The value of a PATCH operation without a path must be an object.")
+		}
+		for path, value := range attrs {
+			ops = append(ops, scimPatchOperation{op: op, path: path, value: value})
+		}
+	}
+	return ops, nil
+}
+
+func serveSCIMServiceProviderConfig(w http.ResponseWriter, r *http.Request) error {
+	type supported struct {
+		Supported bool `json:"supported"`
+	}
+	return writeSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas": []string{scimSchemaServiceProviderConfig},
+		"patch":   supported{true},
+		"bulk": map[string]interface{}{
+			"supported":      false,
+			"maxOperations":  0,
+			"maxPayloadSize": 0,
+		},
+		"filter": map[string]interface{}{
+			"supported":  true,
+			"maxResults": scimMaxResults,
+		},
+		"changePassword": supported{false},
+		"sort":           supported{false},
+		"etag":           supported{false},
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Access token",
+			"description": "Authentication with a Sourcegraph access token with the " + authz.ScopeSiteAdminSCIM + " scope, passed as a bearer token.",
+			"primary":     true,
+		}},
+	})
+}
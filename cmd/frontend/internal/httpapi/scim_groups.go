@@ -0,0 +1,357 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/auth"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+)
+
+// scimGroup is a SCIM group resource (https://tools.ietf.org/html/rfc7643#section-4.2). SCIM
+// groups are organizations, whose names are the normalized display names of the groups.
+type scimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members"`
+	Meta        *scimMeta    `json:"meta,omitempty"`
+}
+
+// scimGroupDisplayName returns the display name of the SCIM group of the given organization.
+func scimGroupDisplayName(org *types.Org) string {
+	if org.DisplayName != nil && *org.DisplayName != "" {
+		return *org.DisplayName
+	}
+	return org.Name
+}
+
+// memberIDs returns the IDs of the users who are members of the group.
+func (g *scimGroup) memberIDs() ([]int32, error) {
+	ids := make([]int32, 0, len(g.Members))
+	for _, m := range g.Members {
+		id, err := strconv.ParseInt(m.Value, 10, 32)
+		if err != nil {
+			return nil, scimBadRequest("invalidValue", "Invalid member %q.", m.Value)
+		}
+		ids = append(ids, int32(id))
+	}
+	return ids, nil
+}
+
+// newSCIMGroup returns the SCIM resource of the given organization.
+func newSCIMGroup(ctx context.Context, org *types.Org) (*scimGroup, error) {
+	members, err := db.OrgMembers.GetByOrgID(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &scimGroup{
+		Schemas:     []string{scimSchemaGroup},
+		ID:          strconv.Itoa(int(org.ID)),
+		DisplayName: scimGroupDisplayName(org),
+		Members:     []scimMember{},
+		Meta: &scimMeta{
+			ResourceType: "Group",
+			Created:      org.CreatedAt,
+			LastModified: org.UpdatedAt,
+		},
+	}
+	for _, m := range members {
+		g.Members = append(g.Members, scimMember{Value: strconv.Itoa(int(m.UserID))})
+	}
+	return g, nil
+}
+
+func serveSCIMGroups(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "POST" {
+		return serveSCIMGroupCreate(w, r)
+	}
+
+	params, err := parseSCIMListParams(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		orgs  []*types.Org
+		total int
+	)
+	switch strings.ToLower(params.filterAttr) {
+	case "":
+		opt := db.OrgsListOptions{LimitOffset: params.limitOffset()}
+		if total, err = db.Orgs.Count(r.Context(), opt); err != nil {
+			return err
+		}
+		if orgs, err = db.Orgs.List(r.Context(), &opt); err != nil {
+			return err
+		}
+
+	case "displayname":
+		name, err := auth.NormalizeUsername(params.filterValue)
+		if err != nil {
+			break
+		}
+		org, err := db.Orgs.GetByName(r.Context(), name)
+		if err == nil {
+			orgs, total = []*types.Org{org}, 1
+		} else if _, ok := err.(*db.OrgNotFoundError); !ok {
+			return err
+		}
+
+	default:
+		return scimBadRequest("invalidFilter", "Groups can only be filtered by displayName.")
+	}
+
+	resources := []*scimGroup{}
+	for _, org := range orgs {
+		g, err := newSCIMGroup(r.Context(), org)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, g)
+	}
+
+	return writeSCIM(w, http.StatusOK, &scimListResponse{
+		Schemas:      []string{scimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   params.startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func serveSCIMGroupCreate(w http.ResponseWriter, r *http.Request) error {
+	var in scimGroup
+	if err := readSCIM(r, &in); err != nil {
+		return err
+	}
+	if in.DisplayName == "" {
+		return scimBadRequest("invalidValue", "The displayName attribute is required.")
+	}
+	name, err := auth.NormalizeUsername(in.DisplayName)
+	if err != nil {
+		return scimBadRequest("invalidValue", "%s", err)
+	}
+	memberIDs, err := in.memberIDs()
+	if err != nil {
+		return err
+	}
+
+	// Organizations and users share a namespace.
+	if _, err := db.Orgs.GetByName(r.Context(), name); err == nil {
+		return &scimError{status: http.StatusConflict, scimType: "uniqueness", detail: "An organization with this name already exists."}
+	}
+	if _, err := db.Users.GetByUsername(r.Context(), name); err == nil {
+		return &scimError{status: http.StatusConflict, scimType: "uniqueness", detail: "A user with this name already exists."}
+	}
+	org, err := db.Orgs.Create(r.Context(), name, &in.DisplayName)
+	if err != nil {
+		return err
+	}
+	if err := setSCIMGroupMembers(r.Context(), org.ID, memberIDs); err != nil {
+		return err
+	}
+
+	out, err := newSCIMGroup(r.Context(), org)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusCreated, out)
+}
+
+func serveSCIMGroup(w http.ResponseWriter, r *http.Request) error {
+	id, err := scimResourceID(r)
+	if err != nil {
+		return err
+	}
+	org, err := db.Orgs.GetByID(r.Context(), id)
+	if _, ok := err.(*db.OrgNotFoundError); ok {
+		return &scimError{status: http.StatusNotFound, detail: "Resource not found."}
+	} else if err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case "DELETE":
+		if err := db.Orgs.Delete(r.Context(), org.ID); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+
+	case "PUT":
+		var in scimGroup
+		if err := readSCIM(r, &in); err != nil {
+			return err
+		}
+		return updateSCIMGroup(w, r, org, &in)
+
+	case "PATCH":
+		var patch scimPatchOp
+		if err := readSCIM(r, &patch); err != nil {
+			return err
+		}
+		ops, err := patch.operations()
+		if err != nil {
+			return err
+		}
+		in, err := newSCIMGroup(r.Context(), org)
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if err := in.apply(op); err != nil {
+				return err
+			}
+		}
+		return updateSCIMGroup(w, r, org, in)
+	}
+
+	out, err := newSCIMGroup(r.Context(), org)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusOK, out)
+}
+
+// updateSCIMGroup updates the display name and members of the given organization to match the
+// given SCIM resource and writes the resulting resource. The name of the organization is never
+// changed, so that links to it keep working.
+func updateSCIMGroup(w http.ResponseWriter, r *http.Request, org *types.Org, in *scimGroup) error {
+	ctx := r.Context()
+
+	memberIDs, err := in.memberIDs()
+	if err != nil {
+		return err
+	}
+
+	if in.DisplayName != "" && in.DisplayName != scimGroupDisplayName(org) {
+		if org, err = db.Orgs.Update(ctx, org.ID, &in.DisplayName); err != nil {
+			return err
+		}
+	}
+	if err := setSCIMGroupMembers(ctx, org.ID, memberIDs); err != nil {
+		return err
+	}
+
+	out, err := newSCIMGroup(ctx, org)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusOK, out)
+}
+
+// setSCIMGroupMembers replaces the members of the given organization with the given users.
+func setSCIMGroupMembers(ctx context.Context, orgID int32, userIDs []int32) error {
+	current, err := db.OrgMembers.GetByOrgID(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	want := make(map[int32]bool, len(userIDs))
+	for _, id := range userIDs {
+		want[id] = true
+	}
+
+	have := make(map[int32]bool, len(current))
+	for _, m := range current {
+		have[m.UserID] = true
+		if !want[m.UserID] {
+			if err := db.OrgMembers.Remove(ctx, orgID, m.UserID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, id := range userIDs {
+		if have[id] {
+			continue
+		}
+		have[id] = true
+
+		// Ensure the user exists, so that unknown members are reported as such.
+		if _, err := db.Users.GetByID(ctx, id); errcode.IsNotFound(err) {
+			return scimBadRequest("invalidValue", "Member %d does not exist.", id)
+		} else if err != nil {
+			return err
+		}
+		if _, err := db.OrgMembers.Create(ctx, orgID, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scimMemberFilter matches member paths of the form `members[value eq "id"]`.
+var scimMemberFilter = regexp.MustCompile(`^(?i:members)\[\s*(?i:value)\s+(?i:eq)\s+"([^"]*)"\s*\]$`)
+
+// apply applies the given PATCH operation to the group resource.
+func (g *scimGroup) apply(op scimPatchOperation) error {
+	path := strings.ToLower(op.path)
+
+	if m := scimMemberFilter.FindStringSubmatch(op.path); m != nil && op.op == "remove" {
+		g.removeMembers(m[1])
+		return nil
+	}
+
+	unmarshal := func(v interface{}) error {
+		if err := json.Unmarshal(op.value, v); err != nil {
+			return scimBadRequest("invalidValue", "Invalid value of the %q attribute: %s", op.path, err)
+		}
+		return nil
+	}
+
+	switch path {
+	case "displayname":
+		if op.op == "remove" {
+			return scimBadRequest("mutability", "The displayName attribute of groups is required.")
+		}
+		return unmarshal(&g.DisplayName)
+
+	case "members":
+		var members []scimMember
+		if len(op.value) > 0 {
+			if err := unmarshal(&members); err != nil {
+				return err
+			}
+		}
+		switch op.op {
+		case "add":
+			g.Members = append(g.Members, members...)
+		case "replace":
+			g.Members = members
+		case "remove":
+			if len(members) == 0 {
+				g.Members = nil
+			}
+			for _, m := range members {
+				g.removeMembers(m.Value)
+			}
+		}
+		return nil
+
+	case "externalid":
+		// External IDs are not stored.
+		return nil
+	}
+	return scimBadRequest("invalidPath", "Unsupported attribute %q.", op.path)
+}
+
+// removeMembers removes the member with the given ID from the group.
+func (g *scimGroup) removeMembers(id string) {
+	members := g.Members[:0]
+	for _, m := range g.Members {
+		if m.Value != id {
+			members = append(members, m)
+		}
+	}
+	g.Members = members
+}
@@ -0,0 +1,296 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/httpapi/router"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtesting"
+)
+
+func TestParseSCIMListParams(t *testing.T) {
+	tests := map[string]struct {
+		query   string
+		want    *scimListParams
+		wantErr bool
+	}{
+		"defaults": {
+			query: "",
+			want:  &scimListParams{startIndex: 1, count: scimMaxResults},
+		},
+		"eq filter": {
+			query: url.Values{"filter": {`userName eq "alice@example.com"`}}.Encode(),
+			want:  &scimListParams{filterAttr: "userName", filterValue: "alice@example.com", startIndex: 1, count: scimMaxResults},
+		},
+		"case-insensitive operator": {
+			query: url.Values{"filter": {`displayName EQ "Engineering"`}}.Encode(),
+			want:  &scimListParams{filterAttr: "displayName", filterValue: "Engineering", startIndex: 1, count: scimMaxResults},
+		},
+		"pagination": {
+			query: "startIndex=11&count=5",
+			want:  &scimListParams{startIndex: 11, count: 5},
+		},
+		"count above maximum": {
+			query: "count=1000",
+			want:  &scimListParams{startIndex: 1, count: scimMaxResults},
+		},
+		"unsupported filter": {
+			query:   url.Values{"filter": {`userName sw "a"`}}.Encode(),
+			wantErr: true,
+		},
+		"invalid startIndex": {
+			query:   "startIndex=x",
+			wantErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/scim/v2/Users?"+test.query, nil)
+			got, err := parseSCIMListParams(r)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSCIMUser_apply(t *testing.T) {
+	var patch scimPatchOp
+	if err := json.Unmarshal([]byte(`{
+		"schemas": ["`+scimSchemaPatchOp+`"],
+		"Operations": [
+			{"op": "Replace", "value": {"displayName": "Alice Smith", "active": "False"}},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "alice@example.org"}
+		]
+	}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	ops, err := patch.operations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active := true
+	u := &scimUser{
+		UserName:    "alice",
+		DisplayName: "Alice",
+		Emails:      []scimEmail{{Value: "alice@example.com", Primary: true}, {Value: "a@example.com"}},
+		Active:      &active,
+	}
+	for _, op := range ops {
+		if err := u.apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := "Alice Smith"; u.displayName() != want {
+		t.Errorf("got display name %q, want %q", u.displayName(), want)
+	}
+	if u.active() {
+		t.Error("got active user, want inactive")
+	}
+	if want := []string{"alice@example.org", "a@example.com"}; !reflect.DeepEqual(u.emails(), want) {
+		t.Errorf("got emails %q, want %q", u.emails(), want)
+	}
+
+	if err := u.apply(scimPatchOperation{op: "replace", path: "nickName", value: json.RawMessage(`"al"`)}); err == nil {
+		t.Error("got no error for unsupported attribute")
+	}
+}
+
+func TestSCIMGroup_apply(t *testing.T) {
+	g := &scimGroup{DisplayName: "Engineering", Members: []scimMember{{Value: "1"}, {Value: "2"}}}
+
+	ops := []scimPatchOperation{
+		{op: "add", path: "members", value: json.RawMessage(`[{"value": "3"}]`)},
+		{op: "remove", path: `members[value eq "1"]`},
+		{op: "replace", path: "displayName", value: json.RawMessage(`"Eng"`)},
+	}
+	for _, op := range ops {
+		if err := g.apply(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := &scimGroup{DisplayName: "Eng", Members: []scimMember{{Value: "2"}, {Value: "3"}}}
+	if !reflect.DeepEqual(g, want) {
+		t.Errorf("got %+v, want %+v", g, want)
+	}
+}
+
+func TestSCIM(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	ctx := dbtesting.TestContext(t)
+	conf.Mock(&conf.Unified{})
+	defer conf.Mock(nil)
+
+	admin, err := db.Users.Create(ctx, db.NewUser{Username: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !admin.SiteAdmin {
+		t.Fatal("first user is not a site admin")
+	}
+	nonAdmin, err := db.Users.Create(ctx, db.NewUser{Username: "nonadmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]int32{"admintoken": admin.ID, "nonadmintoken": nonAdmin.ID}
+	db.Mocks.AccessTokens.Lookup = func(token, requiredScope string) (int32, error) {
+		if requiredScope != authz.ScopeSiteAdminSCIM {
+			t.Errorf("got required scope %q, want %q", requiredScope, authz.ScopeSiteAdminSCIM)
+		}
+		if id, ok := tokens[token]; ok {
+			return id, nil
+		}
+		return 0, errors.New("invalid token")
+	}
+	defer func() { db.Mocks = db.MockStores{} }()
+
+	h := NewHandler(router.New(mux.NewRouter()))
+	do := func(t *testing.T, token, method, path, body string, wantStatus int, out interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != wantStatus {
+			t.Fatalf("%s %s: got status %d, want %d (body: %s)", method, path, rec.Code, wantStatus, rec.Body)
+		}
+		if out != nil {
+			if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("authentication", func(t *testing.T) {
+		do(t, "", "GET", "/scim/v2/Users", "", http.StatusUnauthorized, nil)
+		do(t, "badtoken", "GET", "/scim/v2/Users", "", http.StatusUnauthorized, nil)
+		do(t, "nonadmintoken", "GET", "/scim/v2/Users", "", http.StatusForbidden, nil)
+		do(t, "admintoken", "GET", "/scim/v2/ServiceProviderConfig", "", http.StatusOK, nil)
+	})
+
+	var alice scimUser
+	t.Run("create user", func(t *testing.T) {
+		do(t, "admintoken", "POST", "/scim/v2/Users", `{
+			"schemas": ["`+scimSchemaUser+`"],
+			"userName": "alice@example.com",
+			"name": {"givenName": "Alice", "familyName": "Smith"},
+			"emails": [{"value": "alice@example.com", "primary": true}]
+		}`, http.StatusCreated, &alice)
+
+		if alice.UserName != "alice" || alice.DisplayName != "Alice Smith" {
+			t.Errorf("got user %+v", alice)
+		}
+		if len(alice.Emails) != 1 || alice.Emails[0].Value != "alice@example.com" || !alice.Emails[0].Primary {
+			t.Errorf("got emails %+v", alice.Emails)
+		}
+
+		do(t, "admintoken", "POST", "/scim/v2/Users", `{"userName": "alice"}`, http.StatusConflict, nil)
+	})
+
+	t.Run("filter users", func(t *testing.T) {
+		var list struct {
+			TotalResults int
+			Resources    []scimUser
+		}
+		do(t, "admintoken", "GET", "/scim/v2/Users?"+url.Values{"filter": {`userName eq "alice@example.com"`}}.Encode(), "", http.StatusOK, &list)
+		if list.TotalResults != 1 || len(list.Resources) != 1 || list.Resources[0].ID != alice.ID {
+			t.Errorf("got %+v", list)
+		}
+
+		do(t, "admintoken", "GET", "/scim/v2/Users?"+url.Values{"filter": {`userName eq "bob"`}}.Encode(), "", http.StatusOK, &list)
+		if list.TotalResults != 0 {
+			t.Errorf("got %+v", list)
+		}
+	})
+
+	var group scimGroup
+	t.Run("create group", func(t *testing.T) {
+		do(t, "admintoken", "POST", "/scim/v2/Groups", `{
+			"schemas": ["`+scimSchemaGroup+`"],
+			"displayName": "Engineering Team",
+			"members": [{"value": "`+alice.ID+`"}, {"value": "`+strconv.Itoa(int(admin.ID))+`"}]
+		}`, http.StatusCreated, &group)
+
+		if group.DisplayName != "Engineering Team" || len(group.Members) != 2 {
+			t.Errorf("got group %+v", group)
+		}
+		org, err := db.Orgs.GetByName(ctx, "Engineering-Team")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strconv.Itoa(int(org.ID)) != group.ID {
+			t.Errorf("got org ID %d, want %s", org.ID, group.ID)
+		}
+
+		var user scimUser
+		do(t, "admintoken", "GET", "/scim/v2/Users/"+alice.ID, "", http.StatusOK, &user)
+		if len(user.Groups) != 1 || user.Groups[0].Value != group.ID {
+			t.Errorf("got groups %+v", user.Groups)
+		}
+	})
+
+	t.Run("remove group member", func(t *testing.T) {
+		var got scimGroup
+		do(t, "admintoken", "PATCH", "/scim/v2/Groups/"+group.ID, `{
+			"schemas": ["`+scimSchemaPatchOp+`"],
+			"Operations": [{"op": "remove", "path": "members[value eq \"`+alice.ID+`\"]"}]
+		}`, http.StatusOK, &got)
+
+		want := []scimMember{{Value: strconv.Itoa(int(admin.ID))}}
+		if !reflect.DeepEqual(got.Members, want) {
+			t.Errorf("got members %+v, want %+v", got.Members, want)
+		}
+	})
+
+	t.Run("deactivate and reactivate user", func(t *testing.T) {
+		do(t, "admintoken", "PATCH", "/scim/v2/Users/"+alice.ID, `{
+			"schemas": ["`+scimSchemaPatchOp+`"],
+			"Operations": [{"op": "replace", "path": "active", "value": false}]
+		}`, http.StatusOK, nil)
+
+		var user scimUser
+		do(t, "admintoken", "GET", "/scim/v2/Users/"+alice.ID, "", http.StatusOK, &user)
+		if user.active() {
+			t.Error("got active user, want deactivated")
+		}
+		if len(user.Emails) != 1 || user.Emails[0].Value != "alice@example.com" {
+			t.Errorf("got emails %+v, want them kept while deactivated", user.Emails)
+		}
+
+		do(t, "admintoken", "PATCH", "/scim/v2/Users/"+alice.ID, `{
+			"schemas": ["`+scimSchemaPatchOp+`"],
+			"Operations": [{"op": "replace", "path": "active", "value": true}]
+		}`, http.StatusOK, &user)
+		if !user.active() {
+			t.Error("got deactivated user, want reactivated")
+		}
+	})
+
+	t.Run("delete group", func(t *testing.T) {
+		do(t, "admintoken", "DELETE", "/scim/v2/Groups/"+group.ID, "", http.StatusNoContent, nil)
+		do(t, "admintoken", "GET", "/scim/v2/Groups/"+group.ID, "", http.StatusNotFound, nil)
+	})
+}
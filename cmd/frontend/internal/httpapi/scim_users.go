@@ -0,0 +1,468 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/auth"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+)
+
+// scimUser is a SCIM user resource (https://tools.ietf.org/html/rfc7643#section-4.1).
+type scimUser struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id,omitempty"`
+	UserName    string        `json:"userName"`
+	Name        *scimUserName `json:"name,omitempty"`
+	DisplayName string        `json:"displayName,omitempty"`
+	Emails      []scimEmail   `json:"emails,omitempty"`
+	Active      *bool         `json:"active,omitempty"`
+	Groups      []scimMember  `json:"groups,omitempty"`
+	Meta        *scimMeta     `json:"meta,omitempty"`
+}
+
+type scimUserName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// displayName returns the display name of the user, falling back to their name.
+func (u *scimUser) displayName() string {
+	if u.DisplayName != "" || u.Name == nil {
+		return u.DisplayName
+	}
+	if u.Name.Formatted != "" {
+		return u.Name.Formatted
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// emails returns the email addresses of the user, starting with their primary email address.
+func (u *scimUser) emails() []string {
+	var emails []string
+	for _, e := range u.Emails {
+		if e.Value == "" {
+			continue
+		}
+		if e.Primary {
+			emails = append([]string{e.Value}, emails...)
+		} else {
+			emails = append(emails, e.Value)
+		}
+	}
+	return emails
+}
+
+// active reports whether the user is active. Users are active unless stated otherwise.
+func (u *scimUser) active() bool {
+	return u.Active == nil || *u.Active
+}
+
+// newSCIMUser returns the SCIM resource of the given user.
+func newSCIMUser(ctx context.Context, user *types.User) (*scimUser, error) {
+	emails, err := db.UserEmails.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	primary, _, err := db.UserEmails.GetPrimaryEmail(ctx, user.ID)
+	if err != nil && len(emails) > 0 {
+		return nil, err
+	}
+
+	orgs, err := db.Orgs.GetByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	active := user.DeactivatedAt == nil
+	u := &scimUser{
+		Schemas:     []string{scimSchemaUser},
+		ID:          strconv.Itoa(int(user.ID)),
+		UserName:    user.Username,
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+		},
+	}
+	if user.DisplayName != "" {
+		u.Name = &scimUserName{Formatted: user.DisplayName}
+	}
+	for _, e := range emails {
+		u.Emails = append(u.Emails, scimEmail{Value: e.Email, Type: "work", Primary: e.Email == primary})
+	}
+	for _, org := range orgs {
+		u.Groups = append(u.Groups, scimMember{Value: strconv.Itoa(int(org.ID)), Display: scimGroupDisplayName(org)})
+	}
+	return u, nil
+}
+
+func serveSCIMUsers(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == "POST" {
+		return serveSCIMUserCreate(w, r)
+	}
+
+	params, err := parseSCIMListParams(r)
+	if err != nil {
+		return err
+	}
+
+	var (
+		users []*types.User
+		total int
+	)
+	switch strings.ToLower(params.filterAttr) {
+	case "":
+		opt := db.UsersListOptions{LimitOffset: params.limitOffset()}
+		if total, err = db.Users.Count(r.Context(), &opt); err != nil {
+			return err
+		}
+		if users, err = db.Users.List(r.Context(), &opt); err != nil {
+			return err
+		}
+
+	case "username":
+		username, err := auth.NormalizeUsername(params.filterValue)
+		if err != nil {
+			break
+		}
+		user, err := db.Users.GetByUsername(r.Context(), username)
+		if err == nil {
+			users, total = []*types.User{user}, 1
+		} else if !errcode.IsNotFound(err) {
+			return err
+		}
+
+	default:
+		return scimBadRequest("invalidFilter", "Users can only be filtered by userName.")
+	}
+
+	resources := []*scimUser{}
+	for _, user := range users {
+		u, err := newSCIMUser(r.Context(), user)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, u)
+	}
+
+	return writeSCIM(w, http.StatusOK, &scimListResponse{
+		Schemas:      []string{scimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   params.startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func serveSCIMUserCreate(w http.ResponseWriter, r *http.Request) error {
+	var in scimUser
+	if err := readSCIM(r, &in); err != nil {
+		return err
+	}
+	if in.UserName == "" {
+		return scimBadRequest("invalidValue", "The userName attribute is required.")
+	}
+	if !in.active() {
+		return scimBadRequest("invalidValue", "Inactive users cannot be created.")
+	}
+
+	username, err := auth.NormalizeUsername(in.UserName)
+	if err != nil {
+		return scimBadRequest("invalidValue", "%s", err)
+	}
+
+	// Email addresses are verified by the identity provider.
+	emails := in.emails()
+	newUser := db.NewUser{Username: username, DisplayName: in.displayName()}
+	if len(emails) > 0 {
+		newUser.Email, newUser.EmailIsVerified = emails[0], true
+	}
+
+	user, err := db.Users.Create(r.Context(), newUser)
+	if err != nil {
+		return err
+	}
+	if len(emails) > 1 {
+		if err := setSCIMUserEmails(r.Context(), user.ID, emails); err != nil {
+			return err
+		}
+	}
+
+	out, err := newSCIMUser(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusCreated, out)
+}
+
+func serveSCIMUser(w http.ResponseWriter, r *http.Request) error {
+	id, err := scimResourceID(r)
+	if err != nil {
+		return err
+	}
+	user, err := db.Users.GetByID(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case "DELETE":
+		if err := db.Users.HardDelete(r.Context(), user.ID); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+
+	case "PUT":
+		var in scimUser
+		if err := readSCIM(r, &in); err != nil {
+			return err
+		}
+		return updateSCIMUser(w, r, user, &in)
+
+	case "PATCH":
+		var patch scimPatchOp
+		if err := readSCIM(r, &patch); err != nil {
+			return err
+		}
+		ops, err := patch.operations()
+		if err != nil {
+			return err
+		}
+		in, err := newSCIMUser(r.Context(), user)
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if err := in.apply(op); err != nil {
+				return err
+			}
+		}
+		return updateSCIMUser(w, r, user, in)
+	}
+
+	out, err := newSCIMUser(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusOK, out)
+}
+
+// updateSCIMUser updates the given user to match the given SCIM resource and writes the resulting
+// resource.
+//
+// Deactivating a user signs them out and disables their access tokens, but keeps their account
+// and data so that they can be reactivated later.
+func updateSCIMUser(w http.ResponseWriter, r *http.Request, user *types.User, in *scimUser) error {
+	ctx := r.Context()
+
+	var update db.UserUpdate
+	if in.UserName != "" {
+		username, err := auth.NormalizeUsername(in.UserName)
+		if err != nil {
+			return scimBadRequest("invalidValue", "%s", err)
+		}
+		if username != user.Username {
+			update.Username = username
+		}
+	}
+	if displayName := in.displayName(); displayName != user.DisplayName {
+		update.DisplayName = &displayName
+	}
+	if update != (db.UserUpdate{}) {
+		if err := db.Users.Update(ctx, user.ID, update); err != nil {
+			return err
+		}
+	}
+
+	if err := setSCIMUserEmails(ctx, user.ID, in.emails()); err != nil {
+		return err
+	}
+
+	if deactivated := !in.active(); deactivated != (user.DeactivatedAt != nil) {
+		if err := db.Users.SetDeactivated(ctx, user.ID, deactivated); err != nil {
+			return err
+		}
+	}
+
+	user, err := db.Users.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	out, err := newSCIMUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	return writeSCIM(w, http.StatusOK, out)
+}
+
+// setSCIMUserEmails replaces the email addresses of the given user with the given ones, which are
+// verified by the identity provider. If no email addresses are given, those of the user are left
+// untouched.
+func setSCIMUserEmails(ctx context.Context, userID int32, emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+
+	current, err := db.UserEmails.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	want := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		want[strings.ToLower(email)] = true
+	}
+
+	have := make(map[string]bool, len(current))
+	for _, e := range current {
+		have[strings.ToLower(e.Email)] = true
+		if !want[strings.ToLower(e.Email)] {
+			if err := db.UserEmails.Remove(ctx, userID, e.Email); err != nil {
+				return err
+			}
+		} else if e.VerifiedAt == nil {
+			if err := db.UserEmails.SetVerified(ctx, userID, e.Email, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, email := range emails {
+		if have[strings.ToLower(email)] {
+			continue
+		}
+		have[strings.ToLower(email)] = true
+		if err := db.UserEmails.Add(ctx, userID, email, nil); err != nil {
+			return err
+		}
+		if err := db.UserEmails.SetVerified(ctx, userID, email, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply applies the given PATCH operation to the user resource.
+func (u *scimUser) apply(op scimPatchOperation) error {
+	path := strings.ToLower(op.path)
+	if op.op == "remove" {
+		switch path {
+		case "displayname":
+			u.DisplayName, u.Name = "", nil
+		case "name":
+			u.Name = nil
+		default:
+			return scimBadRequest("noTarget", "The %q attribute of users cannot be removed.", op.path)
+		}
+		return nil
+	}
+
+	unmarshal := func(v interface{}) error {
+		if err := json.Unmarshal(op.value, v); err != nil {
+			return scimBadRequest("invalidValue", "Invalid value of the %q attribute: %s", op.path, err)
+		}
+		return nil
+	}
+
+	name := func() *scimUserName {
+		if u.Name == nil {
+			u.Name = &scimUserName{}
+		}
+		return u.Name
+	}
+
+	switch {
+	case path == "username":
+		return unmarshal(&u.UserName)
+	case path == "displayname":
+		return unmarshal(&u.DisplayName)
+	case path == "name":
+		return unmarshal(name())
+	case path == "name.formatted":
+		return unmarshal(&name().Formatted)
+	case path == "name.givenname":
+		return unmarshal(&name().GivenName)
+	case path == "name.familyname":
+		return unmarshal(&name().FamilyName)
+	case path == "emails":
+		var emails []scimEmail
+		if err := unmarshal(&emails); err != nil {
+			return err
+		}
+		if op.op == "add" {
+			u.Emails = append(u.Emails, emails...)
+		} else {
+			u.Emails = emails
+		}
+		return nil
+	case strings.HasPrefix(path, "emails["):
+		// Some identity providers replace the primary email address with a path like
+		// `emails[type eq "work"].value`.
+		var email string
+		if err := unmarshal(&email); err != nil {
+			return err
+		}
+		u.Emails = append([]scimEmail{{Value: email, Primary: true}}, u.Emails...)
+		for i := 1; i < len(u.Emails); i++ {
+			if u.Emails[i].Primary || strings.EqualFold(u.Emails[i].Value, email) {
+				u.Emails = append(u.Emails[:i], u.Emails[i+1:]...)
+				i--
+			}
+		}
+		return nil
+	case path == "active":
+		// Some identity providers send booleans as strings.
+		var active interface{}
+		if err := unmarshal(&active); err != nil {
+			return err
+		}
+		var b bool
+		switch v := active.(type) {
+		case bool:
+			b = v
+		case string:
+			var err error
+			if b, err = strconv.ParseBool(strings.ToLower(v)); err != nil {
+				return scimBadRequest("invalidValue", "Invalid value of the %q attribute: %q", op.path, v)
+			}
+		default:
+			return scimBadRequest("invalidValue", "Invalid value of the %q attribute.", op.path)
+		}
+		u.Active = &b
+		return nil
+	case path == "externalid", path == "groups", strings.HasPrefix(path, "urn:"):
+		// External IDs and extension attributes are not stored, and group memberships are
+		// managed through groups.
+		return nil
+	}
+	return scimBadRequest("invalidPath", "Unsupported attribute %q.", op.path)
+}
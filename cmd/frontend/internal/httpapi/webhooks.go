@@ -0,0 +1,24 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/gorilla/mux"
+)
+
+// webhooksProxyHandler proxies the code host webhook events to repo-updater,
+// which schedules updates of the repositories they changed.
+//
+// 🚨 SECURITY: Anonymous users can send webhook events. repo-updater only
+// accepts events signed with the webhook secret of an external service.
+func webhooksProxyHandler(p *httputil.ReverseProxy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = "/webhooks/" + mux.Vars(r)["kind"]
+		r.URL.RawPath = ""
+		// Don't forward the credentials of the user, if any.
+		r.Header.Del("Authorization")
+		r.Header.Del("Cookie")
+		p.ServeHTTP(w, r)
+	}
+}
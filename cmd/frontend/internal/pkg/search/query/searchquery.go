@@ -3,6 +3,9 @@
 package query
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/types"
 )
@@ -29,11 +32,12 @@ const (
 	FieldMessage   = "message"
 
 	// Temporary experimental fields:
-	FieldIndex   = "index"
-	FieldCount   = "count" // Searches that specify `count:` will fetch at least that number of results, or the full result set
-	FieldMax     = "max"   // Deprecated alias for count
-	FieldTimeout = "timeout"
-	FieldReplace = "replace"
+	FieldIndex       = "index"
+	FieldCount       = "count" // Searches that specify `count:` will fetch at least that number of results, or the full result set
+	FieldMax         = "max"   // Deprecated alias for count
+	FieldTimeout     = "timeout"
+	FieldReplace     = "replace"
+	FieldPatternType = "patterntype"
 )
 
 var (
@@ -62,11 +66,12 @@ var (
 			FieldMessage:   regexpNegatableFieldType,
 
 			// Experimental fields:
-			FieldIndex:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldCount:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldMax:     {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldTimeout: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldReplace: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldIndex:       {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldCount:       {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldMax:         {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldTimeout:     {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldReplace:     {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldPatternType: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
 		},
 		FieldAliases: map[string]string{
 			"r":        FieldRepo,
@@ -96,9 +101,15 @@ func ParseAndCheck(input string) (*Query, error) {
 }
 
 func parseAndCheck(conf *types.Config, input string) (*Query, error) {
-	syntaxQuery, err := syntax.Parse(input)
-	if err != nil {
-		return nil, err
+	var syntaxQuery *syntax.Query
+	if q := syntax.ParseAllowingErrors(input); isStructural(conf, q) {
+		syntaxQuery = quoteStructuralTerms(conf, q)
+	} else {
+		var err error
+		syntaxQuery, err = syntax.Parse(input)
+		if err != nil {
+			return nil, err
+		}
 	}
 	checkedQuery, err := conf.Check(syntaxQuery)
 	if err != nil {
@@ -107,6 +118,64 @@ func parseAndCheck(conf *types.Config, input string) (*Query, error) {
 	return &Query{conf: conf, Query: checkedQuery}, nil
 }
 
+// isStructural reports whether q is a structural search
+// (patterntype:structural).
+func isStructural(conf *types.Config, q *syntax.Query) bool {
+	for _, expr := range q.Expr {
+		if resolveField(conf, expr.Field) == FieldPatternType && !expr.Not && strings.Trim(expr.Value, `"'`) == "structural" {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteStructuralTerms returns q with each of its terms which isn't a
+// recognized field turned into a quoted term, so that structural patterns
+// (such as foo(:[args]) or :[x].bar) are taken literally instead of being
+// parsed as fields and regexps.
+func quoteStructuralTerms(conf *types.Config, q *syntax.Query) *syntax.Query {
+	q2 := &syntax.Query{Input: q.Input}
+	for i, expr := range q.Expr {
+		_, isField := conf.FieldTypes[resolveField(conf, expr.Field)]
+		isField = isField && expr.Field != "" && expr.ValueType != syntax.TokenError
+		if isField || (expr.Field == "" && expr.ValueType == syntax.TokenQuoted && !expr.Not) {
+			q2.Expr = append(q2.Expr, expr)
+			continue
+		}
+
+		// The value of an erroneous expression isn't always its input, so the
+		// term is taken from the input up to the next expression.
+		end := len(q.Input)
+		if i+1 < len(q.Expr) {
+			end = exprStart(q.Expr[i+1])
+		}
+		q2.Expr = append(q2.Expr, &syntax.Expr{
+			Pos:       exprStart(expr),
+			Value:     strconv.Quote(strings.TrimSpace(q.Input[exprStart(expr):end])),
+			ValueType: syntax.TokenQuoted,
+		})
+	}
+	return q2
+}
+
+// exprStart returns the position of expr in the input, including the "-" of
+// a negated expression.
+func exprStart(expr *syntax.Expr) int {
+	if expr.Not {
+		return expr.Pos - 1
+	}
+	return expr.Pos
+}
+
+// resolveField returns the field name which field is an alias of, or field
+// itself if it isn't an alias.
+func resolveField(conf *types.Config, field string) string {
+	if resolvedField, ok := conf.FieldAliases[field]; ok {
+		return resolvedField
+	}
+	return field
+}
+
 // BoolValue returns the last boolean value (yes/no) for the field. For example, if the query is
 // "foo:yes foo:no foo:yes", then the last boolean value for the "foo" field is true ("yes"). The
 // default boolean value is false.
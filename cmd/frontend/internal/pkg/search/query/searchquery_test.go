@@ -142,3 +142,33 @@ func checkPanic(t *testing.T, msg string, f func()) {
 	}()
 	f()
 }
+
+func TestParseAndCheck_structural(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "patterntype:structural foo(:[args], nil)", want: []string{"foo(:[args],", "nil)"}},
+		{input: "patterntype:structural :[fn](:[args]) file:\\.go$", want: []string{":[fn](:[args])"}},
+		{input: "patterntype:structural x.bar:[y] -:[z]", want: []string{"x.bar:[y]", "-:[z]"}},
+		{input: `patterntype:structural "foo bar"`, want: []string{"foo bar"}},
+	}
+	for _, test := range tests {
+		q, err := ParseAndCheck(test.input)
+		if err != nil {
+			t.Errorf("ParseAndCheck(%q): %s", test.input, err)
+			continue
+		}
+		var got []string
+		for _, v := range q.Values(FieldDefault) {
+			if v.String == nil {
+				t.Errorf("ParseAndCheck(%q): got non-literal term %v", test.input, v.Value())
+				continue
+			}
+			got = append(got, *v.String)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseAndCheck(%q): got terms %q, want %q", test.input, got, test.want)
+		}
+	}
+}
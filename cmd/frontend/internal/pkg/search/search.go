@@ -15,10 +15,14 @@ type PatternInfo struct {
 	IsRegExp        bool
 	IsWordMatch     bool
 	IsCaseSensitive bool
+	IsStructuralPat bool
 	FileMatchLimit  int32
 
-	// We do not support IsMultiline
-	// IsMultiline     bool
+	// IsMultiline is set if matches of Pattern may span several lines. Each
+	// such match is returned as a single line match which records where it
+	// ends. Only searcher supports it.
+	IsMultiline bool
+
 	IncludePattern  string
 	IncludePatterns []string
 	ExcludePattern  string
@@ -329,8 +329,9 @@ func authenticateByCookie(r *http.Request, w http.ResponseWriter) context.Contex
 			return actor.WithActor(r.Context(), &actor.Actor{})
 		}
 
-		// Check that user still exists.
-		if _, err := db.Users.GetByID(r.Context(), info.Actor.UID); err != nil {
+		// Check that user still exists and is not deactivated.
+		user, err := db.Users.GetByID(r.Context(), info.Actor.UID)
+		if err != nil {
 			if errcode.IsNotFound(err) {
 				_ = deleteSession(w, r) // clear the bad value
 			} else {
@@ -340,6 +341,10 @@ func authenticateByCookie(r *http.Request, w http.ResponseWriter) context.Contex
 			}
 			return r.Context() // not authenticated
 		}
+		if user.DeactivatedAt != nil {
+			_ = deleteSession(w, r) // sign out deactivated users
+			return r.Context()      // not authenticated
+		}
 
 		// Renew session
 		if time.Since(info.LastActive) > 5*time.Minute {
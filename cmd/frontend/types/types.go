@@ -78,6 +78,9 @@ type User struct {
 	UpdatedAt   time.Time
 	SiteAdmin   bool
 	Tags        []string
+	// DeactivatedAt is when the user was deactivated, if they are. Deactivated users can't
+	// sign in or use their access tokens until they are reactivated.
+	DeactivatedAt *time.Time
 }
 
 type Org struct {
@@ -25,15 +25,35 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/debugserver"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
 	"github.com/sourcegraph/sourcegraph/pkg/tracer"
+	"golang.org/x/time/rate"
 )
 
-var logRequests, _ = strconv.ParseBool(env.Get("LOG_REQUESTS", "", "log HTTP requests"))
+var (
+	logRequests, _     = strconv.ParseBool(env.Get("LOG_REQUESTS", "", "log HTTP requests"))
+	requestsPerHour, _ = strconv.ParseFloat(env.Get("GITHUB_PROXY_REQUESTS_PER_HOUR", "0", "maximum number of requests per hour to the GitHub API, or 0 for no limit"), 64)
+)
 
 const port = "3180"
 
 // requestMu ensures we only do one request at a time to prevent tripping abuse detection.
 var requestMu sync.Mutex
 
+// rateLimiter optionally spreads the requests over the hour. GitHub limits
+// requests per token, so it doesn't limit requests unless
+// GITHUB_PROXY_REQUESTS_PER_HOUR is set.
+var rateLimiter = newRateLimiter(requestsPerHour)
+
+func newRateLimiter(requestsPerHour float64) *rate.Limiter {
+	if requestsPerHour <= 0 {
+		return rate.NewLimiter(rate.Inf, 1)
+	}
+	burst := int(requestsPerHour / 60)
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(requestsPerHour/3600), burst)
+}
+
 var rateLimitRemainingGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 	Namespace: "src",
 	Subsystem: "github",
@@ -126,6 +146,11 @@ func main() {
 			Header: h2,
 		}
 
+		if err := rateLimiter.Wait(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
 		requestMu.Lock()
 		resp, err := client.Do(req2)
 		requestMu.Unlock()
@@ -19,9 +19,11 @@ import (
 	"gopkg.in/inconshreveable/log15.v2"
 
 	"github.com/sourcegraph/sourcegraph/cmd/gitserver/server"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/debugserver"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
 	"github.com/sourcegraph/sourcegraph/pkg/tracer"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 var (
@@ -29,6 +31,7 @@ var (
 	runRepoCleanup, _ = strconv.ParseBool(env.Get("SRC_RUN_REPO_CLEANUP", "", "Periodically remove inactive repositories."))
 	wantPctFree       = env.Get("SRC_REPOS_DESIRED_PERCENT_FREE", "10", "Target percentage of free space on disk.")
 	janitorInterval   = env.Get("SRC_REPOS_JANITOR_INTERVAL", "1m", "Interval between cleanup runs")
+	hostname          = env.Get("SRC_GITSERVER_HOSTNAME", "", "Hostname of this gitserver in the gitserver addresses. Defaults to the hostname of the machine.")
 )
 
 func main() {
@@ -47,10 +50,29 @@ func main() {
 	if err != nil {
 		log.Fatalf("parsing $SRC_REPOS_DESIRED_PERCENT_FREE: %v", err)
 	}
+	if hostname == "" {
+		hostname, err = os.Hostname()
+		if err != nil {
+			log.Fatalf("failed to get hostname: %s", err)
+		}
+	}
 	gitserver := server.Server{
 		ReposDir:                reposDir,
 		DeleteStaleRepositories: runRepoCleanup,
 		DesiredPercentFree:      wantPctFree2,
+		Hostname:                hostname,
+		GitServerAddrs: func() []string {
+			return conf.Get().ServiceConnections.GitServers
+		},
+		ReplicationFactor: func() int {
+			return conf.Get().GitReplicationFactor
+		},
+		ClonePolicies: func() []*schema.GitClonePolicy {
+			return conf.Get().GitClonePolicies
+		},
+		MaxRepoSizeBytes: func() int64 {
+			return int64(conf.Get().GitMaxRepoSizeMB) * 1024 * 1024
+		},
 	}
 	gitserver.RegisterMetrics()
 
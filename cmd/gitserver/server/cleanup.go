@@ -48,9 +48,11 @@ var reposRecloned = prometheus.NewCounter(prometheus.CounterOpts{
 // cleanupRepos walks the repos directory and performs maintenance tasks:
 //
 // 1. Remove corrupt repos.
-// 2. Remove stale lock files.
-// 3. Remove inactive repos on sourcegraph.com
-// 4. Reclone repos after a while. (simulate git gc)
+// 2. Remove repos larger than the maximum repo size.
+// 3. Remove stale lock files.
+// 4. Run git maintenance (gc, repack, commit-graph) when needed.
+// 5. Remove inactive repos on sourcegraph.com
+// 6. Reclone repos after a while. (simulate git gc)
 func (s *Server) cleanupRepos() {
 	bCtx, bCancel := s.serverContext()
 	defer bCancel()
@@ -71,10 +73,34 @@ func (s *Server) cleanupRepos() {
 		return true, nil
 	}
 
+	maybeRemoveTooLarge := func(gitDir string) (done bool, err error) {
+		if s.maxRepoSize() == 0 {
+			return false, nil
+		}
+		size, err := repoSize(gitDir)
+		if err != nil {
+			return false, err
+		}
+		repo := protocol.NormalizeRepo(api.RepoName(strings.TrimPrefix(filepath.Dir(gitDir), s.ReposDir+"/")))
+		removed, err := s.removeRepoIfTooLarge(repo, gitDir, size)
+		if removed {
+			reposRemoved.Inc()
+		}
+		return removed, err
+	}
+
 	ensureGitAttributes := func(gitDir string) (done bool, err error) {
 		return false, setGitAttributes(gitDir)
 	}
 
+	maybeMaintain := func(gitDir string) (done bool, err error) {
+		ctx, cancel := context.WithTimeout(bCtx, longGitCommandTimeout)
+		defer cancel()
+
+		repo := protocol.NormalizeRepo(api.RepoName(strings.TrimPrefix(filepath.Dir(gitDir), s.ReposDir+"/")))
+		return false, s.maintainRepo(ctx, repo, gitDir)
+	}
+
 	maybeReclone := func(gitDir string) (done bool, err error) {
 		recloneTime, err := getRecloneTime(gitDir)
 		if err != nil {
@@ -149,17 +175,24 @@ func (s *Server) cleanupRepos() {
 	cleanups := []cleanupFn{
 		// Do some sanity checks on the repository.
 		{"maybe remove corrupt", maybeRemoveCorrupt},
+		// Remove repositories larger than the maximum repository size, which
+		// may have been lowered since they were cloned.
+		{"maybe remove too large", maybeRemoveTooLarge},
 		// If git is interrupted it can leave lock files lying around. It does
 		// not clean these up, and instead fails commands.
 		{"remove stale locks", removeStaleLocks},
 		// We always want to have the same git attributes file at
 		// info/attributes.
 		{"ensure git attributes", ensureGitAttributes},
-	}
-	// Old git clones accumulate loose git objects that waste space and
-	// slow down git operations. Periodically do a fresh clone to avoid
-	// these problems. git gc is slow and resource intensive. It is
-	// cheaper and faster to just reclone the repository.
+		// Fetches add loose objects, packs and commit-graph layers, which
+		// slow down fetches and reads until they are consolidated.
+		{"maybe run git maintenance", maybeMaintain},
+	}
+	// git maintenance keeps the loose objects and packs of old git clones
+	// in check, but they still accumulate objects which are no longer
+	// reachable and waste space. Periodically do a fresh clone to drop
+	// them. A full git gc is slow and resource intensive. It is cheaper and
+	// faster to just reclone the repository.
 	cleanups = append(cleanups, cleanupFn{"maybe reclone", maybeReclone})
 
 	err := filepath.Walk(s.ReposDir, func(gitDir string, fi os.FileInfo, fileErr error) error {
@@ -0,0 +1,336 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// defaultFetchRefs are the refs which are fetched when a repository is
+// updated, unless its clone options specify other refs.
+var defaultFetchRefs = []string{"refs/heads/*", "refs/tags/*", "refs/pull/*"}
+
+// clonePolicyOptions returns the clone options of the first clone policy in
+// the site configuration which matches repo and its clone URL remoteURL. It
+// returns nil if no policy matches, in which case repo is mirrored in full.
+func (s *Server) clonePolicyOptions(repo api.RepoName, remoteURL string) *protocol.CloneOptions {
+	if s.ClonePolicies == nil {
+		return nil
+	}
+	for _, p := range s.ClonePolicies() {
+		ok, err := clonePolicyMatches(p, repo, remoteURL)
+		if err != nil {
+			log15.Warn("Ignoring invalid gitClonePolicies entry.", "pattern", p.Pattern, "error", err)
+			continue
+		}
+		if ok {
+			return &protocol.CloneOptions{Filter: p.Filter, Depth: p.Depth, Refs: p.Refs}
+		}
+	}
+	return nil
+}
+
+// clonePolicyMatches reports whether the clone policy p applies to repo,
+// whose clone URL is remoteURL.
+func clonePolicyMatches(p *schema.GitClonePolicy, repo api.RepoName, remoteURL string) (bool, error) {
+	if p.Pattern != "" {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return false, err
+		}
+		if !re.MatchString(string(repo)) {
+			return false, nil
+		}
+	}
+	if p.ExternalServiceURL != "" {
+		u, err := url.Parse(p.ExternalServiceURL)
+		if err != nil {
+			return false, err
+		}
+		if !strings.EqualFold(u.Hostname(), remoteURLHostname(remoteURL)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// scpLikeURL matches the scp-like syntax of SSH clone URLs, e.g.
+// git@github.com:owner/repo.git.
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):`)
+
+// remoteURLHostname returns the hostname of a git clone URL, or "" if it has
+// none.
+func remoteURLHostname(remoteURL string) string {
+	if u, err := url.Parse(remoteURL); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if m := scpLikeURL.FindStringSubmatch(remoteURL); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// clonePartial clones the repository at remoteURL into the bare git directory
+// dir with the clone options opts, writing the progress of the clone to
+// progress. Unlike git clone --mirror, it only fetches the refs matching
+// opts.Refs. The options are recorded in the repository's git config, so
+// that updates of the repository use them too (see readCloneOptions).
+func clonePartial(ctx context.Context, remoteURL, dir string, opts *protocol.CloneOptions, progress io.Writer) error {
+	if err := runGit(ctx, "", "init", "--bare", dir); err != nil {
+		return err
+	}
+	if err := runGit(ctx, dir, "config", "remote.origin.url", remoteURL); err != nil {
+		return err
+	}
+	for _, ref := range fetchRefs(opts) {
+		if err := runGit(ctx, dir, "config", "--add", "remote.origin.fetch", refspec(ref)); err != nil {
+			return err
+		}
+	}
+	if err := writeCloneOptions(ctx, dir, opts); err != nil {
+		return err
+	}
+
+	args := append([]string{"fetch", "--progress"}, cloneOptionsFetchArgs(opts)...)
+	cmd := exec.CommandContext(ctx, "git", append(args, "origin")...)
+	cmd.Dir = dir
+	if output, err := runWithRemoteOpts(ctx, cmd, progress); err != nil {
+		return errors.Wrapf(err, "clone failed. Output: %s", string(output))
+	}
+
+	// git init points HEAD at the default branch of git, so point it at the
+	// default branch of the remote instead. If that fails, the next update
+	// of the repository picks a branch.
+	cmd = exec.CommandContext(ctx, "git", "ls-remote", "--symref", "origin", "HEAD")
+	cmd.Dir = dir
+	output, err := runWithRemoteOpts(ctx, cmd, nil)
+	if err != nil {
+		log15.Warn("Failed to determine HEAD of partial clone", "dir", dir, "error", err, "output", string(output))
+		return nil
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields := strings.Fields(line); len(fields) == 3 && fields[0] == "ref:" && fields[2] == "HEAD" {
+			return runGit(ctx, dir, "symbolic-ref", "HEAD", fields[1])
+		}
+	}
+	return nil
+}
+
+// cloneOptionsFetchArgs returns the git fetch flags which apply opts.
+func cloneOptionsFetchArgs(opts *protocol.CloneOptions) []string {
+	var args []string
+	if opts.Depth > 0 {
+		args = append(args, "--depth="+strconv.Itoa(opts.Depth))
+	}
+	if opts.Filter != "" {
+		args = append(args, "--filter="+opts.Filter)
+	}
+	return args
+}
+
+// updateFetchArgs returns the arguments of the git fetch command which
+// updates a repository from remoteURL with the clone options opts (which may
+// be nil).
+func updateFetchArgs(remoteURL string, opts *protocol.CloneOptions) []string {
+	args := []string{"fetch", "--prune"}
+	if opts != nil {
+		args = append(args, cloneOptionsFetchArgs(opts)...)
+		if opts.Filter != "" {
+			// git only fetches with a filter from the promisor remote, whose
+			// URL doRepoUpdate2 sets to remoteURL.
+			remoteURL = "origin"
+		}
+	}
+	args = append(args, remoteURL)
+	for _, ref := range fetchRefs(opts) {
+		args = append(args, refspec(ref))
+	}
+	return args
+}
+
+// fetchRefs returns the patterns of the refs to fetch with the clone options
+// opts (which may be nil).
+func fetchRefs(opts *protocol.CloneOptions) []string {
+	if opts == nil || len(opts.Refs) == 0 {
+		return defaultFetchRefs
+	}
+	return opts.Refs
+}
+
+// refspec returns the git refspec which mirrors the refs matching pattern.
+func refspec(pattern string) string {
+	return "+" + pattern + ":" + pattern
+}
+
+// Keys of the git config of a repository which record its clone options.
+const (
+	cloneOptionsFilterKey = "sourcegraph.clonefilter"
+	cloneOptionsDepthKey  = "sourcegraph.clonedepth"
+	cloneOptionsRefsKey   = "sourcegraph.clonerefs"
+
+	// cloneOptionsKey is set in repositories cloned with clone options, even
+	// if all of the options are unset.
+	cloneOptionsKey = "sourcegraph.clonepolicy"
+)
+
+// writeCloneOptions records opts in the git config of the repository in dir.
+func writeCloneOptions(ctx context.Context, dir string, opts *protocol.CloneOptions) error {
+	if opts.Filter != "" {
+		if err := runGit(ctx, dir, "config", cloneOptionsFilterKey, opts.Filter); err != nil {
+			return err
+		}
+	}
+	if opts.Depth > 0 {
+		if err := runGit(ctx, dir, "config", cloneOptionsDepthKey, strconv.Itoa(opts.Depth)); err != nil {
+			return err
+		}
+	}
+	for _, ref := range opts.Refs {
+		if err := runGit(ctx, dir, "config", "--add", cloneOptionsRefsKey, ref); err != nil {
+			return err
+		}
+	}
+	return runGit(ctx, dir, "config", cloneOptionsKey, "true")
+}
+
+// readCloneOptions returns the clone options recorded in the git config of
+// the repository in dir, or nil if it was mirrored in full.
+var readCloneOptions = func(ctx context.Context, dir string) (*protocol.CloneOptions, error) {
+	cmd := exec.CommandContext(ctx, "git", "config", "--local", "--get-regexp", `^sourcegraph\.`)
+	cmd.Dir = dir
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if exitCode, err := runCommand(ctx, cmd); exitCode == 1 {
+		// No keys match.
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read clone options")
+	}
+
+	// Other sourcegraph.* keys (such as sourcegraph.recloneTimestamp) are set
+	// in all repositories, so only cloneOptionsKey marks a partial clone.
+	var opts protocol.CloneOptions
+	partial := false
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		kv := strings.SplitN(line, " ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case cloneOptionsKey:
+			partial = true
+		case cloneOptionsFilterKey:
+			opts.Filter = kv[1]
+		case cloneOptionsDepthKey:
+			opts.Depth, _ = strconv.Atoi(kv[1])
+		case cloneOptionsRefsKey:
+			opts.Refs = append(opts.Refs, kv[1])
+		}
+	}
+	if !partial {
+		return nil, nil
+	}
+	return &opts, nil
+}
+
+// fetchMissingArchiveBlobs fetches the blobs which the git archive command
+// with args needs and which were omitted by the partial clone filter of repo
+// (in dir). Failures are logged; git archive then fetches the blobs itself.
+func fetchMissingArchiveBlobs(ctx context.Context, repo api.RepoName, dir string, args []string) {
+	opts, err := readCloneOptions(ctx, dir)
+	if err != nil || opts == nil || opts.Filter == "" {
+		return
+	}
+
+	treeish := archiveTreeish(args)
+	if treeish == "" || checkSpecArgSafety(treeish) != nil {
+		return
+	}
+
+	if err := fetchMissingBlobs(ctx, dir, treeish); err != nil {
+		log15.Warn("Failed to fetch missing blobs for archive", "repo", repo, "treeish", treeish, "error", err)
+	}
+}
+
+// archiveTreeish returns the tree-ish of the git archive command with args
+// (starting with "archive"), which is its first argument that isn't an option,
+// or "" if it has none. The paths after it may or may not be preceded by "--"
+// (see handleArchive and vfsutil.GitServerFetchArchive).
+func archiveTreeish(args []string) string {
+	for i := 1; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		case arg == "-o" || arg == "--output" || arg == "--prefix" || arg == "--remote" || arg == "--exec" || arg == "--format" || arg == "--add-file":
+			// The option's value is the next argument.
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return arg
+		}
+	}
+	return ""
+}
+
+// fetchMissingBlobs fetches the blobs of the tree of treeish which were
+// omitted by the partial clone filter of the repository in dir, in a single
+// fetch.
+// git would otherwise fetch them one at a time as they are read, which is
+// very slow for commands like git archive.
+func fetchMissingBlobs(ctx context.Context, dir, treeish string) error {
+	cmd := exec.CommandContext(ctx, "git", "rev-list", "--objects", "--missing=print", "--no-walk", treeish+"^{tree}", "--")
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if _, err := runCommand(ctx, cmd); err != nil {
+		return fmt.Errorf("git %s failed: %s (%q)", cmd.Args, err, stderr.String())
+	}
+
+	var missing bytes.Buffer
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if strings.HasPrefix(line, "?") {
+			missing.WriteString(line[1:] + "\n")
+		}
+	}
+	if missing.Len() == 0 {
+		return nil
+	}
+
+	// This is how git itself fetches missing objects from a promisor remote.
+	cmd = exec.CommandContext(ctx, "git", "-c", "fetch.negotiationAlgorithm=noop", "fetch", "origin", "--no-tags", "--no-write-fetch-head", "--recurse-submodules=no", "--filter=blob:none", "--stdin")
+	cmd.Dir = dir
+	cmd.Stdin = &missing
+	if output, err := runWithRemoteOpts(ctx, cmd, nil); err != nil {
+		return errors.Wrapf(err, "failed to fetch missing blobs. Output: %s", string(output))
+	}
+	return nil
+}
+
+// runGit runs a local git command in dir.
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if _, err := runCommand(ctx, cmd); err != nil {
+		return errors.Wrapf(err, "git %s failed. Output: %s", strings.Join(args, " "), output.String())
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestClonePolicyOptions(t *testing.T) {
+	s := &Server{
+		ClonePolicies: func() []*schema.GitClonePolicy {
+			return []*schema.GitClonePolicy{
+				{Pattern: `^github\.com/acme/monorepo$`, Filter: "blob:limit=1m"},
+				{ExternalServiceURL: "https://GitLab.example.com", Depth: 10},
+				{Pattern: "[", Depth: 1},
+				{Pattern: `^bitbucket\.example\.com/`, Refs: []string{"refs/heads/*"}},
+			}
+		},
+	}
+	tests := []struct {
+		repo      api.RepoName
+		remoteURL string
+		want      *protocol.CloneOptions
+	}{
+		{"github.com/acme/monorepo", "https://github.com/acme/monorepo", &protocol.CloneOptions{Filter: "blob:limit=1m"}},
+		{"github.com/acme/other", "https://github.com/acme/other", nil},
+		{"gitlab/a/b", "https://token@gitlab.example.com/a/b.git", &protocol.CloneOptions{Depth: 10}},
+		{"gitlab/a/b", "git@gitlab.example.com:a/b.git", &protocol.CloneOptions{Depth: 10}},
+		{"gitlab/a/b", "ssh://git@gitlab.example.com:2222/a/b.git", &protocol.CloneOptions{Depth: 10}},
+		{"bitbucket.example.com/a/b", "https://bitbucket.example.com/scm/a/b.git", &protocol.CloneOptions{Refs: []string{"refs/heads/*"}}},
+	}
+	for _, test := range tests {
+		if got := s.clonePolicyOptions(test.repo, test.remoteURL); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("clonePolicyOptions(%q, %q) got %+v, want %+v", test.repo, test.remoteURL, got, test.want)
+		}
+	}
+}
+
+func TestUpdateFetchArgs(t *testing.T) {
+	tests := []struct {
+		opts *protocol.CloneOptions
+		want []string
+	}{
+		{
+			opts: nil,
+			want: []string{"fetch", "--prune", "u", "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*"},
+		},
+		{
+			opts: &protocol.CloneOptions{Depth: 5, Refs: []string{"refs/heads/master"}},
+			want: []string{"fetch", "--prune", "--depth=5", "u", "+refs/heads/master:refs/heads/master"},
+		},
+		{
+			opts: &protocol.CloneOptions{Filter: "blob:none"},
+			want: []string{"fetch", "--prune", "--filter=blob:none", "origin", "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*"},
+		},
+	}
+	for _, test := range tests {
+		if got := updateFetchArgs("u", test.opts); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("updateFetchArgs(%+v) got %q, want %q", test.opts, got, test.want)
+		}
+	}
+}
+
+func TestArchiveTreeish(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// handleArchive
+		{args: []string{"archive", "--worktree-attributes", "--format=zip", "-0", "HEAD", "--", "a", "b"}, want: "HEAD"},
+		// vfsutil.GitServerFetchArchive
+		{args: []string{"archive", "--format=tar", "deadbeef", ""}, want: "deadbeef"},
+		{args: []string{"archive", "--prefix", "p/", "-o", "out.zip", "HEAD"}, want: "HEAD"},
+		{args: []string{"archive", "--", "HEAD"}, want: "HEAD"},
+		{args: []string{"archive", "--format=zip"}, want: ""},
+	}
+	for _, test := range tests {
+		if got := archiveTreeish(test.args); got != test.want {
+			t.Errorf("archiveTreeish(%q) got %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestCloneRepo_clonePolicy(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	repo := remote
+	cmd := func(name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = repo
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		b, err := c.Output()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s", name, strings.Join(arg, " "), err)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	cmd("git", "init", ".")
+	cmd("git", "config", "uploadpack.allowFilter", "true")
+	cmd("git", "config", "uploadpack.allowAnySHA1InWant", "true")
+	cmd("sh", "-c", "head -c 10000 /dev/zero > big.bin && echo hello > hello.txt")
+	cmd("git", "add", ".")
+	cmd("git", "commit", "-m", "one")
+	cmd("sh", "-c", "echo hello world > hello.txt")
+	cmd("git", "commit", "-am", "two")
+	cmd("git", "tag", "v1")
+	wantCommit := cmd("git", "rev-parse", "HEAD")
+	wantHEAD := cmd("git", "symbolic-ref", "HEAD")
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	opts := &protocol.CloneOptions{Filter: "blob:limit=1k", Depth: 1, Refs: []string{"refs/heads/*"}}
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+		ClonePolicies: func() []*schema.GitClonePolicy {
+			return []*schema.GitClonePolicy{{Pattern: "^example.com/", Filter: opts.Filter, Depth: opts.Depth, Refs: opts.Refs}}
+		},
+	}
+	// A file:// URL, because git ignores filters when cloning a local path.
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", "file://"+remote, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(s.ReposDir, "example.com/foo/bar")
+	repo = dst
+	if got := cmd("git", "rev-parse", "HEAD"); got != wantCommit {
+		t.Errorf("got HEAD %s, want %s", got, wantCommit)
+	}
+	if got := cmd("git", "symbolic-ref", "HEAD"); got != wantHEAD {
+		t.Errorf("got HEAD %s, want %s", got, wantHEAD)
+	}
+	if got := cmd("git", "for-each-ref", "--format=%(refname)"); got != wantHEAD {
+		t.Errorf("got refs %q, want only %s", got, wantHEAD)
+	}
+	if _, err := os.Stat(filepath.Join(dst, ".git", "shallow")); err != nil {
+		t.Errorf("expected a shallow clone: %s", err)
+	}
+	if got, err := readCloneOptions(context.Background(), filepath.Join(dst, ".git")); err != nil || !reflect.DeepEqual(got, opts) {
+		t.Errorf("readCloneOptions got %+v, %v, want %+v", got, err, opts)
+	}
+
+	missing := func() bool {
+		return strings.Contains(cmd("git", "rev-list", "--objects", "--missing=print", "--no-walk", "HEAD^{tree}"), "?")
+	}
+	if !missing() {
+		t.Fatal("expected big.bin to be omitted by the filter")
+	}
+	if err := fetchMissingBlobs(context.Background(), filepath.Join(dst, ".git"), "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if missing() {
+		t.Error("expected fetchMissingBlobs to fetch big.bin")
+	}
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var commitGraphWriteErrorCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "commit_graph_write_errors",
+	Help:      "number of times writing the commit-graph file of a repository failed",
+})
+
+func init() {
+	prometheus.MustRegister(commitGraphWriteErrorCounter)
+}
+
+// writeCommitGraph writes the commit-graph file of the repository in dir,
+// with changed-path Bloom filters. git log, rev-list and merge-base use it to
+// walk the history without parsing each commit, and to skip commits which
+// don't modify a path. The commit graph endpoints rely on it being up to date,
+// so it is written after every clone and fetch. Only the commits added since
+// the last write are written (--split), so this is cheap for updates.
+//
+// Failures are logged, not returned: without a commit-graph file git is slower
+// but still correct.
+func writeCommitGraph(ctx context.Context, repo api.RepoName, dir string) {
+	cmd := exec.CommandContext(ctx, "git", "commit-graph", "write", "--reachable", "--changed-paths", "--split")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log15.Warn("Failed to write commit-graph", "repo", repo, "error", err, "output", string(output))
+		commitGraphWriteErrorCounter.Inc()
+	}
+}
+
+// commitGraphTimeout is the timeout of the git commands run by the commit
+// graph endpoints.
+const commitGraphTimeout = time.Minute
+
+// commitsLogFormat is the git log format of the commits returned by
+// handleCommits. Each commit has commitsLogFields NUL-terminated fields.
+const (
+	commitsLogFormat = "--format=format:%H%x00%aN%x00%aE%x00%at%x00%cN%x00%cE%x00%ct%x00%B%x00%P%x00"
+	commitsLogFields = 9
+)
+
+// handleCommits serves a protocol.CommitsRequest.
+func (s *Server) handleCommits(w http.ResponseWriter, r *http.Request) {
+	var req protocol.CommitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	args, err := commitsArgs(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), commitGraphTimeout)
+	defer cancel()
+
+	req.Repo = protocol.NormalizeRepo(req.Repo)
+	dir, ok := s.commitGraphRepoDir(ctx, w, req.Repo, req.URL)
+	if !ok {
+		return
+	}
+	s.ensureRevision(ctx, req.Repo, req.URL, req.EnsureRevision, dir)
+
+	stdout, exitCode, stderr, err := runCommitGraphCommand(ctx, dir, args...)
+	var res protocol.CommitsResponse
+	switch {
+	case err == nil && exitCode == 0:
+		if req.CountOnly {
+			n, err := strconv.ParseUint(string(bytes.TrimSpace(stdout)), 10, 64)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Count = uint(n)
+		} else {
+			res.Commits, err = parseCommitsLog(stdout)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Count = uint(len(res.Commits))
+		}
+	case isRevisionNotFoundOutput(stderr):
+		res.RevisionNotFound = true
+	default:
+		commitGraphError(w, req.Repo, args, err, stderr)
+		return
+	}
+	writeCommitGraphResponse(w, &res)
+}
+
+// handleMergeBase serves a protocol.MergeBaseRequest.
+func (s *Server) handleMergeBase(w http.ResponseWriter, r *http.Request) {
+	var req protocol.MergeBaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), commitGraphTimeout)
+	defer cancel()
+
+	req.Repo = protocol.NormalizeRepo(req.Repo)
+	dir, ok := s.commitGraphRepoDir(ctx, w, req.Repo, "")
+	if !ok {
+		return
+	}
+
+	args := []string{"merge-base", "--", string(req.A), string(req.B)}
+	stdout, exitCode, stderr, err := runCommitGraphCommand(ctx, dir, args...)
+	var res protocol.MergeBaseResponse
+	switch {
+	case err == nil && exitCode == 0:
+		res.MergeBase = api.CommitID(bytes.TrimSpace(stdout))
+	case err == nil && exitCode == 1 && stderr == "":
+		// The commits have no common ancestor.
+	case isRevisionNotFoundOutput(stderr):
+		res.RevisionNotFound = true
+	default:
+		commitGraphError(w, req.Repo, args, err, stderr)
+		return
+	}
+	writeCommitGraphResponse(w, &res)
+}
+
+// handleIsAncestor serves a protocol.IsAncestorRequest.
+func (s *Server) handleIsAncestor(w http.ResponseWriter, r *http.Request) {
+	var req protocol.IsAncestorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), commitGraphTimeout)
+	defer cancel()
+
+	req.Repo = protocol.NormalizeRepo(req.Repo)
+	dir, ok := s.commitGraphRepoDir(ctx, w, req.Repo, "")
+	if !ok {
+		return
+	}
+
+	args := []string{"merge-base", "--is-ancestor", "--", string(req.Ancestor), string(req.Descendant)}
+	_, exitCode, stderr, err := runCommitGraphCommand(ctx, dir, args...)
+	var res protocol.IsAncestorResponse
+	switch {
+	case err == nil && exitCode == 0:
+		res.IsAncestor = true
+	case err == nil && exitCode == 1 && stderr == "":
+		// Ancestor is not an ancestor of Descendant.
+	case isRevisionNotFoundOutput(stderr):
+		res.RevisionNotFound = true
+	default:
+		commitGraphError(w, req.Repo, args, err, stderr)
+		return
+	}
+	writeCommitGraphResponse(w, &res)
+}
+
+// commitGraphRepoDir returns the directory of repo. If repo isn't cloned, it
+// responds with a protocol.NotFoundPayload like the exec endpoint, and starts
+// cloning repo if url is set.
+func (s *Server) commitGraphRepoDir(ctx context.Context, w http.ResponseWriter, repo api.RepoName, url string) (dir string, ok bool) {
+	dir = path.Join(s.ReposDir, string(repo))
+	cloneProgress, cloneInProgress := s.locker.Status(dir)
+	if !cloneInProgress && !repoCloned(dir) && url != "" {
+		var err error
+		cloneProgress, err = s.cloneRepo(ctx, repo, url, nil)
+		if err != nil {
+			log15.Debug("error cloning repo", "repo", repo, "err", err)
+		} else {
+			cloneInProgress = true
+		}
+	}
+	if cloneInProgress || !repoCloned(dir) {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(&protocol.NotFoundPayload{
+			CloneInProgress: cloneInProgress,
+			CloneProgress:   cloneProgress,
+		})
+		return "", false
+	}
+	return dir, true
+}
+
+// commitsArgs returns the arguments of the git command which answers req.
+func commitsArgs(req *protocol.CommitsRequest) ([]string, error) {
+	if strings.HasPrefix(req.Range, "-") {
+		return nil, errors.Errorf("invalid commit range %q", req.Range)
+	}
+
+	args := []string{"log", commitsLogFormat}
+	if req.CountOnly {
+		args = []string{"rev-list", "--count"}
+	}
+	if req.N != 0 {
+		args = append(args, "-n", strconv.FormatUint(uint64(req.N), 10))
+	}
+	if req.Skip != 0 {
+		args = append(args, "--skip="+strconv.FormatUint(uint64(req.Skip), 10))
+	}
+	if req.Author != "" {
+		args = append(args, "--fixed-strings", "--author="+req.Author)
+	}
+	if req.After != "" {
+		args = append(args, "--after="+req.After)
+	}
+	if req.MessageQuery != "" {
+		args = append(args, "--fixed-strings", "--regexp-ignore-case", "--grep="+req.MessageQuery)
+	}
+	if req.Range != "" {
+		args = append(args, req.Range)
+	} else {
+		// rev-list requires a revision.
+		args = append(args, "HEAD")
+	}
+	args = append(args, "--")
+	if req.Path != "" {
+		args = append(args, req.Path)
+	}
+	return args, nil
+}
+
+// parseCommitsLog parses the output of git log with commitsLogFormat.
+func parseCommitsLog(data []byte) ([]*protocol.Commit, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	parts := bytes.Split(data, []byte{'\x00'})
+	// Each commit's fields are NUL-terminated, so the last part is empty.
+	if len(parts)%commitsLogFields != 1 {
+		return nil, errors.Errorf("invalid git log output: %d fields", len(parts)-1)
+	}
+
+	commits := make([]*protocol.Commit, 0, len(parts)/commitsLogFields)
+	for i := 0; i+commitsLogFields < len(parts); i += commitsLogFields {
+		p := parts[i : i+commitsLogFields]
+		authorTime, err := strconv.ParseInt(string(p[3]), 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing git commit author time")
+		}
+		committerTime, err := strconv.ParseInt(string(p[6]), 10, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing git commit committer time")
+		}
+
+		var parents []api.CommitID
+		if len(p[8]) > 0 {
+			for _, id := range bytes.Split(p[8], []byte{' '}) {
+				parents = append(parents, api.CommitID(id))
+			}
+		}
+
+		commits = append(commits, &protocol.Commit{
+			// Commits are separated by newlines.
+			ID:        api.CommitID(bytes.TrimPrefix(p[0], []byte{'\n'})),
+			Author:    protocol.Signature{Name: string(p[1]), Email: string(p[2]), Date: time.Unix(authorTime, 0).UTC()},
+			Committer: protocol.Signature{Name: string(p[4]), Email: string(p[5]), Date: time.Unix(committerTime, 0).UTC()},
+			Message:   string(bytes.TrimSuffix(p[7], []byte{'\n'})),
+			Parents:   parents,
+		})
+	}
+	return commits, nil
+}
+
+// runCommitGraphCommand runs git with args in dir. err is only set if git
+// couldn't be run or was killed; a failing git command is reported by
+// exitCode and stderr.
+func runCommitGraphCommand(ctx context.Context, dir string, args ...string) (stdout []byte, exitCode int, stderr string, err error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+	exitCode, err = runCommand(ctx, cmd)
+	if _, ok := err.(*exec.ExitError); ok && ctx.Err() == nil {
+		err = nil
+	}
+	return stdoutBuf.Bytes(), exitCode, strings.TrimSpace(stderrBuf.String()), err
+}
+
+// isRevisionNotFoundOutput reports whether stderr is the output of a git
+// command which failed because a revision doesn't exist.
+func isRevisionNotFoundOutput(stderr string) bool {
+	return strings.HasPrefix(stderr, "fatal: bad object ") ||
+		strings.HasPrefix(stderr, "fatal: bad revision ") ||
+		strings.HasPrefix(stderr, "fatal: Not a valid object name ") ||
+		strings.HasPrefix(stderr, "fatal: Not a valid commit name ") ||
+		strings.HasPrefix(stderr, "fatal: Invalid revision range ") ||
+		strings.Contains(stderr, "unknown revision or path not in the working tree")
+}
+
+func commitGraphError(w http.ResponseWriter, repo api.RepoName, args []string, err error, stderr string) {
+	if err == nil {
+		err = errors.Errorf("git command %v failed (output: %q)", args, stderr)
+	}
+	log15.Warn("commit graph request failed", "repo", repo, "args", args, "error", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeCommitGraphResponse(w http.ResponseWriter, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+func TestCommitsArgs(t *testing.T) {
+	tests := map[string]struct {
+		req  protocol.CommitsRequest
+		want []string
+	}{
+		"default": {
+			req:  protocol.CommitsRequest{},
+			want: []string{"log", commitsLogFormat, "HEAD", "--"},
+		},
+		"count": {
+			req:  protocol.CommitsRequest{Range: "a..b", N: 1, After: "1 week ago", CountOnly: true},
+			want: []string{"rev-list", "--count", "-n", "1", "--after=1 week ago", "a..b", "--"},
+		},
+		"path": {
+			req:  protocol.CommitsRequest{Range: "master", Skip: 2, Author: "alice", MessageQuery: "fix", Path: "a/b"},
+			want: []string{"log", commitsLogFormat, "--skip=2", "--fixed-strings", "--author=alice", "--fixed-strings", "--regexp-ignore-case", "--grep=fix", "master", "--", "a/b"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := commitsArgs(&test.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if _, err := commitsArgs(&protocol.CommitsRequest{Range: "--output=/tmp/x"}); err == nil {
+		t.Error("expected error for range starting with -")
+	}
+}
+
+func TestParseCommitsLog(t *testing.T) {
+	data := "b266c7e3ca00b1a17ad0b1449825d0854225c007\x00a\x00a@a.com\x001136214246\x00c\x00c@c.com\x001136214247\x00bar\n\x00ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8\x00\n" +
+		"ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8\x00a\x00a@a.com\x001136214245\x00a\x00a@a.com\x001136214245\x00foo\n\x00\x00"
+	got, err := parseCommitsLog([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*protocol.Commit{
+		{
+			ID:        "b266c7e3ca00b1a17ad0b1449825d0854225c007",
+			Author:    protocol.Signature{Name: "a", Email: "a@a.com", Date: time.Unix(1136214246, 0).UTC()},
+			Committer: protocol.Signature{Name: "c", Email: "c@c.com", Date: time.Unix(1136214247, 0).UTC()},
+			Message:   "bar",
+			Parents:   []api.CommitID{"ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
+		},
+		{
+			ID:        "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8",
+			Author:    protocol.Signature{Name: "a", Email: "a@a.com", Date: time.Unix(1136214245, 0).UTC()},
+			Committer: protocol.Signature{Name: "a", Email: "a@a.com", Date: time.Unix(1136214245, 0).UTC()},
+			Message:   "foo",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := parseCommitsLog([]byte("abc\x00a\x00")); err == nil {
+		t.Error("expected error for truncated log")
+	}
+}
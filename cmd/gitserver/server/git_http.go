@@ -0,0 +1,197 @@
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// handleGitUploadPack serves the read-only part of the git smart HTTP
+// protocol for the repositories on this gitserver, under /git/{repo}. It
+// supports GET /git/{repo}/info/refs?service=git-upload-pack and POST
+// /git/{repo}/git-upload-pack, so that `git clone` and `git fetch` work
+// against gitserver. Pushes are not supported. The frontend proxies users'
+// clones and fetches to this endpoint, so clients can only fetch what is
+// reachable from the refs of the repository (which is why it only speaks git
+// protocol v0 and v1), and the objects omitted from a partial clone are not
+// fetched from the code host.
+//
+// 🚨 SECURITY: This endpoint does no authorization, like the rest of
+// gitserver's API. It must only be reachable by other Sourcegraph services.
+func (s *Server) handleGitUploadPack(w http.ResponseWriter, r *http.Request) {
+	s.serveGitUploadPack(w, r, "/git/", false)
+}
+
+// handleReplicationGitUploadPack is like handleGitUploadPack, but serves the
+// replicas of the repositories on this gitserver under
+// /replication/git/{repo}, so that replicas don't fetch through the endpoint
+// which the frontend proxies users' clones and fetches to. Replicas may fetch
+// any object by its ID, and the objects omitted from a partial clone are
+// fetched from the code host, so that the replicas of a partial clone can
+// fetch them on demand.
+//
+// 🚨 SECURITY: This endpoint must never be proxied to users, because it lets
+// them fetch objects which are not reachable from the refs of a repository.
+func (s *Server) handleReplicationGitUploadPack(w http.ResponseWriter, r *http.Request) {
+	s.serveGitUploadPack(w, r, "/replication/git/", true)
+}
+
+func (s *Server) serveGitUploadPack(w http.ResponseWriter, r *http.Request, prefix string, replication bool) {
+	p := strings.TrimPrefix(r.URL.Path, prefix)
+
+	var (
+		repo          string
+		advertiseRefs bool
+	)
+	switch {
+	case strings.HasSuffix(p, "/info/refs") && r.Method == "GET":
+		if service := r.URL.Query().Get("service"); service != "git-upload-pack" {
+			http.Error(w, fmt.Sprintf("unsupported service %q", service), http.StatusForbidden)
+			return
+		}
+		repo = strings.TrimSuffix(p, "/info/refs")
+		advertiseRefs = true
+	case strings.HasSuffix(p, "/git-upload-pack") && r.Method == "POST":
+		repo = strings.TrimSuffix(p, "/git-upload-pack")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	dir := filepath.Join(s.ReposDir, string(protocol.NormalizeRepo(api.RepoName(repo))), ".git")
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		http.Error(w, "repository not found", http.StatusNotFound)
+		return
+	}
+
+	// Without GIT_NO_LAZY_FETCH, users could make this gitserver fetch the
+	// objects omitted from a partial clone from the code host.
+	if !replication && !s.noLazyFetchSupported {
+		if opts, err := readCloneOptions(r.Context(), dir); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if opts != nil && opts.Filter != "" {
+			http.Error(w, "serving partial clones requires git 2.44 or later on gitserver", http.StatusNotImplemented)
+			return
+		}
+	}
+
+	// Partial clones of the repository are allowed, e.g. by replicas of a
+	// repository which has a clone policy with a filter.
+	args := []string{"-c", "uploadpack.allowFilter=true"}
+	if replication {
+		args = append(args, "-c", "uploadpack.allowAnySHA1InWant=true")
+	}
+	args = append(args, "upload-pack", "--stateless-rpc")
+	if advertiseRefs {
+		args = append(args, "--advertise-refs")
+	}
+	args = append(args, dir)
+	cmd := exec.CommandContext(r.Context(), "git", args...)
+	// Protocol v2 is negotiated with the Git-Protocol header. It is only
+	// offered to replicas, because upload-pack serves any object a protocol
+	// v2 client asks for, reachable or not.
+	var gitProtocol string
+	if replication {
+		gitProtocol = r.Header.Get("Git-Protocol")
+	}
+	cmd.Env = os.Environ()
+	if replication {
+		// If this is a partial clone, fetch the objects the replica wants and
+		// we don't have from the code host.
+		cmd.Env = append(cmd.Env, "GIT_NO_LAZY_FETCH=0")
+	} else {
+		cmd.Env = append(cmd.Env, "GIT_NO_LAZY_FETCH=1")
+	}
+	if gitProtocol != "" {
+		cmd.Env = append(cmd.Env, "GIT_PROTOCOL="+gitProtocol)
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gzr.Close()
+		body = gzr
+	}
+	cmd.Stdin = body
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if advertiseRefs {
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		w.WriteHeader(http.StatusOK)
+		if !strings.Contains(gitProtocol, "version=2") {
+			// Protocol v0 and v1 advertisements start with the service.
+			if _, err := io.WriteString(w, pktLine("# service=git-upload-pack\n")+"0000"); err != nil {
+				return
+			}
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if fw := newFlushingResponseWriter(w); fw != nil {
+		defer fw.Close()
+		cmd.Stdout = fw
+	} else {
+		cmd.Stdout = w
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if _, err := runCommand(r.Context(), cmd); err != nil && r.Context().Err() == nil {
+		log15.Error("git upload-pack failed", "repo", repo, "error", err, "stderr", stderr.String())
+	}
+}
+
+// gitSupportsNoLazyFetch reports whether the installed git honors the
+// GIT_NO_LAZY_FETCH environment variable, which was added in git 2.44.
+func gitSupportsNoLazyFetch() bool {
+	out, err := exec.Command("git", "version").Output()
+	if err != nil {
+		log15.Error("failed to get git version", "error", err)
+		return false
+	}
+	major, minor, ok := parseGitVersion(string(out))
+	return ok && (major > 2 || (major == 2 && minor >= 44))
+}
+
+// parseGitVersion returns the major and minor version in the output of git
+// version, such as "git version 2.39.5" or "git version 2.44.0.windows.1".
+func parseGitVersion(out string) (major, minor int, ok bool) {
+	fields := strings.Fields(out)
+	if len(fields) < 3 || fields[0] != "git" || fields[1] != "version" {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(fields[2], ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// pktLine encodes s as a git protocol pkt-line.
+func pktLine(s string) string {
+	return fmt.Sprintf("%04x%s", len(s)+4, s)
+}
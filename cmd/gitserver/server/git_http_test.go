@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+)
+
+func TestHandleGitUploadPack(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	cmd := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s (output: %s)", name, strings.Join(arg, " "), err, b)
+		}
+		return string(b)
+	}
+
+	cmd(remote, "git", "init", ".")
+	cmd(remote, "sh", "-c", "echo hello world > hello.txt")
+	cmd(remote, "git", "add", "hello.txt")
+	cmd(remote, "git", "commit", "-m", "hello")
+	wantCommit := cmd(remote, "git", "rev-parse", "HEAD")
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", remote, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/git/", s.handleGitUploadPack)
+	mux.HandleFunc("/replication/git/", s.handleReplicationGitUploadPack)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dst, cleanup3 := tmpDir(t)
+	defer cleanup3()
+	for _, prefix := range []string{"/git/", "/replication/git/"} {
+		for _, version := range []string{"0", "2"} {
+			clone := filepath.Join(dst, strings.Trim(prefix, "/"), "v"+version)
+			cmd(dst, "git", "-c", "protocol.version="+version, "clone", srv.URL+prefix+"example.com/foo/bar", clone)
+			if gotCommit := cmd(clone, "git", "rev-parse", "HEAD"); gotCommit != wantCommit {
+				t.Errorf("%s protocol version %s: got HEAD %s, want %s", prefix, version, gotCommit, wantCommit)
+			}
+		}
+	}
+
+	// Users can't fetch objects which are not reachable from the refs, but
+	// replicas can.
+	repoDir := filepath.Join(reposDir, "example.com/foo/bar/.git")
+	unreachable := strings.TrimSpace(cmd(repoDir, "git", "commit-tree", "HEAD^{tree}", "-p", "HEAD", "-m", "unreachable"))
+	for prefix, wantOK := range map[string]bool{"/git/": false, "/replication/git/": true} {
+		for _, version := range []string{"0", "2"} {
+			clone := filepath.Join(dst, strings.Trim(prefix, "/"), "v"+version)
+			c := exec.Command("git", "-c", "protocol.version="+version, "fetch", srv.URL+prefix+"example.com/foo/bar", unreachable)
+			c.Dir = clone
+			if out, err := c.CombinedOutput(); (err == nil) != wantOK {
+				t.Errorf("%s protocol version %s: fetch of unreachable commit: got error %v (output: %s), want success %v", prefix, version, err, out, wantOK)
+			}
+		}
+	}
+
+	for path, want := range map[string]int{
+		"/git/example.com/foo/bar/info/refs?service=git-receive-pack":    http.StatusForbidden,
+		"/git/example.com/foo/missing/info/refs?service=git-upload-pack": http.StatusNotFound,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s: got status %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestParseGitVersion(t *testing.T) {
+	for _, test := range []struct {
+		out          string
+		major, minor int
+		ok           bool
+	}{
+		{out: "git version 2.39.5\n", major: 2, minor: 39, ok: true},
+		{out: "git version 2.44.0.windows.1\n", major: 2, minor: 44, ok: true},
+		{out: "git version 2.30.1 (Apple Git-130)", major: 2, minor: 30, ok: true},
+		{out: "git version 3.0", major: 3, minor: 0, ok: true},
+		{out: "hub version 2.44.0"},
+		{out: ""},
+	} {
+		major, minor, ok := parseGitVersion(test.out)
+		if major != test.major || minor != test.minor || ok != test.ok {
+			t.Errorf("parseGitVersion(%q): got %d, %d, %v, want %d, %d, %v", test.out, major, minor, ok, test.major, test.minor, test.ok)
+		}
+	}
+}
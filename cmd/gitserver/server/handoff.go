@@ -0,0 +1,371 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var repoHandedOffCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "repo_handed_off",
+	Help:      "number of repositories copied from the gitserver which owned them before instead of cloned",
+})
+
+func init() {
+	prometheus.MustRegister(repoHandedOffCounter)
+}
+
+// errHandoffNotCloned is returned by copyRepoFromPeer if the peer does not
+// have a clone of the repository.
+var errHandoffNotCloned = errors.New("repository is not cloned on peer")
+
+// handleRepoHandoff responds with a tar archive of the bare clone of a
+// repository, or with 404 if the repository is not cloned.
+func (s *Server) handleRepoHandoff(w http.ResponseWriter, r *http.Request) {
+	var req protocol.RepoHandoffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	repo := protocol.NormalizeRepo(req.Repo)
+	if !isRepoInReposDir(repo) {
+		http.Error(w, "invalid repository name", http.StatusBadRequest)
+		return
+	}
+
+	repoDir := filepath.Join(s.ReposDir, string(repo))
+	dir := filepath.Join(repoDir, ".git")
+	if _, cloneInProgress := s.locker.Status(repoDir); cloneInProgress {
+		http.Error(w, "repository not cloned", http.StatusNotFound)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		http.Error(w, "repository not cloned", http.StatusNotFound)
+		return
+	}
+
+	// Updates and git maintenance hold the update lock of the repository, so
+	// holding it while archiving gives a consistent copy of the repository.
+	mu := s.repoUpdateLock(repo).mu
+	mu.Lock()
+	defer mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	if err := writeRepoTar(w, dir); err != nil {
+		log15.Error("failed to hand off repository", "repo", req.Repo, "error", err)
+		// Abort the response so that the receiver doesn't mistake the
+		// truncated archive for a complete one.
+		panic(http.ErrAbortHandler)
+	}
+	log15.Info("handed off repository", "repo", req.Repo)
+}
+
+// handleRepoHandoffDone removes the clone of a repository which was handed off
+// to the gitserver which owns it now, once that gitserver has verified its copy.
+// The clone is kept if this gitserver is still assigned the repository, as its
+// owner or one of its replicas (e.g. the addresses are being updated).
+func (s *Server) handleRepoHandoffDone(w http.ResponseWriter, r *http.Request) {
+	var req protocol.RepoHandoffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	repo := protocol.NormalizeRepo(req.Repo)
+	if !isRepoInReposDir(repo) {
+		http.Error(w, "invalid repository name", http.StatusBadRequest)
+		return
+	}
+
+	if s.isAssigned(repo) {
+		log15.Info("kept handed off repository which is assigned to this gitserver", "repo", req.Repo)
+		return
+	}
+	if _, cloneInProgress := s.locker.Status(filepath.Join(s.ReposDir, string(repo))); cloneInProgress {
+		log15.Info("kept handed off repository which is being cloned", "repo", req.Repo)
+		return
+	}
+
+	// Updates and git maintenance hold the update lock of the repository, so
+	// it isn't removed while they use it.
+	mu := s.repoUpdateLock(repo).mu
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := s.deleteRepo(repo); err != nil && !os.IsNotExist(err) {
+		log15.Error("failed to remove handed off repository", "repo", req.Repo, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log15.Info("removed handed off repository", "repo", req.Repo)
+}
+
+// isAssigned reports whether this gitserver owns repo or is one of its
+// replicas (see gitserver.ReplicaAddrs). It reports true if the addresses of
+// the gitservers are unknown.
+func (s *Server) isAssigned(repo api.RepoName) bool {
+	if s.GitServerAddrs == nil || s.Hostname == "" {
+		return true
+	}
+
+	replicationFactor := 1
+	if s.ReplicationFactor != nil {
+		replicationFactor = s.ReplicationFactor()
+	}
+	for _, addr := range gitserver.ReplicaAddrs(string(repo), s.GitServerAddrs(), replicationFactor) {
+		if s.isAddr(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRepoInReposDir reports whether the normalized repository name repo is a
+// relative path which stays inside ReposDir.
+func isRepoInReposDir(repo api.RepoName) bool {
+	name := string(repo)
+	return name != "" && name != "." && name != ".." && !path.IsAbs(name) && !strings.HasPrefix(name, "../")
+}
+
+// handoffPeers returns the addresses of the gitservers which may have a clone
+// of repo because they owned it before this gitserver. With rendezvous
+// hashing (see gitserver.AddrForKey) that is the gitserver ranked after this
+// one, if this gitserver was added recently. The owner under the hashing
+// scheme used before rendezvous hashing is included as well.
+func (s *Server) handoffPeers(repo api.RepoName) []string {
+	if s.GitServerAddrs == nil || s.Hostname == "" {
+		return nil
+	}
+
+	key := string(protocol.NormalizeRepo(repo))
+	addrs := s.GitServerAddrs()
+	ranked := gitserver.RankedAddrs(key, addrs)
+	if len(ranked) < 2 || !s.isAddr(ranked[0]) {
+		// Either there is no other gitserver or we don't own repo (e.g. the
+		// addresses are being updated).
+		return nil
+	}
+
+	peers := []string{ranked[1]}
+	if legacy := gitserver.LegacyAddrForKey(key, addrs); legacy != ranked[1] && !s.isAddr(legacy) {
+		peers = append(peers, legacy)
+	}
+	return peers
+}
+
+// isAddr reports whether addr is the address of this gitserver. Addresses are
+// matched by hostname, e.g. "gitserver-0.gitserver:3178" is the address of
+// the gitserver with hostname "gitserver-0".
+func (s *Server) isAddr(addr string) bool {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	return host == s.Hostname || strings.HasPrefix(host, s.Hostname+".")
+}
+
+// copyRepoFromPeers copies the bare clone of repo into the git directory dir
+// from the first of its handoff peers which has it. It returns the address of
+// the peer the repository was copied from, or "" if it wasn't copied, in which
+// case the repository must be cloned from its code host.
+func (s *Server) copyRepoFromPeers(ctx context.Context, repo api.RepoName, dir string) string {
+	for _, peer := range s.handoffPeers(repo) {
+		err := copyRepoFromPeer(ctx, peer, repo, dir)
+		if err == nil {
+			log15.Info("copied repo from previous gitserver", "repo", repo, "peer", peer)
+			repoHandedOffCounter.Inc()
+			return peer
+		}
+		if err != errHandoffNotCloned {
+			log15.Warn("failed to copy repo from previous gitserver", "repo", repo, "peer", peer, "error", err)
+		}
+		// Remove what may have been copied before the failure.
+		if err := os.RemoveAll(dir); err != nil {
+			log15.Error("failed to remove partially copied repo", "repo", repo, "dir", dir, "error", err)
+			return ""
+		}
+	}
+	return ""
+}
+
+// releaseRepoOnPeer asks the gitserver peer, from which repo was copied, to
+// remove its clone of repo, once the copy is in place. It doesn't wait for
+// the peer.
+func (s *Server) releaseRepoOnPeer(peer string, repo api.RepoName) {
+	go func() {
+		ctx, cancel := s.serverContext()
+		defer cancel()
+
+		resp, err := postHandoffRequest(ctx, peer, "/repo-handoff-done", repo)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.Errorf("repo-handoff-done: http status %d", resp.StatusCode)
+			}
+		}
+		if err != nil {
+			log15.Warn("failed to remove repo from previous gitserver", "repo", repo, "peer", peer, "error", err)
+		}
+	}()
+}
+
+// postHandoffRequest sends a RepoHandoffRequest for repo to the endpoint at
+// path of the gitserver peer.
+func postHandoffRequest(ctx context.Context, peer, path string, repo api.RepoName) (*http.Response, error) {
+	b, err := json.Marshal(&protocol.RepoHandoffRequest{Repo: repo})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", "http://"+peer+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return http.DefaultClient.Do(req.WithContext(ctx))
+}
+
+func copyRepoFromPeer(ctx context.Context, peer string, repo api.RepoName, dir string) error {
+	resp, err := postHandoffRequest(ctx, peer, "/repo-handoff", repo)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errHandoffNotCloned
+	default:
+		return errors.Errorf("repo-handoff: http status %d", resp.StatusCode)
+	}
+
+	if err := extractRepoTar(resp.Body, dir); err != nil {
+		return err
+	}
+	return checkRepoCopy(ctx, dir)
+}
+
+// checkRepoCopy returns an error if the git directory dir copied from a peer
+// is not a complete repository, so that it is cloned from its code host
+// instead.
+func checkRepoCopy(ctx context.Context, dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return errors.Wrap(err, "copied repository is not a git directory")
+	}
+	// Check that all the objects reachable from the refs are present.
+	cmd := exec.CommandContext(ctx, "git", "fsck", "--connectivity-only", "--no-dangling", "--no-progress")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "copied repository is incomplete: %s", out)
+	}
+	return nil
+}
+
+// writeRepoTar writes a tar archive of the git directory dir to w. The caller
+// must hold the update lock of the repository, so that it isn't modified
+// while it is archived.
+func writeRepoTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		return writeTarEntry(tw, dir, path, fi)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// writeTarEntry writes the file or directory at path to tw, named relative to
+// dir. Other kinds of files are skipped.
+func writeTarEntry(tw *tar.Writer, dir, path string, fi os.FileInfo) error {
+	if !fi.Mode().IsRegular() && !fi.IsDir() {
+		return nil
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(rel)
+	if fi.IsDir() {
+		return tw.WriteHeader(hdr)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// extractRepoTar extracts a tar archive written by writeRepoTar into dir.
+func extractRepoTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return errors.Errorf("invalid path in repository archive: %q", hdr.Name)
+		}
+		path := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if err1 := f.Close(); err == nil {
+				err = err1
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+)
+
+func TestHandoffPeers(t *testing.T) {
+	addrs := []string{"gitserver-0.gitserver:3178", "gitserver-1.gitserver:3178", "gitserver-2.gitserver:3178"}
+	for i := 0; i < 100; i++ {
+		repo := "github.com/foo/repo" + strings.Repeat("x", i)
+		ranked := gitserver.RankedAddrs(repo, addrs)
+		owner := strings.SplitN(ranked[0], ".", 2)[0]
+
+		s := &Server{Hostname: owner, GitServerAddrs: func() []string { return addrs }}
+		peers := s.handoffPeers(api.RepoName(repo))
+		if len(peers) == 0 || peers[0] != ranked[1] {
+			t.Fatalf("%s: got peers %v, want %s first", repo, peers, ranked[1])
+		}
+		for _, peer := range peers {
+			if s.isAddr(peer) {
+				t.Fatalf("%s: got own address %s in peers %v", repo, peer, peers)
+			}
+		}
+
+		// Only the owner of a repo asks for it to be handed off.
+		notOwner := &Server{Hostname: strings.SplitN(ranked[1], ".", 2)[0], GitServerAddrs: s.GitServerAddrs}
+		if peers := notOwner.handoffPeers(api.RepoName(repo)); len(peers) != 0 {
+			t.Fatalf("%s: got peers %v for gitserver which doesn't own the repo", repo, peers)
+		}
+	}
+}
+
+func TestRepoTar(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "repo-tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	files := map[string]string{
+		"HEAD":                 "ref: refs/heads/master\n",
+		"config":               "[core]\n\tbare = true\n",
+		"packed-refs":          "",
+		"refs/heads/master":    "4b825dc642cb6eb9a060e54bf8d69288fbee4904\n",
+		"objects/4b/825dc642c": "object",
+		"objects/pack/a.pack":  "pack",
+	}
+	src := filepath.Join(tmp, "src")
+	for name, data := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeRepoTar(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(tmp, "dst")
+	if err := extractRepoTar(&buf, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	err = filepath.Walk(dst, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dst, path)
+		got[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, files) {
+		t.Errorf("got files %v, want %v", got, files)
+	}
+}
+
+func TestCopyRepoFromPeer(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+
+	cmd := func(dir, name string, arg ...string) {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = []string{
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		}
+		if b, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("%s %s failed: %s (output: %s)", name, strings.Join(arg, " "), err, b)
+		}
+	}
+
+	cmd(remote, "git", "init", ".")
+	cmd(remote, "sh", "-c", "echo hello world > hello.txt")
+	cmd(remote, "git", "add", "hello.txt")
+	cmd(remote, "git", "commit", "-m", "hello")
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", remote, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(s.handleRepoHandoff))
+	defer srv.Close()
+	peer := strings.TrimPrefix(srv.URL, "http://")
+
+	dst, cleanup3 := tmpDir(t)
+	defer cleanup3()
+
+	ctx := context.Background()
+	if err := copyRepoFromPeer(ctx, peer, "example.com/foo/bar", filepath.Join(dst, "ok")); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyRepoFromPeer(ctx, peer, "../foo", filepath.Join(dst, "invalid")); err == nil {
+		t.Error("expected error when copying a repository outside of ReposDir")
+	}
+
+	// A copy which lacks the objects of its refs must be rejected.
+	if err := os.RemoveAll(filepath.Join(reposDir, "example.com/foo/bar/.git/objects")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(reposDir, "example.com/foo/bar/.git/objects"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyRepoFromPeer(ctx, peer, "example.com/foo/bar", filepath.Join(dst, "incomplete")); err == nil {
+		t.Error("expected error when copying an incomplete repository")
+	}
+}
+
+func TestHandleRepoHandoffDone(t *testing.T) {
+	addrs := []string{"gitserver-0.gitserver:3178", "gitserver-1.gitserver:3178"}
+	repo := api.RepoName("example.com/foo/bar")
+	owner := gitserver.RankedAddrs(string(repo), addrs)[0]
+
+	for _, test := range []struct {
+		hostname string
+		removed  bool
+	}{
+		{hostname: strings.Split(owner, ".")[0], removed: false},
+		{hostname: strings.Split(gitserver.RankedAddrs(string(repo), addrs)[1], ".")[0], removed: true},
+	} {
+		reposDir, cleanup := tmpDir(t)
+		defer cleanup()
+
+		gitDir := filepath.Join(reposDir, string(repo), ".git")
+		if err := os.MkdirAll(gitDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/master\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		s := &Server{
+			ReposDir:       reposDir,
+			Hostname:       test.hostname,
+			GitServerAddrs: func() []string { return addrs },
+			ctx:            context.Background(),
+			locker:         &RepositoryLocker{},
+		}
+		w := httptest.NewRecorder()
+		s.handleRepoHandoffDone(w, httptest.NewRequest("POST", "/repo-handoff-done", strings.NewReader(`{"Repo":"example.com/foo/bar"}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want %d (body: %s)", test.hostname, w.Code, http.StatusOK, w.Body.String())
+		}
+
+		_, err := os.Stat(gitDir)
+		if removed := os.IsNotExist(err); removed != test.removed {
+			t.Errorf("%s: got removed %v, want %v", test.hostname, removed, test.removed)
+		}
+	}
+}
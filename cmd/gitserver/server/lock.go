@@ -25,12 +25,28 @@ type RepositoryLocker struct {
 	// status tracks directories that are locked. The value is the status. If
 	// a directory is in status, the directory is locked.
 	status map[string]string
+	// background tracks the directories in status which are locked by
+	// TryAcquireBackground.
+	background map[string]bool
 }
 
 // TryAcquire acquires the lock for dir. If it is already held, ok is false
 // and lock is nil. Otherwise a non-nil lock is returned and true. When
 // finished with the lock you must call lock.Release.
 func (rl *RepositoryLocker) TryAcquire(dir string, initialStatus string) (lock *RepositoryLock, ok bool) {
+	return rl.tryAcquire(dir, initialStatus, false)
+}
+
+// TryAcquireBackground is like TryAcquire, but for background tasks which
+// don't prevent other commands from reading the repository, such as git
+// maintenance. Status does not report dir as locked while the lock is held,
+// so the repository stays available, but TryAcquire fails, so the repository
+// is not cloned over.
+func (rl *RepositoryLocker) TryAcquireBackground(dir string, initialStatus string) (lock *RepositoryLock, ok bool) {
+	return rl.tryAcquire(dir, initialStatus, true)
+}
+
+func (rl *RepositoryLocker) tryAcquire(dir string, initialStatus string, background bool) (lock *RepositoryLock, ok bool) {
 	dir = rl.normalize(dir)
 
 	rl.mu.Lock()
@@ -40,6 +56,12 @@ func (rl *RepositoryLocker) TryAcquire(dir string, initialStatus string) (lock *
 			rl.status = make(map[string]string)
 		}
 		rl.status[dir] = initialStatus
+		if background {
+			if rl.background == nil {
+				rl.background = make(map[string]bool)
+			}
+			rl.background[dir] = true
+		}
 	}
 	rl.mu.Unlock()
 
@@ -54,12 +76,15 @@ func (rl *RepositoryLocker) TryAcquire(dir string, initialStatus string) (lock *
 }
 
 // Status returns the status of the locked directory dir. If dir is not
-// locked, then locked is false.
+// locked, or is locked by TryAcquireBackground, then locked is false.
 func (rl *RepositoryLocker) Status(dir string) (status string, locked bool) {
 	dir = rl.normalize(dir)
 
 	rl.mu.Lock()
 	status, locked = rl.status[dir]
+	if rl.background[dir] {
+		status, locked = "", false
+	}
 	rl.mu.Unlock()
 	return
 }
@@ -105,6 +130,7 @@ func (l *RepositoryLock) Release() {
 	// Prevent double release
 	if !l.done {
 		delete(l.locker.status, l.dir)
+		delete(l.locker.background, l.dir)
 		l.done = true
 	}
 	l.locker.mu.Unlock()
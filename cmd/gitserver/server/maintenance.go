@@ -0,0 +1,302 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var (
+	maintenanceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_duration_seconds",
+		Help:      "git maintenance task latencies in seconds.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+	}, []string{"task", "status"})
+	maintenanceSkipped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_skipped",
+		Help:      "number of times git maintenance of a repo was skipped because the repo was being cloned",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(maintenanceDuration)
+	prometheus.MustRegister(maintenanceSkipped)
+}
+
+// The thresholds of the repository statistics above which the maintenance
+// tasks run. They are variables so tests can lower them.
+var (
+	// maintenanceLooseObjects is the number of loose objects above which
+	// git gc runs. It is also passed to git gc as gc.auto.
+	maintenanceLooseObjects = 1000
+
+	// maintenancePacks is the number of packs above which the packs are
+	// repacked.
+	maintenancePacks = 10
+
+	// maintenanceCommitGraphLayers is the number of layers of a split
+	// commit-graph above which the layers are merged.
+	maintenanceCommitGraphLayers = 8
+
+	// maintenanceMinInterval is the minimum time between two runs of a
+	// maintenance task in the same repository. It keeps the janitor from
+	// rerunning a task which can't bring a repository below its threshold
+	// (e.g. because git gc doesn't prune recent unreachable objects).
+	maintenanceMinInterval = time.Hour
+)
+
+// maintenanceStats are the statistics of a repository which determine which
+// maintenance tasks it needs.
+type maintenanceStats struct {
+	LooseObjects      int  // number of loose objects
+	Packs             int  // number of packfiles
+	Bitmap            bool // whether there is a reachability bitmap
+	Partial           bool // whether the repository is a partial clone
+	CommitGraphLayers int  // number of commit-graph files, 0 if none
+}
+
+// maintenanceTask is a git maintenance task which the janitor runs in a
+// repository when its statistics call for it.
+type maintenanceTask struct {
+	// Name identifies the task in metrics, logs and the git config key which
+	// records when it last ran.
+	Name string
+
+	// Needed reports whether a repository with the given statistics needs
+	// the task.
+	Needed func(*maintenanceStats) bool
+
+	// Args returns the arguments of the git command which performs the task
+	// in a repository with the given statistics.
+	Args func(*maintenanceStats) []string
+}
+
+// maintenanceTasks are the git maintenance tasks, in the order they run.
+var maintenanceTasks = []*maintenanceTask{
+	{
+		// Packs loose objects, prunes old unreachable objects and packs
+		// refs. The repack task below takes care of the packs, so git gc
+		// only considers loose objects. It does not run in the background,
+		// so that it finishes before the locks are released.
+		Name:   "gc",
+		Needed: func(st *maintenanceStats) bool { return st.LooseObjects > maintenanceLooseObjects },
+		Args: func(*maintenanceStats) []string {
+			return []string{
+				"-c", "gc.auto=" + strconv.Itoa(maintenanceLooseObjects),
+				"-c", "gc.autoPackLimit=0",
+				"-c", "gc.autoDetach=false",
+				"-c", "gc.writeCommitGraph=false",
+				"gc", "--auto", "--quiet",
+			}
+		},
+	},
+	{
+		// Combines the packs into a geometric progression of packs, so that
+		// only the small packs of recent fetches are rewritten, and writes a
+		// multi-pack index with a reachability bitmap, which speeds up git
+		// upload-pack and counting objects.
+		Name: "repack",
+		Needed: func(st *maintenanceStats) bool {
+			return st.Packs > maintenancePacks || (st.Packs > 0 && !st.Bitmap)
+		},
+		Args: func(st *maintenanceStats) []string {
+			if st.Partial {
+				// git can't repack a partial clone geometrically. Most blobs
+				// of a partial clone are missing, so a full repack is cheap.
+				return []string{"repack", "-a", "-d", "-l", "--write-midx", "--write-bitmap-index", "--quiet"}
+			}
+			return []string{"repack", "-d", "-l", "--geometric=2", "--write-midx", "--write-bitmap-index", "--quiet"}
+		},
+	},
+	{
+		// writeCommitGraph adds a layer to the commit-graph after every
+		// fetch. This merges the layers, and writes a commit-graph for
+		// repositories cloned before gitserver wrote them.
+		Name: "commit-graph",
+		Needed: func(st *maintenanceStats) bool {
+			return st.CommitGraphLayers == 0 || st.CommitGraphLayers > maintenanceCommitGraphLayers
+		},
+		Args: func(*maintenanceStats) []string {
+			return []string{"commit-graph", "write", "--reachable", "--changed-paths", "--split=replace"}
+		},
+	},
+}
+
+// maintainRepo runs the maintenance tasks which the repository in gitDir
+// needs and which didn't run in the last maintenanceMinInterval. It holds the
+// repository's update lock, so that it never runs concurrently with a fetch,
+// and a background lock of s.locker, so that the repository is not cloned
+// over. The repository can be read while it is maintained.
+func (s *Server) maintainRepo(ctx context.Context, repo api.RepoName, gitDir string) error {
+	st, err := readMaintenanceStats(gitDir)
+	if err != nil {
+		return err
+	}
+	lastRun, err := maintenanceLastRun(ctx, gitDir)
+	if err != nil {
+		return err
+	}
+	var tasks []*maintenanceTask
+	for _, t := range maintenanceTasks {
+		if t.Needed(st) && time.Since(lastRun[t.Name]) >= maintenanceMinInterval {
+			tasks = append(tasks, t)
+		}
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	lock, ok := s.locker.TryAcquireBackground(gitDir, "running git maintenance")
+	if !ok {
+		// The repository is being cloned, so try again next time.
+		maintenanceSkipped.Inc()
+		return nil
+	}
+	defer lock.Release()
+	mu := s.repoUpdateLock(repo).mu
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, t := range tasks {
+		lock.SetStatus("running git maintenance: " + t.Name)
+		if err := runMaintenanceTask(ctx, repo, gitDir, t, st); err != nil {
+			return err
+		}
+	}
+
+	if _, err := setRepoSize(gitDir); err != nil {
+		log15.Warn("Failed to update repo size after git maintenance", "repo", repo, "error", err)
+	}
+	return nil
+}
+
+// runMaintenanceTask runs the maintenance task t in the repository in gitDir
+// and records when it ran.
+func runMaintenanceTask(ctx context.Context, repo api.RepoName, gitDir string, t *maintenanceTask, st *maintenanceStats) error {
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "git", t.Args(st)...)
+	cmd.Dir = gitDir
+	output, err := cmd.CombinedOutput()
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	maintenanceDuration.WithLabelValues(t.Name, status).Observe(time.Since(start).Seconds())
+
+	// The run is recorded even if it failed, so that a failing task does not
+	// run on every janitor run.
+	if err := runGit(ctx, gitDir, "config", maintenanceLastRunKey(t.Name), strconv.FormatInt(start.Unix(), 10)); err != nil {
+		log15.Warn("Failed to record git maintenance run", "repo", repo, "task", t.Name, "error", err)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "git maintenance task %s failed. Output: %s", t.Name, string(output))
+	}
+	log15.Debug("ran git maintenance task", "repo", repo, "task", t.Name, "duration", time.Since(start))
+	return nil
+}
+
+// maintenanceLastRunKey is the git config key which records when the
+// maintenance task named name last ran in a repository, in Unix seconds.
+func maintenanceLastRunKey(name string) string {
+	return "sourcegraph.maintenance." + name
+}
+
+// maintenanceLastRun returns when each maintenance task last ran in the
+// repository in gitDir, by task name.
+func maintenanceLastRun(ctx context.Context, gitDir string) (map[string]time.Time, error) {
+	cmd := exec.CommandContext(ctx, "git", "config", "--local", "--get-regexp", `^sourcegraph\.maintenance\.`)
+	cmd.Dir = gitDir
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	lastRun := map[string]time.Time{}
+	if exitCode, err := runCommand(ctx, cmd); exitCode == 1 {
+		// No keys match.
+		return lastRun, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read git maintenance runs")
+	}
+
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), " ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		sec, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		lastRun[strings.TrimPrefix(kv[0], maintenanceLastRunKey(""))] = time.Unix(sec, 0)
+	}
+	return lastRun, scanner.Err()
+}
+
+// readMaintenanceStats returns the maintenance statistics of the repository
+// in gitDir. It only reads directories, so that it is cheap enough for the
+// janitor to call for every repository on every run.
+func readMaintenanceStats(gitDir string) (*maintenanceStats, error) {
+	var st maintenanceStats
+	objects := filepath.Join(gitDir, "objects")
+
+	// Loose objects are stored in objects/xx/, where xx are the first two
+	// hex digits of their IDs.
+	const hex = "0123456789abcdef"
+	for _, a := range hex {
+		for _, b := range hex {
+			entries, err := ioutil.ReadDir(filepath.Join(objects, string(a)+string(b)))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				if len(e.Name()) == 38 {
+					st.LooseObjects++
+				}
+			}
+		}
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(objects, "pack"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".pack":
+			st.Packs++
+		case ".bitmap":
+			st.Bitmap = true
+		case ".promisor":
+			st.Partial = true
+		}
+	}
+
+	chain, err := ioutil.ReadFile(filepath.Join(objects, "info", "commit-graphs", "commit-graph-chain"))
+	if err == nil {
+		st.CommitGraphLayers = len(strings.Fields(string(chain)))
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	} else if _, err := os.Stat(filepath.Join(objects, "info", "commit-graph")); err == nil {
+		st.CommitGraphLayers = 1
+	}
+
+	return &st, nil
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintainRepo(t *testing.T) {
+	defer func(loose, packs int) {
+		maintenanceLooseObjects, maintenancePacks = loose, packs
+	}(maintenanceLooseObjects, maintenancePacks)
+	maintenanceLooseObjects = 1
+	maintenancePacks = 2
+
+	reposDir, cleanup := tmpDir(t)
+	defer cleanup()
+	dir := filepath.Join(reposDir, "example.com/foo/bar")
+	gitDir := filepath.Join(dir, ".git")
+
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = gitDir
+		cmd.Env = append(cmd.Env, "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@a.com", "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@a.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s (%s)", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if out, err := exec.Command("git", "init", "--bare", gitDir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s (%s)", err, out)
+	}
+	// Each iteration adds a pack with a commit, and a loose blob.
+	emptyTree := git("hash-object", "-w", "-t", "tree", "/dev/null")
+	for i := 0; i < 4; i++ {
+		args := []string{"commit-tree", "-m", fmt.Sprint(i), emptyTree}
+		if i > 0 {
+			args = append(args, "-p", "master")
+		}
+		git("update-ref", "refs/heads/master", git(args...))
+		git("repack", "-d", "-q")
+
+		blob := filepath.Join(reposDir, "blob")
+		if err := ioutil.WriteFile(blob, []byte(fmt.Sprint(i)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		git("hash-object", "-w", blob)
+	}
+
+	st, err := readMaintenanceStats(gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (maintenanceStats{LooseObjects: 4, Packs: 4}); *st != want {
+		t.Fatalf("got stats %+v, want %+v", *st, want)
+	}
+
+	s := &Server{ReposDir: reposDir, locker: &RepositoryLocker{}}
+
+	// Nothing runs while the repository is being cloned.
+	lock, _ := s.locker.TryAcquire(dir, "cloning")
+	if err := s.maintainRepo(context.Background(), "example.com/foo/bar", gitDir); err != nil {
+		t.Fatal(err)
+	}
+	lock.Release()
+	if lastRun, err := maintenanceLastRun(context.Background(), gitDir); err != nil || len(lastRun) != 0 {
+		t.Fatalf("got last runs %v, %v, want none", lastRun, err)
+	}
+
+	start := time.Now().Add(-time.Second)
+	if err := s.maintainRepo(context.Background(), "example.com/foo/bar", gitDir); err != nil {
+		t.Fatal(err)
+	}
+	st, err = readMaintenanceStats(gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Packs > maintenancePacks || !st.Bitmap || st.CommitGraphLayers != 1 {
+		t.Errorf("got stats %+v after maintenance", *st)
+	}
+	lastRun, err := maintenanceLastRun(context.Background(), gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range maintenanceTasks {
+		if lastRun[task.Name].Before(start) {
+			t.Errorf("task %s: got last run %s, want after %s", task.Name, lastRun[task.Name], start)
+		}
+	}
+	if _, locked := s.locker.Status(dir); locked {
+		t.Error("expected the repository to be unlocked after maintenance")
+	}
+}
+
+func TestRepositoryLocker_background(t *testing.T) {
+	var rl RepositoryLocker
+	lock, ok := rl.TryAcquireBackground("/repos/a/.git", "maintenance")
+	if !ok {
+		t.Fatal("could not acquire background lock")
+	}
+	if _, locked := rl.Status("/repos/a"); locked {
+		t.Error("a background lock should not be reported by Status")
+	}
+	if _, ok := rl.TryAcquire("/repos/a", "clone"); ok {
+		t.Error("TryAcquire should fail while a background lock is held")
+	}
+	lock.Release()
+	lock, ok = rl.TryAcquire("/repos/a", "clone")
+	if !ok {
+		t.Fatal("could not acquire lock after releasing the background lock")
+	}
+	if status, locked := rl.Status("/repos/a"); !locked || status != "clone" {
+		t.Errorf("got status %q, %v, want %q, true", status, locked, "clone")
+	}
+	lock.Release()
+}
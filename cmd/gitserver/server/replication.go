@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var replicationErrorCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "replication_errors",
+	Help:      "number of failed requests to replicas to fetch a repository from its primary gitserver",
+})
+
+func init() {
+	prometheus.MustRegister(replicationErrorCounter)
+}
+
+// replicaAddrs returns the address of this gitserver and the addresses of the
+// replicas of repo if this gitserver is the primary of repo (see
+// gitserver.ReplicaAddrs). Otherwise it returns no replicas.
+func (s *Server) replicaAddrs(repo api.RepoName) (self string, replicas []string) {
+	if s.GitServerAddrs == nil || s.ReplicationFactor == nil || s.Hostname == "" {
+		return "", nil
+	}
+	addrs := gitserver.ReplicaAddrs(string(protocol.NormalizeRepo(repo)), s.GitServerAddrs(), s.ReplicationFactor())
+	if len(addrs) < 2 || !s.isAddr(addrs[0]) {
+		return "", nil
+	}
+	return addrs[0], addrs[1:]
+}
+
+// replicateRepo asks the replicas of repo to fetch it from this gitserver, if
+// this gitserver is its primary. Replicas clone the repository from the
+// primary's /replication/git endpoint if they don't have it yet. It doesn't
+// wait for the replicas.
+func (s *Server) replicateRepo(repo api.RepoName) {
+	self, replicas := s.replicaAddrs(repo)
+	if len(replicas) == 0 {
+		return
+	}
+
+	repo = protocol.NormalizeRepo(repo)
+	req := &protocol.RepoUpdateRequest{
+		Repo: repo,
+		URL:  "http://" + self + "/replication/git/" + string(repo),
+	}
+	// Replicas which don't have repo yet clone it like the primary did.
+	ctx, cancel := s.serverContext()
+	defer cancel()
+	opts, err := readCloneOptions(ctx, path.Join(s.ReposDir, string(repo)))
+	if err != nil {
+		log15.Warn("Failed to read clone options", "repo", repo, "error", err)
+	}
+	req.CloneOptions = opts
+	for _, addr := range replicas {
+		go func(addr string) {
+			ctx, cancel1 := s.serverContext()
+			defer cancel1()
+			ctx, cancel2 := context.WithTimeout(ctx, longGitCommandTimeout)
+			defer cancel2()
+
+			if err := requestReplicaUpdate(ctx, addr, req); err != nil {
+				log15.Warn("failed to update replica", "repo", repo, "replica", addr, "error", err)
+				replicationErrorCounter.Inc()
+			}
+		}(addr)
+	}
+}
+
+// requestReplicaUpdate sends req to the /repo-update endpoint of the replica
+// at addr.
+func requestReplicaUpdate(ctx context.Context, addr string, req *protocol.RepoUpdateRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest("POST", "http://"+addr+"/repo-update", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("repo-update: http status %d", resp.StatusCode)
+	}
+
+	var res protocol.RepoUpdateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	if res.Error != "" {
+		return errors.New(res.Error)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+func TestReplicaAddrs(t *testing.T) {
+	addrs := []string{"gitserver-0.gitserver:3178", "gitserver-1.gitserver:3178", "gitserver-2.gitserver:3178"}
+	ranked := gitserver.RankedAddrs("github.com/foo/bar", addrs)
+	hostname := func(addr string) string { return strings.SplitN(addr, ".", 2)[0] }
+
+	tests := []struct {
+		name              string
+		hostname          string
+		replicationFactor int
+		wantSelf          string
+		wantReplicas      []string
+	}{
+		{"primary", hostname(ranked[0]), 2, ranked[0], ranked[1:2]},
+		{"primary, all replicated", hostname(ranked[0]), 5, ranked[0], ranked[1:]},
+		{"primary, not replicated", hostname(ranked[0]), 1, "", nil},
+		{"replica", hostname(ranked[1]), 3, "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Server{
+				Hostname:          test.hostname,
+				GitServerAddrs:    func() []string { return addrs },
+				ReplicationFactor: func() int { return test.replicationFactor },
+			}
+			self, replicas := s.replicaAddrs("github.com/foo/bar")
+			if self != test.wantSelf || !reflect.DeepEqual(replicas, test.wantReplicas) {
+				t.Errorf("got %q, %v, want %q, %v", self, replicas, test.wantSelf, test.wantReplicas)
+			}
+		})
+	}
+}
@@ -52,6 +52,15 @@ func (s *Server) repoInfo(ctx context.Context, repo api.RepoName) (*protocol.Rep
 		} else {
 			resp.LastChanged = &lastChanged
 		}
+
+		if size, err := repoSize(filepath.Join(dir, ".git")); err != nil {
+			log15.Warn("error getting repo size", "repo", repo, "err", err)
+		} else {
+			resp.Size = size
+		}
+	} else if size := s.tooLargeRepoSize(repo); size > 0 {
+		resp.TooLarge = true
+		resp.Size = size
 	}
 	return &resp, nil
 }
@@ -91,6 +91,10 @@ func TestServer_handleRepoInfo(t *testing.T) {
 		repoRemoteURL = func(context.Context, string) (string, error) { return "u", nil }
 		defer func() { repoRemoteURL = origRepoRemoteURL }()
 
+		origRepoSize := repoSize
+		repoSize = func(gitDir string) (int64, error) { return 1234, nil }
+		defer func() { repoSize = origRepoSize }()
+
 		want := protocol.RepoInfoResponse{
 			Results: map[api.RepoName]*protocol.RepoInfo{
 				"x": {
@@ -98,6 +102,7 @@ func TestServer_handleRepoInfo(t *testing.T) {
 					LastFetched: &lastFetched,
 					LastChanged: &lastChanged,
 					URL:         "u",
+					Size:        1234,
 				},
 			},
 		}
@@ -106,6 +111,39 @@ func TestServer_handleRepoInfo(t *testing.T) {
 		}
 	})
 
+	t.Run("too large", func(t *testing.T) {
+		origRepoCloned := repoCloned
+		repoCloned = func(dir string) bool { return false }
+		defer func() { repoCloned = origRepoCloned }()
+
+		reposDir, cleanup := tmpDir(t)
+		defer cleanup()
+		s.ReposDir = reposDir
+		defer func() { s.ReposDir = "/testroot" }()
+
+		max := int64(100)
+		s.MaxRepoSizeBytes = func() int64 { return max }
+		defer func() { s.MaxRepoSizeBytes = nil }()
+		s.setRepoTooLarge("x", 200)
+
+		want := protocol.RepoInfoResponse{
+			Results: map[api.RepoName]*protocol.RepoInfo{
+				"x": {TooLarge: true, Size: 200},
+			},
+		}
+		if got := getRepoInfo(t, "x"); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+
+		// Raising the maximum repository size lets the repository be cloned
+		// again.
+		max = 300
+		want.Results["x"] = &protocol.RepoInfo{}
+		if got := getRepoInfo(t, "x"); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
 	t.Run("mutliple", func(t *testing.T) {
 		origRepoCloned := repoCloned
 		repoCloned = func(dir string) bool { return false }
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+func init() {
+	prometheus.MustRegister(reposTooLarge)
+}
+
+var reposTooLarge = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "repos_too_large",
+	Help:      "number of clones aborted and repos removed because they exceeded the maximum repo size",
+})
+
+// repoSizeFile is the file in a repository's git directory which records the
+// size of the repository in bytes. Computing the size walks the whole
+// directory, so it is only done after clones and fetches.
+const repoSizeFile = "sg_size"
+
+// repoSizeCheckInterval is how often the size of a running clone is checked
+// against the maximum repository size.
+var repoSizeCheckInterval = 10 * time.Second
+
+// repoTooLargeError is returned when a repository is larger than the maximum
+// repository size.
+type repoTooLargeError struct {
+	repo      api.RepoName
+	size, max int64
+}
+
+func (e *repoTooLargeError) Error() string {
+	return fmt.Sprintf("repository %s is too large: it uses at least %d bytes, but the maximum repository size is %d bytes", e.repo, e.size, e.max)
+}
+
+// maxRepoSize returns the maximum size of a repository in bytes, or 0 if there
+// is no limit.
+func (s *Server) maxRepoSize() int64 {
+	if s.MaxRepoSizeBytes == nil {
+		return 0
+	}
+	return s.MaxRepoSizeBytes()
+}
+
+// setRepoSize computes the size of the repository in gitDir and records it
+// in gitDir. It returns the size.
+func setRepoSize(gitDir string) (int64, error) {
+	size, err := dirSize(gitDir)
+	if err != nil {
+		return 0, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(gitDir, repoSizeFile), []byte(strconv.FormatInt(size, 10)), 0600); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// repoSize returns the size of the repository in gitDir recorded by
+// setRepoSize. If none was recorded yet, it computes and records it.
+var repoSize = func(gitDir string) (int64, error) {
+	b, err := ioutil.ReadFile(filepath.Join(gitDir, repoSizeFile))
+	if os.IsNotExist(err) {
+		return setRepoSize(gitDir)
+	} else if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return setRepoSize(gitDir)
+	}
+	return size, nil
+}
+
+// tooLargeDirName is the directory under ReposDir which records the
+// repositories found to be too large, so that gitserver doesn't clone them
+// again after it restarts. The size of a repository is recorded in the file
+// tooLargeDirName/{repo}/size.
+const tooLargeDirName = ".too-large"
+
+// tooLargeFile returns the file which records the size of repo if it was
+// found to be too large.
+func (s *Server) tooLargeFile(repo api.RepoName) string {
+	return filepath.Join(s.ReposDir, tooLargeDirName, string(protocol.NormalizeRepo(repo)), "size")
+}
+
+// tooLargeRepoSize returns the size of repo if it was found to be larger than
+// the current maximum repository size, or 0 otherwise. Repositories found to
+// be too large under a smaller maximum (or before the maximum was removed)
+// are forgotten, so that they are cloned again.
+func (s *Server) tooLargeRepoSize(repo api.RepoName) int64 {
+	path := s.tooLargeFile(repo)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log15.Warn("failed to read too large repo size", "repo", repo, "error", err)
+		}
+		return 0
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if max := s.maxRepoSize(); err != nil || max == 0 || size <= max {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log15.Warn("failed to remove too large repo size", "repo", repo, "error", err)
+		}
+		return 0
+	}
+	return size
+}
+
+// setRepoTooLarge records that repo was found to be size bytes large, which
+// is larger than the maximum repository size.
+func (s *Server) setRepoTooLarge(repo api.RepoName, size int64) {
+	reposTooLarge.Inc()
+	path := s.tooLargeFile(repo)
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err == nil {
+		err = ioutil.WriteFile(path, []byte(strconv.FormatInt(size, 10)), 0600)
+	}
+	if err != nil {
+		log15.Error("failed to record too large repo", "repo", repo, "error", err)
+	}
+}
+
+// removeRepoIfTooLarge removes the repository in gitDir if size is larger
+// than the maximum repository size, and records it as too large. It reports
+// whether it removed the repository.
+func (s *Server) removeRepoIfTooLarge(repo api.RepoName, gitDir string, size int64) (bool, error) {
+	max := s.maxRepoSize()
+	if max == 0 || size <= max {
+		return false, nil
+	}
+	log15.Warn("removing repo larger than the maximum repo size", "repo", repo, "size", size, "max", max)
+	if err := s.removeRepoDirectory(gitDir); err != nil {
+		return false, err
+	}
+	s.setRepoTooLarge(repo, size)
+	return true, nil
+}
+
+// watchCloneSize checks the size of the clone in dir every
+// repoSizeCheckInterval until ctx is done. If the clone grows larger than the
+// maximum repository size, it calls cancel to abort the clone and sends the
+// size on the returned channel.
+func (s *Server) watchCloneSize(ctx context.Context, dir string, cancel context.CancelFunc) <-chan int64 {
+	tooLarge := make(chan int64, 1)
+	go func() {
+		ticker := time.NewTicker(repoSizeCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			max := s.maxRepoSize()
+			if max == 0 {
+				continue
+			}
+			if size, err := dirSize(dir); err == nil && size > max {
+				tooLarge <- size
+				cancel()
+				return
+			}
+		}
+	}()
+	return tooLarge
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+)
+
+func TestCloneRepo_tooLarge(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+	for _, args := range [][]string{
+		{"git", "init", "."},
+		{"git", "-c", "user.name=a", "-c", "user.email=a@a.com", "commit", "--allow-empty", "-m", "foo"},
+	} {
+		c := exec.Command(args[0], args[1:]...)
+		c.Dir = remote
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("%v failed: %s (%s)", args, err, out)
+		}
+	}
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+
+	var max int64 = 1
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+		MaxRepoSizeBytes: func() int64 { return max },
+	}
+	dst := filepath.Join(reposDir, "example.com/foo/bar", ".git")
+
+	_, err := s.cloneRepo(context.Background(), "example.com/foo/bar", remote, &cloneOptions{Block: true})
+	if _, ok := errors.Cause(err).(*repoTooLargeError); !ok {
+		t.Fatalf("got error %v, want a repoTooLargeError", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("expected the too large clone to be removed, got %v", err)
+	}
+	size := s.tooLargeRepoSize("example.com/foo/bar")
+	if size <= max {
+		t.Fatalf("got too large size %d, want more than %d", size, max)
+	}
+
+	// Repositories which are too large are not cloned again, even after a
+	// restart.
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", remote, &cloneOptions{Block: true}); err == nil {
+		t.Fatal("expected cloning a too large repository to fail")
+	}
+	restarted := &Server{ReposDir: reposDir, MaxRepoSizeBytes: s.MaxRepoSizeBytes}
+	if got := restarted.tooLargeRepoSize("example.com/foo/bar"); got != size {
+		t.Fatalf("got too large size %d after restart, want %d", got, size)
+	}
+
+	// Until the maximum repository size is raised.
+	max = 2 * size
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", remote, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repoSize(dst); err != nil || got == 0 {
+		t.Fatalf("got repo size %d, %v", got, err)
+	}
+
+	// Lowering the maximum repository size removes the repository.
+	max = 1
+	got, _ := repoSize(dst)
+	if removed, err := s.removeRepoIfTooLarge("example.com/foo/bar", dst, got); err != nil || !removed {
+		t.Fatalf("removeRepoIfTooLarge got %v, %v", removed, err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("expected the too large repository to be removed, got %v", err)
+	}
+	if s.tooLargeRepoSize("example.com/foo/bar") == 0 {
+		t.Error("expected the removed repository to be recorded as too large")
+	}
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/repotrackutil"
 	"github.com/sourcegraph/sourcegraph/pkg/trace"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	"github.com/sourcegraph/sourcegraph/schema"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
@@ -117,6 +118,31 @@ type Server struct {
 	// DiskSizer tells how much disk is free and how large the disk is.
 	DiskSizer DiskSizer
 
+	// Hostname is the hostname of this gitserver, which identifies its
+	// address among the addresses returned by GitServerAddrs.
+	Hostname string
+
+	// GitServerAddrs, if set, returns the addresses of all gitservers. It is
+	// used to copy repositories which moved to this gitserver from the
+	// gitserver which owned them before, instead of cloning them from the
+	// code host.
+	GitServerAddrs func() []string
+
+	// ReplicationFactor, if set, returns the number of gitservers which have
+	// a copy of each repository. The primary gitserver of a repository asks
+	// its replicas to fetch the repository from it after it is cloned or
+	// updated.
+	ReplicationFactor func() int
+
+	// ClonePolicies, if set, returns the clone policies which configure
+	// partial and shallow clones of repositories.
+	ClonePolicies func() []*schema.GitClonePolicy
+
+	// MaxRepoSizeBytes, if set, returns the maximum size of a repository on
+	// disk in bytes, or 0 if there is no limit. Larger repositories are not
+	// kept on disk.
+	MaxRepoSizeBytes func() int64
+
 	// skipCloneForTests is set by tests to avoid clones.
 	skipCloneForTests bool
 
@@ -139,6 +165,10 @@ type Server struct {
 
 	repoUpdateLocksMu sync.Mutex // protects the map below and also updates to locks.once
 	repoUpdateLocks   map[api.RepoName]*locks
+
+	// noLazyFetchSupported is whether git honors GIT_NO_LAZY_FETCH, which
+	// handleGitUploadPack relies on to serve partial clones.
+	noLazyFetchSupported bool
 }
 
 type locks struct {
@@ -201,6 +231,11 @@ func (s *Server) Handler() http.Handler {
 	s.locker = &RepositoryLocker{}
 	s.repoUpdateLocks = make(map[api.RepoName]*locks)
 
+	s.noLazyFetchSupported = gitSupportsNoLazyFetch()
+	if !s.noLazyFetchSupported {
+		log15.Warn("git does not support GIT_NO_LAZY_FETCH (added in git 2.44), so the /git/ endpoint refuses to serve partial clones")
+	}
+
 	// GitMaxConcurrentClones controls the maximum number of clones that
 	// can happen at once on a single gitserver.
 	// Used to prevent throttle limits from a code host. Defaults to 5.
@@ -234,6 +269,13 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/repos", s.handleRepoInfo)
 	mux.HandleFunc("/delete", s.handleRepoDelete)
 	mux.HandleFunc("/repo-update", s.handleRepoUpdate)
+	mux.HandleFunc("/repo-handoff", s.handleRepoHandoff)
+	mux.HandleFunc("/repo-handoff-done", s.handleRepoHandoffDone)
+	mux.HandleFunc("/git/", s.handleGitUploadPack)
+	mux.HandleFunc("/replication/git/", s.handleReplicationGitUploadPack)
+	mux.HandleFunc("/commits", s.handleCommits)
+	mux.HandleFunc("/merge-base", s.handleMergeBase)
+	mux.HandleFunc("/is-ancestor", s.handleIsAncestor)
 	mux.HandleFunc("/getGitolitePhabricatorMetadata", s.handleGetGitolitePhabricatorMetadata)
 	mux.HandleFunc("/create-commit-from-patch", s.handleCreateCommitFromPatch)
 	mux.HandleFunc("/ping", func(w http.ResponseWriter, _ *http.Request) {
@@ -321,11 +363,12 @@ func (s *Server) tempDir(prefix string) (name string, err error) {
 }
 
 func (s *Server) ignorePath(path string) bool {
-	// We ignore any path which starts with .tmp in ReposDir
+	// We ignore any path which starts with .tmp in ReposDir, and the records
+	// of too large repositories.
 	if filepath.Dir(path) != s.ReposDir {
 		return false
 	}
-	return strings.HasPrefix(filepath.Base(path), tempDirName)
+	return strings.HasPrefix(filepath.Base(path), tempDirName) || filepath.Base(path) == tooLargeDirName
 }
 
 func (s *Server) handleIsRepoCloneable(w http.ResponseWriter, r *http.Request) {
@@ -403,7 +446,11 @@ func (s *Server) handleRepoUpdate(w http.ResponseWriter, r *http.Request) {
 		// optimistically, we assume that our cloning attempt might
 		// succeed.
 		resp.CloneInProgress = true
-		_, err := s.cloneRepo(ctx, req.Repo, req.URL, nil)
+		var opts *cloneOptions
+		if req.CloneOptions != nil {
+			opts = &cloneOptions{CloneOptions: req.CloneOptions}
+		}
+		_, err := s.cloneRepo(ctx, req.Repo, req.URL, opts)
 		if err != nil {
 			log15.Warn("error cloning repo", "repo", req.Repo, "err", err)
 			resp.Error = err.Error()
@@ -643,6 +690,10 @@ func (s *Server) exec(w http.ResponseWriter, r *http.Request, req *protocol.Exec
 		ensureRevisionStatus = "noop"
 	}
 
+	if len(req.Args) > 0 && req.Args[0] == "archive" {
+		fetchMissingArchiveBlobs(ctx, req.Repo, dir, req.Args)
+	}
+
 	w.Header().Set("Trailer", "X-Exec-Error")
 	w.Header().Add("Trailer", "X-Exec-Exit-Status")
 	w.Header().Add("Trailer", "X-Exec-Stderr")
@@ -720,6 +771,10 @@ type cloneOptions struct {
 
 	// Overwrite will overwrite the existing clone.
 	Overwrite bool
+
+	// CloneOptions, if set, are used instead of the clone policy of the
+	// repository.
+	CloneOptions *protocol.CloneOptions
 }
 
 // cloneRepo issues a git clone command for the given repo. It is
@@ -729,6 +784,10 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 		return "This will never finish cloning", nil
 	}
 
+	if size := s.tooLargeRepoSize(repo); size > 0 {
+		return "", &repoTooLargeError{repo: repo, size: size, max: s.maxRepoSize()}
+	}
+
 	dir := filepath.Join(s.ReposDir, string(protocol.NormalizeRepo(repo)))
 
 	// PERF: Before doing the network request to check if isCloneable, lets
@@ -801,15 +860,49 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 		defer os.RemoveAll(tmpPath)
 		tmpPath = filepath.Join(tmpPath, ".git")
 
-		cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", "--progress", url, tmpPath)
-		log15.Info("cloning repo", "repo", repo, "tmp", tmpPath, "dst", dstPath)
-
-		pr, pw := io.Pipe()
-		defer pw.Close()
-		go readCloneProgress(repo, url, lock, pr)
+		// Abort the clone if it grows larger than the maximum repository
+		// size, instead of filling up the disk.
+		ctx, cancel3 := context.WithCancel(ctx)
+		defer cancel3()
+		cloneTooLarge := s.watchCloneSize(ctx, tmpPath, cancel3)
+		tooLargeError := func(size int64) error {
+			s.setRepoTooLarge(repo, size)
+			return &repoTooLargeError{repo: repo, size: size, max: s.maxRepoSize()}
+		}
 
-		if output, err := runWithRemoteOpts(ctx, cmd, pw); err != nil {
-			return errors.Wrapf(err, "clone failed. Output: %s", string(output))
+		// A repository which moved to this gitserver is copied from the
+		// gitserver which owned it before, unless we are recloning it.
+		var handoffPeer string
+		if !overwrite {
+			handoffPeer = s.copyRepoFromPeers(ctx, repo, tmpPath)
+		}
+		if handoffPeer == "" {
+			cloneOpts := s.clonePolicyOptions(repo, url)
+			if opts != nil && opts.CloneOptions != nil {
+				cloneOpts = opts.CloneOptions
+			}
+			log15.Info("cloning repo", "repo", repo, "tmp", tmpPath, "dst", dstPath, "options", cloneOpts)
+
+			pr, pw := io.Pipe()
+			defer pw.Close()
+			go readCloneProgress(repo, url, lock, pr)
+
+			if cloneOpts != nil {
+				err = clonePartial(ctx, url, tmpPath, cloneOpts, pw)
+			} else {
+				cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", "--progress", url, tmpPath)
+				if output, cloneErr := runWithRemoteOpts(ctx, cmd, pw); cloneErr != nil {
+					err = errors.Wrapf(cloneErr, "clone failed. Output: %s", string(output))
+				}
+			}
+			if err != nil {
+				select {
+				case size := <-cloneTooLarge:
+					return tooLargeError(size)
+				default:
+					return err
+				}
+			}
 		}
 
 		// Update the last-changed stamp.
@@ -822,6 +915,16 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 			return err
 		}
 
+		writeCommitGraph(ctx, repo, tmpPath)
+
+		size, err := setRepoSize(tmpPath)
+		if err != nil {
+			return errors.Wrap(err, "failed to compute repo size")
+		}
+		if max := s.maxRepoSize(); max > 0 && size > max {
+			return tooLargeError(size)
+		}
+
 		if overwrite {
 			// remove the current repo by putting it into our temporary directory
 			err := renameAndSync(dstPath, filepath.Join(filepath.Dir(tmpPath), "old"))
@@ -839,6 +942,10 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoName, url string, o
 
 		log15.Info("repo cloned", "repo", repo)
 		repoClonedCounter.Inc()
+		s.replicateRepo(repo)
+		if handoffPeer != "" {
+			s.releaseRepoOnPeer(handoffPeer, repo)
+		}
 
 		return nil
 	}
@@ -1022,21 +1129,32 @@ func init() {
 
 var headBranchPattern = regexp.MustCompile(`HEAD branch: (.+?)\n`)
 
-func (s *Server) doRepoUpdate(ctx context.Context, repo api.RepoName, url string) error {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "Server.doRepoUpdate")
-	span.SetTag("repo", repo)
-	span.SetTag("url", url)
-	defer span.Finish()
-
+// repoUpdateLock returns the locks which serialize the updates of repo.
+func (s *Server) repoUpdateLock(repo api.RepoName) *locks {
 	s.repoUpdateLocksMu.Lock()
+	defer s.repoUpdateLocksMu.Unlock()
 	l, ok := s.repoUpdateLocks[repo]
 	if !ok {
 		l = &locks{
 			once: new(sync.Once),
 			mu:   new(sync.Mutex),
 		}
+		if s.repoUpdateLocks == nil {
+			s.repoUpdateLocks = make(map[api.RepoName]*locks)
+		}
 		s.repoUpdateLocks[repo] = l
 	}
+	return l
+}
+
+func (s *Server) doRepoUpdate(ctx context.Context, repo api.RepoName, url string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Server.doRepoUpdate")
+	span.SetTag("repo", repo)
+	span.SetTag("url", url)
+	defer span.Finish()
+
+	l := s.repoUpdateLock(repo)
+	s.repoUpdateLocksMu.Lock()
 	once := l.once
 	mu := l.mu
 	s.repoUpdateLocksMu.Unlock()
@@ -1246,7 +1364,12 @@ func (s *Server) doRepoUpdate2(repo api.RepoName, url string) error {
 		}
 	}
 
-	cmd := exec.CommandContext(ctx, "git", "fetch", "--prune", url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*")
+	// Repositories cloned with clone options are updated with them too.
+	cloneOpts, err := readCloneOptions(ctx, dir)
+	if err != nil {
+		log15.Warn("Failed to read clone options", "repo", repo, "error", err)
+	}
+	cmd := exec.CommandContext(ctx, "git", updateFetchArgs(url, cloneOpts)...)
 	cmd.Dir = dir
 
 	// drop temporary pack files after a fetch. this function won't
@@ -1265,6 +1388,19 @@ func (s *Server) doRepoUpdate2(repo api.RepoName, url string) error {
 		log15.Warn("Failed to update last changed time", "repo", repo, "error", err)
 	}
 
+	writeCommitGraph(ctx, repo, dir)
+
+	// Repositories which grew larger than the maximum repository size are
+	// removed.
+	gitDir := filepath.Join(dir, ".git")
+	if size, err := setRepoSize(gitDir); err != nil {
+		log15.Warn("Failed to update repo size", "repo", repo, "error", err)
+	} else if removed, err := s.removeRepoIfTooLarge(repo, gitDir, size); err != nil {
+		log15.Error("Failed to remove repo larger than the maximum repo size", "repo", repo, "error", err)
+	} else if removed {
+		return &repoTooLargeError{repo: repo, size: size, max: s.maxRepoSize()}
+	}
+
 	headBranch := "master"
 
 	// try to fetch HEAD from origin
@@ -1309,6 +1445,8 @@ func (s *Server) doRepoUpdate2(repo api.RepoName, url string) error {
 		log15.Error("Failed to set HEAD", "repo", repo, "error", err, "output", string(output))
 		return errors.Wrap(err, "Failed to set HEAD")
 	}
+
+	s.replicateRepo(repo)
 	return nil
 }
 
@@ -1,5 +1,5 @@
-// Command replacer is an interface to replace and rewrite code. It passes a zipped repo
-// to external tools and streams back JSON lines results.
+// Command replacer is an interface to replace and rewrite code. It rewrites the files of
+// a zipped repo with structural patterns and streams back JSON lines results.
 package main
 
 import (
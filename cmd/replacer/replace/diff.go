@@ -0,0 +1,115 @@
+package replace
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffContext is the number of unchanged lines shown around each change in a
+// diff.
+const diffContext = 3
+
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// unifiedDiff returns the unified diff of the file at path changing from a
+// to b. The file headers contain the unprefixed path, and the diff has no
+// trailing newline.
+func unifiedDiff(path string, a, b []byte) string {
+	dmp := diffmatchpatch.New()
+	ac, bc, lineArray := dmp.DiffLinesToChars(string(a), string(b))
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(ac, bc, false), lineArray)
+
+	var lines []diffLine
+	for _, d := range diffs {
+		for _, text := range splitLines(d.Text) {
+			lines = append(lines, diffLine{op: d.Type, text: text})
+		}
+	}
+
+	// aLine[i] and bLine[i] are the number of lines of a and b before
+	// lines[i].
+	aLine := make([]int, len(lines)+1)
+	bLine := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if l.op != diffmatchpatch.DiffInsert {
+			aLine[i+1]++
+		}
+		if l.op != diffmatchpatch.DiffDelete {
+			bLine[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", path, path)
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffmatchpatch.DiffEqual {
+			i++
+			continue
+		}
+
+		// Extend the hunk over changes which are separated by at most
+		// 2*diffContext unchanged lines.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(lines) && j-end <= 2*diffContext; j++ {
+			if lines[j].op != diffmatchpatch.DiffEqual {
+				end = j + 1
+			}
+		}
+		i = end
+		end += diffContext
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLine[start], aLine[end]), hunkRange(bLine[start], bLine[end]))
+		for _, l := range lines[start:end] {
+			switch l.op {
+			case diffmatchpatch.DiffEqual:
+				out.WriteByte(' ')
+			case diffmatchpatch.DiffDelete:
+				out.WriteByte('-')
+			case diffmatchpatch.DiffInsert:
+				out.WriteByte('+')
+			}
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// hunkRange formats the range of lines [from, to) for a hunk header. Lines
+// are numbered from 1, and empty ranges refer to the line before them.
+func hunkRange(from, to int) string {
+	if from == to {
+		return fmt.Sprintf("%d,0", from)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// splitLines splits s after each newline.
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
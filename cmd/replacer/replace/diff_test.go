@@ -0,0 +1,38 @@
+package replace
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "context",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n19\n20\n",
+			b:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\nten\n12\n13\n14\n15\n16\n17\n18\n19\ntwenty\n",
+			want: "--- f\n+++ f\n@@ -2,13 +2,13 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n 9\n 10\n-11\n+ten\n 12\n 13\n 14\n@@ -17,4 +17,4 @@\n 17\n 18\n 19\n-20\n+twenty",
+		},
+		{
+			name: "insert at start",
+			a:    "a\nb\n",
+			b:    "x\na\nb\n",
+			want: "--- f\n+++ f\n@@ -1,2 +1,3 @@\n+x\n a\n b",
+		},
+		{
+			name: "no newline at end of file",
+			a:    "a\nb",
+			b:    "a\nc",
+			want: "--- f\n+++ f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := unifiedDiff("f", []byte(tc.a), []byte(tc.b))
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -9,19 +9,18 @@
 // * On disk cache of fetched archives to reduce load on gitserver
 //
 // - Here is where replacer.go differs
-// * Pass the zip file path to external replacer tool(s) after validating
-// * Read tool stdout and write it out on the HTTP connection
-// * Input from stdout is expected to use JSON lines format, but the format isn't checked here: line-buffering is done on the frontend
+// * Each file in the archive is rewritten with a structural pattern (see pkg/structural)
+// * The diff of each changed file is written out on the HTTP connection as a JSON line
 
 package replace
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -34,6 +33,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sourcegraph/sourcegraph/cmd/replacer/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/store"
+	"github.com/sourcegraph/sourcegraph/pkg/structural"
 	"gopkg.in/inconshreveable/log15.v2"
 
 	"github.com/gorilla/schema"
@@ -44,41 +44,6 @@ type Service struct {
 	Log   log15.Logger
 }
 
-type ExternalTool struct {
-	Name       string
-	BinaryPath string
-}
-
-// Configure the command line options and return the command to execute using an external tool
-func (t *ExternalTool) command(spec *protocol.RewriteSpecification, zipPath string) (cmd *exec.Cmd, err error) {
-	switch t.Name {
-	case "comby":
-		_, err = exec.LookPath("comby")
-		if err != nil {
-			return nil, errors.New("comby is not installed on the PATH. Try running 'bash <(curl -sL get.comby.dev)'.")
-		}
-
-		var args []string
-		args = append(args, spec.MatchTemplate, spec.RewriteTemplate)
-
-		if spec.FileExtension != "" {
-			args = append(args, spec.FileExtension)
-		}
-
-		args = append(args, "-zip", zipPath, "-json-lines", "-json-only-diff")
-
-		if spec.DirectoryExclude != "" {
-			args = append(args, "-exclude-dir", spec.DirectoryExclude)
-		}
-
-		log15.Info(fmt.Sprintf("running command: comby %q", strings.Join(args[:], " ")))
-		return exec.Command(t.BinaryPath, args...), nil
-
-	default:
-		return nil, errors.Errorf("Unknown external replace tool %q.", t.Name)
-	}
-}
-
 var decoder = schema.NewDecoder()
 
 func init() {
@@ -187,6 +152,11 @@ func (s *Service) replace(ctx context.Context, p *protocol.Request, w http.Respo
 	prepareCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
 	defer cancel()
 
+	pattern, err := structural.Compile(p.MatchTemplate)
+	if err != nil {
+		return false, badRequestError{err.Error()}
+	}
+
 	getZf := func() (string, *store.ZipFile, error) {
 		path, err := s.Store.PrepareZip(prepareCtx, p.GitserverRepo(), p.Commit)
 		if err != nil {
@@ -196,7 +166,7 @@ func (s *Service) replace(ctx context.Context, p *protocol.Request, w http.Respo
 		return path, zf, err
 	}
 
-	zipPath, zf, err := store.GetZipFileWithRetry(getZf)
+	_, zf, err := store.GetZipFileWithRetry(getZf)
 	if err != nil {
 		return false, err
 	}
@@ -214,39 +184,80 @@ func (s *Service) replace(ctx context.Context, p *protocol.Request, w http.Respo
 	w.Header().Set("Transfer-Encoding", "chunked")
 	w.WriteHeader(http.StatusOK)
 
-	t := &ExternalTool{
-		Name:       "comby",
-		BinaryPath: "comby",
-	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	flusher, _ := w.(http.Flusher)
 
-	cmd, err := t.command(&p.RewriteSpecification, zipPath)
-	if err != nil {
-		log15.Info("Invalid command: " + err.Error())
-		return
+	var nChanged int
+	for i := range zf.Files {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		f := &zf.Files[i]
+		if !includeFile(&p.RewriteSpecification, f.Name) {
+			continue
+		}
+		diff, ok := rewriteFile(pattern, p.RewriteTemplate, f.Name, zf.DataFor(f))
+		if !ok {
+			continue
+		}
+		nChanged++
+		if err := enc.Encode(result{URI: f.Name, Diff: diff}); err != nil {
+			// The client went away, so there is no one to report the
+			// error to.
+			log15.Info("Error writing replace result: " + err.Error())
+			return false, nil
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
 	}
+	tr.LazyPrintf("changed=%d", nChanged)
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log15.Info("Could not connect to command stdout: " + err.Error())
-		return
-	}
+	return false, nil
+}
 
-	if err := cmd.Start(); err != nil {
-		log15.Info("Error starting command: " + err.Error())
-		return false, errors.New(err.Error())
-	}
+// result is a JSON line written for each file changed by a replace request.
+type result struct {
+	URI  string `json:"uri"`
+	Diff string `json:"diff"`
+}
 
-	_, err = io.Copy(w, stdout)
-	if err != nil {
-		log15.Info("Error copying external command output to HTTP writer: " + err.Error())
-		return
+// includeFile reports whether the file at path should be rewritten according
+// to spec.
+func includeFile(spec *protocol.RewriteSpecification, path string) bool {
+	if spec.FileExtension != "" && !strings.HasSuffix(path, spec.FileExtension) {
+		return false
 	}
-
-	if err := cmd.Wait(); err != nil {
-		log15.Info("Error after executing command: " + string(err.(*exec.ExitError).Stderr))
+	if spec.DirectoryExclude != "" {
+		dirs := strings.Split(path, "/")
+		for _, dir := range dirs[:len(dirs)-1] {
+			if strings.HasPrefix(dir, spec.DirectoryExclude) {
+				return false
+			}
+		}
 	}
+	return true
+}
 
-	return false, nil
+// rewriteFile replaces each match of pattern in data with template. If this
+// changes the file, the unified diff of the change is returned. Files are
+// rewritten completely or not at all: if the matcher gives up part way through
+// the file, it is left unchanged.
+func rewriteFile(pattern *structural.Pattern, template, path string, data []byte) (diff string, ok bool) {
+	// Skip binary files.
+	if bytes.IndexByte(data, 0) >= 0 || !bytes.Contains(data, pattern.Literal()) {
+		return "", false
+	}
+	out, n, limitHit := pattern.ReplaceAll(data, template)
+	if limitHit {
+		log15.Info("Gave up rewriting file, leaving it unchanged", "path", path, "replaced", n)
+		return "", false
+	}
+	if n == 0 || bytes.Equal(out, data) {
+		return "", false
+	}
+	return unifiedDiff(path, data, out), true
 }
 
 func validateParams(p *protocol.Request) error {
@@ -302,6 +313,11 @@ func init() {
 	prometheus.MustRegister(requestTotal)
 }
 
+type badRequestError struct{ msg string }
+
+func (e badRequestError) Error() string    { return e.msg }
+func (e badRequestError) BadRequest() bool { return true }
+
 func isBadRequest(err error) bool {
 	e, ok := errors.Cause(err).(interface {
 		BadRequest() bool
@@ -23,11 +23,6 @@ import (
 )
 
 func TestReplace(t *testing.T) {
-	// If we are not on CI skip the test if comby is not installed.
-	if _, err := exec.LookPath("comby"); os.Getenv("CI") == "" && err != nil {
-		t.Skip("comby is not installed on the PATH. Try running 'bash <(curl -sL get.comby.dev)'.")
-	}
-
 	files := map[string]string{
 
 		"README.md": `# Hello World
@@ -40,6 +35,12 @@ import "fmt"
 func main() {
 	fmt.Println("Hello foo")
 }
+`,
+		"vendor/lib.go": `package lib
+
+import "fmt"
+
+var x = fmt.Println("vendored")
 `,
 	}
 
@@ -53,6 +54,18 @@ func main() {
 			FileExtension:   ".go",
 		}, `
 {"uri":"main.go","diff":"--- main.go\n+++ main.go\n@@ -2,6 +2,6 @@\n \n import \"fmt\"\n \n-func main() {\n+derp main() {\n \tfmt.Println(\"Hello foo\")\n }"}
+`},
+		{protocol.RewriteSpecification{
+			MatchTemplate:    "fmt.Println(:[args])",
+			RewriteTemplate:  "log.Println(:[args])",
+			DirectoryExclude: "vendor",
+		}, `
+{"uri":"main.go","diff":"--- main.go\n+++ main.go\n@@ -3,5 +3,5 @@\n import \"fmt\"\n \n func main() {\n-\tfmt.Println(\"Hello foo\")\n+\tlog.Println(\"Hello foo\")\n }"}
+`},
+		{protocol.RewriteSpecification{
+			MatchTemplate:   "nothing(:[x])",
+			RewriteTemplate: "matches(:[x])",
+		}, `
 `},
 	}
 
@@ -103,6 +116,14 @@ func TestReplace_badrequest(t *testing.T) {
 			Commit: "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
 			// No MatchTemplate
 		},
+		{
+			Repo:   "foo",
+			URL:    "u",
+			Commit: "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+			RewriteSpecification: protocol.RewriteSpecification{
+				MatchTemplate: ":[x]",
+			},
+		},
 	}
 
 	store, cleanup, err := newStore(nil)
@@ -126,12 +147,13 @@ func TestReplace_badrequest(t *testing.T) {
 
 func doReplace(u string, p *protocol.Request) (string, error) {
 	form := url.Values{
-		"Repo":            []string{string(p.Repo)},
-		"URL":             []string{string(p.URL)},
-		"Commit":          []string{string(p.Commit)},
-		"MatchTemplate":   []string{p.RewriteSpecification.MatchTemplate},
-		"RewriteTemplate": []string{p.RewriteSpecification.RewriteTemplate},
-		"FileExtension":   []string{p.RewriteSpecification.FileExtension},
+		"Repo":             []string{string(p.Repo)},
+		"URL":              []string{string(p.URL)},
+		"Commit":           []string{string(p.Commit)},
+		"MatchTemplate":    []string{p.RewriteSpecification.MatchTemplate},
+		"RewriteTemplate":  []string{p.RewriteSpecification.RewriteTemplate},
+		"FileExtension":    []string{p.RewriteSpecification.FileExtension},
+		"DirectoryExclude": []string{p.RewriteSpecification.DirectoryExclude},
 	}
 	resp, err := http.PostForm(u, form)
 	if err != nil {
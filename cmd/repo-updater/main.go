@@ -76,6 +76,8 @@ func main() {
 			m.ListExternalServices,
 			m.UpsertExternalServices,
 			m.ListAllRepoNames,
+			m.ListRepoSchedules,
+			m.UpsertRepoSchedules,
 		} {
 			om.MustRegister(prometheus.DefaultRegisterer)
 		}
@@ -97,7 +99,12 @@ func main() {
 		src = repos.NewSourcer(cf, repos.ObservedSource(log15.Root(), m))
 	}
 
-	scheduler := repos.NewUpdateScheduler()
+	scheduler := repos.NewUpdateScheduler(store)
+	if err := scheduler.Restore(ctx); err != nil {
+		// The scheduler still works without the persisted schedule, it just
+		// updates all repos right away.
+		log15.Error("failed to restore update schedule", "error", err)
+	}
 	server := repoupdater.Server{
 		Store:           store,
 		Scheduler:       scheduler,
@@ -12,6 +12,7 @@ import (
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/awscodecommit"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
 	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
 	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/schema"
@@ -77,12 +78,15 @@ func newAWSCodeCommitSource(svc *ExternalService, c *schema.AWSCodeCommitConnect
 		}
 	}
 
+	client := awscodecommit.NewClient(awsConfig)
+	client.RateLimiter = ratelimits.AWSCodeCommit(c).Configure(svc.ID)
+
 	s := &AWSCodeCommitSource{
 		svc:       svc,
 		config:    c,
 		awsConfig: awsConfig,
 		exclude:   exclude,
-		client:    awscodecommit.NewClient(awsConfig),
+		client:    client,
 	}
 
 	var ok bool
@@ -12,6 +12,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketcloud"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
 	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
 	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/schema"
@@ -49,6 +50,8 @@ func newBitbucketCloudSource(svc *ExternalService, c *schema.BitbucketCloudConne
 	client.Username = c.Username
 	client.AppPassword = c.AppPassword
 
+	client.RateLimit = ratelimits.BitbucketCloud(c).Configure(svc.ID)
+
 	return &BitbucketCloudSource{
 		svc:    svc,
 		config: c,
@@ -14,6 +14,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
 	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
 	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/schema"
@@ -89,6 +90,8 @@ func newBitbucketServerSource(svc *ExternalService, c *schema.BitbucketServerCon
 	client.Username = c.Username
 	client.Password = c.Password
 
+	client.RateLimit = ratelimits.BitbucketServer(c).Configure(svc.ID)
+
 	return &BitbucketServerSource{
 		svc:             svc,
 		config:          c,
@@ -14,6 +14,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
 	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
 	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/schema"
@@ -101,6 +102,14 @@ func newGithubSource(svc *ExternalService, c *schema.GitHubConnection, cf *httpc
 		}
 	}
 
+	// The clients of the external service share its rate limiter.
+	rl := ratelimits.GitHub(c).Configure(svc.ID)
+
+	client := github.NewClient(apiURL, c.Token, cli)
+	client.RateLimiter = rl
+	searchClient := github.NewClient(apiURL, c.Token, cli)
+	searchClient.RateLimiter = rl
+
 	return &GithubSource{
 		svc:              svc,
 		config:           c,
@@ -108,8 +117,8 @@ func newGithubSource(svc *ExternalService, c *schema.GitHubConnection, cf *httpc
 		excludePatterns:  excludePatterns,
 		baseURL:          baseURL,
 		githubDotCom:     githubDotCom,
-		client:           github.NewClient(apiURL, c.Token, cli),
-		searchClient:     github.NewClient(apiURL, c.Token, cli),
+		client:           client,
+		searchClient:     searchClient,
 		originalHostname: originalHostname,
 	}, nil
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
 	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
 	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/schema"
@@ -74,12 +75,16 @@ func newGitLabSource(svc *ExternalService, c *schema.GitLabConnection, cf *httpc
 		}
 	}
 
+	// The clients of the external service share its rate limiter.
+	provider := gitlab.NewClientProvider(baseURL, cli)
+	provider.RateLimiter = ratelimits.GitLab(c).Configure(svc.ID)
+
 	return &GitLabSource{
 		svc:     svc,
 		config:  c,
 		exclude: exclude,
 		baseURL: baseURL,
-		client:  gitlab.NewClientProvider(baseURL, cli).GetPATClient(c.Token, ""),
+		client:  provider.GetPATClient(c.Token, ""),
 	}, nil
 }
 
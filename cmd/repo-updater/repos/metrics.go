@@ -60,6 +60,12 @@ var (
 		Name:      "sched_error",
 		Help:      "Incremented each time we encounter an error updating a repository.",
 	})
+	schedPersistError = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "src",
+		Subsystem: "repoupdater",
+		Name:      "sched_persist_error",
+		Help:      "Incremented each time we fail to persist the schedule of a repository.",
+	})
 	schedLoops = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: "src",
 		Subsystem: "repoupdater",
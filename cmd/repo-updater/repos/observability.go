@@ -144,6 +144,8 @@ type StoreMetrics struct {
 	UpsertExternalServices *OperationMetrics
 	ListExternalServices   *OperationMetrics
 	ListAllRepoNames       *OperationMetrics
+	ListRepoSchedules      *OperationMetrics
+	UpsertRepoSchedules    *OperationMetrics
 }
 
 // NewStoreMetrics returns StoreMetrics that need to be registered
@@ -290,6 +292,46 @@ func NewStoreMetrics() StoreMetrics {
 				Help:      "Total number of errors when listing repo names",
 			}, []string{}),
 		},
+		ListRepoSchedules: &OperationMetrics{
+			Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: "src",
+				Subsystem: "repoupdater",
+				Name:      "store_list_repo_schedules_duration_seconds",
+				Help:      "Time spent listing repo schedules",
+			}, []string{}),
+			Count: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "src",
+				Subsystem: "repoupdater",
+				Name:      "store_list_repo_schedules_total",
+				Help:      "Total number of listed repo schedules",
+			}, []string{}),
+			Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "src",
+				Subsystem: "repoupdater",
+				Name:      "store_list_repo_schedules_errors_total",
+				Help:      "Total number of errors when listing repo schedules",
+			}, []string{}),
+		},
+		UpsertRepoSchedules: &OperationMetrics{
+			Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: "src",
+				Subsystem: "repoupdater",
+				Name:      "store_upsert_repo_schedules_duration_seconds",
+				Help:      "Time spent upserting repo schedules",
+			}, []string{}),
+			Count: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "src",
+				Subsystem: "repoupdater",
+				Name:      "store_upsert_repo_schedules_total",
+				Help:      "Total number of upserted repo schedules",
+			}, []string{}),
+			Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "src",
+				Subsystem: "repoupdater",
+				Name:      "store_upsert_repo_schedules_errors_total",
+				Help:      "Total number of errors when upserting repo schedules",
+			}, []string{}),
+		},
 	}
 }
 
@@ -473,6 +515,45 @@ func (o *ObservedStore) UpsertRepos(ctx context.Context, repos ...*Repo) (err er
 	return o.store.UpsertRepos(ctx, repos...)
 }
 
+// ListRepoSchedules calls into the inner Store and registers the observed results.
+func (o *ObservedStore) ListRepoSchedules(ctx context.Context, args StoreListRepoSchedulesArgs) (schedules []*RepoSchedule, err error) {
+	tr, ctx := o.trace(ctx, "Store.ListRepoSchedules")
+	tr.LogFields(otlog.Int("args.repo_ids", len(args.RepoIDs)))
+
+	defer func(began time.Time) {
+		secs := time.Since(began).Seconds()
+		count := float64(len(schedules))
+
+		o.metrics.ListRepoSchedules.Observe(secs, count, &err)
+		log(o.log, "store.list-repo-schedules", &err, "count", len(schedules))
+
+		tr.LogFields(otlog.Int("count", len(schedules)))
+		tr.SetError(err)
+		tr.Finish()
+	}(time.Now())
+
+	return o.store.ListRepoSchedules(ctx, args)
+}
+
+// UpsertRepoSchedules calls into the inner Store and registers the observed results.
+func (o *ObservedStore) UpsertRepoSchedules(ctx context.Context, schedules ...*RepoSchedule) (err error) {
+	tr, ctx := o.trace(ctx, "Store.UpsertRepoSchedules")
+	tr.LogFields(otlog.Int("count", len(schedules)))
+
+	defer func(began time.Time) {
+		secs := time.Since(began).Seconds()
+		count := float64(len(schedules))
+
+		o.metrics.UpsertRepoSchedules.Observe(secs, count, &err)
+		log(o.log, "store.upsert-repo-schedules", &err, "count", len(schedules))
+
+		tr.SetError(err)
+		tr.Finish()
+	}(time.Now())
+
+	return o.store.UpsertRepoSchedules(ctx, schedules...)
+}
+
 func (o *ObservedStore) trace(ctx context.Context, family string) (*trace.Trace, context.Context) {
 	txctx := o.txctx
 	if txctx == nil {
@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/phabricator"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
 	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
 	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/schema"
@@ -174,8 +175,14 @@ func (s *PhabricatorSource) client(ctx context.Context) (*phabricator.Client, er
 		return nil, err
 	}
 
-	s.cli, err = phabricator.NewClient(ctx, s.conn.Url, s.conn.Token, hc)
-	return s.cli, err
+	cli, err := phabricator.NewClient(ctx, s.conn.Url, s.conn.Token, hc)
+	if err != nil {
+		return nil, err
+	}
+	cli.RateLimiter = ratelimits.Phabricator(s.conn).Configure(s.svc.ID)
+
+	s.cli = cli
+	return s.cli, nil
 }
 
 // RunPhabricatorRepositorySyncWorker runs the worker that syncs repositories from Phabricator to Sourcegraph
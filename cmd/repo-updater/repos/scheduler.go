@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
@@ -92,6 +93,9 @@ const (
 //
 // When it is time for a repo to update, the scheduler inserts the repo into a queue.
 //
+// The schedule of each repo is persisted in the Store after every update, so that
+// the scheduler can resume it after a restart instead of updating every repo at once.
+//
 // A worker continuously dequeues repos and sends updates to gitserver, but its concurrency
 // is limited by the gitMaxConcurrentClones site configuration.
 type updateScheduler struct {
@@ -103,6 +107,10 @@ type updateScheduler struct {
 
 	updateQueue *updateQueue
 	schedule    *schedule
+
+	// store persists the schedule of each repo. It is nil if the schedule
+	// is not persisted.
+	store Store
 }
 
 // A configuredRepo2 represents the configuration data for a given repo from
@@ -123,21 +131,50 @@ type sourceRepoMap map[api.RepoName]*configuredRepo2
 // non-blocking sends.
 const notifyChanBuffer = 1
 
-// newUpdateScheduler returns a new scheduler.
-func NewUpdateScheduler() *updateScheduler {
+// NewUpdateScheduler returns a new scheduler which persists the schedule
+// of each repo in the given Store. The schedule is not persisted if
+// the Store is nil.
+func NewUpdateScheduler(store Store) *updateScheduler {
 	return &updateScheduler{
+		store:       store,
 		sourceRepos: make(map[string]sourceRepoMap),
 		updateQueue: &updateQueue{
 			index:         make(map[uint32]*repoUpdate),
 			notifyEnqueue: make(chan struct{}, notifyChanBuffer),
 		},
 		schedule: &schedule{
-			index:  make(map[uint32]*scheduledRepoUpdate),
-			wakeup: make(chan struct{}, notifyChanBuffer),
+			index:    make(map[uint32]*scheduledRepoUpdate),
+			restored: make(map[uint32]*RepoSchedule),
+			wakeup:   make(chan struct{}, notifyChanBuffer),
 		},
 	}
 }
 
+// Restore loads the persisted schedules of all repos from the Store.
+// A repo that is then added to the scheduler resumes its persisted schedule,
+// instead of being updated right away. It should be called before
+// the first call to Update.
+func (s *updateScheduler) Restore(ctx context.Context) error {
+	if s.store == nil {
+		return nil
+	}
+
+	schedules, err := s.store.ListRepoSchedules(ctx, StoreListRepoSchedulesArgs{})
+	if err != nil {
+		return errors.Wrap(err, "scheduler.restore")
+	}
+
+	s.schedule.mu.Lock()
+	defer s.schedule.mu.Unlock()
+
+	for _, rs := range schedules {
+		s.schedule.restored[rs.RepoID] = rs
+	}
+
+	log15.Debug("scheduler.restored", "count", len(schedules))
+	return nil
+}
+
 // runScheduleLoop starts the loop that schedules updates by enqueuing them into the updateQueue.
 func (s *updateScheduler) runScheduleLoop(ctx context.Context) {
 	for {
@@ -211,11 +248,27 @@ func (s *updateScheduler) runUpdateLoop(ctx context.Context) {
 					interval := resp.LastFetched.Sub(*resp.LastChanged) / 2
 					s.schedule.updateInterval(repo, interval)
 				}
+
+				s.persist(ctx, s.schedule.recordUpdate(repo, resp, err))
 			}(ctx, repo, cancel)
 		}
 	}
 }
 
+// persist stores the given schedule of a repo in the Store. It does nothing if
+// the schedule is nil or the scheduler has no Store. A failure to persist the schedule
+// only affects when the repo is updated after a restart, so it is logged and ignored.
+func (s *updateScheduler) persist(ctx context.Context, rs *RepoSchedule) {
+	if rs == nil || s.store == nil {
+		return
+	}
+
+	if err := s.store.UpsertRepoSchedules(ctx, rs); err != nil {
+		schedPersistError.Inc()
+		log15.Warn("error persisting repo schedule", "id", rs.RepoID, "err", err)
+	}
+}
+
 // requestRepoUpdate sends a request to gitserver to request an update.
 var requestRepoUpdate = func(ctx context.Context, repo *configuredRepo2, since time.Duration) (*gitserverprotocol.RepoUpdateResponse, error) {
 	return gitserver.DefaultClient.RequestRepoUpdate(ctx, gitserver.Repo{Name: repo.Name, URL: repo.URL}, since)
@@ -257,6 +310,13 @@ func (s *updateScheduler) Update(rs ...*Repo) {
 func (s *updateScheduler) upsert(r *Repo) {
 	repo := configuredRepo2FromRepo(r)
 
+	if s.schedule.restore(repo) {
+		// The repo resumes its persisted schedule, so there is no need
+		// to update it right away.
+		log15.Debug("scheduler.schedule.restored", "repo", r.Name)
+		return
+	}
+
 	updated := s.schedule.upsert(repo)
 	log15.Debug("scheduler.schedule.upserted", "repo", r.Name, "updated", updated)
 
@@ -410,6 +470,15 @@ func (s *updateScheduler) ScheduleInfo(id uint32) *protocol.RepoUpdateSchedulerI
 			Total:           len(s.schedule.index),
 			IntervalSeconds: int(update.Interval / time.Second),
 			Due:             update.Due,
+			LastError:       update.LastError,
+		}
+		if !update.LastUpdated.IsZero() {
+			lastUpdated := update.LastUpdated
+			result.Schedule.LastUpdated = &lastUpdated
+		}
+		if !update.LastChanged.IsZero() {
+			lastChanged := update.LastChanged
+			result.Schedule.LastChanged = &lastChanged
 		}
 	}
 	s.schedule.mu.Unlock()
@@ -615,6 +684,10 @@ type schedule struct {
 	heap  []*scheduledRepoUpdate // min heap of scheduledRepoUpdates based on their due time.
 	index map[uint32]*scheduledRepoUpdate
 
+	// restored holds the persisted schedules of repos that haven't been
+	// added to the schedule since they were restored.
+	restored map[uint32]*RepoSchedule
+
 	// timer sends a value on the wakeup channel when it is time
 	timer  *time.Timer
 	wakeup chan struct{}
@@ -626,6 +699,10 @@ type scheduledRepoUpdate struct {
 	Interval time.Duration    // how regularly the repo is updated
 	Due      time.Time        // the next time that the repo will be enqueued for a update
 	Index    int              `json:"-"` // the index in the heap
+
+	LastUpdated time.Time // the last time that an update of the repo finished
+	LastChanged time.Time // the last time that the repo changed, as reported by gitserver
+	LastError   string    // the error of the last update, if it failed
 }
 
 // upsert inserts or updates a repo in the schedule.
@@ -653,6 +730,88 @@ func (s *schedule) upsert(repo *configuredRepo2) (updated bool) {
 	return false
 }
 
+// restore inserts a repo into the schedule with its persisted schedule,
+// if the repo is not in the schedule yet and its schedule was restored.
+// It reports whether the repo's next update is already scheduled, which is
+// the case if its last update succeeded.
+func (s *schedule) restore(repo *configuredRepo2) (scheduled bool) {
+	if repo.ID == 0 {
+		panic("repo.id is zero")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rs := s.restored[repo.ID]
+	if rs == nil {
+		return false
+	}
+	delete(s.restored, repo.ID)
+
+	if s.index[repo.ID] != nil {
+		return false
+	}
+
+	interval := rs.Interval
+	switch {
+	case interval > maxDelay:
+		interval = maxDelay
+	case interval < minDelay:
+		interval = minDelay
+	}
+
+	heap.Push(s, &scheduledRepoUpdate{
+		Repo:        repo,
+		Interval:    interval,
+		Due:         rs.Due,
+		LastUpdated: rs.LastUpdated,
+		LastChanged: rs.LastChanged,
+		LastError:   rs.LastError,
+	})
+
+	s.rescheduleTimer()
+
+	return !rs.LastUpdated.IsZero() && rs.LastError == ""
+}
+
+// recordUpdate records the outcome of an update of a repo in the schedule
+// and returns the resulting schedule of the repo to persist. It returns nil
+// if the repo is not in the schedule.
+func (s *schedule) recordUpdate(repo *configuredRepo2, resp *gitserverprotocol.RepoUpdateResponse, err error) *RepoSchedule {
+	if repo.ID == 0 {
+		panic("repo.id is zero")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update := s.index[repo.ID]
+	if update == nil {
+		return nil
+	}
+
+	update.LastUpdated = timeNow()
+	update.LastError = ""
+	switch {
+	case err != nil:
+		update.LastError = err.Error()
+	case resp != nil && resp.Error != "":
+		update.LastError = resp.Error
+	}
+	if resp != nil && resp.LastChanged != nil {
+		update.LastChanged = *resp.LastChanged
+	}
+
+	return &RepoSchedule{
+		RepoID:      repo.ID,
+		Interval:    update.Interval,
+		Due:         update.Due,
+		LastUpdated: update.LastUpdated,
+		LastChanged: update.LastChanged,
+		LastError:   update.LastError,
+	}
+}
+
 // updateInterval updates the update interval of a repo in the schedule.
 // It does nothing if the repo is not in the schedule.
 func (s *schedule) updateInterval(repo *configuredRepo2, interval time.Duration) {
@@ -722,6 +881,7 @@ func (s *schedule) reset() {
 
 	s.heap = s.heap[:0]
 	s.index = map[uint32]*scheduledRepoUpdate{}
+	s.restored = map[uint32]*RepoSchedule{}
 	s.wakeup = make(chan struct{}, notifyChanBuffer)
 	if s.timer != nil {
 		s.timer.Stop()
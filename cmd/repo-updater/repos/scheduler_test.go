@@ -271,7 +271,7 @@ func TestUpdateQueue_enqueue(t *testing.T) {
 			r, stop := startRecording()
 			defer stop()
 
-			s := NewUpdateScheduler()
+			s := NewUpdateScheduler(nil)
 
 			for _, call := range test.calls {
 				s.updateQueue.enqueue(&call.repo, call.priority)
@@ -437,7 +437,7 @@ func TestUpdateQueue_remove(t *testing.T) {
 			r, stop := startRecording()
 			defer stop()
 
-			s := NewUpdateScheduler()
+			s := NewUpdateScheduler(nil)
 			setupInitialQueue(s, test.initialQueue)
 
 			// Perform the removals.
@@ -509,7 +509,7 @@ func TestUpdateQueue_acquireNext(t *testing.T) {
 			r, stop := startRecording()
 			defer stop()
 
-			s := NewUpdateScheduler()
+			s := NewUpdateScheduler(nil)
 			setupInitialQueue(s, test.initialQueue)
 
 			// Test aquireNext.
@@ -685,7 +685,7 @@ func TestSchedule_upsert(t *testing.T) {
 			r, stop := startRecording()
 			defer stop()
 
-			s := NewUpdateScheduler()
+			s := NewUpdateScheduler(nil)
 			setupInitialSchedule(s, test.initialSchedule)
 
 			for _, call := range test.upsertCalls {
@@ -863,7 +863,7 @@ func TestSchedule_updateInterval(t *testing.T) {
 			r, stop := startRecording()
 			defer stop()
 
-			s := NewUpdateScheduler()
+			s := NewUpdateScheduler(nil)
 			setupInitialSchedule(s, test.initialSchedule)
 
 			for _, call := range test.updateCalls {
@@ -961,7 +961,7 @@ func TestSchedule_remove(t *testing.T) {
 			r, stop := startRecording()
 			defer stop()
 
-			s := NewUpdateScheduler()
+			s := NewUpdateScheduler(nil)
 			setupInitialSchedule(s, test.initialSchedule)
 
 			for _, call := range test.removeCalls {
@@ -1123,7 +1123,7 @@ func TestUpdateScheduler_runSchedule(t *testing.T) {
 			r, stop := startRecording()
 			defer stop()
 
-			s := NewUpdateScheduler()
+			s := NewUpdateScheduler(nil)
 
 			setupInitialSchedule(s, test.initialSchedule)
 
@@ -1155,6 +1155,7 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 		mockRequestRepoUpdates []*mockRequestRepoUpdate
 		finalSchedule          []*scheduledRepoUpdate
 		finalQueue             []*repoUpdate
+		persisted              []*RepoSchedule
 		timeAfterFuncDelays    []time.Duration
 		expectedNotifications  func(s *updateScheduler) []chan struct{}
 	}{
@@ -1211,13 +1212,64 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 				},
 			},
 			finalSchedule: []*scheduledRepoUpdate{
-				{Repo: a, Interval: time.Minute, Due: defaultTime.Add(time.Minute)},
+				{
+					Repo:        a,
+					Interval:    time.Minute,
+					Due:         defaultTime.Add(time.Minute),
+					LastUpdated: defaultTime,
+					LastChanged: defaultTime,
+				},
+			},
+			persisted: []*RepoSchedule{
+				{
+					RepoID:      a.ID,
+					Interval:    time.Minute,
+					Due:         defaultTime.Add(time.Minute),
+					LastUpdated: defaultTime,
+					LastChanged: defaultTime,
+				},
 			},
 			timeAfterFuncDelays: []time.Duration{time.Minute},
 			expectedNotifications: func(s *updateScheduler) []chan struct{} {
 				return []chan struct{}{s.schedule.wakeup}
 			},
 		},
+		{
+			name:                   "update error recorded",
+			gitMaxConcurrentClones: 1,
+			initialSchedule: []*scheduledRepoUpdate{
+				{Repo: a, Interval: time.Hour, Due: defaultTime.Add(time.Hour), LastChanged: defaultTime},
+			},
+			initialQueue: []*repoUpdate{
+				{Repo: a, Seq: 1},
+			},
+			mockRequestRepoUpdates: []*mockRequestRepoUpdate{
+				{
+					repo: a,
+					resp: &gitserverprotocol.RepoUpdateResponse{Error: "fetch failed"},
+				},
+			},
+			finalSchedule: []*scheduledRepoUpdate{
+				{
+					Repo:        a,
+					Interval:    time.Hour,
+					Due:         defaultTime.Add(time.Hour),
+					LastUpdated: defaultTime,
+					LastChanged: defaultTime,
+					LastError:   "fetch failed",
+				},
+			},
+			persisted: []*RepoSchedule{
+				{
+					RepoID:      a.ID,
+					Interval:    time.Hour,
+					Due:         defaultTime.Add(time.Hour),
+					LastUpdated: defaultTime,
+					LastChanged: defaultTime,
+					LastError:   "fetch failed",
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -1254,7 +1306,16 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 			}
 			defer func() { requestRepoUpdate = nil }()
 
-			s := NewUpdateScheduler()
+			store := new(FakeStore)
+			if err := store.UpsertRepos(context.Background(),
+				&Repo{Name: string(a.Name)},
+				&Repo{Name: string(b.Name)},
+				&Repo{Name: string(c.Name)},
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewUpdateScheduler(store)
 
 			// unbuffer the channel
 			s.updateQueue.notifyEnqueue = make(chan struct{})
@@ -1285,6 +1346,7 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 			verifySchedule(t, s, test.finalSchedule)
 			verifyQueue(t, s, test.finalQueue)
 			verifyRecording(t, s, test.timeAfterFuncDelays, test.expectedNotifications, r)
+			verifyPersisted(t, store, test.persisted)
 
 			// Cancel the context.
 			cancel()
@@ -1295,6 +1357,23 @@ func TestUpdateScheduler_runUpdateLoop(t *testing.T) {
 	}
 }
 
+func verifyPersisted(t *testing.T, store Store, expected []*RepoSchedule) {
+	t.Helper()
+
+	persisted, err := store.ListRepoSchedules(context.Background(), StoreListRepoSchedulesArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(expected) == 0 && len(persisted) == 0 {
+		return
+	}
+
+	if !reflect.DeepEqual(expected, persisted) {
+		t.Fatalf("\nexpected persisted schedules\n%s\ngot\n%s", spew.Sdump(expected), spew.Sdump(persisted))
+	}
+}
+
 func verifyRecording(t *testing.T, s *updateScheduler, timeAfterFuncDelays []time.Duration, expectedNotifications func(s *updateScheduler) []chan struct{}, r *recording) {
 	if !reflect.DeepEqual(timeAfterFuncDelays, r.timeAfterFuncDelays) {
 		t.Fatalf("\nexpected timeAfterFuncDelays\n%s\ngot\n%s", spew.Sdump(timeAfterFuncDelays), spew.Sdump(r.timeAfterFuncDelays))
@@ -1608,7 +1687,7 @@ func TestUpdateScheduler_updateSource(t *testing.T) {
 			r, stop := startRecording()
 			defer stop()
 
-			s := NewUpdateScheduler()
+			s := NewUpdateScheduler(nil)
 			s.sourceRepos = test.initialSourceRepos
 			setupInitialSchedule(s, test.initialSchedule)
 			setupInitialQueue(s, test.initialQueue)
@@ -1629,3 +1708,79 @@ func TestUpdateScheduler_updateSource(t *testing.T) {
 }
 
 // TODO: update enabled state and url once in the queue?
+
+func TestUpdateScheduler_Restore(t *testing.T) {
+	ctx := context.Background()
+
+	a := &Repo{Name: "a", Enabled: true}
+	b := &Repo{Name: "b", Enabled: true}
+	c := &Repo{Name: "c", Enabled: true}
+
+	store := new(FakeStore)
+	if err := store.UpsertRepos(ctx, a, b, c); err != nil {
+		t.Fatal(err)
+	}
+
+	// a was updated successfully, b failed to update and c was never updated.
+	if err := store.UpsertRepoSchedules(ctx,
+		&RepoSchedule{
+			RepoID:      a.ID,
+			Interval:    time.Hour,
+			Due:         defaultTime.Add(time.Hour),
+			LastUpdated: defaultTime.Add(-time.Minute),
+			LastChanged: defaultTime.Add(-2 * time.Hour),
+		},
+		&RepoSchedule{
+			RepoID:      b.ID,
+			Interval:    2 * time.Hour,
+			Due:         defaultTime.Add(2 * time.Hour),
+			LastUpdated: defaultTime.Add(-time.Minute),
+			LastError:   "fetch failed",
+		},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	r, stop := startRecording()
+	defer stop()
+
+	s := NewUpdateScheduler(store)
+	if err := s.Restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.Update(a, b, c)
+
+	ra, rb, rc := configuredRepo2FromRepo(a), configuredRepo2FromRepo(b), configuredRepo2FromRepo(c)
+
+	// Only the repos whose last update didn't succeed are updated right away.
+	verifyQueue(t, s, []*repoUpdate{
+		{Repo: rb, Seq: 1},
+		{Repo: rc, Seq: 2},
+	})
+	verifySchedule(t, s, []*scheduledRepoUpdate{
+		{Repo: rc, Interval: minDelay, Due: defaultTime.Add(minDelay)},
+		{
+			Repo:        ra,
+			Interval:    time.Hour,
+			Due:         defaultTime.Add(time.Hour),
+			LastUpdated: defaultTime.Add(-time.Minute),
+			LastChanged: defaultTime.Add(-2 * time.Hour),
+		},
+		{
+			Repo:        rb,
+			Interval:    2 * time.Hour,
+			Due:         defaultTime.Add(2 * time.Hour),
+			LastUpdated: defaultTime.Add(-time.Minute),
+			LastError:   "fetch failed",
+		},
+	})
+
+	if len(r.timeAfterFuncDelays) != 3 {
+		t.Errorf("expected the timer to be rescheduled 3 times; got %v", r.timeAfterFuncDelays)
+	}
+
+	// The restored schedules are only used once.
+	if len(s.schedule.restored) != 0 {
+		t.Errorf("expected no restored schedules left; got %d", len(s.schedule.restored))
+	}
+}
@@ -29,6 +29,9 @@ type Store interface {
 	UpsertRepos(ctx context.Context, repos ...*Repo) error
 
 	ListAllRepoNames(context.Context) ([]api.RepoName, error)
+
+	ListRepoSchedules(context.Context, StoreListRepoSchedulesArgs) ([]*RepoSchedule, error)
+	UpsertRepoSchedules(ctx context.Context, schedules ...*RepoSchedule) error
 }
 
 // StoreListReposArgs is a query arguments type used by
@@ -62,6 +65,13 @@ type StoreListExternalServicesArgs struct {
 	Kinds []string
 }
 
+// StoreListRepoSchedulesArgs is a query arguments type used by
+// the ListRepoSchedules method of Store implementations.
+type StoreListRepoSchedulesArgs struct {
+	// RepoIDs of the repos whose schedules to list. When zero-valued, this is omitted from the predicate set.
+	RepoIDs []uint32
+}
+
 // ErrNoResults is returned by Store method invocations that yield no result set.
 var ErrNoResults = errors.New("store: no results")
 
@@ -408,6 +418,142 @@ func listAllRepoNamesQuery(cursor, limit int64) *sqlf.Query {
 	return sqlf.Sprintf(listAllRepoNamesQueryFmtstr, cursor, limit)
 }
 
+// ListRepoSchedules lists the stored update schedules of repos matching the given args.
+func (s DBStore) ListRepoSchedules(ctx context.Context, args StoreListRepoSchedulesArgs) (schedules []*RepoSchedule, _ error) {
+	return schedules, s.paginate(ctx, 0, 0, listRepoSchedulesQuery(args),
+		func(sc scanner) (last, count int64, err error) {
+			var rs RepoSchedule
+			if err = scanRepoSchedule(&rs, sc); err != nil {
+				return 0, 0, err
+			}
+			schedules = append(schedules, &rs)
+			return int64(rs.RepoID), 1, nil
+		},
+	)
+}
+
+const listRepoSchedulesQueryFmtstr = `
+-- source: cmd/repo-updater/repos/store.go:DBStore.ListRepoSchedules
+SELECT
+  repo_id,
+  interval_seconds,
+  due_at,
+  last_updated_at,
+  last_changed_at,
+  last_error,
+  updated_at
+FROM repo_update_schedules
+WHERE repo_id > %s
+AND %s
+ORDER BY repo_id ASC LIMIT %s
+`
+
+func listRepoSchedulesQuery(args StoreListRepoSchedulesArgs) paginatedQuery {
+	pred := sqlf.Sprintf("TRUE")
+	if len(args.RepoIDs) > 0 {
+		ids := make([]*sqlf.Query, 0, len(args.RepoIDs))
+		for _, id := range args.RepoIDs {
+			ids = append(ids, sqlf.Sprintf("%d", id))
+		}
+		pred = sqlf.Sprintf("repo_id IN (%s)", sqlf.Join(ids, ","))
+	}
+
+	return func(cursor, limit int64) *sqlf.Query {
+		return sqlf.Sprintf(listRepoSchedulesQueryFmtstr, cursor, pred, limit)
+	}
+}
+
+// UpsertRepoSchedules updates or inserts the given repo update schedules.
+// Schedules of repos which don't exist (anymore) are ignored.
+func (s DBStore) UpsertRepoSchedules(ctx context.Context, schedules ...*RepoSchedule) error {
+	// Each schedule takes 7 bind parameters, and Postgres supports at most
+	// 32767 per statement.
+	const batchSize = 1000
+
+	for len(schedules) > 0 {
+		batch := schedules
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		schedules = schedules[len(batch):]
+
+		q := upsertRepoSchedulesQuery(batch)
+		rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+		if err != nil {
+			return err
+		}
+		// Nothing to scan
+		if err = rows.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func upsertRepoSchedulesQuery(schedules []*RepoSchedule) *sqlf.Query {
+	vals := make([]*sqlf.Query, 0, len(schedules))
+	for _, rs := range schedules {
+		vals = append(vals, sqlf.Sprintf(
+			upsertRepoSchedulesQueryValueFmtstr,
+			rs.RepoID,
+			int64(rs.Interval/time.Second),
+			rs.Due.UTC(),
+			nullTimeColumn(rs.LastUpdated.UTC()),
+			nullTimeColumn(rs.LastChanged.UTC()),
+			nullStringColumn(rs.LastError),
+			nullTimeColumn(rs.UpdatedAt.UTC()),
+		))
+	}
+
+	return sqlf.Sprintf(
+		upsertRepoSchedulesQueryFmtstr,
+		sqlf.Join(vals, ",\n"),
+	)
+}
+
+const upsertRepoSchedulesQueryValueFmtstr = `
+  (%s::integer, %s::integer, %s::timestamptz, %s::timestamptz, %s::timestamptz, %s::text, %s::timestamptz)
+`
+
+const upsertRepoSchedulesQueryFmtstr = `
+-- source: cmd/repo-updater/repos/store.go:DBStore.UpsertRepoSchedules
+INSERT INTO repo_update_schedules (
+  repo_id,
+  interval_seconds,
+  due_at,
+  last_updated_at,
+  last_changed_at,
+  last_error,
+  updated_at
+)
+SELECT
+  repo_id,
+  interval_seconds,
+  due_at,
+  last_updated_at,
+  last_changed_at,
+  last_error,
+  COALESCE(updated_at, now())
+FROM (VALUES %s) AS batch (
+  repo_id,
+  interval_seconds,
+  due_at,
+  last_updated_at,
+  last_changed_at,
+  last_error,
+  updated_at
+)
+WHERE EXISTS (SELECT 1 FROM repo WHERE repo.id = batch.repo_id)
+ON CONFLICT (repo_id) DO UPDATE
+SET
+  interval_seconds = excluded.interval_seconds,
+  due_at           = excluded.due_at,
+  last_updated_at  = excluded.last_updated_at,
+  last_changed_at  = excluded.last_changed_at,
+  last_error       = excluded.last_error,
+  updated_at       = excluded.updated_at
+`
+
 // a paginatedQuery returns a query with the given pagination
 // parameters
 type paginatedQuery func(cursor, limit int64) *sqlf.Query
@@ -794,6 +940,21 @@ func scanExternalService(svc *ExternalService, s scanner) error {
 	)
 }
 
+func scanRepoSchedule(rs *RepoSchedule, s scanner) error {
+	var intervalSeconds int64
+	err := s.Scan(
+		&rs.RepoID,
+		&intervalSeconds,
+		&rs.Due,
+		&dbutil.NullTime{Time: &rs.LastUpdated},
+		&dbutil.NullTime{Time: &rs.LastChanged},
+		&dbutil.NullString{S: &rs.LastError},
+		&rs.UpdatedAt,
+	)
+	rs.Interval = time.Duration(intervalSeconds) * time.Second
+	return err
+}
+
 func scanRepo(r *Repo, s scanner) error {
 	var sources, metadata json.RawMessage
 	err := s.Scan(
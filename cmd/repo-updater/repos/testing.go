@@ -57,12 +57,15 @@ type FakeStore struct {
 	ListReposError              error // error to be returned in ListRepos
 	UpsertReposError            error // error to be returned in UpsertRepos
 	ListAllRepoNamesError       error // error to be returned in ListAllRepoNames
-
-	svcIDSeq  int64
-	repoIDSeq uint32
-	svcByID   map[int64]*ExternalService
-	repoByID  map[uint32]*Repo
-	parent    *FakeStore
+	ListRepoSchedulesError      error // error to be returned in ListRepoSchedules
+	UpsertRepoSchedulesError    error // error to be returned in UpsertRepoSchedules
+
+	svcIDSeq       int64
+	repoIDSeq      uint32
+	svcByID        map[int64]*ExternalService
+	repoByID       map[uint32]*Repo
+	scheduleByRepo map[uint32]*RepoSchedule
+	parent         *FakeStore
 }
 
 // Transact returns a TxStore whose methods operate within the context of a transaction.
@@ -82,6 +85,11 @@ func (s *FakeStore) Transact(ctx context.Context) (TxStore, error) {
 		repoByID[r.ID] = clone
 	}
 
+	scheduleByRepo := make(map[uint32]*RepoSchedule, len(s.scheduleByRepo))
+	for id, rs := range s.scheduleByRepo {
+		scheduleByRepo[id] = rs.Clone()
+	}
+
 	return &FakeStore{
 		ListExternalServicesError:   s.ListExternalServicesError,
 		UpsertExternalServicesError: s.UpsertExternalServicesError,
@@ -89,12 +97,15 @@ func (s *FakeStore) Transact(ctx context.Context) (TxStore, error) {
 		ListReposError:              s.ListReposError,
 		UpsertReposError:            s.UpsertReposError,
 		ListAllRepoNamesError:       s.ListAllRepoNamesError,
-
-		svcIDSeq:  s.svcIDSeq,
-		svcByID:   svcByID,
-		repoIDSeq: s.repoIDSeq,
-		repoByID:  repoByID,
-		parent:    s,
+		ListRepoSchedulesError:      s.ListRepoSchedulesError,
+		UpsertRepoSchedulesError:    s.UpsertRepoSchedulesError,
+
+		svcIDSeq:       s.svcIDSeq,
+		svcByID:        svcByID,
+		repoIDSeq:      s.repoIDSeq,
+		repoByID:       repoByID,
+		scheduleByRepo: scheduleByRepo,
+		parent:         s,
 	}, nil
 }
 
@@ -267,6 +278,52 @@ func (s FakeStore) ListAllRepoNames(ctx context.Context) ([]api.RepoName, error)
 	return names, nil
 }
 
+// ListRepoSchedules lists all stored repo update schedules that match the given args.
+func (s FakeStore) ListRepoSchedules(ctx context.Context, args StoreListRepoSchedulesArgs) ([]*RepoSchedule, error) {
+	if s.ListRepoSchedulesError != nil {
+		return nil, s.ListRepoSchedulesError
+	}
+
+	ids := make(map[uint32]bool, len(args.RepoIDs))
+	for _, id := range args.RepoIDs {
+		ids[id] = true
+	}
+
+	schedules := make([]*RepoSchedule, 0, len(s.scheduleByRepo))
+	for id, rs := range s.scheduleByRepo {
+		if len(ids) == 0 || ids[id] {
+			schedules = append(schedules, rs.Clone())
+		}
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].RepoID < schedules[j].RepoID
+	})
+
+	return schedules, nil
+}
+
+// UpsertRepoSchedules upserts all the given repo update schedules in the store.
+// Schedules of repos which are not in the store are ignored.
+func (s *FakeStore) UpsertRepoSchedules(ctx context.Context, schedules ...*RepoSchedule) error {
+	if s.UpsertRepoSchedulesError != nil {
+		return s.UpsertRepoSchedulesError
+	}
+
+	if s.scheduleByRepo == nil {
+		s.scheduleByRepo = make(map[uint32]*RepoSchedule, len(schedules))
+	}
+
+	for _, rs := range schedules {
+		if _, ok := s.repoByID[rs.RepoID]; !ok {
+			continue
+		}
+		s.scheduleByRepo[rs.RepoID] = rs.Clone()
+	}
+
+	return nil
+}
+
 func evalOr(bs ...bool) bool {
 	if len(bs) == 0 {
 		return true
@@ -312,6 +369,7 @@ func (s *FakeStore) UpsertRepos(ctx context.Context, upserts ...*Repo) error {
 
 	for _, r := range deletes {
 		delete(s.repoByID, r.ID)
+		delete(s.scheduleByRepo, r.ID)
 	}
 
 	for _, r := range updates {
@@ -892,3 +892,29 @@ func (es ExternalServices) With(opts ...func(*ExternalService)) ExternalServices
 	clone.Apply(opts...)
 	return clone
 }
+
+// RepoSchedule is the update schedule of a Repo in the update scheduler. It is
+// stored so that the scheduler resumes where it left off when repo-updater
+// restarts.
+type RepoSchedule struct {
+	// RepoID is the ID of the scheduled Repo.
+	RepoID uint32
+	// Interval is how regularly the repo is updated.
+	Interval time.Duration
+	// Due is the next time that the repo will be enqueued for an update.
+	Due time.Time
+	// LastUpdated is when the last update of the repo finished.
+	LastUpdated time.Time
+	// LastChanged is when the repo last changed, according to gitserver.
+	LastChanged time.Time
+	// LastError is the error of the last update of the repo, if it failed.
+	LastError string
+	// UpdatedAt is when this schedule was last stored.
+	UpdatedAt time.Time
+}
+
+// Clone returns a clone of the given schedule.
+func (s *RepoSchedule) Clone() *RepoSchedule {
+	clone := *s
+	return &clone
+}
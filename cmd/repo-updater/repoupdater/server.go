@@ -53,6 +53,9 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/exclude-repo", s.handleExcludeRepo)
 	mux.HandleFunc("/sync-external-service", s.handleExternalServiceSync)
 	mux.HandleFunc("/status-messages", s.handleStatusMessages)
+	mux.HandleFunc("/webhooks/github", s.handleWebhook(githubWebhook))
+	mux.HandleFunc("/webhooks/gitlab", s.handleWebhook(gitlabWebhook))
+	mux.HandleFunc("/webhooks/bitbucket-server", s.handleWebhook(bitbucketServerWebhook))
 	return mux
 }
 
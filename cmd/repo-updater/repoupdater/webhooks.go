@@ -0,0 +1,284 @@
+package repoupdater
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/repos"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// maxWebhookPayloadSize is the maximum size of a webhook payload. It is the
+// maximum size of the payloads GitHub sends.
+const maxWebhookPayloadSize = 25 << 20
+
+// A webhook receives the events of the code hosts of one kind of external
+// service, and schedules an update of the repositories they changed.
+type webhook struct {
+	// kind is the kind of the external services whose code hosts send the
+	// events.
+	kind string
+
+	// serviceType is the service type of the external repositories of those
+	// external services.
+	serviceType string
+
+	// authenticate reports whether the event with the given headers and
+	// payload was sent by a code host configured with the given webhook
+	// secret.
+	authenticate func(h http.Header, payload []byte, secret string) bool
+
+	// externalIDs returns the external IDs of the repositories changed by the
+	// event with the given headers and payload. It returns no IDs for events
+	// which don't change repositories.
+	externalIDs func(h http.Header, payload []byte) ([]string, error)
+}
+
+// webhookResponse is the response to a webhook event.
+type webhookResponse struct {
+	// Updated are the names of the repositories whose update was scheduled.
+	Updated []api.RepoName `json:"updated"`
+}
+
+// githubWebhook receives the push events of GitHub webhooks.
+//
+// See https://developer.github.com/webhooks/.
+var githubWebhook = &webhook{
+	kind:        "github",
+	serviceType: github.ServiceType,
+	authenticate: func(h http.Header, payload []byte, secret string) bool {
+		if sig := h.Get("X-Hub-Signature-256"); sig != "" {
+			return validHMACSignature(sha256.New, sig, "sha256=", payload, secret)
+		}
+		return validHMACSignature(sha1.New, h.Get("X-Hub-Signature"), "sha1=", payload, secret)
+	},
+	externalIDs: func(h http.Header, payload []byte) ([]string, error) {
+		if h.Get("X-GitHub-Event") != "push" {
+			return nil, nil
+		}
+		var event struct {
+			Repository struct {
+				NodeID string `json:"node_id"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		if event.Repository.NodeID == "" {
+			return nil, nil
+		}
+		return []string{event.Repository.NodeID}, nil
+	},
+}
+
+// gitlabWebhook receives the push, tag push and repository update events of
+// GitLab system hooks.
+//
+// See https://docs.gitlab.com/ee/system_hooks/system_hooks.html.
+var gitlabWebhook = &webhook{
+	kind:        "gitlab",
+	serviceType: gitlab.ServiceType,
+	authenticate: func(h http.Header, _ []byte, secret string) bool {
+		return subtle.ConstantTimeCompare([]byte(h.Get("X-Gitlab-Token")), []byte(secret)) == 1
+	},
+	externalIDs: func(_ http.Header, payload []byte) ([]string, error) {
+		var event struct {
+			EventName string `json:"event_name"`
+			ProjectID int    `json:"project_id"`
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		switch event.EventName {
+		case "push", "tag_push", "repository_update":
+			if event.ProjectID != 0 {
+				return []string{strconv.Itoa(event.ProjectID)}, nil
+			}
+		}
+		return nil, nil
+	},
+}
+
+// bitbucketServerWebhook receives the repository push events of Bitbucket
+// Server webhooks.
+//
+// See https://confluence.atlassian.com/bitbucketserver/event-payload-938025882.html.
+var bitbucketServerWebhook = &webhook{
+	kind:        "bitbucketserver",
+	serviceType: bitbucketserver.ServiceType,
+	authenticate: func(h http.Header, payload []byte, secret string) bool {
+		return validHMACSignature(sha256.New, h.Get("X-Hub-Signature"), "sha256=", payload, secret)
+	},
+	externalIDs: func(h http.Header, payload []byte) ([]string, error) {
+		if h.Get("X-Event-Key") != "repo:refs_changed" {
+			return nil, nil
+		}
+		var event struct {
+			Repository struct {
+				ID int `json:"id"`
+			} `json:"repository"`
+		}
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		if event.Repository.ID == 0 {
+			return nil, nil
+		}
+		return []string{strconv.Itoa(event.Repository.ID)}, nil
+	},
+}
+
+// handleWebhook returns the handler of the events of the given webhook. An
+// event is accepted if it is authenticated by the webhook secret of one of
+// the external services of the webhook's kind. The update of the repositories
+// the event changed on the code hosts of those external services is scheduled
+// right away.
+func (s *Server) handleWebhook(wh *webhook) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		payload, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayloadSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		serviceIDs, err := s.authenticateWebhook(r, wh, payload)
+		if err != nil {
+			respond(w, http.StatusInternalServerError, err)
+			return
+		}
+		if len(serviceIDs) == 0 {
+			// 🚨 SECURITY: Don't reveal whether an external service with a
+			// webhook secret exists.
+			http.Error(w, "invalid webhook signature", http.StatusUnauthorized)
+			return
+		}
+
+		ids, err := wh.externalIDs(r.Header, payload)
+		if err != nil {
+			http.Error(w, "invalid webhook payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := webhookResponse{Updated: []api.RepoName{}}
+		if len(ids) == 0 {
+			// Pings and events which don't change repositories.
+			respond(w, http.StatusOK, resp)
+			return
+		}
+
+		specs := make([]api.ExternalRepoSpec, 0, len(ids)*len(serviceIDs))
+		for _, serviceID := range serviceIDs {
+			for _, id := range ids {
+				specs = append(specs, api.ExternalRepoSpec{
+					ID:          id,
+					ServiceType: wh.serviceType,
+					ServiceID:   serviceID,
+				})
+			}
+		}
+
+		rs, err := s.Store.ListRepos(r.Context(), repos.StoreListReposArgs{ExternalRepos: specs})
+		if err != nil {
+			respond(w, http.StatusInternalServerError, errors.Wrap(err, "store.list-repos"))
+			return
+		}
+
+		for _, repo := range rs {
+			var cloneURL string
+			if urls := repo.CloneURLs(); len(urls) > 0 {
+				cloneURL = urls[0]
+			}
+			s.Scheduler.UpdateOnce(repo.ID, api.RepoName(repo.Name), cloneURL)
+			resp.Updated = append(resp.Updated, api.RepoName(repo.Name))
+		}
+		log15.Debug("webhook", "kind", wh.kind, "externalIDs", ids, "updated", resp.Updated)
+
+		respond(w, http.StatusOK, resp)
+	}
+}
+
+// authenticateWebhook returns the service IDs of the external services of the
+// webhook's kind whose webhook secret authenticates the event with the given
+// payload.
+func (s *Server) authenticateWebhook(r *http.Request, wh *webhook, payload []byte) ([]string, error) {
+	es, err := s.Store.ListExternalServices(r.Context(), repos.StoreListExternalServicesArgs{
+		Kinds: []string{wh.kind},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "store.list-external-services")
+	}
+
+	var serviceIDs []string
+	for _, e := range es {
+		secret, baseURL, err := webhookConfig(e)
+		if err != nil {
+			log15.Warn("webhook: skipping external service with invalid config", "id", e.ID, "error", err)
+			continue
+		}
+		if secret == "" || !wh.authenticate(r.Header, payload, secret) {
+			continue
+		}
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			log15.Warn("webhook: skipping external service with invalid url", "id", e.ID, "error", err)
+			continue
+		}
+		serviceIDs = append(serviceIDs, repos.NormalizeBaseURL(u).String())
+	}
+	return serviceIDs, nil
+}
+
+// webhookConfig returns the webhook secret and URL of the given external
+// service.
+func webhookConfig(e *repos.ExternalService) (secret, baseURL string, err error) {
+	cfg, err := e.Configuration()
+	if err != nil {
+		return "", "", err
+	}
+	switch c := cfg.(type) {
+	case *schema.GitHubConnection:
+		return c.WebhookSecret, c.Url, nil
+	case *schema.GitLabConnection:
+		return c.WebhookSecret, c.Url, nil
+	case *schema.BitbucketServerConnection:
+		return c.WebhookSecret, c.Url, nil
+	default:
+		return "", "", errors.Errorf("external service kind %q doesn't support webhooks", e.Kind)
+	}
+}
+
+// validHMACSignature reports whether sig, which is the hex encoded HMAC of
+// payload keyed with secret following prefix, is valid.
+func validHMACSignature(h func() hash.Hash, sig, prefix string, payload []byte, secret string) bool {
+	if !strings.HasPrefix(sig, prefix) {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, prefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
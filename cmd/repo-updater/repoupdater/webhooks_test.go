@@ -0,0 +1,232 @@
+package repoupdater
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/repos"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
+)
+
+func TestServer_handleWebhook(t *testing.T) {
+	ctx := context.Background()
+
+	store := new(repos.FakeStore)
+	must(store.UpsertExternalServices(ctx,
+		&repos.ExternalService{
+			Kind:        "GITHUB",
+			DisplayName: "github.com",
+			Config:      `{"url": "https://github.com", "token": "t", "webhookSecret": "github-secret"}`,
+		},
+		&repos.ExternalService{
+			Kind:        "GITHUB",
+			DisplayName: "github.example.com",
+			Config:      `{"url": "https://github.example.com", "token": "t"}`,
+		},
+		&repos.ExternalService{
+			Kind:        "GITLAB",
+			DisplayName: "gitlab.com",
+			Config:      `{"url": "https://gitlab.com", "token": "t", "projectQuery": ["none"], "webhookSecret": "gitlab-secret"}`,
+		},
+		&repos.ExternalService{
+			Kind:        "BITBUCKETSERVER",
+			DisplayName: "bitbucket.sgdev.org",
+			Config:      `{"url": "https://bitbucket.sgdev.org", "token": "t", "username": "u", "webhookSecret": "bitbucket-secret"}`,
+		},
+	))
+	must(store.UpsertRepos(ctx,
+		&repos.Repo{
+			Name: "github.com/foo/bar",
+			ExternalRepo: api.ExternalRepoSpec{
+				ID:          "MDEwOlJlcG9zaXRvcnkx",
+				ServiceType: github.ServiceType,
+				ServiceID:   "https://github.com/",
+			},
+		},
+		&repos.Repo{
+			Name: "github.example.com/foo/bar",
+			ExternalRepo: api.ExternalRepoSpec{
+				ID:          "MDEwOlJlcG9zaXRvcnkx",
+				ServiceType: github.ServiceType,
+				ServiceID:   "https://github.example.com/",
+			},
+		},
+		&repos.Repo{
+			Name: "gitlab.com/foo/bar",
+			ExternalRepo: api.ExternalRepoSpec{
+				ID:          "42",
+				ServiceType: gitlab.ServiceType,
+				ServiceID:   "https://gitlab.com/",
+			},
+		},
+		&repos.Repo{
+			Name: "bitbucket.sgdev.org/FOO/bar",
+			ExternalRepo: api.ExternalRepoSpec{
+				ID:          "7",
+				ServiceType: bitbucketserver.ServiceType,
+				ServiceID:   "https://bitbucket.sgdev.org/",
+			},
+		},
+	))
+
+	sign := func(h func() hash.Hash, prefix, secret, payload string) string {
+		mac := hmac.New(h, []byte(secret))
+		mac.Write([]byte(payload))
+		return prefix + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	githubPush := `{"ref": "refs/heads/master", "repository": {"node_id": "MDEwOlJlcG9zaXRvcnkx", "full_name": "foo/bar"}}`
+	gitlabPush := `{"event_name": "push", "project_id": 42}`
+	bitbucketPush := `{"eventKey": "repo:refs_changed", "repository": {"id": 7, "slug": "bar"}}`
+
+	for _, tc := range []struct {
+		name        string
+		path        string
+		header      map[string]string
+		payload     string
+		wantCode    int
+		wantUpdated []api.RepoName
+	}{
+		{
+			name: "github push",
+			path: "/webhooks/github",
+			header: map[string]string{
+				"X-GitHub-Event":      "push",
+				"X-Hub-Signature-256": sign(sha256.New, "sha256=", "github-secret", githubPush),
+			},
+			payload:     githubPush,
+			wantCode:    http.StatusOK,
+			wantUpdated: []api.RepoName{"github.com/foo/bar"},
+		},
+		{
+			name: "github push with sha1 signature",
+			path: "/webhooks/github",
+			header: map[string]string{
+				"X-GitHub-Event":  "push",
+				"X-Hub-Signature": sign(sha1.New, "sha1=", "github-secret", githubPush),
+			},
+			payload:     githubPush,
+			wantCode:    http.StatusOK,
+			wantUpdated: []api.RepoName{"github.com/foo/bar"},
+		},
+		{
+			name: "github ping",
+			path: "/webhooks/github",
+			header: map[string]string{
+				"X-GitHub-Event":      "ping",
+				"X-Hub-Signature-256": sign(sha256.New, "sha256=", "github-secret", `{"zen": "Keep it simple."}`),
+			},
+			payload:  `{"zen": "Keep it simple."}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "github push with invalid signature",
+			path: "/webhooks/github",
+			header: map[string]string{
+				"X-GitHub-Event":      "push",
+				"X-Hub-Signature-256": sign(sha256.New, "sha256=", "wrong-secret", githubPush),
+			},
+			payload:  githubPush,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "github push without signature",
+			path:     "/webhooks/github",
+			header:   map[string]string{"X-GitHub-Event": "push"},
+			payload:  githubPush,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "gitlab push",
+			path: "/webhooks/gitlab",
+			header: map[string]string{
+				"X-Gitlab-Event": "System Hook",
+				"X-Gitlab-Token": "gitlab-secret",
+			},
+			payload:     gitlabPush,
+			wantCode:    http.StatusOK,
+			wantUpdated: []api.RepoName{"gitlab.com/foo/bar"},
+		},
+		{
+			name: "gitlab project created",
+			path: "/webhooks/gitlab",
+			header: map[string]string{
+				"X-Gitlab-Event": "System Hook",
+				"X-Gitlab-Token": "gitlab-secret",
+			},
+			payload:  `{"event_name": "project_create", "project_id": 42}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "gitlab push with invalid token",
+			path: "/webhooks/gitlab",
+			header: map[string]string{
+				"X-Gitlab-Event": "System Hook",
+				"X-Gitlab-Token": "github-secret",
+			},
+			payload:  gitlabPush,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "bitbucket server push",
+			path: "/webhooks/bitbucket-server",
+			header: map[string]string{
+				"X-Event-Key":     "repo:refs_changed",
+				"X-Hub-Signature": sign(sha256.New, "sha256=", "bitbucket-secret", bitbucketPush),
+			},
+			payload:     bitbucketPush,
+			wantCode:    http.StatusOK,
+			wantUpdated: []api.RepoName{"bitbucket.sgdev.org/FOO/bar"},
+		},
+		{
+			name: "bitbucket server push with invalid signature",
+			path: "/webhooks/bitbucket-server",
+			header: map[string]string{
+				"X-Event-Key":     "repo:refs_changed",
+				"X-Hub-Signature": sign(sha256.New, "sha256=", "bitbucket-secret", gitlabPush),
+			},
+			payload:  bitbucketPush,
+			wantCode: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler := &recordingScheduler{}
+			s := &Server{Store: store, Scheduler: scheduler}
+
+			req := httptest.NewRequest("POST", tc.path, strings.NewReader(tc.payload))
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantCode {
+				t.Fatalf("got HTTP status code %d, want %d (%s)", rr.Code, tc.wantCode, rr.Body)
+			}
+			if !reflect.DeepEqual(scheduler.updated, tc.wantUpdated) {
+				t.Errorf("got updated repos %v, want %v", scheduler.updated, tc.wantUpdated)
+			}
+		})
+	}
+}
+
+type recordingScheduler struct {
+	fakeScheduler
+	updated []api.RepoName
+}
+
+func (s *recordingScheduler) UpdateOnce(_ uint32, name api.RepoName, _ string) {
+	s.updated = append(s.updated, name)
+}
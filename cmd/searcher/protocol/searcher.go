@@ -42,6 +42,11 @@ type Request struct {
 	// The deadline for the search request.
 	// It is parsed with time.Time.UnmarshalText.
 	Deadline string
+
+	// Stream if true will make searcher respond with newline-delimited JSON
+	// encoded StreamEvents, flushing each FileMatch as soon as it is
+	// found. Otherwise a single Response is written once the search is done.
+	Stream bool
 }
 
 // GitserverRepo returns the repository information necessary to perform gitserver requests.
@@ -64,6 +69,18 @@ type PatternInfo struct {
 	// when finding matches.
 	IsCaseSensitive bool
 
+	// IsStructuralPat if true will treat the Pattern as a structural search
+	// pattern. Holes like :[name] in the pattern match text with balanced
+	// parentheses, brackets, braces and strings, possibly across lines.
+	// Structural patterns are always case sensitive and never match paths.
+	IsStructuralPat bool
+
+	// IsMultiline if true will report each match as a single LineMatch, even
+	// if it spans several lines. The LineMatch's EndLineNumber and EndOffset
+	// describe where such a match ends. Otherwise a match which spans several
+	// lines is reported as one LineMatch per line.
+	IsMultiline bool
+
 	// ExcludePattern is a pattern that may not match the returned files' paths.
 	// eg '**/node_modules'
 	ExcludePattern string
@@ -128,6 +145,12 @@ func (p *PatternInfo) String() string {
 	if p.IsCaseSensitive {
 		args = append(args, "case")
 	}
+	if p.IsStructuralPat {
+		args = append(args, "structural")
+	}
+	if p.IsMultiline {
+		args = append(args, "multiline")
+	}
 	if !p.PatternMatchesContent {
 		args = append(args, "nocontent")
 	}
@@ -168,6 +191,31 @@ type Response struct {
 	DeadlineHit bool
 }
 
+// StreamContentType is the Content-Type of a streaming search response.
+const StreamContentType = "application/x-ndjson"
+
+// StreamEvent is a single value in a streaming search response (see
+// Request.Stream). Every event but the last contains a FileMatch. The last
+// event has Done set and describes the overall result of the search.
+type StreamEvent struct {
+	FileMatch *FileMatch `json:",omitempty"`
+
+	// Done is true for the last event in a stream.
+	Done bool `json:",omitempty"`
+
+	// LimitHit is true if the stream may not include all FileMatches because
+	// a match limit was hit. Only set on the last event.
+	LimitHit bool `json:",omitempty"`
+
+	// DeadlineHit is true if the stream may not include all FileMatches
+	// because a deadline was hit. Only set on the last event.
+	DeadlineHit bool `json:",omitempty"`
+
+	// Error is set on the last event if the search failed after some
+	// FileMatches had already been sent.
+	Error string `json:",omitempty"`
+}
+
 // FileMatch is the struct used by vscode to receive search results
 type FileMatch struct {
 	Path        string
@@ -193,4 +241,13 @@ type LineMatch struct {
 
 	// LimitHit is true if OffsetAndLengths may not include all OffsetAndLengths.
 	LimitHit bool
+
+	// EndLineNumber is the 0-based line number of the line on which the
+	// match ends, and EndOffset is the character offset in that line at which
+	// it ends. They are only set if the request has IsMultiline set. In that
+	// case Preview contains every line the match spans and OffsetAndLengths
+	// contains a single (Offset, Length) measured across those lines,
+	// including newlines.
+	EndLineNumber int `json:",omitempty"`
+	EndOffset     int `json:",omitempty"`
 }
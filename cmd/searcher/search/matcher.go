@@ -17,6 +17,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/pathmatch"
 	"github.com/sourcegraph/sourcegraph/pkg/store"
+	"github.com/sourcegraph/sourcegraph/pkg/structural"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
@@ -74,6 +75,13 @@ type readerGrep struct {
 	// re. It is the output of the longestLiteral function. It is only set if
 	// the regex has an empty LiteralPrefix.
 	literalSubstring []byte
+
+	// structural is the structural pattern to match. If it is set, re is nil.
+	structural *structural.Pattern
+
+	// multiline if true means each match is reported as a single LineMatch,
+	// even if it spans several lines.
+	multiline bool
 }
 
 // compile returns a readerGrep for matching p.
@@ -81,8 +89,15 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 	var (
 		re               *regexp.Regexp
 		literalSubstring []byte
+		structuralPat    *structural.Pattern
 	)
-	if p.Pattern != "" {
+	if p.IsStructuralPat {
+		var err error
+		structuralPat, err = structural.Compile(p.Pattern)
+		if err != nil {
+			return nil, err
+		}
+	} else if p.Pattern != "" {
 		expr := p.Pattern
 		if !p.IsRegExp {
 			expr = regexp.QuoteMeta(expr)
@@ -136,9 +151,11 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 
 	return &readerGrep{
 		re:               re,
-		ignoreCase:       !p.IsCaseSensitive,
+		ignoreCase:       !p.IsCaseSensitive && !p.IsStructuralPat,
 		matchPath:        matchPath,
 		literalSubstring: literalSubstring,
+		structural:       structuralPat,
+		multiline:        p.IsMultiline,
 	}, nil
 }
 
@@ -154,12 +171,18 @@ func (rg *readerGrep) Copy() *readerGrep {
 		ignoreCase:       rg.ignoreCase,
 		matchPath:        rg.matchPath.Copy(),
 		literalSubstring: rg.literalSubstring,
+		structural:       rg.structural,
+		multiline:        rg.multiline,
 	}
 }
 
 // matchString returns whether rg's regexp pattern matches s. It is intended to be
 // used to match file paths.
 func (rg *readerGrep) matchString(s string) bool {
+	if rg.structural != nil {
+		// Structural patterns describe code, not file paths.
+		return false
+	}
 	if rg.re == nil {
 		return true
 	}
@@ -173,6 +196,10 @@ func (rg *readerGrep) matchString(s string) bool {
 // LimitHit is true if some matches may not have been included in the result.
 // NOTE: This is not safe to use concurrently.
 func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []protocol.LineMatch, limitHit bool, err error) {
+	if rg.structural != nil {
+		return rg.findStructural(zf, f)
+	}
+
 	if rg.ignoreCase && rg.transformBuf == nil {
 		rg.transformBuf = make([]byte, zf.MaxLen)
 	}
@@ -234,7 +261,11 @@ func (rg *readerGrep) Find(zf *store.ZipFile, f *store.SrcFile) (matches []proto
 
 		lastMatchIndex = matchIndex
 		lastLineNumber = lineNumber
-		matches = appendMatches(matches, fileBuf[lineStart:lineEnd], fileMatchBuf[lineStart:lineEnd], lineNumber, start-lineStart, end-lineStart)
+		if rg.multiline {
+			matches = appendMultilineMatch(matches, fileBuf[lineStart:lineEnd], lineNumber, start-lineStart, end-lineStart)
+		} else {
+			matches = appendMatches(matches, fileBuf[lineStart:lineEnd], fileMatchBuf[lineStart:lineEnd], lineNumber, start-lineStart, end-lineStart)
+		}
 
 		if len(matches) > maxLineMatches {
 			matches = matches[:maxLineMatches]
@@ -301,6 +332,29 @@ func appendMatches(matches []protocol.LineMatch, fileBuf []byte, matchLineBuf []
 	return matches
 }
 
+// appendMultilineMatch appends a single LineMatch for the match [start, end)
+// in lineBuf, which contains the full line(s) that the match appears on.
+func appendMultilineMatch(matches []protocol.LineMatch, lineBuf []byte, lineNumber, start, end int) []protocol.LineMatch {
+	offset := utf8.RuneCount(lineBuf[:start])
+	length := utf8.RuneCount(lineBuf[start:end])
+
+	endLineNumber := lineNumber + bytes.Count(lineBuf[start:end], []byte{'\n'})
+	endOffset := offset + length
+	if idx := bytes.LastIndexByte(lineBuf[start:end], '\n'); idx >= 0 {
+		endOffset = utf8.RuneCount(lineBuf[start+idx+1 : end])
+	}
+
+	return append(matches, protocol.LineMatch{
+		// As in appendMatches, we copy the data since we may not use it
+		// after the ZipFile has been Closed.
+		Preview:          string(lineBuf),
+		LineNumber:       lineNumber,
+		OffsetAndLengths: [][2]int{{offset, length}},
+		EndLineNumber:    endLineNumber,
+		EndOffset:        endOffset,
+	})
+}
+
 // FindZip is a convenience function to run Find on f.
 func (rg *readerGrep) FindZip(zf *store.ZipFile, f *store.SrcFile) (protocol.FileMatch, error) {
 	lm, limitHit, err := rg.Find(zf, f)
@@ -313,11 +367,25 @@ func (rg *readerGrep) FindZip(zf *store.ZipFile, f *store.SrcFile) (protocol.Fil
 
 // concurrentFind searches files in zr looking for matches using rg.
 func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool) (fm []protocol.FileMatch, limitHit bool, err error) {
+	matches := []protocol.FileMatch{}
+	limitHit, err = concurrentFindFunc(ctx, rg, zf, fileMatchLimit, patternMatchesContent, patternMatchesPaths, func(fm protocol.FileMatch) {
+		matches = append(matches, fm)
+	})
+	return matches, limitHit, err
+}
+
+// concurrentFindFunc is like concurrentFind, but instead of collecting the
+// matches it calls onMatch as soon as a file match is found. Calls to onMatch
+// are serialized, and onMatch is called at most fileMatchLimit times.
+func concurrentFindFunc(ctx context.Context, rg *readerGrep, zf *store.ZipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool, onMatch func(protocol.FileMatch)) (limitHit bool, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "ConcurrentFind")
 	ext.Component.Set(span, "matcher")
 	if rg.re != nil {
 		span.SetTag("re", rg.re.String())
 	}
+	if rg.structural != nil {
+		span.SetTag("structural", true)
+	}
 	span.SetTag("path", rg.matchPath.String())
 	defer func() {
 		if err != nil {
@@ -348,28 +416,41 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 	defer cancel()
 
 	var (
-		filesmu   sync.Mutex // protects files
-		files     = zf.Files
-		matchesmu sync.Mutex // protects matches, limitHit
-		matches   = []protocol.FileMatch{}
+		filesmu    sync.Mutex // protects files
+		files      = zf.Files
+		matchesmu  sync.Mutex // protects matchCount and limitHit
+		matchCount int
 	)
 
-	if rg.re == nil || (patternMatchesPaths && !patternMatchesContent) {
+	if (rg.re == nil && rg.structural == nil) || (patternMatchesPaths && !patternMatchesContent) {
 		// Fast path for only matching file paths (or with a nil pattern, which matches all files,
 		// so is effectively matching only on file paths).
 		for _, f := range files {
 			if rg.matchPath.MatchPath(f.Name) && rg.matchString(f.Name) {
-				if len(matches) < fileMatchLimit {
-					matches = append(matches, protocol.FileMatch{Path: f.Name})
+				if matchCount < fileMatchLimit {
+					matchCount++
+					onMatch(protocol.FileMatch{Path: f.Name})
 				} else {
 					limitHit = true
 					break
 				}
 			}
 		}
-		return matches, limitHit, nil
+		return limitHit, nil
 	}
 
+	// Workers pass matches to a single goroutine which calls onMatch, so that
+	// a slow onMatch doesn't stall the search. At most fileMatchLimit matches
+	// are sent, so sending never blocks.
+	matchc := make(chan protocol.FileMatch, fileMatchLimit)
+	emitted := make(chan struct{})
+	go func() {
+		defer close(emitted)
+		for fm := range matchc {
+			onMatch(fm)
+		}
+	}()
+
 	var (
 		done          = ctx.Done()
 		wg            sync.WaitGroup
@@ -430,19 +511,25 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 				}
 				if match {
 					matchesmu.Lock()
-					if len(matches) < fileMatchLimit {
-						matches = append(matches, fm)
+					send := matchCount < fileMatchLimit
+					if send {
+						matchCount++
 					} else {
 						limitHit = true
 						cancel()
 					}
 					matchesmu.Unlock()
+					if send {
+						matchc <- fm
+					}
 				}
 			}
 		}(rg.Copy())
 	}
 
 	wg.Wait()
+	close(matchc)
+	<-emitted
 
 	err = wgErr
 	if err == nil && ctx.Err() == context.DeadlineExceeded {
@@ -455,7 +542,7 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *store.ZipFile, file
 		otlog.Int("filesSearched", int(atomic.LoadUint32(&filesSearched))),
 	)
 
-	return matches, limitHit, err
+	return limitHit, err
 }
 
 // lowerRegexpASCII lowers rune literals and expands char classes to include
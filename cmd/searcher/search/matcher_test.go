@@ -475,6 +475,56 @@ func TestMaxMatches(t *testing.T) {
 	}
 }
 
+func TestFind_multiline(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go": "package a\n\nfunc a() {\n\treturn\n}\n\nfunc b() { return }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := store.MockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{
+		Pattern:         `func\s+\w+\(\)\s*\{\s*return`,
+		IsRegExp:        true,
+		IsCaseSensitive: true,
+		IsMultiline:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lm, limitHit, err := rg.Find(zf, &zf.Files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limitHit {
+		t.Fatal("unexpected limitHit")
+	}
+
+	want := []protocol.LineMatch{
+		{
+			Preview:          "func a() {\n\treturn",
+			LineNumber:       2,
+			OffsetAndLengths: [][2]int{{0, 18}},
+			EndLineNumber:    3,
+			EndOffset:        7,
+		},
+		{
+			Preview:          "func b() { return }",
+			LineNumber:       6,
+			OffsetAndLengths: [][2]int{{0, 17}},
+			EndLineNumber:    6,
+			EndOffset:        17,
+		},
+	}
+	if !reflect.DeepEqual(lm, want) {
+		t.Errorf("got %+v, want %+v", lm, want)
+	}
+}
+
 // Tests that:
 //
 // - IncludePatterns can match the path in any order
@@ -85,17 +85,17 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	matches, limitHit, deadlineHit, err := s.search(ctx, &p)
+	if p.Stream {
+		s.serveStream(ctx, w, &p)
+		return
+	}
+
+	var matches []protocol.FileMatch
+	limitHit, deadlineHit, err := s.search(ctx, &p, func(fm protocol.FileMatch) {
+		matches = append(matches, fm)
+	})
 	if err != nil {
-		code := http.StatusInternalServerError
-		if isBadRequest(err) || ctx.Err() == context.Canceled {
-			code = http.StatusBadRequest
-		} else if isTemporary(err) {
-			code = http.StatusServiceUnavailable
-		} else {
-			log.Printf("internal error serving %#+v: %s", p, err)
-		}
-		http.Error(w, err.Error(), code)
+		writeSearchError(ctx, w, &p, err)
 		return
 	}
 	if matches == nil {
@@ -116,7 +116,74 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(&resp)
 }
 
-func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []protocol.FileMatch, limitHit, deadlineHit bool, err error) {
+// serveStream runs the search described by p and writes each file match to w
+// as soon as it is found (see protocol.Request.Stream).
+//
+// The response headers are only written once the first event is sent, so
+// errors which happen before any match was found (such as an invalid pattern)
+// are still reported with an appropriate HTTP status code.
+func (s *Service) serveStream(ctx context.Context, w http.ResponseWriter, p *protocol.Request) {
+	var (
+		enc        = json.NewEncoder(w)
+		flusher, _ = w.(http.Flusher)
+		started    bool
+	)
+	send := func(ev *protocol.StreamEvent) {
+		if !started {
+			w.Header().Set("Content-Type", protocol.StreamContentType)
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		// As with the non-streaming response, the only reasonable error is
+		// the client going away, so we ignore it.
+		_ = enc.Encode(ev)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	limitHit, deadlineHit, err := s.search(ctx, p, func(fm protocol.FileMatch) {
+		send(&protocol.StreamEvent{FileMatch: &fm})
+	})
+	if err != nil && !started {
+		writeSearchError(ctx, w, p, err)
+		return
+	}
+
+	done := &protocol.StreamEvent{
+		Done:        true,
+		LimitHit:    limitHit,
+		DeadlineHit: deadlineHit,
+	}
+	if err != nil {
+		log.Printf("error streaming %#+v: %s", p, err)
+		done.Error = err.Error()
+	}
+	send(done)
+}
+
+// writeSearchError writes err as the response to the search request p.
+func writeSearchError(ctx context.Context, w http.ResponseWriter, p *protocol.Request, err error) {
+	code := http.StatusInternalServerError
+	if isBadRequest(err) || ctx.Err() == context.Canceled {
+		code = http.StatusBadRequest
+	} else if isTemporary(err) {
+		code = http.StatusServiceUnavailable
+	} else {
+		log.Printf("internal error serving %#+v: %s", p, err)
+	}
+	http.Error(w, err.Error(), code)
+}
+
+// search runs the search described by p, calling onMatch for each file match
+// as soon as it is found. Calls to onMatch are serialized.
+func (s *Service) search(ctx context.Context, p *protocol.Request, onMatch func(protocol.FileMatch)) (limitHit, deadlineHit bool, err error) {
+	var matches int
+	countMatch := func(fm protocol.FileMatch) {
+		matches++
+		onMatch(fm)
+	}
+
 	tr := trace.New("search", fmt.Sprintf("%s@%s", p.Repo, p.Commit))
 	tr.LazyPrintf("%s", p.Pattern)
 
@@ -129,12 +196,15 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	span.SetTag("isRegExp", strconv.FormatBool(p.IsRegExp))
 	span.SetTag("isWordMatch", strconv.FormatBool(p.IsWordMatch))
 	span.SetTag("isCaseSensitive", strconv.FormatBool(p.IsCaseSensitive))
+	span.SetTag("isStructuralPat", strconv.FormatBool(p.IsStructuralPat))
+	span.SetTag("isMultiline", strconv.FormatBool(p.IsMultiline))
 	span.SetTag("pathPatternsAreRegExps", strconv.FormatBool(p.PathPatternsAreRegExps))
 	span.SetTag("pathPatternsAreCaseSensitive", strconv.FormatBool(p.PathPatternsAreCaseSensitive))
 	span.SetTag("fileMatchLimit", p.FileMatchLimit)
 	span.SetTag("patternMatchesContent", p.PatternMatchesContent)
 	span.SetTag("patternMatchesPath", p.PatternMatchesPath)
 	span.SetTag("deadline", p.Deadline)
+	span.SetTag("stream", p.Stream)
 	defer func(start time.Time) {
 		code := "200"
 		// We often have canceled and timed out requests. We do not want to
@@ -160,21 +230,21 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 				code = "500"
 			}
 		}
-		tr.LazyPrintf("code=%s matches=%d limitHit=%v deadlineHit=%v", code, len(matches), limitHit, deadlineHit)
+		tr.LazyPrintf("code=%s matches=%d limitHit=%v deadlineHit=%v", code, matches, limitHit, deadlineHit)
 		tr.Finish()
 		requestTotal.WithLabelValues(code).Inc()
-		span.LogFields(otlog.Int("matches.len", len(matches)))
+		span.LogFields(otlog.Int("matches.len", matches))
 		span.SetTag("limitHit", limitHit)
 		span.SetTag("deadlineHit", deadlineHit)
 		span.Finish()
 		if s.Log != nil {
-			s.Log.Debug("search request", "repo", p.Repo, "commit", p.Commit, "pattern", p.Pattern, "isRegExp", p.IsRegExp, "isWordMatch", p.IsWordMatch, "isCaseSensitive", p.IsCaseSensitive, "patternMatchesContent", p.PatternMatchesContent, "patternMatchesPath", p.PatternMatchesPath, "matches", len(matches), "code", code, "duration", time.Since(start), "err", err)
+			s.Log.Debug("search request", "repo", p.Repo, "commit", p.Commit, "pattern", p.Pattern, "isRegExp", p.IsRegExp, "isWordMatch", p.IsWordMatch, "isCaseSensitive", p.IsCaseSensitive, "patternMatchesContent", p.PatternMatchesContent, "patternMatchesPath", p.PatternMatchesPath, "matches", matches, "code", code, "duration", time.Since(start), "err", err)
 		}
 	}(time.Now())
 
 	rg, err := compile(&p.PatternInfo)
 	if err != nil {
-		return nil, false, false, badRequestError{err.Error()}
+		return false, false, badRequestError{err.Error()}
 	}
 
 	if p.FetchTimeout == "" {
@@ -182,7 +252,7 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	}
 	fetchTimeout, err := time.ParseDuration(p.FetchTimeout)
 	if err != nil {
-		return nil, false, false, err
+		return false, false, err
 	}
 	prepareCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
 	defer cancel()
@@ -198,7 +268,7 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 
 	_, zf, err := store.GetZipFileWithRetry(getZf)
 	if err != nil {
-		return nil, false, false, err
+		return false, false, err
 	}
 	defer zf.Close()
 
@@ -211,8 +281,8 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	archiveFiles.Observe(float64(nFiles))
 	archiveSize.Observe(float64(bytes))
 
-	matches, limitHit, err = concurrentFind(ctx, rg, zf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath)
-	return matches, limitHit, false, err
+	limitHit, err = concurrentFindFunc(ctx, rg, zf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath, countMatch)
+	return limitHit, false, err
 }
 
 func validateParams(p *protocol.Request) error {
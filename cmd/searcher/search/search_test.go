@@ -180,6 +180,10 @@ main.go:7:}
 `},
 
 		{protocol.PatternInfo{Pattern: "^$", IsRegExp: true}, ``},
+
+		{protocol.PatternInfo{Pattern: "fmt.Println(:[x])", IsStructuralPat: true}, `
+main.go:6:	fmt.Println("Hello world")
+`},
 	}
 
 	store, cleanup, err := newStore(files)
@@ -347,6 +351,52 @@ func TestSearch_badrequest(t *testing.T) {
 	}
 }
 
+func TestSearch_stream(t *testing.T) {
+	files := map[string]string{
+		"README.md": "# Hello World\n\nHello world example in go",
+		"main.go":   "package main\n\nfunc main() {\n\tfmt.Println(\"Hello world\")\n}\n",
+	}
+
+	store, cleanup, err := newStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	ts := httptest.NewServer(&search.Service{Store: store})
+	defer ts.Close()
+
+	req := protocol.Request{
+		Repo:   "foo",
+		URL:    "u",
+		Commit: "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+		PatternInfo: protocol.PatternInfo{
+			Pattern:               "hello",
+			PatternMatchesContent: true,
+		},
+	}
+	want, err := doSearch(ts.URL, &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Stream = true
+	got, err := doSearch(ts.URL, &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(sortByPath(want))
+	sort.Sort(sortByPath(got))
+	if len(got) != 2 || toString(got) != toString(want) {
+		t.Fatalf("streamed matches differ:\ngot:\n%s\nwant:\n%s", toString(got), toString(want))
+	}
+
+	// Errors before any match is found should still be HTTP errors.
+	req.Pattern = `\F`
+	req.IsRegExp = true
+	if _, err := doSearch(ts.URL, &req); err == nil || !strings.HasPrefix(err.Error(), "non-200 response: code=400 ") {
+		t.Fatalf("expected HTTP 400 response. Got %v", err)
+	}
+}
+
 func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	form := url.Values{
 		"Repo":            []string{string(p.Repo)},
@@ -366,6 +416,9 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	if p.IsCaseSensitive {
 		form.Set("IsCaseSensitive", "true")
 	}
+	if p.IsStructuralPat {
+		form.Set("IsStructuralPat", "true")
+	}
 	if p.PathPatternsAreRegExps {
 		form.Set("PathPatternsAreRegExps", "true")
 	}
@@ -378,11 +431,33 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	if p.PatternMatchesPath {
 		form.Set("PatternMatchesPath", "true")
 	}
+	if p.Stream {
+		form.Set("Stream", "true")
+	}
 	resp, err := http.PostForm(u, form)
 	if err != nil {
 		return nil, err
 	}
 
+	if resp.StatusCode == 200 && p.Stream {
+		defer resp.Body.Close()
+		var matches []protocol.FileMatch
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var ev protocol.StreamEvent
+			if err := dec.Decode(&ev); err != nil {
+				return nil, err
+			}
+			if ev.Done {
+				if ev.Error != "" {
+					return nil, errors.New(ev.Error)
+				}
+				return matches, nil
+			}
+			matches = append(matches, *ev.FileMatch)
+		}
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
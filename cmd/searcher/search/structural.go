@@ -0,0 +1,44 @@
+package search
+
+import (
+	"bytes"
+
+	"github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/store"
+)
+
+// findStructural returns the LineMatches for each match of rg.structural in
+// f. Matches which span several lines are reported as one LineMatch per line,
+// unless rg.multiline is set.
+func (rg *readerGrep) findStructural(zf *store.ZipFile, f *store.SrcFile) (matches []protocol.LineMatch, limitHit bool, err error) {
+	fileBuf := zf.DataFor(f)
+	if !bytes.Contains(fileBuf, rg.structural.Literal()) {
+		return nil, false, nil
+	}
+
+	locs, limitHit := rg.structural.FindAll(fileBuf, maxLineMatches)
+	lineNumber := 0
+	lastStart := 0
+	for _, loc := range locs {
+		start, end := loc.Start, loc.End
+		lineNumber += bytes.Count(fileBuf[lastStart:start], []byte{'\n'})
+		lastStart = start
+
+		lineStart := bytes.LastIndexByte(fileBuf[:start], '\n') + 1
+		lineEnd := len(fileBuf)
+		if idx := bytes.IndexByte(fileBuf[end:], '\n'); idx >= 0 {
+			lineEnd = end + idx
+		}
+		if rg.multiline {
+			matches = appendMultilineMatch(matches, fileBuf[lineStart:lineEnd], lineNumber, start-lineStart, end-lineStart)
+		} else {
+			matches = appendMatches(matches, fileBuf[lineStart:lineEnd], fileBuf[lineStart:lineEnd], lineNumber, start-lineStart, end-lineStart)
+		}
+		if len(matches) > maxLineMatches {
+			matches = matches[:maxLineMatches]
+			limitHit = true
+			break
+		}
+	}
+	return matches, limitHit, nil
+}
@@ -0,0 +1,103 @@
+package ctags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+// defaultLanguages are the languages built into universal-ctags which are
+// enabled.
+var defaultLanguages = []string{"Basic", "C", "C#", "C++", "Clojure", "Cobol", "CSS", "CUDA", "D", "Elixir", "elm", "Erlang", "Go", "haskell", "Java", "JavaScript", "kotlin", "Lisp", "Lua", "MatLab", "ObjectiveC", "OCaml", "Perl", "Perl6", "PHP", "Protobuf", "Python", "R", "Ruby", "Rust", "scala", "Scheme", "Sh", "swift", "Tcl", "typescript", "tsx", "Verilog", "Vim"}
+
+// Args returns the universal-ctags command line options which select the
+// enabled languages, including the regex-based languages defined in the
+// site configuration `symbols.ctags`. config may be nil.
+func Args(config *schema.SymbolsCtags) ([]string, error) {
+	languages := append([]string{}, defaultLanguages...)
+	var args []string
+	if config != nil {
+		for _, lang := range config.Languages {
+			langArgs, err := languageArgs(lang)
+			if err != nil {
+				return nil, errors.Wrapf(err, "ctags language %q", lang.Name)
+			}
+			args = append(args, langArgs...)
+			languages = append(languages, lang.Name)
+		}
+	}
+
+	return append([]string{
+		"--fields=*",
+		"--languages=" + strings.Join(languages, ","),
+		"--map-CSS=+.scss", "--map-CSS=+.less", "--map-CSS=+.sass",
+	}, args...), nil
+}
+
+// languageArgs returns the universal-ctags options which define lang as a
+// regex parser.
+func languageArgs(lang *schema.CtagsLanguage) ([]string, error) {
+	if lang.Name == "" || strings.ContainsAny(lang.Name, ",=/ \t") {
+		return nil, errors.New("invalid language name")
+	}
+
+	args := []string{"--langdef=" + lang.Name}
+	for _, ext := range lang.Extensions {
+		if ext == "" || strings.ContainsAny(ext, ",/ \t") {
+			return nil, errors.Errorf("invalid extension %q", ext)
+		}
+		args = append(args, fmt.Sprintf("--map-%s=+.%s", lang.Name, strings.TrimPrefix(ext, ".")))
+	}
+
+	kinds := map[string]bool{}
+	for _, kind := range lang.Kinds {
+		if len(kind.Letter) != 1 || kind.Name == "" || strings.ContainsAny(kind.Name, ", \t") {
+			return nil, errors.Errorf("invalid kind %q with letter %q", kind.Name, kind.Letter)
+		}
+		description := kind.Description
+		if description == "" {
+			description = kind.Name
+		}
+		kinds[kind.Letter] = true
+		args = append(args, fmt.Sprintf("--kinddef-%s=%s,%s,%s", lang.Name, kind.Letter, kind.Name, description))
+	}
+
+	for _, re := range lang.Regexes {
+		if !kinds[re.Kind] {
+			return nil, errors.Errorf("regex %q uses kind %q which is not defined", re.Regex, re.Kind)
+		}
+		args = append(args, fmt.Sprintf("--regex-%s=/%s/%s/%s/", lang.Name, escapeSlashes(re.Regex), escapeSlashes(re.Name), re.Kind))
+	}
+	return args, nil
+}
+
+// escapeSlashes escapes the slashes in s which are not already escaped, so
+// that s can be used in a /regex/name/kind/ ctags option.
+func escapeSlashes(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '/' && !escaped {
+			b.WriteByte('\\')
+		}
+		escaped = r == '\\' && !escaped
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// kindMap returns the map from language to the kinds reported by ctags to
+// the kinds to use instead, as configured in the site configuration.
+// Languages are lowercased since ctags matches them case insensitively.
+func kindMap(config *schema.SymbolsCtags) map[string]map[string]string {
+	if config == nil || len(config.KindMap) == 0 {
+		return nil
+	}
+	m := make(map[string]map[string]string, len(config.KindMap))
+	for lang, kinds := range config.KindMap {
+		m[strings.ToLower(lang)] = kinds
+	}
+	return m
+}
@@ -0,0 +1,68 @@
+package ctags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestArgs(t *testing.T) {
+	args, err := Args(&schema.SymbolsCtags{
+		Languages: []*schema.CtagsLanguage{{
+			Name:       "MyDSL",
+			Extensions: []string{"mydsl", ".dsl"},
+			Kinds: []*schema.CtagsKind{
+				{Letter: "r", Name: "rule", Description: "rules"},
+				{Letter: "p", Name: "path"},
+			},
+			Regexes: []*schema.CtagsRegex{
+				{Regex: `^rule[ \t]+([a-z]+)`, Name: `\1`, Kind: "r"},
+				{Regex: `^path ([a-z/]+\/[a-z]+)`, Name: `\1`, Kind: "p"},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "--languages=" + strings.Join(defaultLanguages, ",") + ",MyDSL"; args[1] != want {
+		t.Errorf("got %q, want %q", args[1], want)
+	}
+	want := []string{
+		"--langdef=MyDSL",
+		"--map-MyDSL=+.mydsl",
+		"--map-MyDSL=+.dsl",
+		"--kinddef-MyDSL=r,rule,rules",
+		"--kinddef-MyDSL=p,path,path",
+		`--regex-MyDSL=/^rule[ \t]+([a-z]+)/\1/r/`,
+		`--regex-MyDSL=/^path ([a-z\/]+\/[a-z]+)/\1/p/`,
+	}
+	if got := args[len(args)-len(want):]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestArgs_invalid(t *testing.T) {
+	for name, lang := range map[string]*schema.CtagsLanguage{
+		"name":      {Name: "My DSL", Extensions: []string{"x"}},
+		"extension": {Name: "MyDSL", Extensions: []string{"a,b"}},
+		"kind":      {Name: "MyDSL", Extensions: []string{"x"}, Regexes: []*schema.CtagsRegex{{Regex: "a", Name: "b", Kind: "r"}}},
+	} {
+		if _, err := Args(&schema.SymbolsCtags{Languages: []*schema.CtagsLanguage{lang}}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestKindMap(t *testing.T) {
+	got := kindMap(&schema.SymbolsCtags{KindMap: map[string]map[string]string{"MyDSL": {"rule": "function"}}})
+	want := map[string]map[string]string{"mydsl": {"rule": "function"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := kindMap(nil); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+}
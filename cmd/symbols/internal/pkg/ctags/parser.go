@@ -9,9 +9,11 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 type Entry struct {
@@ -57,7 +59,9 @@ func GetCommand() string {
 	return ctagsCommand
 }
 
-func NewParser(ctagsCommand string) (Parser, error) {
+// NewParser starts a universal-ctags process. config is the site
+// configuration `symbols.ctags`, and may be nil.
+func NewParser(ctagsCommand string, config *schema.SymbolsCtags) (Parser, error) {
 	opt := "default"
 
 	// TODO(sqs): Figure out why running with --_interactive=sandbox causes `Bad system call` inside Docker, and
@@ -67,10 +71,11 @@ func NewParser(ctagsCommand string) (Parser, error) {
 	//  opt = "sandbox"
 	// }
 
-	cmd := exec.Command(ctagsCommand, "--_interactive="+opt, "--fields=*",
-		"--languages=Basic,C,C#,C++,Clojure,Cobol,CSS,CUDA,D,Elixir,elm,Erlang,Go,haskell,Java,JavaScript,kotlin,Lisp,Lua,MatLab,ObjectiveC,OCaml,Perl,Perl6,PHP,Protobuf,Python,R,Ruby,Rust,scala,Scheme,Sh,swift,Tcl,typescript,tsx,Verilog,Vim",
-		"--map-CSS=+.scss", "--map-CSS=+.less", "--map-CSS=+.sass",
-	)
+	args, err := Args(config)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(ctagsCommand, append([]string{"--_interactive=" + opt}, args...)...)
 	in, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, err
@@ -87,6 +92,7 @@ func NewParser(ctagsCommand string) (Parser, error) {
 		in:      in,
 		out:     bufio.NewScanner(out),
 		outPipe: out,
+		kindMap: kindMap(config),
 	}
 
 	if err := cmd.Start(); err != nil {
@@ -112,6 +118,10 @@ type ctagsProcess struct {
 	in      io.WriteCloser
 	out     *bufio.Scanner
 	outPipe io.ReadCloser
+
+	// kindMap maps lowercased languages to the kinds reported by ctags to
+	// the kinds to use instead.
+	kindMap map[string]map[string]string
 }
 
 func (p *ctagsProcess) Close() {
@@ -221,14 +231,24 @@ func (p *ctagsProcess) Parse(name string, content []byte) (entries []Entry, err
 			break
 		}
 
+		kind, parentKind := rep.Kind, rep.ScopeKind
+		if kinds, ok := p.kindMap[strings.ToLower(rep.Language)]; ok {
+			if k, ok := kinds[kind]; ok {
+				kind = k
+			}
+			if k, ok := kinds[parentKind]; ok {
+				parentKind = k
+			}
+		}
+
 		entries = append(entries, Entry{
 			Name:        rep.Name,
 			Path:        rep.Path,
 			Line:        rep.Line,
-			Kind:        rep.Kind,
+			Kind:        kind,
 			Language:    rep.Language,
 			Parent:      rep.Scope,
-			ParentKind:  rep.ScopeKind,
+			ParentKind:  parentKind,
 			Pattern:     rep.Pattern,
 			Signature:   rep.Signature,
 			FileLimited: rep.File,
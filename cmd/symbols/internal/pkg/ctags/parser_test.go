@@ -14,7 +14,7 @@ func TestParser(t *testing.T) {
 		t.Skipf("command not in PATH: %s", command)
 	}
 
-	p, err := NewParser(command)
+	p, err := NewParser(command, nil)
 	if err != nil {
 		if os.Getenv("CI") == "" {
 			t.Skipf("failed to start universal-ctags. Assuming it is due to our custom build of universal-ctags not being installed. Reason: %v", err)
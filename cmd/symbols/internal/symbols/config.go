@@ -0,0 +1,68 @@
+package symbols
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// pooledParser is a parser in the pool, along with the hash of the ctags
+// configuration it was created with.
+type pooledParser struct {
+	ctags.Parser
+	configHash string
+}
+
+// ctagsConfig returns the current ctags configuration and its hash. The hash
+// is empty if there is no configuration.
+func (s *Service) ctagsConfig() (*schema.SymbolsCtags, string) {
+	if s.CtagsConfig == nil {
+		return nil, ""
+	}
+	config := s.CtagsConfig()
+	if config == nil {
+		return nil, ""
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		// Can't happen, since the configuration was unmarshaled from JSON.
+		panic(err)
+	}
+	sum := sha256.Sum256(b)
+	return config, hex.EncodeToString(sum[:8])
+}
+
+// disabledLanguages returns the lowercased names of the languages whose
+// symbols are not indexed in repo.
+func disabledLanguages(config *schema.SymbolsCtags, repo api.RepoName) map[string]bool {
+	if config == nil {
+		return nil
+	}
+	var disabled map[string]bool
+	for _, d := range config.DisabledLanguages {
+		re, err := regexp.Compile(d.Repos)
+		if err != nil {
+			// Invalid patterns are reported when the site configuration is
+			// validated.
+			log15.Warn("Ignoring invalid symbols.ctags disabledLanguages repos pattern.", "repos", d.Repos, "error", err)
+			continue
+		}
+		if !re.MatchString(string(repo)) {
+			continue
+		}
+		if disabled == nil {
+			disabled = map[string]bool{}
+		}
+		for _, lang := range d.Languages {
+			disabled[strings.ToLower(lang)] = true
+		}
+	}
+	return disabled
+}
@@ -0,0 +1,53 @@
+package symbols
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestDisabledLanguages(t *testing.T) {
+	config := &schema.SymbolsCtags{
+		DisabledLanguages: []*schema.CtagsDisabledLanguages{
+			{Repos: `^github\.com/org/`, Languages: []string{"JavaScript"}},
+			{Repos: `-generated$`, Languages: []string{"Go", "Protobuf"}},
+			{Repos: `(`, Languages: []string{"C"}},
+		},
+	}
+	tests := map[string]map[string]bool{
+		"github.com/org/a":           {"javascript": true},
+		"github.com/org/a-generated": {"javascript": true, "go": true, "protobuf": true},
+		"github.com/other/a":         nil,
+	}
+	for repo, want := range tests {
+		if got := disabledLanguages(config, api.RepoName(repo)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", repo, got, want)
+		}
+	}
+}
+
+func TestService_ctagsConfig(t *testing.T) {
+	var config *schema.SymbolsCtags
+	s := &Service{CtagsConfig: func() *schema.SymbolsCtags { return config }}
+
+	if _, hash := s.ctagsConfig(); hash != "" {
+		t.Errorf("got hash %q for no config, want empty", hash)
+	}
+	noConfigKey := s.dbKey("r", "c")
+
+	config = &schema.SymbolsCtags{KindMap: map[string]map[string]string{"Go": {"func": "function"}}}
+	_, hash := s.ctagsConfig()
+	if hash == "" {
+		t.Fatal("got empty hash for config")
+	}
+	if key := s.dbKey("r", "c"); key == noConfigKey {
+		t.Errorf("got the same key %q with and without config", key)
+	}
+
+	config = &schema.SymbolsCtags{KindMap: map[string]map[string]string{"Go": {"func": "method"}}}
+	if _, hash2 := s.ctagsConfig(); hash2 == hash {
+		t.Errorf("got the same hash %q for different configs", hash)
+	}
+}
@@ -0,0 +1,151 @@
+package symbols
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+// maxIncrementalChanges is the maximum number of changed files for which a
+// commit is indexed incrementally. Commits which change more files are
+// indexed from scratch, which is cheaper than passing that many paths to
+// gitserver.
+const maxIncrementalChanges = 1000
+
+// errNotIncremental is returned by writeChangedSymbolsToNewDB if a commit
+// can't be indexed incrementally.
+var errNotIncremental = errors.New("commit can't be indexed incrementally")
+
+// Changes are the paths of the files which differ between two commits.
+type Changes struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// ParseGitDiffNameStatus parses the output of `git diff -z --name-status
+// --no-renames`.
+func ParseGitDiffNameStatus(out []byte) (Changes, error) {
+	var changes Changes
+	fields := bytes.Split(bytes.TrimSuffix(out, []byte{0}), []byte{0})
+	if len(fields) == 1 && len(fields[0]) == 0 {
+		return changes, nil
+	}
+	if len(fields)%2 != 0 {
+		return changes, errors.Errorf("unexpected number of fields in git diff output: %d", len(fields))
+	}
+	for i := 0; i < len(fields); i += 2 {
+		status, path := fields[i], string(fields[i+1])
+		if len(status) == 0 {
+			return changes, errors.New("unexpected empty status in git diff output")
+		}
+		switch status[0] {
+		case 'A':
+			changes.Added = append(changes.Added, path)
+		case 'M', 'T':
+			changes.Modified = append(changes.Modified, path)
+		case 'D':
+			changes.Deleted = append(changes.Deleted, path)
+		default:
+			return changes, errors.Errorf("unexpected status %q in git diff output", status)
+		}
+	}
+	return changes, nil
+}
+
+// writeChangedSymbolsToNewDB writes the symbols of repo@commitID to the blank
+// database file `dbFile` by copying the database of the parent commit from
+// the cache and only parsing the files which changed. It returns
+// errNotIncremental if GitDiff is not set, the parent's database is not
+// cached or the commit changes too many files.
+func (s *Service) writeChangedSymbolsToNewDB(ctx context.Context, dbFile string, repoName api.RepoName, commitID api.CommitID) error {
+	if s.GitDiff == nil {
+		return errNotIncremental
+	}
+	parent, changes, err := s.GitDiff(ctx, gitserver.Repo{Name: repoName}, commitID)
+	if err != nil {
+		return err
+	}
+	if parent == "" {
+		return errNotIncremental
+	}
+	changed := append(append([]string{}, changes.Added...), changes.Modified...)
+	if len(changed)+len(changes.Deleted) > maxIncrementalChanges {
+		return errNotIncremental
+	}
+
+	parentDB, err := s.cache.OpenIfExists(s.dbKey(repoName, parent))
+	if os.IsNotExist(err) {
+		return errNotIncremental
+	} else if err != nil {
+		return err
+	}
+	err = copyToFile(dbFile, parentDB.File)
+	parentDB.File.Close()
+	if err != nil {
+		return err
+	}
+
+	db, err := sqlx.Open("sqlite3_with_pcre", dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteStatement, err := tx.Preparex("DELETE FROM symbols WHERE path = ?")
+	if err != nil {
+		return err
+	}
+	for _, paths := range [][]string{changes.Modified, changes.Deleted} {
+		for _, path := range paths {
+			if _, err := deleteStatement.Exec(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	// An empty list of paths means all paths to parseUncached.
+	if len(changed) > 0 {
+		insertStatement, err := prepareInsertSymbol(tx)
+		if err != nil {
+			return err
+		}
+		err = s.parseUncached(ctx, repoName, commitID, changed, func(symbol protocol.Symbol) error {
+			symbolInDBValue := symbolToSymbolInDB(symbol)
+			_, err := insertStatement.Exec(&symbolInDBValue)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// copyToFile replaces the contents of the file at path with the contents of
+// r.
+func copyToFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
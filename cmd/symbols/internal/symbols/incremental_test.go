@@ -0,0 +1,128 @@
+package symbols
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	symbolsclient "github.com/sourcegraph/sourcegraph/pkg/symbols"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+func TestParseGitDiffNameStatus(t *testing.T) {
+	out := []byte("A\x00new.go\x00M\x00dir/changed file.go\x00T\x00link\x00D\x00old.go\x00")
+	got, err := ParseGitDiffNameStatus(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Changes{
+		Added:    []string{"new.go"},
+		Modified: []string{"dir/changed file.go", "link"},
+		Deleted:  []string{"old.go"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if got, err := ParseGitDiffNameStatus(nil); err != nil || !reflect.DeepEqual(got, Changes{}) {
+		t.Errorf("got %+v, %v for empty output", got, err)
+	}
+
+	for _, out := range []string{"M\x00", "R100\x00a\x00b\x00"} {
+		if _, err := ParseGitDiffNameStatus([]byte(out)); err == nil {
+			t.Errorf("expected error for %q", out)
+		}
+	}
+}
+
+func TestService_incremental(t *testing.T) {
+	MustRegisterSqlite3WithPcre()
+
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { os.RemoveAll(tmpDir) }()
+
+	commits := map[api.CommitID]map[string]string{
+		"parent": {"a.js": "a", "b.js": "b", "c.js": "c"},
+		"child":  {"a.js": "a", "b.js": "b2", "d.js": "d"},
+	}
+	var fetchedPaths [][]string
+	service := Service{
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
+			fetchedPaths = append(fetchedPaths, nil)
+			return createTar(commits[commit])
+		},
+		FetchTarPaths: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			fetchedPaths = append(fetchedPaths, paths)
+			files := map[string]string{}
+			for _, p := range paths {
+				files[p] = commits[commit][p]
+			}
+			return createTar(files)
+		},
+		GitDiff: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (api.CommitID, Changes, error) {
+			if commit == "parent" {
+				return "", Changes{}, nil
+			}
+			return "parent", Changes{Added: []string{"d.js"}, Modified: []string{"b.js"}, Deleted: []string{"c.js"}}, nil
+		},
+		NewParser: func() (ctags.Parser, error) {
+			return contentParser{}, nil
+		},
+		Path: tmpDir,
+	}
+
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(service.Handler())
+	defer server.Close()
+	client := symbolsclient.Client{URL: server.URL}
+
+	search := func(commit api.CommitID) []protocol.Symbol {
+		result, err := client.Search(context.Background(), protocol.SearchArgs{Repo: "r", CommitID: commit, First: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(result.Symbols, func(i, j int) bool { return result.Symbols[i].Path < result.Symbols[j].Path })
+		return result.Symbols
+	}
+
+	// The child commit can only be indexed incrementally once the parent is
+	// indexed.
+	search("parent")
+	got := search("child")
+	want := []protocol.Symbol{
+		{Name: "a", Path: "a.js"},
+		{Name: "b2", Path: "b.js"},
+		{Name: "d", Path: "d.js"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	wantFetched := [][]string{nil, {"d.js", "b.js"}}
+	if !reflect.DeepEqual(fetchedPaths, wantFetched) {
+		t.Errorf("got fetched paths %q, want %q", fetchedPaths, wantFetched)
+	}
+}
+
+// contentParser is a ctags.Parser which returns a single symbol for each
+// file, named after the file's contents.
+type contentParser struct{}
+
+func (contentParser) Parse(name string, content []byte) ([]ctags.Entry, error) {
+	return []ctags.Entry{{Name: string(content), Path: name}}, nil
+}
+
+func (contentParser) Close() {}
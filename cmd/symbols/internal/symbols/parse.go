@@ -26,18 +26,21 @@ func (s *Service) startParsers() error {
 		n = runtime.GOMAXPROCS(0)
 	}
 
-	s.parsers = make(chan ctags.Parser, n)
+	_, configHash := s.ctagsConfig()
+	s.parsers = make(chan *pooledParser, n)
 	for i := 0; i < n; i++ {
 		parser, err := s.NewParser()
 		if err != nil {
 			return errors.Wrap(err, "NewParser")
 		}
-		s.parsers <- parser
+		s.parsers <- &pooledParser{Parser: parser, configHash: configHash}
 	}
 	return nil
 }
 
-func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID api.CommitID, callback func(symbol protocol.Symbol) error) (err error) {
+// parseUncached parses the files of repo@commitID and calls callback for each
+// symbol found. If paths is non-empty, only those paths are parsed.
+func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID api.CommitID, paths []string, callback func(symbol protocol.Symbol) error) (err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "parseUncached")
 	defer func() {
 		if err != nil {
@@ -48,9 +51,10 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 	}()
 	span.SetTag("repo", string(repo))
 	span.SetTag("commit", string(commitID))
+	span.SetTag("paths", len(paths))
 
 	tr := trace.New("parseUncached", string(repo))
-	tr.LazyPrintf("commitID: %s", commitID)
+	tr.LazyPrintf("commitID: %s paths: %d", commitID, len(paths))
 
 	totalSymbols := 0
 	defer func() {
@@ -63,7 +67,7 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 	}()
 
 	tr.LazyPrintf("fetch")
-	parseRequests, errChan, err := s.fetchRepositoryArchive(ctx, repo, commitID)
+	parseRequests, errChan, err := s.fetchRepositoryArchive(ctx, repo, commitID, paths)
 	tr.LazyPrintf("fetch (returned chans)")
 	if err != nil {
 		return err
@@ -72,6 +76,9 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	config, _ := s.ctagsConfig()
+	disabled := disabledLanguages(config, repo)
+
 	var (
 		mu  sync.Mutex // protects symbols and err
 		wg  sync.WaitGroup
@@ -107,6 +114,9 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoName, commitID
 					if e.Name == "" || strings.HasPrefix(e.Name, "__anon") || strings.HasPrefix(e.Parent, "__anon") || strings.HasPrefix(e.Name, "AnonymousFunction") || strings.HasPrefix(e.Parent, "AnonymousFunction") {
 						continue
 					}
+					if disabled[strings.ToLower(e.Language)] {
+						continue
+					}
 					totalSymbols++
 					err = callback(entryToSymbol(e))
 					if err != nil {
@@ -141,14 +151,22 @@ func (s *Service) parse(ctx context.Context, req parseRequest) (entries []ctags.
 			return nil, nil
 		}
 
+		_, configHash := s.ctagsConfig()
+		if parser != nil && parser.configHash != configHash {
+			// The ctags configuration changed since the parser was created.
+			parser.Close()
+			parser = nil
+		}
 		if parser == nil {
-			// The parser failed for some previous receiver (who returned a nil parser to the channel). Try
-			// creating a parser.
-			var err error
-			parser, err = s.NewParser()
+			// The parser failed for some previous receiver (who returned a nil parser to the channel), or
+			// it was created with an old configuration. Try creating a parser.
+			p, err := s.NewParser()
 			if err != nil {
+				// Let the next receiver try again.
+				s.parsers <- nil
 				return nil, err
 			}
+			parser = &pooledParser{Parser: p, configHash: configHash}
 		}
 
 		defer func() {
@@ -0,0 +1,259 @@
+package symbols
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
+	searcherprotocol "github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+	"golang.org/x/net/trace"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+const (
+	// maxReferences is the maximum number of references returned for a
+	// symbol.
+	maxReferences = 500
+
+	// maxReferenceFileMatches is the maximum number of files which are
+	// searched for references to a symbol.
+	maxReferenceFileMatches = 200
+)
+
+// The scores of candidate references. A reference is scored by the
+// definition it is most likely to refer to.
+const (
+	// scoreOtherLanguage is the score of a reference in a file of a different
+	// language than all the definitions, or outside the file of a definition
+	// which is only visible in its own file.
+	scoreOtherLanguage = iota
+
+	// scoreSameLanguage is the score of a reference in a file of the same
+	// language as a definition.
+	scoreSameLanguage
+
+	// scoreSameDirectory is the score of a reference in the same directory and
+	// language as a definition.
+	scoreSameDirectory
+
+	// scoreSameFile is the score of a reference in the file of a definition.
+	scoreSameFile
+)
+
+func (s *Service) handleReferences(w http.ResponseWriter, r *http.Request) {
+	var args protocol.ReferencesArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if args.Symbol == "" {
+		http.Error(w, "symbol must not be empty", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.references(r.Context(), args)
+	if err != nil {
+		if err == context.Canceled && r.Context().Err() == context.Canceled {
+			return // client went away
+		}
+		log15.Error("Symbol references failed", "args", args, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// references finds the definitions of a symbol in the symbols database and
+// the candidate references to it with a word search, and ranks the
+// references by how likely they refer to one of the definitions.
+func (s *Service) references(ctx context.Context, args protocol.ReferencesArgs) (result *protocol.ReferencesResult, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "references")
+	span.SetTag("repo", args.Repo)
+	span.SetTag("commitID", args.CommitID)
+	span.SetTag("symbol", args.Symbol)
+	span.SetTag("first", args.First)
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+
+	tr := trace.New("symbols.references", fmt.Sprintf("args:%+v", args))
+	defer func() {
+		if err != nil {
+			tr.LazyPrintf("error: %v", err)
+			tr.SetError()
+		}
+		tr.Finish()
+	}()
+
+	if s.SearchWord == nil {
+		return nil, errors.New("finding references requires a searcher")
+	}
+	if args.First <= 0 || args.First > maxReferences {
+		args.First = maxReferences
+	}
+
+	dbFile, err := s.getDBFile(ctx, protocol.SearchArgs{Repo: args.Repo, CommitID: args.CommitID})
+	if err != nil {
+		return nil, err
+	}
+	db, err := sqlx.Open("sqlite3_with_pcre", dbFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var definitionsInDB []symbolInDB
+	if err := db.Select(&definitionsInDB, "SELECT * FROM symbols WHERE name = ?", args.Symbol); err != nil {
+		return nil, err
+	}
+	result = &protocol.ReferencesResult{}
+	for _, symbolInDB := range definitionsInDB {
+		result.Definitions = append(result.Definitions, symbolInDBToSymbol(symbolInDB))
+	}
+
+	fileMatches, limitHit, err := s.SearchWord(ctx, gitserver.Repo{Name: args.Repo}, args.CommitID, args.Symbol, maxReferenceFileMatches)
+	if err != nil {
+		return nil, errors.Wrap(err, "searcher")
+	}
+	tr.LazyPrintf("%d definitions, %d file matches", len(result.Definitions), len(fileMatches))
+
+	languages, err := fileLanguages(db, fileMatches)
+	if err != nil {
+		return nil, err
+	}
+	result.References = rankReferences(result.Definitions, fileMatches, languages)
+	result.LimitHit = limitHit
+	if len(result.References) > args.First {
+		result.References = result.References[:args.First]
+		result.LimitHit = true
+	}
+	span.SetTag("hits", len(result.References))
+	return result, nil
+}
+
+// fileLanguages returns the language of each file with symbols among the
+// given file matches.
+func fileLanguages(db *sqlx.DB, fileMatches []searcherprotocol.FileMatch) (map[string]string, error) {
+	stmt, err := db.Preparex("SELECT language FROM symbols WHERE path = ? AND language != '' LIMIT 1")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	languages := map[string]string{}
+	for _, fm := range fileMatches {
+		var language string
+		err := stmt.Get(&language, fm.Path)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		languages[fm.Path] = language
+	}
+	return languages, nil
+}
+
+// rankReferences returns the references in fileMatches, excluding the
+// definitions themselves, sorted by descending score. languages maps paths to
+// the language of the file.
+func rankReferences(definitions []protocol.Symbol, fileMatches []searcherprotocol.FileMatch, languages map[string]string) []protocol.Reference {
+	isDefinition := map[protocol.Reference]bool{}
+	for _, def := range definitions {
+		isDefinition[protocol.Reference{Path: def.Path, Line: def.Line}] = true
+	}
+
+	var refs []protocol.Reference
+	for _, fm := range fileMatches {
+		language := languages[fm.Path]
+		score := referenceScore(definitions, fm.Path, language)
+		for _, lm := range fm.LineMatches {
+			line := lm.LineNumber + 1
+			if isDefinition[protocol.Reference{Path: fm.Path, Line: line}] {
+				continue
+			}
+			for _, ol := range lm.OffsetAndLengths {
+				refs = append(refs, protocol.Reference{
+					Path:      fm.Path,
+					Line:      line,
+					Character: ol[0],
+					Preview:   lm.Preview,
+					Language:  language,
+					Score:     score,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].Score != refs[j].Score {
+			return refs[i].Score > refs[j].Score
+		}
+		if refs[i].Path != refs[j].Path {
+			return refs[i].Path < refs[j].Path
+		}
+		if refs[i].Line != refs[j].Line {
+			return refs[i].Line < refs[j].Line
+		}
+		return refs[i].Character < refs[j].Character
+	})
+	return refs
+}
+
+// referenceScore returns the score of a reference in the file at filePath with
+// the given language (empty if unknown) to the best matching definition.
+func referenceScore(definitions []protocol.Symbol, filePath, language string) int {
+	best := scoreOtherLanguage
+	for _, def := range definitions {
+		score := scoreOtherLanguage
+		switch {
+		case def.Path == filePath:
+			score = scoreSameFile
+		case def.FileLimited:
+			// The definition is not visible outside its file.
+		case !sameLanguage(def, filePath, language):
+			// A symbol with the same name in another language.
+		case path.Dir(def.Path) == path.Dir(filePath):
+			score = scoreSameDirectory
+		default:
+			score = scoreSameLanguage
+		}
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// sameLanguage reports whether the file at path with the given language is
+// written in the language of def. Files without symbols have no known
+// language, so their extension is compared instead.
+func sameLanguage(def protocol.Symbol, filePath, language string) bool {
+	if language != "" && def.Language != "" {
+		return language == def.Language
+	}
+	return path.Ext(filePath) == path.Ext(def.Path)
+}
@@ -0,0 +1,48 @@
+package symbols
+
+import (
+	"reflect"
+	"testing"
+
+	searcherprotocol "github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+func TestRankReferences(t *testing.T) {
+	definitions := []protocol.Symbol{
+		{Name: "foo", Path: "a/def.go", Line: 3, Language: "Go"},
+		{Name: "foo", Path: "c/static.c", Line: 1, Language: "C", FileLimited: true},
+	}
+	lineMatch := func(line int, offsets ...int) searcherprotocol.LineMatch {
+		lm := searcherprotocol.LineMatch{Preview: "foo()", LineNumber: line}
+		for _, o := range offsets {
+			lm.OffsetAndLengths = append(lm.OffsetAndLengths, [2]int{o, 3})
+		}
+		return lm
+	}
+	fileMatches := []searcherprotocol.FileMatch{
+		{Path: "b/other.go", LineMatches: []searcherprotocol.LineMatch{lineMatch(0, 0)}},
+		{Path: "c/other.c", LineMatches: []searcherprotocol.LineMatch{lineMatch(4, 0)}},
+		{Path: "a/def.go", LineMatches: []searcherprotocol.LineMatch{lineMatch(2, 5), lineMatch(9, 7, 0)}},
+		{Path: "a/nosymbols.go", LineMatches: []searcherprotocol.LineMatch{lineMatch(0, 0)}},
+		{Path: "a/README.md", LineMatches: []searcherprotocol.LineMatch{lineMatch(0, 0)}},
+	}
+	languages := map[string]string{
+		"a/def.go":   "Go",
+		"b/other.go": "Go",
+		"c/other.c":  "C",
+	}
+
+	got := rankReferences(definitions, fileMatches, languages)
+	want := []protocol.Reference{
+		{Path: "a/def.go", Line: 10, Character: 0, Preview: "foo()", Language: "Go", Score: scoreSameFile},
+		{Path: "a/def.go", Line: 10, Character: 7, Preview: "foo()", Language: "Go", Score: scoreSameFile},
+		{Path: "a/nosymbols.go", Line: 1, Character: 0, Preview: "foo()", Score: scoreSameDirectory},
+		{Path: "b/other.go", Line: 1, Character: 0, Preview: "foo()", Language: "Go", Score: scoreSameLanguage},
+		{Path: "a/README.md", Line: 1, Character: 0, Preview: "foo()", Score: scoreOtherLanguage},
+		{Path: "c/other.c", Line: 5, Character: 0, Preview: "foo()", Language: "C", Score: scoreOtherLanguage},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	searcherprotocol "github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/diskcache"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 // Service is the symbols service.
@@ -22,12 +24,35 @@ type Service struct {
 	// determine if the error is a bad request (eg invalid repo).
 	FetchTar func(context.Context, gitserver.Repo, api.CommitID) (io.ReadCloser, error)
 
+	// FetchTarPaths is like FetchTar, but the archive only contains the given
+	// paths. It must be set if GitDiff is set.
+	FetchTarPaths func(context.Context, gitserver.Repo, api.CommitID, []string) (io.ReadCloser, error)
+
+	// GitDiff returns the first parent of a commit and the paths of the files
+	// changed by the commit relative to that parent. The parent is empty for
+	// a root commit. If GitDiff is set, the symbols of a commit whose parent
+	// is already cached are indexed incrementally by copying the parent's
+	// database and only parsing the changed files.
+	GitDiff func(context.Context, gitserver.Repo, api.CommitID) (parent api.CommitID, changes Changes, err error)
+
+	// SearchWord returns the lines of the files in a repository at a commit on
+	// which word occurs as a whole, case sensitive word. At most
+	// fileMatchLimit files are returned, and limitHit is true if there are
+	// more. It is required to find the references to symbols.
+	SearchWord func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, word string, fileMatchLimit int) (matches []searcherprotocol.FileMatch, limitHit bool, err error)
+
 	// MaxConcurrentFetchTar is the maximum number of concurrent calls allowed
-	// to FetchTar. It defaults to 15.
+	// to FetchTar and FetchTarPaths. It defaults to 15.
 	MaxConcurrentFetchTar int
 
 	NewParser func() (ctags.Parser, error)
 
+	// CtagsConfig returns the site configuration `symbols.ctags`, which may be
+	// nil. NewParser should create parsers with the same configuration. When it
+	// changes, parsers are created again and the symbols of each commit are
+	// parsed again the next time they are searched.
+	CtagsConfig func() *schema.SymbolsCtags
+
 	// NumParserProcesses is the maximum number of ctags parser child processes to run.
 	NumParserProcesses int
 
@@ -48,7 +73,7 @@ type Service struct {
 	fetchSem chan int
 
 	// pool of ctags parser child processes
-	parsers chan ctags.Parser
+	parsers chan *pooledParser
 }
 
 // Start must be called before any requests are handled.
@@ -81,6 +106,7 @@ func (s *Service) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/references", s.handleReferences)
 	mux.HandleFunc("/healthz", s.handleHealthCheck)
 
 	return mux
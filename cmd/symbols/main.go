@@ -4,11 +4,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
@@ -20,13 +23,18 @@ import (
 	"github.com/pkg/errors"
 	log15 "gopkg.in/inconshreveable/log15.v2"
 
+	searcherprotocol "github.com/sourcegraph/sourcegraph/cmd/searcher/protocol"
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/symbols"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/debugserver"
+	"github.com/sourcegraph/sourcegraph/pkg/endpoint"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/tracer"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 const port = "3184"
@@ -36,6 +44,7 @@ func main() {
 		cacheDir       = env.Get("CACHE_DIR", "/tmp/symbols-cache", "directory to store cached symbols")
 		cacheSizeMB    = env.Get("SYMBOLS_CACHE_SIZE_MB", "100000", "maximum size of the disk cache in megabytes")
 		ctagsProcesses = env.Get("CTAGS_PROCESSES", strconv.Itoa(runtime.GOMAXPROCS(0)), "number of ctags child processes to run")
+		searcherURL    = env.Get("SEARCHER_URL", "k8s+http://searcher:3181", "searcher server URL, used to find the references to symbols")
 	)
 
 	env.Lock()
@@ -47,17 +56,35 @@ func main() {
 
 	go debugserver.Start()
 
+	searcherURLs := endpoint.New(searcherURL)
+
 	service := symbols.Service{
 		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
 			return gitserver.DefaultClient.Archive(ctx, repo, gitserver.ArchiveOptions{Treeish: string(commit), Format: "tar"})
 		},
+		FetchTarPaths: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			return gitserver.DefaultClient.Archive(ctx, repo, gitserver.ArchiveOptions{Treeish: string(commit), Format: "tar", Paths: paths})
+		},
+		GitDiff: gitDiff,
+		SearchWord: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, word string, fileMatchLimit int) ([]searcherprotocol.FileMatch, bool, error) {
+			return searchWord(ctx, searcherURLs, repo, commit, word, fileMatchLimit)
+		},
 		NewParser: func() (ctags.Parser, error) {
-			parser, err := ctags.NewParser(ctags.GetCommand())
+			config := conf.Get().SymbolsCtags
+			parser, err := ctags.NewParser(ctags.GetCommand(), config)
+			if err != nil && config != nil {
+				// Keep finding the symbols of the built-in languages.
+				log15.Error("Unable to start ctags with the symbols.ctags site configuration, ignoring it.", "error", err)
+				parser, err = ctags.NewParser(ctags.GetCommand(), nil)
+			}
 			if err != nil {
 				return nil, errors.Wrap(err, fmt.Sprintf("command: %s", ctags.GetCommand()))
 			}
 			return parser, nil
 		},
+		CtagsConfig: func() *schema.SymbolsCtags {
+			return conf.Get().SymbolsCtags
+		},
 		Path: cacheDir,
 	}
 	if mb, err := strconv.ParseInt(cacheSizeMB, 10, 64); err != nil {
@@ -90,6 +117,88 @@ func main() {
 	}
 }
 
+// gitDiff returns the first parent of commit and the files changed by commit
+// relative to it.
+func gitDiff(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (api.CommitID, symbols.Changes, error) {
+	c, err := git.GetCommit(ctx, repo, nil, commit)
+	if err != nil {
+		return "", symbols.Changes{}, err
+	}
+	if len(c.Parents) == 0 {
+		return "", symbols.Changes{}, nil
+	}
+	parent := c.Parents[0]
+
+	cmd := gitserver.DefaultClient.Command("git", "diff", "-z", "--name-status", "--no-renames", string(parent), string(c.ID))
+	cmd.Repo = repo
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return "", symbols.Changes{}, errors.Wrap(err, "git diff")
+	}
+	changes, err := symbols.ParseGitDiffNameStatus(out)
+	return parent, changes, err
+}
+
+// searchWord searches repo@commit for a whole, case sensitive word on
+// searcher.
+func searchWord(ctx context.Context, searcherURLs *endpoint.Map, repo gitserver.Repo, commit api.CommitID, word string, fileMatchLimit int) ([]searcherprotocol.FileMatch, bool, error) {
+	q := url.Values{
+		"Repo":                  []string{string(repo.Name)},
+		"URL":                   []string{repo.URL},
+		"Commit":                []string{string(commit)},
+		"Pattern":               []string{word},
+		"IsWordMatch":           []string{"true"},
+		"IsCaseSensitive":       []string{"true"},
+		"PatternMatchesContent": []string{"true"},
+		"PatternMatchesPath":    []string{"false"},
+		"FileMatchLimit":        []string{strconv.Itoa(fileMatchLimit)},
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		t, err := deadline.MarshalText()
+		if err != nil {
+			return nil, false, err
+		}
+		q.Set("Deadline", string(t))
+	}
+
+	// Searcher caches the file contents for repo@commit, so use the same
+	// consistent hashing key as the frontend to hit that cache.
+	searcherURL, err := searcherURLs.Get(string(repo.Name)+"@"+string(commit), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req, err := http.NewRequest("GET", searcherURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req, ht := nethttp.TraceRequest(opentracing.GlobalTracer(), req.WithContext(ctx),
+		nethttp.OperationName("Searcher Client"),
+		nethttp.ClientTrace(false))
+	defer ht.Finish()
+
+	resp, err := searcherHTTPClient.Do(req)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "searcher request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 200))
+		return nil, false, errors.Errorf("searcher http status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var r searcherprotocol.Response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, false, errors.Wrap(err, "searcher response invalid")
+	}
+	if r.DeadlineHit {
+		return nil, false, context.DeadlineExceeded
+	}
+	return r.Matches, r.LimitHit, nil
+}
+
+// nethttp.Transport will propagate opentracing spans
+var searcherHTTPClient = &http.Client{Transport: &nethttp.Transport{}}
+
 func shutdownOnSIGINT(s *http.Server) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
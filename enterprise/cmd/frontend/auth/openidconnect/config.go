@@ -60,13 +60,21 @@ func validateConfig(c conf.Unified) (problems []string) {
 		}
 	}
 
-	seen := map[schema.OpenIDConnectAuthProvider]int{}
+	// Compare the providers by their JSON encoding, since their group mappings make them
+	// unusable as map keys.
+	seen := map[string]int{}
 	for i, p := range c.Critical.AuthProviders {
 		if p.Openidconnect != nil {
-			if j, ok := seen[*p.Openidconnect]; ok {
+			data, err := json.Marshal(p.Openidconnect)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("invalid auth provider at index %d: %s", i, err))
+				continue
+			}
+			key := string(data)
+			if j, ok := seen[key]; ok {
 				problems = append(problems, fmt.Sprintf("OpenID Connect auth provider at index %d is duplicate of index %d, ignoring", i, j))
 			} else {
-				seen[*p.Openidconnect] = i
+				seen[key] = i
 			}
 		}
 	}
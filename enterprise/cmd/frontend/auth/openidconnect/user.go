@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/auth"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/groups"
 	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
 )
@@ -75,5 +76,53 @@ func getOrCreateUser(ctx context.Context, p *provider, idToken *oidc.IDToken, us
 	if err != nil {
 		return nil, safeErrMsg, err
 	}
+
+	groupsClaimName := p.config.GroupsClaimName
+	if groupsClaimName == "" {
+		groupsClaimName = "groups"
+	}
+	if err := groups.Apply(ctx, userID, providerType, pi.ServiceID, userGroups(groupsClaimName, idToken, userInfo), p.config.GroupMappings); err != nil {
+		return nil, "Error applying the group mappings of the OpenID Connect authentication provider. Ask a site admin for help.", err
+	}
 	return actor.FromUser(userID), "", nil
 }
+
+// userGroups returns the groups listed in the claim with the given name of the ID token or, if
+// the ID token has no such claim, of the user info.
+func userGroups(claimName string, idToken *oidc.IDToken, userInfo *oidc.UserInfo) []string {
+	var sources []interface{ Claims(interface{}) error }
+	if idToken != nil {
+		sources = append(sources, idToken)
+	}
+	if userInfo != nil {
+		sources = append(sources, userInfo)
+	}
+
+	for _, src := range sources {
+		var claims map[string]interface{}
+		if err := src.Claims(&claims); err != nil {
+			continue
+		}
+		if groups, ok := claimValues(claims[claimName]); ok {
+			return groups
+		}
+	}
+	return nil
+}
+
+// claimValues returns the strings in the given claim value, which is either a single string or
+// a list of strings. Any other values in a list are ignored.
+func claimValues(v interface{}) (values []string, ok bool) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, true
+	case []interface{}:
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
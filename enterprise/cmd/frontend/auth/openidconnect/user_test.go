@@ -0,0 +1,38 @@
+package openidconnect
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestClaimValues(t *testing.T) {
+	tests := map[string]struct {
+		claims string
+		want   []string
+		wantOK bool
+	}{
+		"list":       {claims: `{"groups": ["engineering", "admins"]}`, want: []string{"engineering", "admins"}, wantOK: true},
+		"single":     {claims: `{"groups": "engineering"}`, want: []string{"engineering"}, wantOK: true},
+		"mixed list": {claims: `{"groups": ["engineering", 1, null]}`, want: []string{"engineering"}, wantOK: true},
+		"empty list": {claims: `{"groups": []}`, wantOK: true},
+		"wrong type": {claims: `{"groups": 1}`},
+		"no claim":   {claims: `{"roles": ["engineering"]}`},
+		"null claim": {claims: `{"groups": null}`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var claims map[string]interface{}
+			if err := json.Unmarshal([]byte(test.claims), &claims); err != nil {
+				t.Fatal(err)
+			}
+			got, ok := claimValues(claims["groups"])
+			if ok != test.wantOK {
+				t.Errorf("got ok %v, want %v", ok, test.wantOK)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
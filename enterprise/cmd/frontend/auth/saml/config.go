@@ -75,13 +75,21 @@ func validateConfig(c conf.Unified) (problems []string) {
 		}
 	}
 
-	seen := map[schema.SAMLAuthProvider]int{}
+	// Compare the providers by their JSON encoding, since their group mappings make them
+	// unusable as map keys.
+	seen := map[string]int{}
 	for i, p := range c.Critical.AuthProviders {
 		if p.Saml != nil {
-			if j, ok := seen[*p.Saml]; ok {
+			data, err := json.Marshal(p.Saml)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("invalid auth provider at index %d: %s", i, err))
+				continue
+			}
+			key := string(data)
+			if j, ok := seen[key]; ok {
 				problems = append(problems, fmt.Sprintf("SAML auth provider at index %d is duplicate of index %d, ignoring", i, j))
 			} else {
-				seen[*p.Saml] = i
+				seen[key] = i
 			}
 		}
 	}
@@ -10,14 +10,17 @@ import (
 	saml2 "github.com/russellhaering/gosaml2"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/auth"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/groups"
 	"github.com/sourcegraph/sourcegraph/pkg/actor"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 type authnResponseInfo struct {
 	spec                 extsvc.ExternalAccountSpec
 	email, displayName   string
 	unnormalizedUsername string
+	groups               []string
 	accountData          interface{}
 }
 
@@ -67,6 +70,7 @@ func readAuthnResponse(p *provider, encodedResp string) (*authnResponseInfo, err
 		email:                email,
 		unnormalizedUsername: firstNonempty(attr.Get("login"), attr.Get("uid"), email),
 		displayName:          firstNonempty(attr.Get("displayName"), attr.Get("givenName")+" "+attr.Get("surname")),
+		groups:               attr.GetAll(groupsAttributeName(&p.config)),
 		accountData:          assertions,
 	}
 	if assertions.NameID == "" {
@@ -84,7 +88,7 @@ func readAuthnResponse(p *provider, encodedResp string) (*authnResponseInfo, err
 // getOrCreateUser gets or creates a user account based on the SAML claims. It returns the
 // authenticated actor if successful; otherwise it returns an friendly error message (safeErrMsg)
 // that is safe to display to users, and a non-nil err with lower-level error details.
-func getOrCreateUser(ctx context.Context, info *authnResponseInfo) (_ *actor.Actor, safeErrMsg string, err error) {
+func getOrCreateUser(ctx context.Context, p *provider, info *authnResponseInfo) (_ *actor.Actor, safeErrMsg string, err error) {
 	var data extsvc.ExternalAccountData
 	data.SetAccountData(info.accountData)
 
@@ -108,9 +112,22 @@ func getOrCreateUser(ctx context.Context, info *authnResponseInfo) (_ *actor.Act
 	if err != nil {
 		return nil, safeErrMsg, err
 	}
+
+	if err := groups.Apply(ctx, userID, info.spec.ServiceType, info.spec.ServiceID, info.groups, p.config.GroupMappings); err != nil {
+		return nil, "Error applying the group mappings of the SAML authentication provider. Ask a site admin for help.", err
+	}
 	return actor.FromUser(userID), "", nil
 }
 
+// groupsAttributeName returns the name of the SAML assertion attribute that lists the groups
+// of the user.
+func groupsAttributeName(pc *schema.SAMLAuthProvider) string {
+	if pc.GroupsAttributeName != "" {
+		return pc.GroupsAttributeName
+	}
+	return "groups"
+}
+
 func mightBeEmail(s string) bool {
 	return strings.Count(s, "@") == 1
 }
@@ -125,3 +142,16 @@ func (v samlAssertionValues) Get(key string) string {
 	}
 	return ""
 }
+
+// GetAll returns all values of the attributes with the given name or friendly name.
+func (v samlAssertionValues) GetAll(key string) []string {
+	var values []string
+	for _, a := range v {
+		if a.Name == key || a.FriendlyName == key {
+			for _, av := range a.Values {
+				values = append(values, av.Value)
+			}
+		}
+	}
+	return values
+}
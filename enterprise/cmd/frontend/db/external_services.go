@@ -19,5 +19,8 @@ func NewExternalServicesStore() *db.ExternalServicesStore {
 		BitbucketServerValidators: []func(*schema.BitbucketServerConnection, []schema.AuthProviders) error{
 			authz.ValidateBitbucketServerAuthz,
 		},
+		BitbucketCloudValidators: []func(*schema.BitbucketCloudConnection) error{
+			authz.ValidateBitbucketCloudAuthz,
+		},
 	}
 }
@@ -0,0 +1,106 @@
+package authz
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	bbcauthz "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/bitbucketcloud"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketcloud"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
+	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
+	"github.com/sourcegraph/sourcegraph/schema"
+	"golang.org/x/time/rate"
+)
+
+func bitbucketCloudProviders(
+	ctx context.Context,
+	db *sql.DB,
+	cfg *conf.Unified,
+	svcs []*types.ExternalService,
+) (
+	authzProviders []authz.Provider,
+	seriousProblems []string,
+	warnings []string,
+) {
+	// Authorization (i.e., permissions) providers
+	for _, svc := range svcs {
+		var c schema.BitbucketCloudConnection
+		if err := jsonc.Unmarshal(svc.Config, &c); err != nil {
+			seriousProblems = append(seriousProblems, fmt.Sprintf("Could not parse Bitbucket Cloud external service config (id=%d): %s", svc.ID, err))
+			continue
+		}
+		rl := ratelimits.BitbucketCloud(&c).Configure(svc.ID)
+		if p, err := bitbucketCloudProvider(db, &c, rl); err != nil {
+			seriousProblems = append(seriousProblems, err.Error())
+		} else if p != nil {
+			authzProviders = append(authzProviders, p)
+		}
+	}
+
+	for _, p := range authzProviders {
+		for _, problem := range p.Validate() {
+			warnings = append(warnings, fmt.Sprintf("BitbucketCloud config for %s was invalid: %s", p.ServiceID(), problem))
+		}
+	}
+
+	return authzProviders, seriousProblems, warnings
+}
+
+func bitbucketCloudProvider(db *sql.DB, c *schema.BitbucketCloudConnection, rl *rate.Limiter) (authz.Provider, error) {
+	a := c.Authorization
+	if a == nil {
+		return nil, nil
+	}
+
+	errs := new(multierror.Error)
+
+	ttl, err := parseTTL(a.Ttl)
+	if err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
+	hardTTL, err := parseHardTTL(a.HardTTL, ttl)
+	if err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
+	baseURL, err := url.Parse(c.Url)
+	if err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
+	if len(c.Teams) == 0 {
+		errs = multierror.Append(errs, errors.Errorf("authorization: teams must be set to enforce Bitbucket Cloud repository permissions"))
+	}
+
+	cli := bitbucketcloud.NewClient(nil)
+	cli.Username = c.Username
+	cli.AppPassword = c.AppPassword
+	if rl != nil {
+		cli.RateLimit = rl
+	}
+
+	var p authz.Provider
+	switch idp := a.IdentityProvider; {
+	case idp.Username != nil:
+		p = bbcauthz.NewProvider(cli, baseURL, c.Teams, db, ttl, hardTTL)
+	default:
+		errs = multierror.Append(errs, errors.Errorf("No identityProvider was specified"))
+	}
+
+	return p, errs.ErrorOrNil()
+}
+
+// ValidateBitbucketCloudAuthz validates the authorization fields of the given BitbucketCloud external
+// service config.
+func ValidateBitbucketCloudAuthz(c *schema.BitbucketCloudConnection) error {
+	_, err := bitbucketCloudProvider(nil, c, nil)
+	return err
+}
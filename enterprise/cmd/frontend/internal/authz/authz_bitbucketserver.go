@@ -9,25 +9,35 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	bbsauthz "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/bitbucketserver"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
+	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/schema"
+	"golang.org/x/time/rate"
 )
 
 func bitbucketServerProviders(
 	ctx context.Context,
 	db *sql.DB,
 	cfg *conf.Unified,
-	conns []*schema.BitbucketServerConnection,
+	svcs []*types.ExternalService,
 ) (
 	authzProviders []authz.Provider,
 	seriousProblems []string,
 	warnings []string,
 ) {
 	// Authorization (i.e., permissions) providers
-	for _, c := range conns {
-		if p, err := bitbucketServerProvider(db, c.Authorization, c.Url, c.Username, cfg.Critical.AuthProviders); err != nil {
+	for _, svc := range svcs {
+		var c schema.BitbucketServerConnection
+		if err := jsonc.Unmarshal(svc.Config, &c); err != nil {
+			seriousProblems = append(seriousProblems, fmt.Sprintf("Could not parse Bitbucket Server external service config (id=%d): %s", svc.ID, err))
+			continue
+		}
+		rl := ratelimits.BitbucketServer(&c).Configure(svc.ID)
+		if p, err := bitbucketServerProvider(db, c.Authorization, c.Url, c.Username, rl, cfg.Critical.AuthProviders); err != nil {
 			seriousProblems = append(seriousProblems, err.Error())
 		} else if p != nil {
 			authzProviders = append(authzProviders, p)
@@ -47,6 +57,7 @@ func bitbucketServerProvider(
 	db *sql.DB,
 	a *schema.BitbucketServerAuthorization,
 	instanceURL, username string,
+	rl *rate.Limiter,
 	ps []schema.AuthProviders,
 ) (authz.Provider, error) {
 	if a == nil {
@@ -76,6 +87,9 @@ func bitbucketServerProvider(
 
 	cli := bitbucketserver.NewClient(baseURL, nil)
 	cli.Username = username
+	if rl != nil {
+		cli.RateLimit = rl
+	}
 
 	if err = cli.SetOAuth(a.Oauth.ConsumerKey, a.Oauth.SigningKey); err != nil {
 		errs = multierror.Append(errs, errors.Wrap(err, "authorization.oauth.signingKey"))
@@ -95,6 +109,6 @@ func bitbucketServerProvider(
 // ValidateBitbucketServerAuthz validates the authorization fields of the given BitbucketServer external
 // service config.
 func ValidateBitbucketServerAuthz(c *schema.BitbucketServerConnection, ps []schema.AuthProviders) error {
-	_, err := bitbucketServerProvider(nil, c.Authorization, c.Url, c.Username, ps)
+	_, err := bitbucketServerProvider(nil, c.Authorization, c.Url, c.Username, nil, ps)
 	return err
 }
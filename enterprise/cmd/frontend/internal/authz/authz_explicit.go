@@ -0,0 +1,90 @@
+package authz
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/explicit"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitolite"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+// explicitProviders returns an explicit authz provider for each code host of the given
+// Gitolite and other external service connections with authorization enabled.
+func explicitProviders(
+	ctx context.Context,
+	db *sql.DB,
+	gitolites []*schema.GitoliteConnection,
+	others []*schema.OtherExternalServiceConnection,
+) (
+	authzProviders []authz.Provider,
+	seriousProblems []string,
+	warnings []string,
+) {
+	var serviceIDs []string
+	for _, c := range gitolites {
+		if c.Authorization != nil {
+			serviceIDs = append(serviceIDs, gitolite.ServiceID(c.Host))
+		}
+	}
+
+	for _, c := range others {
+		if c.Authorization == nil {
+			continue
+		}
+		ids, err := otherServiceIDs(c)
+		if err != nil {
+			seriousProblems = append(seriousProblems, err.Error())
+			continue
+		}
+		serviceIDs = append(serviceIDs, ids...)
+	}
+
+	// Only the first provider of a given service ID is ever consulted, so
+	// connections to the same code host share one.
+	seen := make(map[string]bool, len(serviceIDs))
+	for _, id := range serviceIDs {
+		if !seen[id] {
+			seen[id] = true
+			authzProviders = append(authzProviders, explicit.NewProvider(id, db))
+		}
+	}
+
+	return authzProviders, seriousProblems, warnings
+}
+
+// otherServiceIDs returns the service IDs of the repositories of the given connection,
+// computed the same way repo-updater computes them when syncing the repositories.
+func otherServiceIDs(c *schema.OtherExternalServiceConnection) ([]string, error) {
+	var base *url.URL
+	if c.Url != "" {
+		var err error
+		if base, err = url.Parse(c.Url); err != nil {
+			return nil, fmt.Errorf("Could not parse URL %q of other external service: %s", c.Url, err)
+		}
+	}
+
+	var ids []string
+	for _, repo := range c.Repos {
+		var (
+			u   *url.URL
+			err error
+		)
+		if base == nil {
+			u, err = url.Parse(repo)
+		} else {
+			u, err = base.Parse(repo)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse clone URL %q of other external service: %s", repo, err)
+		}
+
+		u.Path, u.RawQuery = "", ""
+		ids = append(ids, u.String())
+	}
+
+	return ids, nil
+}
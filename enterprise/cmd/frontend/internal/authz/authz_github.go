@@ -2,6 +2,7 @@ package authz
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/url"
 
@@ -10,13 +11,13 @@ import (
 	"github.com/sourcegraph/sourcegraph/schema"
 )
 
-func githubProviders(ctx context.Context, githubs []*schema.GitHubConnection) (
+func githubProviders(ctx context.Context, db *sql.DB, githubs []*schema.GitHubConnection) (
 	authzProviders []authz.Provider,
 	seriousProblems []string,
 	warnings []string,
 ) {
 	for _, g := range githubs {
-		p, err := githubProvider(g.Authorization, g.Url, g.Token)
+		p, err := githubProvider(db, g.Authorization, g.Url, g.Token)
 		if err != nil {
 			seriousProblems = append(seriousProblems, err.Error())
 			continue
@@ -28,7 +29,7 @@ func githubProviders(ctx context.Context, githubs []*schema.GitHubConnection) (
 	return authzProviders, seriousProblems, warnings
 }
 
-func githubProvider(a *schema.GitHubAuthorization, instanceURL, token string) (authz.Provider, error) {
+func githubProvider(db *sql.DB, a *schema.GitHubAuthorization, instanceURL, token string) (authz.Provider, error) {
 	if a == nil {
 		return nil, nil
 	}
@@ -43,12 +44,17 @@ func githubProvider(a *schema.GitHubAuthorization, instanceURL, token string) (a
 		return nil, err
 	}
 
-	return permgh.NewProvider(ghURL, token, ttl, nil), nil
+	hardTTL, err := parseHardTTL(a.HardTTL, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return permgh.NewProvider(ghURL, token, db, ttl, hardTTL), nil
 }
 
 // ValidateGitHubAuthz validates the authorization fields of the given GitHub external
 // service config.
 func ValidateGitHubAuthz(cfg *schema.GitHubConnection) error {
-	_, err := githubProvider(cfg.Authorization, cfg.Url, cfg.Token)
+	_, err := githubProvider(nil, cfg.Authorization, cfg.Url, cfg.Token)
 	return err
 }
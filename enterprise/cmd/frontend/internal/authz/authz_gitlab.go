@@ -2,28 +2,40 @@ package authz
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/url"
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/auth/providers"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	permgl "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/gitlab"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/ratelimits"
+	"github.com/sourcegraph/sourcegraph/pkg/jsonc"
 	"github.com/sourcegraph/sourcegraph/schema"
+	"golang.org/x/time/rate"
 )
 
 func gitlabProviders(
 	ctx context.Context,
+	db *sql.DB,
 	cfg *conf.Unified,
-	gitlabs []*schema.GitLabConnection,
+	gitlabs []*types.ExternalService,
 ) (
 	authzProviders []authz.Provider,
 	seriousProblems []string,
 	warnings []string,
 ) {
 	// Authorization (i.e., permissions) providers
-	for _, gl := range gitlabs {
-		p, err := gitlabProvider(gl.Authorization, gl.Url, gl.Token, cfg.Critical.AuthProviders)
+	for _, svc := range gitlabs {
+		var gl schema.GitLabConnection
+		if err := jsonc.Unmarshal(svc.Config, &gl); err != nil {
+			seriousProblems = append(seriousProblems, fmt.Sprintf("Could not parse GitLab external service config (id=%d): %s", svc.ID, err))
+			continue
+		}
+		rl := ratelimits.GitLab(&gl).Configure(svc.ID)
+		p, err := gitlabProvider(db, gl.Authorization, gl.Url, gl.Token, rl, cfg.Critical.AuthProviders)
 		if err != nil {
 			seriousProblems = append(seriousProblems, err.Error())
 			continue
@@ -40,7 +52,10 @@ func gitlabProviders(
 	return authzProviders, seriousProblems, warnings
 }
 
-func gitlabProvider(a *schema.GitLabAuthorization, instanceURL, token string, ps []schema.AuthProviders) (authz.Provider, error) {
+// gitlabProvider returns the authz provider of a GitLab external service. The
+// sudo providers use the token of the external service, so their requests are
+// limited by rl, the rate limiter of the external service.
+func gitlabProvider(db *sql.DB, a *schema.GitLabAuthorization, instanceURL, token string, rl *rate.Limiter, ps []schema.AuthProviders) (authz.Provider, error) {
 	if a == nil {
 		return nil, nil
 	}
@@ -55,6 +70,11 @@ func gitlabProvider(a *schema.GitLabAuthorization, instanceURL, token string, ps
 		return nil, err
 	}
 
+	hardTTL, err := parseHardTTL(a.HardTTL, ttl)
+	if err != nil {
+		return nil, err
+	}
+
 	switch idp := a.IdentityProvider; {
 	case idp.Oauth != nil:
 		// Check that there is a GitLab authn provider corresponding to this GitLab instance
@@ -83,13 +103,18 @@ func gitlabProvider(a *schema.GitLabAuthorization, instanceURL, token string, ps
 
 		return NewGitLabOAuthProvider(permgl.GitLabOAuthAuthzProviderOp{
 			BaseURL:  glURL,
+			DB:       db,
 			CacheTTL: ttl,
+			HardTTL:  hardTTL,
 		}), nil
 	case idp.Username != nil:
 		return NewGitLabSudoProvider(permgl.SudoProviderOp{
 			BaseURL:           glURL,
 			SudoToken:         token,
+			RateLimiter:       rl,
+			DB:                db,
 			CacheTTL:          ttl,
+			HardTTL:           hardTTL,
 			UseNativeUsername: true,
 		}), nil
 	case idp.External != nil:
@@ -108,7 +133,10 @@ func gitlabProvider(a *schema.GitLabAuthorization, instanceURL, token string, ps
 					},
 					GitLabProvider:    ext.GitlabProvider,
 					SudoToken:         token,
+					RateLimiter:       rl,
+					DB:                db,
 					CacheTTL:          ttl,
+					HardTTL:           hardTTL,
 					UseNativeUsername: false,
 				}), nil
 			}
@@ -132,6 +160,6 @@ var NewGitLabSudoProvider = func(op permgl.SudoProviderOp) authz.Provider {
 // ValidateGitLabAuthz validates the authorization fields of the given GitLab external
 // service config.
 func ValidateGitLabAuthz(cfg *schema.GitLabConnection, ps []schema.AuthProviders) error {
-	_, err := gitlabProvider(cfg.Authorization, cfg.Url, cfg.Token, ps)
+	_, err := gitlabProvider(nil, cfg.Authorization, cfg.Url, cfg.Token, nil, ps)
 	return err
 }
@@ -0,0 +1,46 @@
+package authz
+
+import (
+	"database/sql"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/groups"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+// groupProviders wraps each of the given authz providers so that they also grant the
+// repositories the identity provider groups of users are mapped to, if any SAML or
+// OpenID Connect auth provider maps groups to repositories. Otherwise, the given
+// providers are returned unchanged.
+func groupProviders(db *sql.DB, cfg *conf.Unified, ps []authz.Provider) []authz.Provider {
+	if !hasGroupRepoMappings(cfg.Critical.AuthProviders) {
+		return ps
+	}
+
+	wrapped := make([]authz.Provider, 0, len(ps))
+	for _, p := range ps {
+		wrapped = append(wrapped, groups.NewProvider(p, db))
+	}
+	return wrapped
+}
+
+// hasGroupRepoMappings reports whether any of the given auth providers maps groups to
+// repositories.
+func hasGroupRepoMappings(ps []schema.AuthProviders) bool {
+	for _, p := range ps {
+		var mappings []*schema.AuthProviderGroupMapping
+		switch {
+		case p.Saml != nil:
+			mappings = p.Saml.GroupMappings
+		case p.Openidconnect != nil:
+			mappings = p.Openidconnect.GroupMappings
+		}
+		for _, m := range mappings {
+			if len(m.Repos) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -10,11 +10,15 @@ import (
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/auth/providers"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	bbsauthz "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/explicit"
 	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/gitlab"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/groups"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+	"github.com/sourcegraph/sourcegraph/pkg/ratelimit"
 	"github.com/sourcegraph/sourcegraph/schema"
 )
 
@@ -46,11 +50,9 @@ func (m gitlabAuthzProviderParams) Validate() []string { return nil }
 
 func Test_providersFromConfig(t *testing.T) {
 	NewGitLabOAuthProvider = func(op gitlab.GitLabOAuthAuthzProviderOp) authz.Provider {
-		op.MockCache = nil // ignore cache value
 		return gitlabAuthzProviderParams{OAuthOp: op}
 	}
 	NewGitLabSudoProvider = func(op gitlab.SudoProviderOp) authz.Provider {
-		op.MockCache = nil // ignore cache value
 		return gitlabAuthzProviderParams{SudoOp: op}
 	}
 
@@ -69,6 +71,9 @@ func Test_providersFromConfig(t *testing.T) {
 		cfg                          conf.Unified
 		gitlabConnections            []*schema.GitLabConnection
 		bitbucketServerConnections   []*schema.BitbucketServerConnection
+		bitbucketCloudConnections    []*schema.BitbucketCloudConnection
+		gitoliteConnections          []*schema.GitoliteConnection
+		otherConnections             []*schema.OtherExternalServiceConnection
 		expAuthzAllowAccessByDefault bool
 		expAuthzProviders            func(*testing.T, []authz.Provider)
 		expSeriousProblems           []string
@@ -104,6 +109,7 @@ func Test_providersFromConfig(t *testing.T) {
 					OAuthOp: gitlab.GitLabOAuthAuthzProviderOp{
 						BaseURL:  mustURLParse(t, "https://gitlab.mine"),
 						CacheTTL: 48 * time.Hour,
+						HardTTL:  72 * time.Hour,
 					},
 				},
 			),
@@ -205,12 +211,14 @@ func Test_providersFromConfig(t *testing.T) {
 					OAuthOp: gitlab.GitLabOAuthAuthzProviderOp{
 						BaseURL:  mustURLParse(t, "https://gitlab.mine"),
 						CacheTTL: 3 * time.Hour,
+						HardTTL:  72 * time.Hour,
 					},
 				},
 				gitlabAuthzProviderParams{
 					OAuthOp: gitlab.GitLabOAuthAuthzProviderOp{
 						BaseURL:  mustURLParse(t, "https://gitlab.com"),
 						CacheTTL: 3 * time.Hour,
+						HardTTL:  72 * time.Hour,
 					},
 				},
 			),
@@ -305,7 +313,9 @@ func Test_providersFromConfig(t *testing.T) {
 						},
 						GitLabProvider:    "my-external",
 						SudoToken:         "asdf",
+						RateLimiter:       ratelimit.DefaultRegistry.Get(1),
 						CacheTTL:          3 * time.Hour,
+						HardTTL:           72 * time.Hour,
 						UseNativeUsername: false,
 					},
 				},
@@ -333,7 +343,9 @@ func Test_providersFromConfig(t *testing.T) {
 					SudoOp: gitlab.SudoProviderOp{
 						BaseURL:           mustURLParse(t, "https://gitlab.mine"),
 						SudoToken:         "asdf",
+						RateLimiter:       ratelimit.DefaultRegistry.Get(1),
 						CacheTTL:          3 * time.Hour,
+						HardTTL:           72 * time.Hour,
 						UseNativeUsername: true,
 					},
 				},
@@ -435,6 +447,95 @@ func Test_providersFromConfig(t *testing.T) {
 				}
 			},
 		},
+		{
+			description: "Bitbucket Cloud without teams",
+			cfg:         conf.Unified{},
+			bitbucketCloudConnections: []*schema.BitbucketCloudConnection{
+				{
+					Authorization: &schema.BitbucketCloudAuthorization{
+						IdentityProvider: schema.BitbucketCloudIdentityProvider{
+							Username: &schema.BitbucketCloudUsernameIdentity{
+								Type: "username",
+							},
+						},
+						Ttl:     "15m",
+						HardTTL: "10m",
+					},
+					Url:         "https://bitbucket.org",
+					Username:    "admin",
+					AppPassword: "secret-password",
+				},
+			},
+			expAuthzAllowAccessByDefault: false,
+			expSeriousProblems:           []string{"2 errors occurred:\n\t* authorization.hardTTL: must be larger than ttl\n\t* authorization: teams must be set to enforce Bitbucket Cloud repository permissions\n\n"},
+		},
+		{
+			description: "Gitolite and other connections with explicit authorization",
+			cfg:         conf.Unified{},
+			gitoliteConnections: []*schema.GitoliteConnection{
+				{Host: "git@gitolite.example.com", Authorization: &schema.ExplicitAuthorization{}},
+				{Host: "git@gitolite.example.com", Authorization: &schema.ExplicitAuthorization{}},
+				{Host: "git@gitolite.example.org"},
+			},
+			otherConnections: []*schema.OtherExternalServiceConnection{
+				{
+					Url:           "https://git.example.com/base/?token=secret",
+					Repos:         []string{"a", "b/c.git"},
+					Authorization: &schema.ExplicitAuthorization{},
+				},
+				{Url: "https://git.example.org", Repos: []string{"d"}},
+			},
+			expAuthzAllowAccessByDefault: true,
+			expAuthzProviders: func(t *testing.T, have []authz.Provider) {
+				var serviceIDs []string
+				for _, p := range have {
+					if _, ok := p.(*explicit.Provider); !ok {
+						t.Fatalf("got provider %T, want *explicit.Provider", p)
+					}
+					serviceIDs = append(serviceIDs, p.ServiceID())
+				}
+
+				want := []string{"git@gitolite.example.com", "https://git.example.com"}
+				if !reflect.DeepEqual(serviceIDs, want) {
+					t.Errorf("got service IDs %q, want %q", serviceIDs, want)
+				}
+			},
+		},
+		{
+			description: "SAML auth provider mapping groups to repositories",
+			cfg: conf.Unified{
+				Critical: schema.CriticalConfiguration{
+					AuthProviders: []schema.AuthProviders{{
+						Saml: &schema.SAMLAuthProvider{
+							Type: "saml",
+							GroupMappings: []*schema.AuthProviderGroupMapping{
+								{Group: "engineering", Repos: []string{"git.example.com/base/a"}},
+							},
+						},
+					}},
+				},
+			},
+			otherConnections: []*schema.OtherExternalServiceConnection{
+				{
+					Url:           "https://git.example.com/base/",
+					Repos:         []string{"a"},
+					Authorization: &schema.ExplicitAuthorization{},
+				},
+			},
+			expAuthzAllowAccessByDefault: true,
+			expAuthzProviders: func(t *testing.T, have []authz.Provider) {
+				if len(have) != 1 {
+					t.Fatalf("got %d providers, want 1", len(have))
+				}
+				p, ok := have[0].(*groups.Provider)
+				if !ok {
+					t.Fatalf("got provider %T, want *groups.Provider", have[0])
+				}
+				if _, ok := p.Provider.(*explicit.Provider); !ok {
+					t.Fatalf("got wrapped provider %T, want *explicit.Provider", p.Provider)
+				}
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -443,6 +544,9 @@ func Test_providersFromConfig(t *testing.T) {
 		store := fakeStore{
 			gitlabs:          test.gitlabConnections,
 			bitbucketServers: test.bitbucketServerConnections,
+			bitbucketClouds:  test.bitbucketCloudConnections,
+			gitolites:        test.gitoliteConnections,
+			others:           test.otherConnections,
 		}
 
 		allowAccessByDefault, authzProviders, seriousProblems, _ := ProvidersFromConfig(context.Background(), &test.cfg, &store, nil)
@@ -478,16 +582,55 @@ type fakeStore struct {
 	gitlabs          []*schema.GitLabConnection
 	githubs          []*schema.GitHubConnection
 	bitbucketServers []*schema.BitbucketServerConnection
+	bitbucketClouds  []*schema.BitbucketCloudConnection
+	gitolites        []*schema.GitoliteConnection
+	others           []*schema.OtherExternalServiceConnection
+}
+
+// List returns the connections of the given kinds as external services whose
+// IDs are their 1-based index among the connections of their kind.
+func (s fakeStore) List(ctx context.Context, opt db.ExternalServicesListOptions) ([]*types.ExternalService, error) {
+	var svcs []*types.ExternalService
+	add := func(kind string, conn interface{}, i int) error {
+		config, err := json.Marshal(conn)
+		if err != nil {
+			return err
+		}
+		svcs = append(svcs, &types.ExternalService{ID: int64(i + 1), Kind: kind, Config: string(config)})
+		return nil
+	}
+
+	for _, kind := range opt.Kinds {
+		var err error
+		switch kind {
+		case "GITLAB":
+			for i, c := range s.gitlabs {
+				err = add(kind, c, i)
+			}
+		case "BITBUCKETSERVER":
+			for i, c := range s.bitbucketServers {
+				err = add(kind, c, i)
+			}
+		case "BITBUCKETCLOUD":
+			for i, c := range s.bitbucketClouds {
+				err = add(kind, c, i)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return svcs, nil
 }
 
 func (s fakeStore) ListGitHubConnections(context.Context) ([]*schema.GitHubConnection, error) {
 	return s.githubs, nil
 }
 
-func (s fakeStore) ListGitLabConnections(context.Context) ([]*schema.GitLabConnection, error) {
-	return s.gitlabs, nil
+func (s fakeStore) ListGitoliteConnections(context.Context) ([]*schema.GitoliteConnection, error) {
+	return s.gitolites, nil
 }
 
-func (s fakeStore) ListBitbucketServerConnections(context.Context) ([]*schema.BitbucketServerConnection, error) {
-	return s.bitbucketServers, nil
+func (s fakeStore) ListOtherExternalServicesConnections(context.Context) ([]*schema.OtherExternalServiceConnection, error) {
+	return s.others, nil
 }
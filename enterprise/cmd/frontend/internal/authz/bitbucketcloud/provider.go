@@ -0,0 +1,245 @@
+// Package bitbucketcloud contains an authorization provider for Bitbucket Cloud.
+package bitbucketcloud
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketcloud"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
+)
+
+// Provider is an implementation of AuthzProvider that provides repository permissions as
+// determined from the Bitbucket Cloud API.
+type Provider struct {
+	client   *bitbucketcloud.Client
+	codeHost *extsvc.CodeHost
+	teams    []string
+	pageSize int // Page size to use in paginated requests.
+	store    *store.Store
+}
+
+var _ authz.Provider = ((*Provider)(nil))
+
+// NewProvider returns a new Bitbucket Cloud authorization provider that uses the given
+// bitbucketcloud.Client to fetch the permissions of users on the repositories of the
+// given teams. The client's user must be an administrator of those teams. It assumes
+// usernames of Sourcegraph accounts match 1-1 with usernames of Bitbucket Cloud users.
+func NewProvider(cli *bitbucketcloud.Client, baseURL *url.URL, teams []string, db *sql.DB, ttl, hardTTL time.Duration) *Provider {
+	return &Provider{
+		client:   cli,
+		codeHost: extsvc.NewCodeHost(baseURL, bitbucketcloud.ServiceType),
+		teams:    teams,
+		pageSize: 100,
+		store:    store.New(db, ttl, hardTTL, store.Clock, store.NewCache()),
+	}
+}
+
+// Validate validates that the Provider has access to the repository permissions of the
+// teams it was configured with.
+func (p *Provider) Validate() (problems []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, team := range p.teams {
+		_, _, err := p.client.TeamRepositoryPermissions(ctx, &bitbucketcloud.PageToken{Pagelen: 1}, team, "")
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("could not list the repository permissions of team %q, the configured user must be an administrator of the team: %s", team, err))
+		}
+	}
+
+	return problems
+}
+
+// ServiceID returns the absolute URL that identifies the Bitbucket Cloud instance
+// this provider is configured with.
+func (p *Provider) ServiceID() string { return p.codeHost.ServiceID }
+
+// ServiceType returns the type of this Provider, namely, "bitbucketCloud".
+func (p *Provider) ServiceType() string { return p.codeHost.ServiceType }
+
+// RepoPerms returns the permissions the given external account has in relation to the given set of repos.
+// A user can read the public repositories of the configured teams and those they have an explicit
+// permission on, as listed by the Bitbucket Cloud API. Without an account, only public repositories
+// can be read.
+func (p *Provider) RepoPerms(ctx context.Context, acct *extsvc.ExternalAccount, repos []*types.Repo) (
+	perms []authz.RepoPerms,
+	err error,
+) {
+	var (
+		userUUID string
+		userID   int32
+	)
+
+	tr, ctx := trace.New(ctx, "bitbucketcloud.authz.provider.RepoPerms", "")
+	defer func() {
+		tr.LogFields(
+			otlog.String("user.uuid", userUUID),
+			otlog.Int32("user.id", userID),
+			otlog.Int("repos.count", len(repos)),
+			otlog.Int("perms.count", len(perms)),
+		)
+
+		if err != nil {
+			tr.SetError(err)
+		}
+
+		tr.Finish()
+	}()
+
+	if acct != nil && acct.ServiceID == p.codeHost.ServiceID && acct.ServiceType == p.codeHost.ServiceType {
+		var user bitbucketcloud.User
+		if acct.AccountData != nil {
+			if err := json.Unmarshal(*acct.AccountData, &user); err != nil {
+				return nil, err
+			}
+		}
+
+		userID = acct.UserID
+		userUUID = user.UUID
+	}
+
+	// The stored permissions are used for all later requests of the user, so they
+	// cover all the repositories of this code host, not only the requested ones.
+	update := func(ctx context.Context) ([]uint32, error) {
+		visible, err := p.repos(ctx, userUUID)
+		if err != nil {
+			return nil, err
+		}
+
+		all, err := p.store.Repos(ctx, p.codeHost.ServiceType, p.codeHost.ServiceID)
+		if err != nil {
+			return nil, err
+		}
+
+		authorized := make([]uint32, 0, len(visible))
+		for _, repo := range all {
+			if visible[repo.ExternalRepo.ID] {
+				authorized = append(authorized, uint32(repo.ID))
+			}
+		}
+
+		return authorized, nil
+	}
+
+	ps := &store.Permissions{
+		UserID:      userID,
+		Perm:        authz.Read,
+		Type:        "repos",
+		ServiceType: p.codeHost.ServiceType,
+		ServiceID:   p.codeHost.ServiceID,
+	}
+
+	err = p.store.LoadPermissions(ctx, &ps, update)
+	if err != nil {
+		return nil, err
+	}
+
+	return ps.Authorized(repos), nil
+}
+
+// FetchAccount satisfies the authz.Provider interface. It returns the Bitbucket Cloud
+// account with the same username as the given user, if any.
+func (p *Provider) FetchAccount(ctx context.Context, user *types.User, _ []*extsvc.ExternalAccount) (acct *extsvc.ExternalAccount, err error) {
+	if user == nil {
+		return nil, nil
+	}
+
+	tr, ctx := trace.New(ctx, "bitbucketcloud.authz.provider.FetchAccount", "")
+	defer func() {
+		tr.LogFields(
+			otlog.String("user.name", user.Username),
+			otlog.Int32("user.id", user.ID),
+		)
+
+		if err != nil {
+			tr.SetError(err)
+		}
+
+		tr.Finish()
+	}()
+
+	bitbucketUser, err := p.client.User(ctx, user.Username)
+	if errcode.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	accountData, err := json.Marshal(bitbucketUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return &extsvc.ExternalAccount{
+		UserID: user.ID,
+		ExternalAccountSpec: extsvc.ExternalAccountSpec{
+			ServiceType: p.codeHost.ServiceType,
+			ServiceID:   p.codeHost.ServiceID,
+			AccountID:   bitbucketUser.UUID,
+		},
+		ExternalAccountData: extsvc.ExternalAccountData{
+			AccountData: (*json.RawMessage)(&accountData),
+		},
+	}, nil
+}
+
+// repos returns the set of the UUIDs of the repositories of the configured teams which
+// the user with the given UUID can read. When no user UUID is given, only public
+// repositories are returned.
+func (p *Provider) repos(ctx context.Context, userUUID string) (map[string]bool, error) {
+	visible := make(map[string]bool)
+
+	for _, team := range p.teams {
+		for t := (&bitbucketcloud.PageToken{Pagelen: p.pageSize}); t != nil; {
+			repos, next, err := p.client.Repos(ctx, t, team)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range repos {
+				if !r.IsPrivate {
+					visible[r.UUID] = true
+				}
+			}
+			if t = next; !t.HasMore() {
+				t = nil
+			}
+		}
+
+		if userUUID == "" {
+			continue
+		}
+
+		q := fmt.Sprintf("user.uuid=%q", userUUID)
+		for t := (&bitbucketcloud.PageToken{Pagelen: p.pageSize}); t != nil; {
+			perms, next, err := p.client.TeamRepositoryPermissions(ctx, t, team, q)
+			if err != nil {
+				return nil, err
+			}
+			for _, perm := range perms {
+				if perm.Repository == nil || perm.User == nil || perm.User.UUID != userUUID {
+					continue
+				}
+				switch perm.Permission {
+				case "read", "write", "admin":
+					visible[perm.Repository.UUID] = true
+				}
+			}
+			if t = next; !t.HasMore() {
+				t = nil
+			}
+		}
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,262 @@
+package bitbucketcloud
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketcloud"
+)
+
+func TestProvider_FetchAccount(t *testing.T) {
+	bb := newMockBitbucketCloud()
+	srv := httptest.NewServer(bb)
+	defer srv.Close()
+
+	p := newProvider(t, srv, nil)
+
+	for _, tc := range []struct {
+		name string
+		user *types.User
+		want *extsvc.ExternalAccount
+	}{
+		{
+			name: "no user",
+		},
+		{
+			name: "no matching username",
+			user: &types.User{ID: 1, Username: "nobody"},
+		},
+		{
+			name: "matching username",
+			user: &types.User{ID: 1, Username: "alice"},
+			want: account(t, 1, bb.users["alice"]),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			have, err := p.FetchAccount(context.Background(), tc.user, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(have, tc.want) {
+				t.Error(cmp.Diff(have, tc.want))
+			}
+		})
+	}
+}
+
+func testProviderRepoPerms(db *sql.DB) func(*testing.T) {
+	return func(t *testing.T) {
+		bb := newMockBitbucketCloud()
+		srv := httptest.NewServer(bb)
+		defer srv.Close()
+
+		p := newProvider(t, srv, db)
+		p.store.Block = true // Wait for first update to complete.
+
+		repos := make([]*types.Repo, 0, len(bb.repos))
+		repo := make(map[string]*types.Repo, len(bb.repos))
+		for i, r := range bb.repos {
+			repo[r.FullName] = &types.Repo{
+				ID:   api.RepoID(i + 1),
+				Name: api.RepoName("bitbucket.org/" + r.FullName),
+				ExternalRepo: api.ExternalRepoSpec{
+					ID:          r.UUID,
+					ServiceType: bitbucketcloud.ServiceType,
+					ServiceID:   "https://bitbucket.org/",
+				},
+			}
+			repos = append(repos, repo[r.FullName])
+		}
+		insertRepos(t, db, repos)
+
+		for _, tc := range []struct {
+			name string
+			acct *extsvc.ExternalAccount
+			want []authz.RepoPerms
+		}{
+			{
+				name: "anonymous user can read public repos",
+				want: []authz.RepoPerms{
+					{Repo: repo["team/public"], Perms: authz.Read},
+				},
+			},
+			{
+				name: "user with permissions can read public and permitted repos",
+				acct: account(t, 1, bb.users["alice"]),
+				want: []authz.RepoPerms{
+					{Repo: repo["team/public"], Perms: authz.Read},
+					{Repo: repo["team/private"], Perms: authz.Read},
+				},
+			},
+			{
+				name: "user without permissions can read public repos",
+				acct: account(t, 2, bb.users["bob"]),
+				want: []authz.RepoPerms{
+					{Repo: repo["team/public"], Perms: authz.Read},
+				},
+			},
+			{
+				name: "account of another code host is ignored",
+				acct: &extsvc.ExternalAccount{
+					UserID: 3,
+					ExternalAccountSpec: extsvc.ExternalAccountSpec{
+						ServiceType: bitbucketcloud.ServiceType,
+						ServiceID:   "https://bitbucket.example.com/",
+						AccountID:   "{alice}",
+					},
+				},
+				want: []authz.RepoPerms{
+					{Repo: repo["team/public"], Perms: authz.Read},
+				},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				for i := 0; i < 2; i++ { // Run twice to load the stored permissions
+					bb.requests = 0
+
+					have, err := p.RepoPerms(context.Background(), tc.acct, repos)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !reflect.DeepEqual(have, tc.want) {
+						t.Error(cmp.Diff(have, tc.want))
+					}
+
+					if i == 1 && bb.requests > 0 {
+						t.Errorf("expected stored permissions to be used, but %d requests were made", bb.requests)
+					}
+				}
+			})
+		}
+	}
+}
+
+// insertRepos stores the given repos in the repo table, of which the provider
+// reads the repositories to check permissions on.
+func insertRepos(t *testing.T, db *sql.DB, repos []*types.Repo) {
+	t.Helper()
+	for _, r := range repos {
+		_, err := db.Exec(
+			"INSERT INTO repo (id, name, external_id, external_service_type, external_service_id) VALUES ($1, $2, $3, $4, $5)",
+			r.ID, r.Name, r.ExternalRepo.ID, r.ExternalRepo.ServiceType, r.ExternalRepo.ServiceID,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newProvider(t *testing.T, srv *httptest.Server, db *sql.DB) *Provider {
+	t.Helper()
+
+	cli := bitbucketcloud.NewClient(srv.Client())
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.URL = u
+
+	baseURL, _ := url.Parse("https://bitbucket.org")
+	return NewProvider(cli, baseURL, []string{"team"}, db, 3*time.Hour, store.DefaultHardTTL)
+}
+
+func account(t *testing.T, userID int32, u *bitbucketcloud.User) *extsvc.ExternalAccount {
+	t.Helper()
+
+	data, err := json.Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &extsvc.ExternalAccount{
+		UserID: userID,
+		ExternalAccountSpec: extsvc.ExternalAccountSpec{
+			ServiceType: bitbucketcloud.ServiceType,
+			ServiceID:   "https://bitbucket.org/",
+			AccountID:   u.UUID,
+		},
+		ExternalAccountData: extsvc.ExternalAccountData{
+			AccountData: (*json.RawMessage)(&data),
+		},
+	}
+}
+
+// mockBitbucketCloud serves the Bitbucket Cloud API endpoints used by the Provider
+// for a single team named "team".
+type mockBitbucketCloud struct {
+	users    map[string]*bitbucketcloud.User
+	repos    []*bitbucketcloud.Repo
+	perms    []*bitbucketcloud.RepositoryPermission
+	requests int
+}
+
+func newMockBitbucketCloud() *mockBitbucketCloud {
+	alice := &bitbucketcloud.User{UUID: "{alice}", Username: "alice"}
+	bob := &bitbucketcloud.User{UUID: "{bob}", Username: "bob"}
+
+	public := &bitbucketcloud.Repo{UUID: "{public}", FullName: "team/public", SCM: "git"}
+	private := &bitbucketcloud.Repo{UUID: "{private}", FullName: "team/private", SCM: "git", IsPrivate: true}
+	secret := &bitbucketcloud.Repo{UUID: "{secret}", FullName: "team/secret", SCM: "git", IsPrivate: true}
+
+	return &mockBitbucketCloud{
+		users: map[string]*bitbucketcloud.User{"alice": alice, "bob": bob},
+		repos: []*bitbucketcloud.Repo{public, private, secret},
+		perms: []*bitbucketcloud.RepositoryPermission{
+			{Permission: "write", User: alice, Repository: private},
+			{Permission: "none", User: alice, Repository: secret},
+			{Permission: "read", User: bob, Repository: public},
+		},
+	}
+}
+
+func (m *mockBitbucketCloud) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.requests++
+
+	var values interface{}
+	switch path := r.URL.Path; {
+	case strings.HasPrefix(path, "/2.0/users/"):
+		u, ok := m.users[strings.TrimPrefix(path, "/2.0/users/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(u)
+		return
+	case path == "/2.0/repositories/team":
+		values = m.repos
+	case path == "/2.0/teams/team/permissions/repositories":
+		perms := []*bitbucketcloud.RepositoryPermission{}
+		for _, p := range m.perms {
+			if q := r.URL.Query().Get("q"); q == "" || q == fmt.Sprintf("user.uuid=%q", p.User.UUID) {
+				perms = append(perms, p)
+			}
+		}
+		values = perms
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"page":    1,
+		"pagelen": 100,
+		"values":  values,
+	})
+}
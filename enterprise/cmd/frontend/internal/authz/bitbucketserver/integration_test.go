@@ -23,7 +23,6 @@ func TestIntegration(t *testing.T) {
 		name string
 		test func(*testing.T)
 	}{
-		{"Store", testStore(db)},
 		{"Provider/RepoPerms", testProviderRepoPerms(db)},
 	} {
 		t.Run(tc.name, tc.test)
@@ -12,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
 	"github.com/sourcegraph/sourcegraph/pkg/trace"
@@ -23,13 +24,11 @@ type Provider struct {
 	client   *bitbucketserver.Client
 	codeHost *extsvc.CodeHost
 	pageSize int // Page size to use in paginated requests.
-	store    *store
+	store    *store.Store
 }
 
 var _ authz.Provider = ((*Provider)(nil))
 
-var clock = func() time.Time { return time.Now().UTC().Truncate(time.Microsecond) }
-
 // NewProvider returns a new Bitbucket Server authorization provider that uses
 // the given bitbucketserver.Client to talk to a Bitbucket Server API that is
 // the source of truth for permissions. It assumes usernames of Sourcegraph accounts
@@ -39,7 +38,7 @@ func NewProvider(cli *bitbucketserver.Client, db *sql.DB, ttl, hardTTL time.Dura
 		client:   cli,
 		codeHost: extsvc.NewCodeHost(cli.URL, bitbucketserver.ServiceType),
 		pageSize: 1000,
-		store:    newStore(db, ttl, hardTTL, clock, newCache()),
+		store:    store.New(db, ttl, hardTTL, store.Clock, store.NewCache()),
 	}
 }
 
@@ -102,20 +101,28 @@ func (p *Provider) RepoPerms(ctx context.Context, acct *extsvc.ExternalAccount,
 		userName = user.Name
 	}
 
-	ids := make(map[int]*types.Repo, len(repos))
-	for _, r := range repos {
-		if id, _ := strconv.Atoi(r.ExternalRepo.ID); id != 0 {
-			ids[id] = r
-		}
-	}
-
+	// The stored permissions are used for all later requests of the user, and are
+	// also synced in the background, so they cover all the repositories of this code
+	// host, not only the requested ones.
 	update := func(ctx context.Context) ([]uint32, error) {
 		visible, err := p.repos(ctx, userName)
 		if err != nil && err != errNoResults {
 			return nil, err
 		}
 
-		authorized := make([]uint32, 0, len(repos))
+		all, err := p.store.Repos(ctx, p.codeHost.ServiceType, p.codeHost.ServiceID)
+		if err != nil {
+			return nil, err
+		}
+
+		ids := make(map[int]*types.Repo, len(all))
+		for _, r := range all {
+			if id, _ := strconv.Atoi(r.ExternalRepo.ID); id != 0 {
+				ids[id] = r
+			}
+		}
+
+		authorized := make([]uint32, 0, len(visible))
 		for _, r := range visible {
 			if repo, ok := ids[r.ID]; ok {
 				authorized = append(authorized, uint32(repo.ID))
@@ -125,10 +132,12 @@ func (p *Provider) RepoPerms(ctx context.Context, acct *extsvc.ExternalAccount,
 		return authorized, nil
 	}
 
-	ps := &Permissions{
-		UserID: userID,
-		Perm:   authz.Read,
-		Type:   "repos",
+	ps := &store.Permissions{
+		UserID:      userID,
+		Perm:        authz.Read,
+		Type:        "repos",
+		ServiceType: p.codeHost.ServiceType,
+		ServiceID:   p.codeHost.ServiceID,
 	}
 
 	err = p.store.LoadPermissions(ctx, &ps, update)
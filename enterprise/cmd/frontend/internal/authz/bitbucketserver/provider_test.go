@@ -16,6 +16,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/bitbucketserver"
@@ -80,6 +81,7 @@ func testProviderRepoPerms(db *sql.DB) func(*testing.T) {
 			}
 			repos = append(repos, repo[r.Name])
 		}
+		insertRepos(t, db, repos)
 
 		sort.Slice(repos, func(i, j int) bool {
 			return repos[i].Name <= repos[j].Name
@@ -458,9 +460,24 @@ func newClient(t *testing.T, name string) (*bitbucketserver.Client, func()) {
 	return cli, save
 }
 
+// insertRepos stores the given repos in the repo table, of which the provider
+// reads the repositories to check permissions on.
+func insertRepos(t *testing.T, db *sql.DB, repos []*types.Repo) {
+	t.Helper()
+	for _, r := range repos {
+		_, err := db.Exec(
+			"INSERT INTO repo (id, name, external_id, external_service_type, external_service_id) VALUES ($1, $2, $3, $4, $5)",
+			r.ID, r.Name, r.ExternalRepo.ID, r.ExternalRepo.ServiceType, r.ExternalRepo.ServiceID,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func newProvider(cli *bitbucketserver.Client, db *sql.DB, ttl time.Duration) *Provider {
-	p := NewProvider(cli, db, ttl, DefaultHardTTL)
+	p := NewProvider(cli, db, ttl, store.DefaultHardTTL)
 	p.pageSize = 1       // Exercise pagination
-	p.store.block = true // Wait for first update to complete.
+	p.store.Block = true // Wait for first update to complete.
 	return p
 }
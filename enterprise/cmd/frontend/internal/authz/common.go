@@ -3,6 +3,8 @@ package authz
 import (
 	"fmt"
 	"time"
+
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
 )
 
 func parseTTL(ttl string) (time.Duration, error) {
@@ -16,3 +18,21 @@ func parseTTL(ttl string) (time.Duration, error) {
 	}
 	return d, nil
 }
+
+func parseHardTTL(hardTTL string, ttl time.Duration) (time.Duration, error) {
+	defaultValue := store.DefaultHardTTL
+	if defaultValue < ttl {
+		defaultValue = ttl
+	}
+	if hardTTL == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(hardTTL)
+	if err != nil {
+		return defaultValue, fmt.Errorf("authorization.hardTTL: %s", err)
+	}
+	if d < ttl {
+		return defaultValue, fmt.Errorf("authorization.hardTTL: must be larger than ttl")
+	}
+	return d, nil
+}
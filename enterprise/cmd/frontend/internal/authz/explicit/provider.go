@@ -0,0 +1,115 @@
+// Package explicit contains an authorization provider for the repositories of code hosts
+// that have no permissions of their own (such as Gitolite), which enforces the repository
+// permissions site admins set explicitly through the GraphQL API.
+package explicit
+
+import (
+	"context"
+	"strconv"
+
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbutil"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
+)
+
+// ServiceType is the service type of the external accounts that identify users to a
+// Provider. These accounts are computed from the Sourcegraph user they belong to.
+const ServiceType = "explicit"
+
+// Provider is an implementation of AuthzProvider that provides the repository permissions
+// explicitly set by site admins on the repositories of a code host.
+type Provider struct {
+	serviceID string
+	store     *Store
+}
+
+var _ authz.Provider = ((*Provider)(nil))
+
+// NewProvider returns a new explicit authorization provider for the repositories of the
+// code host with the given service ID, whose permissions are stored in the given database.
+func NewProvider(serviceID string, db dbutil.DB) *Provider {
+	return &Provider{
+		serviceID: serviceID,
+		store:     NewStore(db),
+	}
+}
+
+// Validate satisfies the authz.Provider interface. Explicit permissions need no
+// credentials, so there's nothing to validate.
+func (p *Provider) Validate() (problems []string) { return nil }
+
+// ServiceID returns the service ID of the code host whose repositories this provider
+// enforces permissions on.
+func (p *Provider) ServiceID() string { return p.serviceID }
+
+// ServiceType returns the type of this Provider, namely, "explicit".
+func (p *Provider) ServiceType() string { return ServiceType }
+
+// RepoPerms returns the permissions the given external account has in relation to the given set of repos.
+// A user can only read the repositories they were explicitly granted access to. Without an account, no
+// repositories can be read.
+func (p *Provider) RepoPerms(ctx context.Context, acct *extsvc.ExternalAccount, repos []*types.Repo) (
+	perms []authz.RepoPerms,
+	err error,
+) {
+	var userID int32
+
+	tr, ctx := trace.New(ctx, "explicit.authz.provider.RepoPerms", "")
+	defer func() {
+		tr.LogFields(
+			otlog.Int32("user.id", userID),
+			otlog.Int("repos.count", len(repos)),
+			otlog.Int("perms.count", len(perms)),
+		)
+
+		if err != nil {
+			tr.SetError(err)
+		}
+
+		tr.Finish()
+	}()
+
+	if acct == nil || acct.ServiceID != p.serviceID || acct.ServiceType != ServiceType {
+		return nil, nil
+	}
+	userID = acct.UserID
+
+	ids, err := p.store.UserPermittedRepoIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permitted := make(map[api.RepoID]bool, len(ids))
+	for _, id := range ids {
+		permitted[id] = true
+	}
+
+	for _, r := range repos {
+		if permitted[r.ID] {
+			perms = append(perms, authz.RepoPerms{Repo: r, Perms: authz.Read})
+		}
+	}
+
+	return perms, nil
+}
+
+// FetchAccount satisfies the authz.Provider interface. Every user is identified to this
+// provider by an account whose ID is their Sourcegraph user ID.
+func (p *Provider) FetchAccount(ctx context.Context, user *types.User, _ []*extsvc.ExternalAccount) (*extsvc.ExternalAccount, error) {
+	if user == nil {
+		return nil, nil
+	}
+
+	return &extsvc.ExternalAccount{
+		UserID: user.ID,
+		ExternalAccountSpec: extsvc.ExternalAccountSpec{
+			ServiceType: ServiceType,
+			ServiceID:   p.serviceID,
+			AccountID:   strconv.FormatInt(int64(user.ID), 10),
+		},
+	}, nil
+}
@@ -0,0 +1,111 @@
+package explicit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtesting"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+)
+
+func TestProvider_FetchAccount(t *testing.T) {
+	p := NewProvider("git@gitolite.example.com", nil)
+
+	for _, tc := range []struct {
+		name string
+		user *types.User
+		want *extsvc.ExternalAccount
+	}{
+		{
+			name: "no user",
+		},
+		{
+			name: "user",
+			user: &types.User{ID: 42, Username: "alice"},
+			want: &extsvc.ExternalAccount{
+				UserID: 42,
+				ExternalAccountSpec: extsvc.ExternalAccountSpec{
+					ServiceType: ServiceType,
+					ServiceID:   "git@gitolite.example.com",
+					AccountID:   "42",
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			have, err := p.FetchAccount(context.Background(), tc.user, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(have, tc.want) {
+				t.Error(cmp.Diff(have, tc.want))
+			}
+		})
+	}
+}
+
+func TestProvider_RepoPerms(t *testing.T) {
+	ctx := dbtesting.TestContext(t)
+
+	users := createUsers(ctx, t, "dave", "erin")
+	repos := createRepos(ctx, t, "gitolite.example.com/c", "gitolite.example.com/d")
+	dave, erin := users[0], users[1]
+
+	p := NewProvider("git@gitolite.example.com", dbconn.Global)
+	if err := p.store.SetRepositoryPermissionsForUsers(ctx, repos[0].ID, []int32{dave.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	account := func(u *types.User) *extsvc.ExternalAccount {
+		acct, err := p.FetchAccount(ctx, u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return acct
+	}
+
+	otherHost := account(dave)
+	otherHost.ServiceID = "git@gitolite.example.org"
+
+	for _, tc := range []struct {
+		name string
+		acct *extsvc.ExternalAccount
+		want []authz.RepoPerms
+	}{
+		{
+			name: "anonymous user can't read any repos",
+		},
+		{
+			name: "user can read explicitly permitted repos",
+			acct: account(dave),
+			want: []authz.RepoPerms{
+				{Repo: repos[0], Perms: authz.Read},
+			},
+		},
+		{
+			name: "user without permissions can't read any repos",
+			acct: account(erin),
+		},
+		{
+			name: "account of another code host is ignored",
+			acct: otherHost,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			have, err := p.RepoPerms(ctx, tc.acct, repos)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(have, tc.want) {
+				t.Error(cmp.Diff(have, tc.want))
+			}
+		})
+	}
+}
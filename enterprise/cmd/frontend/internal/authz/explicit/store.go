@@ -0,0 +1,153 @@
+package explicit
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbutil"
+)
+
+// A Store of the repository permissions set explicitly by site admins. Unlike the
+// permissions fetched from code hosts, these are the source of truth, so they are
+// never cached or expired.
+type Store struct {
+	db dbutil.DB
+}
+
+// NewStore returns a Store of the explicit repository permissions in the given database.
+func NewStore(db dbutil.DB) *Store {
+	return &Store{db: db}
+}
+
+// SetRepositoryPermissionsForUsers replaces the set of users who can read the given
+// repository with the given users.
+func (s *Store) SetRepositoryPermissionsForUsers(ctx context.Context, repoID api.RepoID, userIDs []int32) (err error) {
+	var tx *sql.Tx
+	if tx, err = s.tx(ctx); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	txs := Store{db: tx}
+
+	perm := authz.Read.String()
+	if err = txs.exec(ctx, sqlf.Sprintf(deleteRepoPermissionsQueryFmtStr, repoID, perm)); err != nil {
+		return err
+	}
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	values := make([]*sqlf.Query, 0, len(userIDs))
+	for _, id := range userIDs {
+		values = append(values, sqlf.Sprintf("(%s, %s, %s)", repoID, id, perm))
+	}
+
+	return txs.exec(ctx, sqlf.Sprintf(insertRepoPermissionsQueryFmtStr, sqlf.Join(values, ",\n")))
+}
+
+const deleteRepoPermissionsQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/explicit/store.go:Store.SetRepositoryPermissionsForUsers
+DELETE FROM explicit_repo_permissions WHERE repo_id = %s AND permission = %s
+`
+
+const insertRepoPermissionsQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/explicit/store.go:Store.SetRepositoryPermissionsForUsers
+INSERT INTO explicit_repo_permissions
+  (repo_id, user_id, permission)
+VALUES
+  %s
+ON CONFLICT DO NOTHING
+`
+
+// RepositoryPermittedUserIDs returns the IDs of the users who were explicitly granted
+// read access to the given repository, in ascending order.
+func (s *Store) RepositoryPermittedUserIDs(ctx context.Context, repoID api.RepoID) ([]int32, error) {
+	var ids []int32
+	q := sqlf.Sprintf(repoUserIDsQueryFmtStr, repoID, authz.Read.String())
+	err := s.query(ctx, q, func(rows *sql.Rows) error {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+const repoUserIDsQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/explicit/store.go:Store.RepositoryPermittedUserIDs
+SELECT p.user_id
+FROM explicit_repo_permissions p
+JOIN users u ON u.id = p.user_id
+WHERE p.repo_id = %s AND p.permission = %s AND u.deleted_at IS NULL
+ORDER BY p.user_id ASC
+`
+
+// UserPermittedRepoIDs returns the IDs of the repositories the given user was
+// explicitly granted read access to.
+func (s *Store) UserPermittedRepoIDs(ctx context.Context, userID int32) ([]api.RepoID, error) {
+	var ids []api.RepoID
+	q := sqlf.Sprintf(userRepoIDsQueryFmtStr, userID, authz.Read.String())
+	err := s.query(ctx, q, func(rows *sql.Rows) error {
+		var id api.RepoID
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+const userRepoIDsQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/explicit/store.go:Store.UserPermittedRepoIDs
+SELECT repo_id FROM explicit_repo_permissions WHERE user_id = %s AND permission = %s
+`
+
+func (s *Store) tx(ctx context.Context) (*sql.Tx, error) {
+	switch t := s.db.(type) {
+	case *sql.Tx:
+		return t, nil
+	case *sql.DB:
+		return t.BeginTx(ctx, nil)
+	default:
+		panic("can't open transaction with unknown implementation of dbutil.DB")
+	}
+}
+
+func (s *Store) query(ctx context.Context, q *sqlf.Query, scan func(*sql.Rows) error) error {
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *Store) exec(ctx context.Context, q *sqlf.Query) error {
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
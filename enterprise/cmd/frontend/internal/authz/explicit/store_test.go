@@ -0,0 +1,126 @@
+package explicit
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtesting"
+)
+
+func init() {
+	dbtesting.DBNameSuffix = "authzexplicit"
+}
+
+func TestStore(t *testing.T) {
+	ctx := dbtesting.TestContext(t)
+
+	users := createUsers(ctx, t, "alice", "bob", "carol")
+	repos := createRepos(ctx, t, "gitolite.example.com/a", "gitolite.example.com/b")
+	alice, bob, carol := users[0].ID, users[1].ID, users[2].ID
+	a, b := repos[0].ID, repos[1].ID
+
+	s := NewStore(dbconn.Global)
+
+	for _, step := range []struct {
+		repo  api.RepoID
+		users []int32
+	}{
+		{a, []int32{carol, alice, alice}},
+		{b, []int32{bob}},
+		{a, []int32{bob, alice}}, // Replaces the users of repo a.
+	} {
+		if err := s.SetRepositoryPermissionsForUsers(ctx, step.repo, step.users); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		repo api.RepoID
+		want []int32
+	}{
+		{a, []int32{alice, bob}},
+		{b, []int32{bob}},
+	} {
+		have, err := s.RepositoryPermittedUserIDs(ctx, tc.repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("repo %d: got users %v, want %v", tc.repo, have, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		user int32
+		want []api.RepoID
+	}{
+		{alice, []api.RepoID{a}},
+		{bob, []api.RepoID{a, b}},
+		{carol, nil},
+	} {
+		have, err := s.UserPermittedRepoIDs(ctx, tc.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(have, func(i, j int) bool { return have[i] < have[j] })
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("user %d: got repos %v, want %v", tc.user, have, tc.want)
+		}
+	}
+
+	if err := s.SetRepositoryPermissionsForUsers(ctx, b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if have, err := s.RepositoryPermittedUserIDs(ctx, b); err != nil {
+		t.Fatal(err)
+	} else if len(have) != 0 {
+		t.Errorf("got users %v, want none", have)
+	}
+}
+
+func createUsers(ctx context.Context, t *testing.T, usernames ...string) []*types.User {
+	t.Helper()
+
+	users := make([]*types.User, 0, len(usernames))
+	for _, username := range usernames {
+		u, err := db.Users.Create(ctx, db.NewUser{Username: username})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+	return users
+}
+
+func createRepos(ctx context.Context, t *testing.T, names ...string) []*types.Repo {
+	t.Helper()
+
+	repos := make([]*types.Repo, 0, len(names))
+	for _, name := range names {
+		err := db.Repos.Upsert(ctx, api.InsertRepoOp{
+			Name:    api.RepoName(name),
+			Enabled: true,
+			ExternalRepo: api.ExternalRepoSpec{
+				ID:          name,
+				ServiceType: "gitolite",
+				ServiceID:   "git@gitolite.example.com",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := db.Repos.GetByName(ctx, api.RepoName(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repos = append(repos, r)
+	}
+	return repos
+}
@@ -2,323 +2,211 @@ package github
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"math"
+	"database/sql"
 	"net/url"
 	"time"
 
+	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
-	"github.com/sourcegraph/sourcegraph/pkg/rcache"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
 )
 
 // Provider implements authz.Provider for GitHub repository permissions.
 type Provider struct {
 	client   *github.Client
 	codeHost *extsvc.CodeHost
-	cacheTTL time.Duration
-	cache    cache
+	store    *store.Store
 }
 
-func NewProvider(githubURL *url.URL, baseToken string, cacheTTL time.Duration, mockCache cache) *Provider {
+// NewProvider returns a new GitHub authorization provider. It checks whether
+// repositories are public with the given base token, and which repositories a
+// user can read with the OAuth token of their GitHub account. The permissions
+// are stored in the given database, and updated in the background after ttl.
+func NewProvider(githubURL *url.URL, baseToken string, db *sql.DB, ttl, hardTTL time.Duration) *Provider {
 	apiURL, _ := github.APIRoot(githubURL)
-	client := github.NewClient(apiURL, baseToken, nil)
-
-	p := &Provider{
+	return &Provider{
 		codeHost: extsvc.NewCodeHost(githubURL, github.ServiceType),
-		client:   client,
-		cache:    mockCache,
-		cacheTTL: cacheTTL,
-	}
-	// Note: this will use the same underlying Redis instance and key namespace for every instance
-	// of Provider.  This is by design, so that different instances, even in different processes,
-	// will share cache entries.
-	if p.cache == nil {
-		p.cache = rcache.NewWithTTL(fmt.Sprintf("githubAuthz:%s", githubURL.String()), int(math.Ceil(cacheTTL.Seconds())))
+		client:   github.NewClient(apiURL, baseToken, nil),
+		store:    store.New(db, ttl, hardTTL, store.Clock, store.NewCache()),
 	}
-	return p
 }
 
 var _ authz.Provider = ((*Provider)(nil))
 
 // RepoPerms implements the authz.Provider interface.
 //
-// It computes permissions by keeping track of two classes of info:
-// * Whether a given user can access a given repository
-// * Whether a given repository is public
+// The repositories a user can read are fetched from the GitHub API with the OAuth
+// token of their GitHub account, in batches of github.MaxNodeIDs. Users without a
+// GitHub account can only read public repositories, which are fetched with the
+// base token.
 //
-// For each repo in the input set, we look first to see if the above information is cached in Redis.
-// If not, then the info is computed by querying the GitHub API. A separate query is issued for each
-// repository (and for each user for the explicit case).
-func (p *Provider) RepoPerms(ctx context.Context, userAccount *extsvc.ExternalAccount, repos []*types.Repo) ([]authz.RepoPerms, error) {
-	remaining := repos
-	remainingPublic := remaining
-	if len(remaining) == 0 {
+// The stored permissions of a user cover all the repositories of the code host,
+// and are fetched in the background. Until they are, and for the repositories
+// added since they were, only the requested repositories are checked.
+func (p *Provider) RepoPerms(ctx context.Context, userAccount *extsvc.ExternalAccount, repos []*types.Repo) (
+	perms []authz.RepoPerms,
+	err error,
+) {
+	if len(repos) == 0 {
 		return nil, nil
 	}
 
-	perms := make([]authz.RepoPerms, 0, len(remaining))
-	populatePermsPublic := func(checkAccess func(ctx context.Context, repos []*types.Repo) (map[string]bool, error)) error {
-		nextRemaining := []*types.Repo{}
-		nextRemainingPublic := []*types.Repo{}
-		canAccess, err := checkAccess(ctx, remainingPublic)
+	tr, ctx := trace.New(ctx, "github.authz.provider.RepoPerms", "")
+	defer func() {
+		tr.LogFields(
+			otlog.Int("repos.count", len(repos)),
+			otlog.Int("perms.count", len(perms)),
+		)
+
 		if err != nil {
-			return err
-		}
-		for _, repo := range remaining {
-			canAcc, isExplicit := canAccess[repo.ExternalRepo.ID]
-			if canAcc {
-				perms = append(perms, authz.RepoPerms{Repo: repo, Perms: authz.Read})
-				continue
-			}
-			nextRemaining = append(nextRemaining, repo)
-			if !isExplicit {
-				nextRemainingPublic = append(nextRemainingPublic, repo)
-			}
+			tr.SetError(err)
 		}
-		remaining = nextRemaining
-		remainingPublic = nextRemainingPublic
-		return nil
+
+		tr.Finish()
+	}()
+
+	var userID int32
+	if userAccount != nil && userAccount.ServiceID == p.codeHost.ServiceID && userAccount.ServiceType == p.codeHost.ServiceType {
+		userID = userAccount.UserID
+	} else {
+		userAccount = nil
 	}
-	populatePerms := func(checkAccess func(ctx context.Context, userAccount *extsvc.ExternalAccount, repos []*types.Repo) (map[string]bool, error)) error {
-		nextRemaining := []*types.Repo{}
-		canAccess, err := checkAccess(ctx, userAccount, remaining)
+
+	// The stored permissions are used for all later requests of the user, so they
+	// cover all the repositories of this code host, not only the requested ones.
+	update := func(ctx context.Context) ([]uint32, error) {
+		all, err := p.store.Repos(ctx, p.codeHost.ServiceType, p.codeHost.ServiceID)
+		if err != nil {
+			return nil, err
+		}
+
+		canRead, err := p.fetchReadable(ctx, userAccount, all)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		for _, repo := range remaining {
-			if canAcc, isExplicit := canAccess[repo.ExternalRepo.ID]; isExplicit {
-				p := authz.None
-				if canAcc {
-					p = authz.Read
-				}
-				perms = append(perms, authz.RepoPerms{Repo: repo, Perms: p})
-				continue
+
+		authorized := make([]uint32, 0, len(all))
+		for _, r := range all {
+			if canRead[r.ExternalRepo.ID] {
+				authorized = append(authorized, uint32(r.ID))
 			}
-			nextRemaining = append(nextRemaining, repo)
 		}
-		remaining = nextRemaining
-		return nil
-	}
 
-	if err := populatePerms(p.getCachedUserRepos); err != nil {
-		return nil, err
+		return authorized, nil
 	}
-	if len(remaining) == 0 {
-		return perms, nil
+
+	ps := &store.Permissions{
+		UserID:      userID,
+		Perm:        authz.Read,
+		Type:        "repos",
+		ServiceType: p.codeHost.ServiceType,
+		ServiceID:   p.codeHost.ServiceID,
 	}
-	if err := populatePermsPublic(p.getCachedPublicRepos); err != nil {
+
+	err = p.store.LoadPermissions(ctx, &ps, update)
+	if _, ok := err.(*store.StalePermissionsError); ok {
+		// The permissions are being fetched in the background.
+		return p.fetchRepoPerms(ctx, userAccount, repos)
+	} else if err != nil {
 		return nil, err
 	}
-	if len(remaining) == 0 {
-		return perms, nil
+
+	perms = ps.Authorized(repos)
+
+	// The stored permissions deny access to the repositories they don't cover,
+	// such as those added to the code host since they were fetched, so those are
+	// checked instead.
+	authorized := make(map[api.RepoID]bool, len(perms))
+	for _, perm := range perms {
+		authorized[perm.Repo.ID] = true
 	}
-	if err := populatePerms(p.fetchAndSetUserRepos); err != nil {
-		return nil, err
+
+	var denied []*types.Repo
+	for _, r := range repos {
+		if !authorized[r.ID] {
+			denied = append(denied, r)
+		}
 	}
-	if len(remaining) == 0 {
+
+	if len(denied) == 0 {
 		return perms, nil
 	}
-	if err := populatePermsPublic(p.fetchAndSetPublicRepos); err != nil {
-		return nil, err
-	}
 
-	return perms, nil
-}
-
-// fetchAndSetPublicRepos accepts a set of repositories and returns a map from repository external
-// ID (the GitHub repository GraphQL ID) to true/false indicating whether the repository is public
-// or private. It consults and updates the cache. As a side effect, it caches the publicness of the
-// repos.
-func (p *Provider) fetchAndSetPublicRepos(ctx context.Context, repos []*types.Repo) (map[string]bool, error) {
-	isPublic, err := p.fetchPublicRepos(ctx, repos)
+	unknown, err := p.store.ReposAddedAfter(ctx, denied, ps.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
-	if err := p.setCachedPublicRepos(ctx, isPublic); err != nil {
+
+	more, err := p.fetchRepoPerms(ctx, userAccount, unknown)
+	if err != nil {
 		return nil, err
 	}
-	return isPublic, nil
-}
 
-// setCachedPublicRepos updates the cache with a map from GitHub repo ID to true/false indicating
-// whether the repo is public or private. The GitHub repo ID is the GraphQL API ID ("repository node
-// ID").
-//
-// Internally, it sets a separate cache key for each repo ID.
-func (p *Provider) setCachedPublicRepos(ctx context.Context, isPublic map[string]bool) error {
-	setArgs := make([][2]string, 0, len(isPublic))
-	for k, v := range isPublic {
-		key := publicRepoCacheKey(k)
-		val, err := json.Marshal(publicRepoCacheVal{
-			Public: v,
-			TTL:    p.cacheTTL,
-		})
-		if err != nil {
-			return err
-		}
-		setArgs = append(setArgs, [2]string{key, string(val)})
-	}
-	p.cache.SetMulti(setArgs...)
-	return nil
+	return append(perms, more...), nil
 }
 
-// getCachedPublicRepos accepts a set of repos and returns a map from repo ID to true/false
-// indicating whether the repo is public or private. The returned map may be incomplete (i.e., not
-// every input repo may be represented in the key set) due to cache incompleteness.
-func (p *Provider) getCachedPublicRepos(ctx context.Context, repos []*types.Repo) (isPublic map[string]bool, err error) {
+// fetchRepoPerms returns the permissions of the user on the given repositories,
+// fetched from the GitHub API rather than the store.
+func (p *Provider) fetchRepoPerms(ctx context.Context, userAccount *extsvc.ExternalAccount, repos []*types.Repo) ([]authz.RepoPerms, error) {
 	if len(repos) == 0 {
 		return nil, nil
 	}
-	isPublic = make(map[string]bool)
-	repoList := make([]string, 0, len(repos))
-	getArgs := make([]string, 0, len(repos))
-	for _, r := range repos {
-		getArgs = append(getArgs, publicRepoCacheKey(r.ExternalRepo.ID))
-		repoList = append(repoList, r.ExternalRepo.ID)
-	}
-	vals := p.cache.GetMulti(getArgs...)
-	for i, v := range vals {
-		if len(v) == 0 {
-			continue
-		}
-		var val publicRepoCacheVal
-		if err := json.Unmarshal(v, &val); err != nil {
-			return nil, err
-		}
-		if p.cacheTTL < val.TTL {
-			// if the cache TTL is now less than the cache entry TTL, invalidate that entry
-			continue
-		}
-		isPublic[repoList[i]] = val.Public
-	}
-	return isPublic, nil
-}
 
-// fetchPublicRepos returns a map from GitHub repository ID (the GraphQL repo node ID) to true/false
-// indicating whether a repository is public (true) or private (false).
-func (p *Provider) fetchPublicRepos(ctx context.Context, repos []*types.Repo) (map[string]bool, error) {
-	isPublic := make(map[string]bool)
-	for _, repo := range repos {
-		ghRepo, err := p.client.GetRepositoryByNodeID(ctx, "", repo.ExternalRepo.ID)
-		if err == github.ErrNotFound {
-			// Note: we could set `isPublic[repo.ExternalRepoSpec.ID] = false` here, but
-			// purposefully don't cache if a repo is private in case it later becomes public.
-			continue
-		}
-		if err != nil {
-			return nil, err
-		}
-		isPublic[repo.ExternalRepo.ID] = !ghRepo.IsPrivate
-	}
-	return isPublic, nil
-}
-
-// fetchAndSetUserRepos accepts a user account and a set of repos. It returns a map from repository
-// external ID to true/false indicating whether the given user has read access to each repo. If a
-// repo ID is missing from the return map, the user does not have read access to that repo. As a
-// side effect, it caches the fetched repos (whether the given user has access to each and whether
-// each is public).
-func (p *Provider) fetchAndSetUserRepos(ctx context.Context, userAccount *extsvc.ExternalAccount, repos []*types.Repo) (isAllowed map[string]bool, err error) {
-	if userAccount == nil {
-		return nil, nil
-	}
-
-	repoIDs := make([]string, len(repos))
-	i := 0
-	for _, repo := range repos {
-		repoIDs[i] = repo.ExternalRepo.ID
-		i++
-	}
-
-	canAccess, isPublic, err := p.fetchUserRepos(ctx, userAccount, repoIDs)
+	canRead, err := p.fetchReadable(ctx, userAccount, repos)
 	if err != nil {
 		return nil, err
 	}
-	userRepos := make(map[string]bool)
-	publicRepos := make(map[string]bool)
-	for _, r := range repos {
-		userRepos[r.ExternalRepo.ID] = canAccess[r.ExternalRepo.ID]
-		publicRepos[r.ExternalRepo.ID] = isPublic[r.ExternalRepo.ID]
-	}
-
-	if err := p.setCachedUserRepos(ctx, userAccount, userRepos); err != nil {
-		return nil, err
-	}
-	if err := p.setCachedPublicRepos(ctx, publicRepos); err != nil { // also cache whether repos are public
-		return nil, err
-	}
-	return userRepos, nil
-}
 
-// setCachedUserRepos updates the cache with a map from GitHub repo ID to true/false indicating
-// whether the user can access the repo. The GitHub repo ID is the GraphQL API ID ("repository node
-// ID").
-//
-// Internally, it sets a separate cache key for each user and repo ID.
-func (p *Provider) setCachedUserRepos(ctx context.Context, userAccount *extsvc.ExternalAccount, isAllowed map[string]bool) error {
-	setArgs := make([][2]string, 0, len(isAllowed))
-	for k, v := range isAllowed {
-		rkey, err := json.Marshal(userRepoCacheKey{User: userAccount.AccountID, Repo: k})
-		if err != nil {
-			return err
-		}
-		rval, err := json.Marshal(userRepoCacheVal{Read: v, TTL: p.cacheTTL})
-		if err != nil {
-			return err
+	perms := make([]authz.RepoPerms, 0, len(repos))
+	for _, r := range repos {
+		if canRead[r.ExternalRepo.ID] {
+			perms = append(perms, authz.RepoPerms{Repo: r, Perms: authz.Read})
 		}
-		setArgs = append(setArgs, [2]string{string(rkey), string(rval)})
 	}
-	p.cache.SetMulti(setArgs...)
-	return nil
+
+	return perms, nil
 }
 
-// getCachedUserRepos accepts a user account and set of repos and returns a map from repo ID to
-// true/false indicating whether the user can access the repo. The returned map may be incomplete
-// (i.e., not every input repo may be represented in the key set) due to cache incompleteness.
-func (p *Provider) getCachedUserRepos(ctx context.Context, userAccount *extsvc.ExternalAccount, repos []*types.Repo) (cachedUserRepos map[string]bool, err error) {
-	if userAccount == nil {
-		return nil, nil
+// fetchReadable returns a map from GitHub repository ID to true/false indicating
+// whether the given repositories can be read by the user, or by anyone if the
+// user has no GitHub account.
+func (p *Provider) fetchReadable(ctx context.Context, userAccount *extsvc.ExternalAccount, repos []*types.Repo) (canRead map[string]bool, err error) {
+	repoIDs := make([]string, 0, len(repos))
+	for _, r := range repos {
+		repoIDs = append(repoIDs, r.ExternalRepo.ID)
 	}
 
-	getArgs := make([]string, 0, len(repos))
-	repoList := make([]string, 0, len(repos))
-	for _, repo := range repos {
-		rkey, err := json.Marshal(userRepoCacheKey{
-			User: userAccount.AccountID,
-			Repo: repo.ExternalRepo.ID,
-		})
-		if err != nil {
-			return nil, err
-		}
-		getArgs = append(getArgs, string(rkey))
-		repoList = append(repoList, repo.ExternalRepo.ID)
+	if userAccount != nil {
+		canRead, _, err = p.fetchUserRepos(ctx, userAccount, repoIDs)
+		return canRead, err
 	}
+	return p.fetchPublicRepos(ctx, repoIDs)
+}
 
-	cacheVals := p.cache.GetMulti(getArgs...)
-	if len(cacheVals) == 0 {
-		return nil, nil
-	}
-	cachedIsAllowed := make(map[string]bool)
-	for i, v := range cacheVals {
-		if len(v) == 0 {
-			continue
+// fetchPublicRepos returns a map from GitHub repository ID (the GraphQL repo node ID) to true/false
+// indicating whether a repository is public (true) or private (false). The repositories are fetched
+// with the base token, in batches of github.MaxNodeIDs.
+func (p *Provider) fetchPublicRepos(ctx context.Context, repoIDs []string) (map[string]bool, error) {
+	isPublic := make(map[string]bool)
+	for i := 0; i < len(repoIDs); i += github.MaxNodeIDs {
+		j := i + github.MaxNodeIDs
+		if j > len(repoIDs) {
+			j = len(repoIDs)
 		}
-
-		var val userRepoCacheVal
-		if err := json.Unmarshal(v, &val); err != nil {
+		ghRepos, err := p.client.GetRepositoriesByNodeIDFromAPI(ctx, "", repoIDs[i:j])
+		if err != nil {
 			return nil, err
 		}
-		if p.cacheTTL < val.TTL {
-			// if the cache TTL is now less than the cache entry TTL, invalidate that entry
-			continue
+		for id, r := range ghRepos {
+			isPublic[id] = !r.IsPrivate
 		}
-		cachedIsAllowed[repoList[i]] = val.Read
 	}
-	return cachedIsAllowed, nil
+	return isPublic, nil
 }
 
 func (p *Provider) fetchUserRepos(ctx context.Context, userAccount *extsvc.ExternalAccount, repoIDs []string) (canAccess map[string]bool, isPublic map[string]bool, err error) {
@@ -356,22 +244,6 @@ func (p *Provider) fetchUserRepos(ctx context.Context, userAccount *extsvc.Exter
 	return canAccess, isPublic, nil
 }
 
-// fetchUserRepo fetches whether the given user can access the given repo from the GitHub API.
-func (p *Provider) fetchUserRepo(ctx context.Context, userAccount *extsvc.ExternalAccount, repoID string) (canAccess bool, isPublic bool, err error) {
-	_, tok, err := github.GetExternalAccountData(&userAccount.ExternalAccountData)
-	if err != nil {
-		return false, false, err
-	}
-	ghRepo, err := p.client.GetRepositoryByNodeIDNoCache(ctx, tok.AccessToken, repoID)
-	if err != nil {
-		if err == github.ErrNotFound {
-			return false, false, nil
-		}
-		return false, false, err
-	}
-	return true, !ghRepo.IsPrivate, nil
-}
-
 // FetchAccount implements the authz.Provider interface. It always returns nil, because the GitHub
 // API doesn't currently provide a way to fetch user by external SSO account.
 func (p *Provider) FetchAccount(ctx context.Context, user *types.User, current []*extsvc.ExternalAccount) (mine *extsvc.ExternalAccount, err error) {
@@ -2,10 +2,12 @@ package github
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/url"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/sergi/go-diff/diffmatchpatch"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/github"
@@ -22,7 +25,6 @@ import (
 type Provider_RepoPerms_Test struct {
 	description string
 	githubURL   *url.URL
-	cacheTTL    time.Duration
 	calls       []Provider_RepoPerms_call
 }
 
@@ -34,176 +36,271 @@ type Provider_RepoPerms_call struct {
 	wantErr     error
 }
 
-func (p *Provider_RepoPerms_Test) run(t *testing.T) {
-	githubMock := newMockGitHub([]*github.Repository{
-		{ID: "u0/private", IsPrivate: true},
-		{ID: "u0/public"},
-		{ID: "u1/private", IsPrivate: true},
-		{ID: "u1/public"},
-		{ID: "u99/private", IsPrivate: true},
-		{ID: "u99/public"},
-	}, map[string][]string{
-		"t0": {"u0/private", "u0/public"},
-		"t1": {"u1/private", "u1/public"},
-	})
-	github.GetRepositoryByNodeIDMock = githubMock.GetRepositoryByNodeID
-	defer func() { github.GetRepositoryByNodeIDMock = nil }()
-	github.GetRepositoriesByNodeIDFromAPIMock = githubMock.GetRepositoriesByNodeIDFromAPI
-	defer func() { github.GetRepositoriesByNodeIDFromAPIMock = nil }()
+func (p *Provider_RepoPerms_Test) run(db *sql.DB) func(*testing.T) {
+	return func(t *testing.T) {
+		githubMock := newMockGitHub([]*github.Repository{
+			{ID: "u0/private", IsPrivate: true},
+			{ID: "u0/public"},
+			{ID: "u1/private", IsPrivate: true},
+			{ID: "u1/public"},
+			{ID: "u99/private", IsPrivate: true},
+			{ID: "u99/public"},
+		}, map[string][]string{
+			"t0": {"u0/private", "u0/public"},
+			"t1": {"u1/private", "u1/public"},
+		})
+		github.GetRepositoryByNodeIDMock = githubMock.GetRepositoryByNodeID
+		defer func() { github.GetRepositoryByNodeIDMock = nil }()
+		github.GetRepositoriesByNodeIDFromAPIMock = githubMock.GetRepositoriesByNodeIDFromAPI
+		defer func() { github.GetRepositoriesByNodeIDFromAPIMock = nil }()
+
+		provider := NewProvider(p.githubURL, "base-token", db, 3*time.Hour, store.DefaultHardTTL)
+		provider.store.Block = true // Wait for the first update to complete.
+
+		for j := 0; j < 2; j++ { // run twice for stored permissions
+			for _, c := range p.calls {
+				t.Run(fmt.Sprintf("%s: run %d", c.description, j), func(t *testing.T) {
+					c := c
+					ctx := context.Background()
+					githubMock.resetCalls()
+
+					gotPerms, gotErr := provider.RepoPerms(ctx, c.userAccount, c.repos)
+					if gotErr != c.wantErr {
+						t.Errorf("expected err %v, got err %v", c.wantErr, gotErr)
+					}
 
-	provider := NewProvider(p.githubURL, "base-token", p.cacheTTL, make(authz.MockCache))
-	for j := 0; j < 2; j++ { // run twice for cache coherency
-		for _, c := range p.calls {
-			t.Run(fmt.Sprintf("%s: run %d", c.description, j), func(t *testing.T) {
-				c := c
-				ctx := context.Background()
-				githubMock.getRepositoryByNodeIDCount = 0
-
-				gotPerms, gotErr := provider.RepoPerms(ctx, c.userAccount, c.repos)
-				if gotErr != c.wantErr {
-					t.Errorf("expected err %v, got err %v", c.wantErr, gotErr)
-				}
-
-				for _, perms := range [][]authz.RepoPerms{gotPerms, c.wantPerms} {
-					sort.Slice(perms, func(i, j int) bool {
-						return perms[i].Repo.Name <= perms[j].Repo.Name
-					})
-				}
-
-				if !reflect.DeepEqual(gotPerms, c.wantPerms) {
-					dmp := diffmatchpatch.New()
-					t.Errorf("expected perms did not equal actual, diff:\n%s",
-						dmp.DiffPrettyText(dmp.DiffMain(spew.Sdump(c.wantPerms), spew.Sdump(gotPerms), false)))
-				}
-
-				if j == 1 && githubMock.getRepositoryByNodeIDCount > 0 {
-					t.Errorf("expected entries to be fully cached")
-				}
-			})
+					for _, perms := range [][]authz.RepoPerms{gotPerms, c.wantPerms} {
+						sort.Slice(perms, func(i, j int) bool {
+							return perms[i].Repo.Name <= perms[j].Repo.Name
+						})
+					}
+
+					if !reflect.DeepEqual(gotPerms, c.wantPerms) {
+						dmp := diffmatchpatch.New()
+						t.Errorf("expected perms did not equal actual, diff:\n%s",
+							dmp.DiffPrettyText(dmp.DiffMain(spew.Sdump(c.wantPerms), spew.Sdump(gotPerms), false)))
+					}
+
+					if j == 1 && githubMock.calls() > 0 {
+						t.Errorf("expected permissions to be stored")
+					}
+				})
+			}
 		}
 	}
 }
 
-func TestProvider_RepoPerms(t *testing.T) {
-	repos := map[string]*types.Repo{
-		"r0":  rp("r0", "u0/private", "https://github.com/"),
-		"r1":  rp("r1", "u0/public", "https://github.com/"),
-		"r2":  rp("r2", "u1/private", "https://github.com/"),
-		"r3":  rp("r3", "u1/public", "https://github.com/"),
-		"r4":  rp("r4", "u99/private", "https://github.com/"),
-		"r5":  rp("r5", "u99/public", "https://github.com/"),
-		"r00": rp("r00", "404", "https://github.com/"),
-	}
+func testProviderRepoPerms(db *sql.DB) func(*testing.T) {
+	return func(t *testing.T) {
+		repos := map[string]*types.Repo{
+			"r0":  rp(1, "r0", "u0/private", "https://github.com/"),
+			"r1":  rp(2, "r1", "u0/public", "https://github.com/"),
+			"r2":  rp(3, "r2", "u1/private", "https://github.com/"),
+			"r3":  rp(4, "r3", "u1/public", "https://github.com/"),
+			"r4":  rp(5, "r4", "u99/private", "https://github.com/"),
+			"r5":  rp(6, "r5", "u99/public", "https://github.com/"),
+			"r00": rp(7, "r00", "404", "https://github.com/"),
+		}
+		insertRepos(t, db, repos)
 
-	tests := []Provider_RepoPerms_Test{
-		{
-			description: "common_case",
-			githubURL:   mustURL(t, "https://github.com"),
-			cacheTTL:    3 * time.Hour,
-			calls: []Provider_RepoPerms_call{
-				{
-					description: "t0_repos",
-					userAccount: ua("u0", "t0"),
-					repos: []*types.Repo{
-						repos["r0"],
-						repos["r1"],
-						repos["r2"],
-						repos["r3"],
-						repos["r4"],
-						repos["r5"],
+		tests := []Provider_RepoPerms_Test{
+			{
+				description: "common_case",
+				githubURL:   mustURL(t, "https://github.com"),
+				calls: []Provider_RepoPerms_call{
+					{
+						description: "t0_repos",
+						userAccount: ua(1, "u0", "t0"),
+						repos: []*types.Repo{
+							repos["r0"],
+							repos["r1"],
+							repos["r2"],
+							repos["r3"],
+							repos["r4"],
+							repos["r5"],
+						},
+						wantPerms: []authz.RepoPerms{
+							{Repo: repos["r0"], Perms: authz.Read},
+							{Repo: repos["r1"], Perms: authz.Read},
+							{Repo: repos["r3"], Perms: authz.Read},
+							{Repo: repos["r5"], Perms: authz.Read},
+						},
 					},
-					wantPerms: []authz.RepoPerms{
-						{Repo: repos["r0"], Perms: authz.Read},
-						{Repo: repos["r1"], Perms: authz.Read},
-						{Repo: repos["r2"], Perms: authz.None},
-						{Repo: repos["r3"], Perms: authz.Read},
-						{Repo: repos["r4"], Perms: authz.None},
-						{Repo: repos["r5"], Perms: authz.Read},
+					{
+						description: "t1_repos",
+						userAccount: ua(2, "u1", "t1"),
+						repos: []*types.Repo{
+							repos["r0"],
+							repos["r1"],
+							repos["r2"],
+							repos["r3"],
+							repos["r4"],
+							repos["r5"],
+						},
+						wantPerms: []authz.RepoPerms{
+							{Repo: repos["r1"], Perms: authz.Read},
+							{Repo: repos["r2"], Perms: authz.Read},
+							{Repo: repos["r3"], Perms: authz.Read},
+							{Repo: repos["r5"], Perms: authz.Read},
+						},
 					},
-				},
-				{
-					description: "t1_repos",
-					userAccount: ua("u1", "t1"),
-					repos: []*types.Repo{
-						repos["r0"],
-						repos["r1"],
-						repos["r2"],
-						repos["r3"],
-						repos["r4"],
-						repos["r5"],
+					{
+						description: "repos_with_unknown_token_(only_public_repos)",
+						userAccount: ua(3, "unknown-user", "unknown-token"),
+						repos: []*types.Repo{
+							repos["r0"],
+							repos["r1"],
+							repos["r2"],
+							repos["r3"],
+							repos["r4"],
+							repos["r5"],
+						},
+						wantPerms: []authz.RepoPerms{
+							{Repo: repos["r1"], Perms: authz.Read},
+							{Repo: repos["r3"], Perms: authz.Read},
+							{Repo: repos["r5"], Perms: authz.Read},
+						},
 					},
-					wantPerms: []authz.RepoPerms{
-						{Repo: repos["r0"], Perms: authz.None},
-						{Repo: repos["r1"], Perms: authz.Read},
-						{Repo: repos["r2"], Perms: authz.Read},
-						{Repo: repos["r3"], Perms: authz.Read},
-						{Repo: repos["r4"], Perms: authz.None},
-						{Repo: repos["r5"], Perms: authz.Read},
+					{
+						description: "public repos",
+						userAccount: nil,
+						repos: []*types.Repo{
+							repos["r0"],
+							repos["r1"],
+							repos["r2"],
+							repos["r3"],
+							repos["r4"],
+							repos["r5"],
+						},
+						wantPerms: []authz.RepoPerms{
+							{Repo: repos["r1"], Perms: authz.Read},
+							{Repo: repos["r3"], Perms: authz.Read},
+							{Repo: repos["r5"], Perms: authz.Read},
+						},
 					},
-				},
-				{
-					description: "repos_with_unknown_token_(only_public_repos)",
-					userAccount: ua("unknown-user", "unknown-token"),
-					repos: []*types.Repo{
-						repos["r0"],
-						repos["r1"],
-						repos["r2"],
-						repos["r3"],
-						repos["r4"],
-						repos["r5"],
+					{
+						description: "t1 select before all repos",
+						userAccount: ua(4, "u1", "t1"),
+						repos: []*types.Repo{
+							repos["r1"],
+						},
+						wantPerms: []authz.RepoPerms{
+							{Repo: repos["r1"], Perms: authz.Read},
+						},
 					},
-					wantPerms: []authz.RepoPerms{
-						{Repo: repos["r0"], Perms: authz.None},
-						{Repo: repos["r1"], Perms: authz.Read},
-						{Repo: repos["r2"], Perms: authz.None},
-						{Repo: repos["r3"], Perms: authz.Read},
-						{Repo: repos["r4"], Perms: authz.None},
-						{Repo: repos["r5"], Perms: authz.Read},
+					{
+						description: "t1 all repos after select",
+						userAccount: ua(4, "u1", "t1"),
+						repos: []*types.Repo{
+							repos["r0"],
+							repos["r1"],
+							repos["r2"],
+							repos["r3"],
+							repos["r4"],
+							repos["r5"],
+						},
+						wantPerms: []authz.RepoPerms{
+							{Repo: repos["r1"], Perms: authz.Read},
+							{Repo: repos["r2"], Perms: authz.Read},
+							{Repo: repos["r3"], Perms: authz.Read},
+							{Repo: repos["r5"], Perms: authz.Read},
+						},
 					},
-				},
-				{
-					description: "public repos",
-					userAccount: nil,
-					repos: []*types.Repo{
-						repos["r0"],
-						repos["r1"],
-						repos["r2"],
-						repos["r3"],
-						repos["r4"],
-						repos["r5"],
+					{
+						description: "t0 select",
+						userAccount: ua(1, "u0", "t0"),
+						repos: []*types.Repo{
+							repos["r2"],
+						},
+						wantPerms: []authz.RepoPerms{},
 					},
-					wantPerms: []authz.RepoPerms{
-						{Repo: repos["r1"], Perms: authz.Read},
-						{Repo: repos["r3"], Perms: authz.Read},
-						{Repo: repos["r5"], Perms: authz.Read},
-					},
-				},
-				{
-					description: "t0 select",
-					userAccount: ua("u0", "t0"),
-					repos: []*types.Repo{
-						repos["r2"],
-					},
-					wantPerms: []authz.RepoPerms{
-						{Repo: repos["r2"], Perms: authz.None},
-					},
-				},
-				{
-					description: "t0 missing",
-					userAccount: ua("u0", "t0"),
-					repos: []*types.Repo{
-						repos["r00"],
-					},
-					wantPerms: []authz.RepoPerms{
-						{Repo: repos["r00"], Perms: authz.None},
+					{
+						description: "t0 missing",
+						userAccount: ua(1, "u0", "t0"),
+						repos: []*types.Repo{
+							repos["r00"],
+						},
+						wantPerms: []authz.RepoPerms{},
 					},
 				},
 			},
-		},
+		}
+		for _, test := range tests {
+			t.Run(test.description, test.run(db))
+		}
+	}
+}
+
+func testProviderRepoPermsNotStored(db *sql.DB) func(*testing.T) {
+	return func(t *testing.T) {
+		repos := map[string]*types.Repo{
+			"r0": rp(11, "r0", "u0/private", "https://github.com/"),
+			"r1": rp(12, "r1", "u1/private", "https://github.com/"),
+			"r2": rp(13, "r2", "u0/new", "https://github.com/"),
+		}
+		insertRepos(t, db, map[string]*types.Repo{"r0": repos["r0"], "r1": repos["r1"]})
+
+		githubMock := newMockGitHub([]*github.Repository{
+			{ID: "u0/private", IsPrivate: true},
+			{ID: "u1/private", IsPrivate: true},
+			{ID: "u0/new", IsPrivate: true},
+		}, map[string][]string{
+			"t0": {"u0/private", "u0/new"},
+		})
+		github.GetRepositoriesByNodeIDFromAPIMock = githubMock.GetRepositoriesByNodeIDFromAPI
+		defer func() { github.GetRepositoriesByNodeIDFromAPIMock = nil }()
+
+		provider := NewProvider(mustURL(t, "https://github.com"), "base-token", db, 3*time.Hour, store.DefaultHardTTL)
+		ctx := context.Background()
+		account := ua(11, "u0", "t0")
+
+		// The requested repositories are checked while the permissions of the
+		// user are fetched in the background.
+		perms, err := provider.RepoPerms(ctx, account, []*types.Repo{repos["r0"], repos["r1"]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []authz.RepoPerms{{Repo: repos["r0"], Perms: authz.Read}}; !reflect.DeepEqual(perms, want) {
+			t.Fatalf("perms: want %s, got %s", spew.Sdump(want), spew.Sdump(perms))
+		}
+
+		waitForPermissions(t, db, account.UserID)
+		insertRepos(t, db, map[string]*types.Repo{"r2": repos["r2"]})
+
+		// The stored permissions don't cover the repository added since they
+		// were fetched, so it's checked instead of being denied.
+		githubMock.resetCalls()
+		perms, err = provider.RepoPerms(ctx, account, []*types.Repo{repos["r0"], repos["r1"], repos["r2"]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []authz.RepoPerms{
+			{Repo: repos["r0"], Perms: authz.Read},
+			{Repo: repos["r2"], Perms: authz.Read},
+		}
+		if !reflect.DeepEqual(perms, want) {
+			t.Fatalf("perms: want %s, got %s", spew.Sdump(want), spew.Sdump(perms))
+		}
+		if calls := githubMock.calls(); calls != 1 {
+			t.Errorf("expected 1 call to check the added repository, got %d", calls)
+		}
 	}
-	for _, test := range tests {
-		t.Run(test.description, test.run)
+}
+
+// waitForPermissions waits for the permissions of the given user to be stored.
+func waitForPermissions(t *testing.T, db *sql.DB, userID int32) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var stored bool
+		err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM user_permissions WHERE user_id = $1)", userID).Scan(&stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored {
+			return
+		}
 	}
+	t.Fatalf("permissions of user %d weren't stored", userID)
 }
 
 func Test_fetchUserRepos(t *testing.T) {
@@ -224,8 +321,8 @@ func Test_fetchUserRepos(t *testing.T) {
 	github.MaxNodeIDs = 2
 	defer func() { github.MaxNodeIDs = oldMaxNodeIDs }()
 
-	provider := NewProvider(mustURL(t, "https://github.com"), "base-token", 0, make(authz.MockCache))
-	canAccess, isPublic, err := provider.fetchUserRepos(context.Background(), ua("u0", "t0"), []string{
+	provider := NewProvider(mustURL(t, "https://github.com"), "base-token", nil, 0, 0)
+	canAccess, isPublic, err := provider.fetchUserRepos(context.Background(), ua(1, "u0", "t0"), []string{
 		"u0/private",
 		"u0/public",
 		"u1/private",
@@ -268,8 +365,11 @@ func mustURL(t *testing.T, u string) *url.URL {
 	return parsed
 }
 
-func ua(accountID, token string) *extsvc.ExternalAccount {
+func ua(userID int32, accountID, token string) *extsvc.ExternalAccount {
 	var a extsvc.ExternalAccount
+	a.UserID = userID
+	a.ServiceType = github.ServiceType
+	a.ServiceID = "https://github.com/"
 	a.AccountID = accountID
 	github.SetExternalAccountData(&a.ExternalAccountData, nil, &oauth2.Token{
 		AccessToken: token,
@@ -277,8 +377,9 @@ func ua(accountID, token string) *extsvc.ExternalAccount {
 	return &a
 }
 
-func rp(name, ghid, serviceID string) *types.Repo {
+func rp(id api.RepoID, name, ghid, serviceID string) *types.Repo {
 	return &types.Repo{
+		ID:   id,
 		Name: api.RepoName(name),
 		ExternalRepo: api.ExternalRepoSpec{
 			ID:          ghid,
@@ -288,6 +389,21 @@ func rp(name, ghid, serviceID string) *types.Repo {
 	}
 }
 
+// insertRepos stores the given repos in the repo table, of which the provider
+// reads the repositories to check permissions on.
+func insertRepos(t *testing.T, db *sql.DB, repos map[string]*types.Repo) {
+	t.Helper()
+	for _, r := range repos {
+		_, err := db.Exec(
+			"INSERT INTO repo (id, name, external_id, external_service_type, external_service_id) VALUES ($1, $2, $3, $4, $5)",
+			r.ID, r.Name, r.ExternalRepo.ID, r.ExternalRepo.ServiceType, r.ExternalRepo.ServiceID,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 type mockGitHub struct {
 	// Repos is a map from repo ID to repository
 	Repos map[string]*github.Repository
@@ -298,6 +414,9 @@ type mockGitHub struct {
 	// PublicRepos is the set of repo IDs corresponding to public repos
 	PublicRepos map[string]struct{}
 
+	// mu guards the counts, since permissions are also fetched in the background.
+	mu sync.Mutex
+
 	// getRepositoryByNodeIDCount tracks the number of times GetRepositoryByNodeID is called
 	getRepositoryByNodeIDCount int
 
@@ -305,6 +424,20 @@ type mockGitHub struct {
 	getRepositoriesByNodeIDCount int
 }
 
+// calls returns the number of calls to the GitHub API since the last resetCalls.
+func (m *mockGitHub) calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getRepositoryByNodeIDCount + m.getRepositoriesByNodeIDCount
+}
+
+func (m *mockGitHub) resetCalls() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.getRepositoryByNodeIDCount = 0
+	m.getRepositoriesByNodeIDCount = 0
+}
+
 func newMockGitHub(repos []*github.Repository, tokenRepos map[string][]string) *mockGitHub {
 	rp := make(map[string]*github.Repository)
 	for _, r := range repos {
@@ -331,7 +464,9 @@ func newMockGitHub(repos []*github.Repository, tokenRepos map[string][]string) *
 }
 
 func (m *mockGitHub) GetRepositoryByNodeID(ctx context.Context, token, id string) (repo *github.Repository, err error) {
+	m.mu.Lock()
 	m.getRepositoryByNodeIDCount++
+	m.mu.Unlock()
 	if _, isPublic := m.PublicRepos[id]; isPublic {
 		r, ok := m.Repos[id]
 		if !ok {
@@ -359,7 +494,9 @@ func (m *mockGitHub) GetRepositoryByNodeID(ctx context.Context, token, id string
 }
 
 func (m *mockGitHub) GetRepositoriesByNodeIDFromAPI(ctx context.Context, token string, nodeIDs []string) (map[string]*github.Repository, error) {
+	m.mu.Lock()
 	m.getRepositoriesByNodeIDCount++
+	m.mu.Unlock()
 
 	repos := make(map[string]*github.Repository)
 	for rid := range m.PublicRepos {
@@ -0,0 +1,31 @@
+package github
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtest"
+)
+
+var dsn = flag.String("dsn", "", "Database connection string to use in integration tests")
+
+func TestIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	t.Parallel()
+
+	db, cleanup := dbtest.NewDB(t, *dsn)
+	defer cleanup()
+
+	for _, tc := range []struct {
+		name string
+		test func(*testing.T)
+	}{
+		{"Provider/RepoPerms", testProviderRepoPerms(db)},
+		{"Provider/RepoPermsNotStored", testProviderRepoPermsNotStored(db)},
+	} {
+		t.Run(tc.name, tc.test)
+	}
+}
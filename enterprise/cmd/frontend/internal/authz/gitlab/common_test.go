@@ -2,6 +2,7 @@ package gitlab
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"net/url"
 	"strconv"
@@ -277,21 +278,6 @@ func (m *mockGitLab) ListUsers(c *gitlab.Client, ctx context.Context, urlStr str
 	return pagedUsers, nextPageURL, nil
 }
 
-type mockCache map[string]string
-
-func (m mockCache) Get(key string) ([]byte, bool) {
-	v, ok := m[key]
-	return []byte(v), ok
-}
-
-func (m mockCache) Set(key string, b []byte) {
-	m[key] = string(b)
-}
-
-func (m mockCache) Delete(key string) {
-	delete(m, key)
-}
-
 type mockAuthnProvider struct {
 	configID  providers.ConfigID
 	serviceID string
@@ -348,17 +334,33 @@ func acct(t *testing.T, userID int32, serviceType, serviceID, accountID, oauthTo
 	}
 }
 
-func repo(uri, serviceType, serviceID, id string) *types.Repo {
+func repo(id api.RepoID, uri, serviceType, serviceID, externalID string) *types.Repo {
 	return &types.Repo{
+		ID:   id,
 		Name: api.RepoName(uri),
 		ExternalRepo: api.ExternalRepoSpec{
-			ID:          id,
+			ID:          externalID,
 			ServiceType: serviceType,
 			ServiceID:   serviceID,
 		},
 	}
 }
 
+// insertRepos stores the given repos in the repo table, of which the providers
+// read the projects to check permissions on.
+func insertRepos(t *testing.T, db *sql.DB, repos ...*types.Repo) {
+	t.Helper()
+	for _, r := range repos {
+		_, err := db.Exec(
+			"INSERT INTO repo (id, name, external_id, external_service_type, external_service_id) VALUES ($1, $2, $3, $4, $5)",
+			r.ID, r.Name, r.ExternalRepo.ID, r.ExternalRepo.ServiceType, r.ExternalRepo.ServiceID,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func mustURL(t *testing.T, u string) *url.URL {
 	parsed, err := url.Parse(u)
 	if err != nil {
@@ -4,20 +4,19 @@ package gitlab
 
 import (
 	"context"
-	"fmt"
-	"math"
-	"net/http"
+	"database/sql"
 	"net/url"
 	"strconv"
 	"time"
 
+	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
-	"github.com/sourcegraph/sourcegraph/pkg/rcache"
-	log15 "gopkg.in/inconshreveable/log15.v2"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
 )
 
 var _ authz.Provider = ((*GitLabOAuthAuthzProvider)(nil))
@@ -26,34 +25,37 @@ type GitLabOAuthAuthzProvider struct {
 	clientProvider *gitlab.ClientProvider
 	clientURL      *url.URL
 	codeHost       *extsvc.CodeHost
-	cache          cache
-	cacheTTL       time.Duration
+	store          *store.Store
 }
 
 type GitLabOAuthAuthzProviderOp struct {
 	// BaseURL is the URL of the GitLab instance.
 	BaseURL *url.URL
 
-	// CacheTTL is the TTL of cached permissions lists from the GitLab API.
+	// DB is the database in which the permissions fetched from the GitLab API are stored.
+	DB *sql.DB
+
+	// CacheTTL is the TTL of the stored permissions, after which they're updated.
 	CacheTTL time.Duration
 
-	// MockCache, if non-nil, replaces the default Redis-based cache with the supplied cache mock.
-	// Should only be used in tests.
-	MockCache cache
+	// HardTTL is the TTL of the stored permissions after which they can no longer be used,
+	// and must be updated. It defaults to store.DefaultHardTTL.
+	HardTTL time.Duration
 }
 
 func NewOAuthProvider(op GitLabOAuthAuthzProviderOp) *GitLabOAuthAuthzProvider {
-	p := &GitLabOAuthAuthzProvider{
+	hardTTL := op.HardTTL
+	if hardTTL == 0 {
+		hardTTL = store.DefaultHardTTL
+	}
+	s := store.New(op.DB, op.CacheTTL, hardTTL, store.Clock, store.NewCache())
+	s.Block = true // The first request of a user waits for their permissions.
+	return &GitLabOAuthAuthzProvider{
 		clientProvider: gitlab.NewClientProvider(op.BaseURL, nil),
 		clientURL:      op.BaseURL,
 		codeHost:       extsvc.NewCodeHost(op.BaseURL, gitlab.ServiceType),
-		cache:          op.MockCache,
-		cacheTTL:       op.CacheTTL,
-	}
-	if p.cache == nil {
-		p.cache = rcache.NewWithTTL(fmt.Sprintf("gitlabAuthz:%s", op.BaseURL.String()), int(math.Ceil(op.CacheTTL.Seconds())))
+		store:          s,
 	}
-	return p
 }
 
 func (p *GitLabOAuthAuthzProvider) Validate() (problems []string) {
@@ -72,90 +74,89 @@ func (p *GitLabOAuthAuthzProvider) FetchAccount(ctx context.Context, user *types
 	return nil, nil
 }
 
+// RepoPerms implements the authz.Provider interface. The projects a user can read are
+// fetched from the GitLab API with the OAuth token of their GitLab account, and stored
+// in the permissions store. Users without a GitLab account can only read public projects.
 func (p *GitLabOAuthAuthzProvider) RepoPerms(ctx context.Context, account *extsvc.ExternalAccount, repos []*types.Repo) (
-	[]authz.RepoPerms, error,
+	perms []authz.RepoPerms, err error,
 ) {
-	accountID := "" // empty means public / unauthenticated to the code host
-	if account != nil && account.ServiceID == p.codeHost.ServiceID && account.ServiceType == p.codeHost.ServiceType {
-		accountID = account.AccountID
+	if len(repos) == 0 {
+		return nil, nil
 	}
 
-	remaining := repos
-	perms := make([]authz.RepoPerms, 0, len(remaining))
+	tr, ctx := trace.New(ctx, "gitlab.authz.oauth.RepoPerms", "")
+	defer func() {
+		tr.LogFields(
+			otlog.Int("repos.count", len(repos)),
+			otlog.Int("perms.count", len(perms)),
+		)
 
-	// Populate perms using cached repository visibility information.
-	for _, repo := range remaining {
-		projID, err := strconv.Atoi(repo.ExternalRepo.ID)
 		if err != nil {
-			return nil, errors.Wrap(err, "GitLab repo external ID did not parse to int")
+			tr.SetError(err)
 		}
 
-		if vis, exists := cacheGetRepoVisibility(p.cache, projID, p.cacheTTL); exists {
-			if v := vis.Visibility; v == gitlab.Public || (v == gitlab.Internal && accountID != "") {
-				perms = append(perms, authz.RepoPerms{Repo: repo, Perms: authz.Read})
-				continue
-			}
-		}
+		tr.Finish()
+	}()
 
-		// Populate perms using cached user-can-access-repository information.
-		if accountID != "" {
-			if userRepo, exists := cacheGetUserRepo(p.cache, accountID, projID, p.cacheTTL); exists {
-				rp := authz.RepoPerms{Repo: repo}
-				if userRepo.Read {
-					rp.Perms = authz.Read
-				}
-				perms = append(perms, rp)
-				continue
-			}
-		}
+	var userID int32 // zero means public / unauthenticated to the code host
+	if account != nil && account.ServiceID == p.codeHost.ServiceID && account.ServiceType == p.codeHost.ServiceType {
+		userID = account.UserID
+	} else {
+		account = nil
+	}
 
-		// Populate perms for the remaining repos (nextRemaining) by fetching directly from the GitLab
-		// API (and update the user repo-visibility and user-can-access-repo permissions, as well)
+	update := func(ctx context.Context) ([]uint32, error) {
 		var oauthToken string
 		if account != nil {
 			_, tok, err := gitlab.GetExternalAccountData(&account.ExternalAccountData)
 			if err != nil {
 				return nil, err
 			}
-			oauthToken = tok.AccessToken
+			if tok != nil {
+				oauthToken = tok.AccessToken
+			}
 		}
 
-		isAccessible, vis, isContentAccessible, err := p.fetchProjVis(ctx, oauthToken, projID)
+		// The stored permissions are used for all later requests of the user, so
+		// they cover all the projects of this code host, not only the requested ones.
+		all, err := p.store.Repos(ctx, p.codeHost.ServiceType, p.codeHost.ServiceID)
 		if err != nil {
-			log15.Error("Failed to fetch visibility for GitLab project", "projectID", projID, "gitlabHost", p.codeHost.BaseURL.String(), "error", err)
-			continue
+			return nil, err
 		}
-		if isAccessible {
-			// Set perms
-			perms = append(perms, authz.RepoPerms{Repo: repo, Perms: authz.Read})
 
-			// Update visibility cache
-			err := cacheSetRepoVisibility(p.cache, projID, repoVisibilityCacheVal{Visibility: vis, TTL: p.cacheTTL})
+		ids := make([]uint32, 0, len(all))
+		for _, repo := range all {
+			projID, err := strconv.Atoi(repo.ExternalRepo.ID)
 			if err != nil {
-				return nil, errors.Wrap(err, "could not set cached repo visibility")
+				return nil, errors.Wrap(err, "GitLab repo external ID did not parse to int")
 			}
 
-			// Update userRepo cache if the visibility is private
-			if vis == gitlab.Private {
-				err := cacheSetUserRepo(p.cache, accountID, projID, userRepoCacheVal{Read: isContentAccessible, TTL: p.cacheTTL})
-				if err != nil {
-					return nil, errors.Wrap(err, "could not set cached user repo")
-				}
-			}
-		} else if accountID != "" {
-			// A repo is private if it is not accessible to an authenticated user
-			err := cacheSetRepoVisibility(p.cache, projID, repoVisibilityCacheVal{Visibility: gitlab.Private, TTL: p.cacheTTL})
+			isAccessible, _, isContentAccessible, err := p.fetchProjVis(ctx, oauthToken, projID)
 			if err != nil {
-				return nil, errors.Wrap(err, "could not set cached repo visibility")
+				return nil, errors.Wrapf(err, "failed to fetch visibility for GitLab project %d", projID)
 			}
-			err = cacheSetUserRepo(p.cache, accountID, projID, userRepoCacheVal{Read: false, TTL: p.cacheTTL})
-			if err != nil {
-				return nil, errors.Wrap(err, "could not set cached user repo")
+
+			if isAccessible && isContentAccessible {
+				ids = append(ids, uint32(repo.ID))
 			}
 		}
+
+		return ids, nil
+	}
+
+	ps := &store.Permissions{
+		UserID:      userID,
+		Perm:        authz.Read,
+		Type:        "repos",
+		ServiceType: p.codeHost.ServiceType,
+		ServiceID:   p.codeHost.ServiceID,
+	}
+
+	if err = p.store.LoadPermissions(ctx, &ps, update); err != nil {
+		return nil, err
 	}
 
-	return perms, nil
+	return ps.Authorized(repos), nil
 }
 
 // fetchProjVis fetches a repository's visibility with usr's credentials. It returns:
@@ -172,7 +173,7 @@ func (p *GitLabOAuthAuthzProvider) fetchProjVis(ctx context.Context, oauthToken
 		CommonOp: gitlab.CommonOp{NoCache: true},
 	})
 	if err != nil {
-		if errCode := gitlab.HTTPErrorCode(err); errCode == http.StatusNotFound {
+		if gitlab.IsNotFound(err) {
 			return false, "", false, nil
 		}
 		return false, "", false, err
@@ -194,7 +195,7 @@ func (p *GitLabOAuthAuthzProvider) fetchProjVis(ctx context.Context, oauthToken
 		ProjID:   projID,
 		CommonOp: gitlab.CommonOp{NoCache: true},
 	}); err != nil {
-		if errCode := gitlab.HTTPErrorCode(err); errCode == http.StatusNotFound {
+		if gitlab.IsNotFound(err) {
 			return true, proj.Visibility, false, nil
 		}
 		return false, "", false, err
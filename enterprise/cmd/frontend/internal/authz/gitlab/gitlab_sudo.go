@@ -3,29 +3,24 @@ package gitlab
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
-	"math"
-	"net/http"
 	"net/url"
 	"strconv"
 	"time"
 
+	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/auth/providers"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
 	"github.com/sourcegraph/sourcegraph/pkg/extsvc/gitlab"
-	"github.com/sourcegraph/sourcegraph/pkg/rcache"
-	log15 "gopkg.in/inconshreveable/log15.v2"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
+	"golang.org/x/time/rate"
 )
 
-type pcache interface {
-	Get(key string) ([]byte, bool)
-	Set(key string, b []byte)
-	Delete(key string)
-}
-
 // SudoProvider is an implementation of AuthzProvider that provides repository permissions as
 // determined from a GitLab instance API. For documentation of specific fields, see the docstrings
 // of SudoProviderOp.
@@ -40,8 +35,7 @@ type SudoProvider struct {
 	gitlabProvider    string
 	authnConfigID     providers.ConfigID
 	useNativeUsername bool
-	cache             pcache
-	cacheTTL          time.Duration
+	store             *store.Store
 }
 
 var _ authz.Provider = ((*SudoProvider)(nil))
@@ -63,36 +57,48 @@ type SudoProviderOp struct {
 	// 🚨 SECURITY: This value contains secret information that must not be shown to non-site-admins.
 	SudoToken string
 
-	// CacheTTL is the TTL of cached permissions lists from the GitLab API.
+	// RateLimiter, if set, is the rate limiter of the external service, which
+	// the requests made with SudoToken share.
+	RateLimiter *rate.Limiter
+
+	// DB is the database in which the permissions fetched from the GitLab API are stored.
+	DB *sql.DB
+
+	// CacheTTL is the TTL of the stored permissions, after which they're updated.
 	CacheTTL time.Duration
 
+	// HardTTL is the TTL of the stored permissions after which they can no longer be used,
+	// and must be updated. It defaults to store.DefaultHardTTL.
+	HardTTL time.Duration
+
 	// UseNativeUsername, if true, maps Sourcegraph users to GitLab users using username equivalency
 	// instead of the authn provider user ID. This is *very* insecure (Sourcegraph usernames can be
 	// changed at the user's will) and should only be used in development environments.
 	UseNativeUsername bool
-
-	// MockCache, if non-nil, replaces the default Redis-based cache with the supplied cache mock.
-	// Should only be used in tests.
-	MockCache pcache
 }
 
 func NewSudoProvider(op SudoProviderOp) *SudoProvider {
-	p := &SudoProvider{
+	hardTTL := op.HardTTL
+	if hardTTL == 0 {
+		hardTTL = store.DefaultHardTTL
+	}
+	s := store.New(op.DB, op.CacheTTL, hardTTL, store.Clock, store.NewCache())
+	s.Block = true // The first request of a user waits for their permissions.
+	clientProvider := gitlab.NewClientProvider(op.BaseURL, nil)
+	if op.RateLimiter != nil {
+		clientProvider.RateLimiter = op.RateLimiter
+	}
+	return &SudoProvider{
 		sudoToken: op.SudoToken,
 
-		clientProvider:    gitlab.NewClientProvider(op.BaseURL, nil),
+		clientProvider:    clientProvider,
 		clientURL:         op.BaseURL,
 		codeHost:          extsvc.NewCodeHost(op.BaseURL, gitlab.ServiceType),
-		cache:             op.MockCache,
+		store:             s,
 		authnConfigID:     op.AuthnConfigID,
 		gitlabProvider:    op.GitLabProvider,
-		cacheTTL:          op.CacheTTL,
 		useNativeUsername: op.UseNativeUsername,
 	}
-	if p.cache == nil {
-		p.cache = rcache.NewWithTTL(fmt.Sprintf("gitlabAuthz:%s", op.BaseURL.String()), int(math.Ceil(op.CacheTTL.Seconds())))
-	}
-	return p
 }
 
 func (p *SudoProvider) Validate() (problems []string) {
@@ -116,89 +122,90 @@ func (p *SudoProvider) ServiceType() string {
 	return p.codeHost.ServiceType
 }
 
-func (p *SudoProvider) RepoPerms(ctx context.Context, account *extsvc.ExternalAccount, repos []*types.Repo) ([]authz.RepoPerms, error) {
-	accountID := "" // empty means public / unauthenticated to the code host
-	if account != nil && account.ServiceID == p.codeHost.ServiceID && account.ServiceType == p.codeHost.ServiceType {
-		accountID = account.AccountID
+// RepoPerms implements the authz.Provider interface. The projects a user can read are
+// fetched from the GitLab API by impersonating their GitLab account with the sudo token,
+// and stored in the permissions store. Users without a GitLab account can only read
+// public projects.
+func (p *SudoProvider) RepoPerms(ctx context.Context, account *extsvc.ExternalAccount, repos []*types.Repo) (
+	perms []authz.RepoPerms, err error,
+) {
+	if len(repos) == 0 {
+		return nil, nil
 	}
 
-	remaining := repos
-	perms := make([]authz.RepoPerms, 0, len(remaining))
+	tr, ctx := trace.New(ctx, "gitlab.authz.sudo.RepoPerms", "")
+	defer func() {
+		tr.LogFields(
+			otlog.Int("repos.count", len(repos)),
+			otlog.Int("perms.count", len(perms)),
+		)
 
-	for _, repo := range remaining {
-		// Populate perms using cached repository visibility information.
-		projID, err := strconv.Atoi(repo.ExternalRepo.ID)
 		if err != nil {
-			return nil, errors.Wrap(err, "GitLab repo external ID did not parse to int")
+			tr.SetError(err)
 		}
 
-		if vis, exists := cacheGetRepoVisibility(p.cache, projID, p.cacheTTL); exists {
-			if v := vis.Visibility; v == gitlab.Public || (v == gitlab.Internal && accountID != "") {
-				perms = append(perms, authz.RepoPerms{Repo: repo, Perms: authz.Read})
-				continue
-			}
-		}
+		tr.Finish()
+	}()
 
-		// Populate perms using cached user-can-access-repository information.
-		if accountID != "" {
-			if userRepo, exists := cacheGetUserRepo(p.cache, accountID, projID, p.cacheTTL); exists {
-				rp := authz.RepoPerms{Repo: repo}
-				if userRepo.Read {
-					rp.Perms = authz.Read
-				}
-				perms = append(perms, rp)
-				continue
-			}
-		}
+	var userID int32 // zero means public / unauthenticated to the code host
+	if account != nil && account.ServiceID == p.codeHost.ServiceID && account.ServiceType == p.codeHost.ServiceType {
+		userID = account.UserID
+	} else {
+		account = nil
+	}
 
-		// Populate perms by fetching directly from the GitLab
-		// API (and update the user repo-visibility and user-can-access-repo permissions, as well)
+	update := func(ctx context.Context) ([]uint32, error) {
 		var sudo string
 		if account != nil {
 			usr, _, err := gitlab.GetExternalAccountData(&account.ExternalAccountData)
 			if err != nil {
 				return nil, err
 			}
-			sudo = strconv.Itoa(int(usr.ID))
+			if usr != nil {
+				sudo = strconv.Itoa(int(usr.ID))
+			}
 		}
 
-		isAccessible, vis, isContentAccessible, err := p.fetchProjVis(ctx, sudo, projID)
+		// The stored permissions are used for all later requests of the user, so
+		// they cover all the projects of this code host, not only the requested ones.
+		all, err := p.store.Repos(ctx, p.codeHost.ServiceType, p.codeHost.ServiceID)
 		if err != nil {
-			log15.Error("Failed to fetch visibility for GitLab project", "projectID", projID, "gitlabHost", p.codeHost.BaseURL.String(), "error", err)
-			continue
+			return nil, err
 		}
 
-		if isAccessible {
-			// Set perms
-			perms = append(perms, authz.RepoPerms{Repo: repo, Perms: authz.Read})
-
-			// Update visibility cache
-			err := cacheSetRepoVisibility(p.cache, projID, repoVisibilityCacheVal{Visibility: vis, TTL: p.cacheTTL})
+		ids := make([]uint32, 0, len(all))
+		for _, repo := range all {
+			projID, err := strconv.Atoi(repo.ExternalRepo.ID)
 			if err != nil {
-				return nil, errors.Wrap(err, "could not set cached repo visibility")
+				return nil, errors.Wrap(err, "GitLab repo external ID did not parse to int")
 			}
 
-			// Update userRepo cache if the visibility is private
-			if vis == gitlab.Private {
-				err := cacheSetUserRepo(p.cache, accountID, projID, userRepoCacheVal{Read: isContentAccessible, TTL: p.cacheTTL})
-				if err != nil {
-					return nil, errors.Wrap(err, "could not set cached user repo")
-				}
-			}
-		} else if accountID != "" {
-			// A repo is private if it is not accessible to an authenticated user
-			err := cacheSetRepoVisibility(p.cache, projID, repoVisibilityCacheVal{Visibility: gitlab.Private, TTL: p.cacheTTL})
+			isAccessible, _, isContentAccessible, err := p.fetchProjVis(ctx, sudo, projID)
 			if err != nil {
-				return nil, errors.Wrap(err, "could not set cached repo visibility")
+				return nil, errors.Wrapf(err, "failed to fetch visibility for GitLab project %d", projID)
 			}
-			err = cacheSetUserRepo(p.cache, accountID, projID, userRepoCacheVal{Read: false, TTL: p.cacheTTL})
-			if err != nil {
-				return nil, errors.Wrap(err, "could not set cached user repo")
+
+			if isAccessible && isContentAccessible {
+				ids = append(ids, uint32(repo.ID))
 			}
 		}
+
+		return ids, nil
+	}
+
+	ps := &store.Permissions{
+		UserID:      userID,
+		Perm:        authz.Read,
+		Type:        "repos",
+		ServiceType: p.codeHost.ServiceType,
+		ServiceID:   p.codeHost.ServiceID,
 	}
 
-	return perms, nil
+	if err = p.store.LoadPermissions(ctx, &ps, update); err != nil {
+		return nil, err
+	}
+
+	return ps.Authorized(repos), nil
 }
 
 // fetchProjVis fetches a repository's visibility with usr's credentials. It returns:
@@ -215,6 +222,9 @@ func (p *SudoProvider) fetchProjVis(ctx context.Context, sudo string, projID int
 		CommonOp: gitlab.CommonOp{NoCache: true},
 	})
 	if err != nil {
+		if gitlab.IsNotFound(err) {
+			return false, "", false, nil
+		}
 		return false, "", false, err
 	}
 
@@ -241,7 +251,7 @@ func (p *SudoProvider) fetchProjVis(ctx context.Context, sudo string, projID int
 		ProjID:   projID,
 		CommonOp: gitlab.CommonOp{NoCache: true},
 	}); err != nil {
-		if errCode := gitlab.HTTPErrorCode(err); errCode == http.StatusNotFound {
+		if gitlab.IsNotFound(err) {
 			return true, proj.Visibility, false, nil
 		}
 		return false, "", false, err
@@ -2,8 +2,10 @@ package gitlab
 
 import (
 	"context"
+	"database/sql"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/sergi/go-diff/diffmatchpatch"
@@ -215,180 +217,185 @@ func Test_GitLab_FetchAccount(t *testing.T) {
 	}
 }
 
-func Test_SudoProvider_RepoPerms(t *testing.T) {
-	type call struct {
-		description string
-		account     *extsvc.ExternalAccount
-		repos       []*types.Repo
-		expPerms    []authz.RepoPerms
-	}
-	type test struct {
-		description string
-		op          SudoProviderOp
-		calls       []call
-	}
+func testSudoProviderRepoPerms(db *sql.DB) func(*testing.T) {
+	return func(t *testing.T) {
+		type call struct {
+			description string
+			account     *extsvc.ExternalAccount
+			repos       []*types.Repo
+			expPerms    []authz.RepoPerms
+		}
+		type test struct {
+			description string
+			op          SudoProviderOp
+			calls       []call
+		}
 
-	// Mock the following scenario:
-	// - public projects begin with 99
-	// - internal projects begin with 98
-	// - private projects begin with the digit of the user that owns them (other users may have access)
-	// - user 1 owns its own repositories and nothing else
-	// - user 2 owns its own repos and has guest access to user 1's
-	// - user 3 owns its own repos and has full access to user 1's and guest access to user 2's
-	gitlabMock := newMockGitLab(mockGitLabOp{
-		t: t,
-		publicProjs: []int{ // public projects
-			991,
-		},
-		internalProjs: []int{ // internal projects
-			981,
-		},
-		privateProjs: map[int][2][]int32{ // private projects
-			10: {
-				{ // guests
-					2,
-				},
-				{ // content ("full access")
-					1,
-					3,
-				},
-			},
-			20: {
-				{
-					3,
-				},
-				{
-					2,
-				},
+		// Mock the following scenario:
+		// - public projects begin with 99
+		// - internal projects begin with 98
+		// - private projects begin with the digit of the user that owns them (other users may have access)
+		// - user 1 owns its own repositories and nothing else
+		// - user 2 owns its own repos and has guest access to user 1's
+		// - user 3 owns its own repos and has full access to user 1's and guest access to user 2's
+		gitlabMock := newMockGitLab(mockGitLabOp{
+			t: t,
+			publicProjs: []int{ // public projects
+				991,
 			},
-			30: {
-				{},
-				{3},
-			},
-		},
-		sudoTok: "sudo-token",
-	})
-	gitlab.MockGetProject = gitlabMock.GetProject
-	gitlab.MockListTree = gitlabMock.ListTree
-
-	repos := map[string]*types.Repo{
-		"u1/repo1":       repo("u1/repo1", gitlab.ServiceType, "https://gitlab.mine/", "10"),
-		"u2/repo1":       repo("u2/repo1", gitlab.ServiceType, "https://gitlab.mine/", "20"),
-		"u3/repo1":       repo("u3/repo1", gitlab.ServiceType, "https://gitlab.mine/", "30"),
-		"internal/repo1": repo("internal/repo1", gitlab.ServiceType, "https://gitlab.mine/", "981"),
-		"public/repo1":   repo("public/repo1", gitlab.ServiceType, "https://gitlab.mine/", "991"),
-	}
-
-	tests := []test{
-		{
-			description: "standard config",
-			op: SudoProviderOp{
-				BaseURL:   mustURL(t, "https://gitlab.mine"),
-				SudoToken: "sudo-token",
+			internalProjs: []int{ // internal projects
+				981,
 			},
-			calls: []call{
-				{
-					description: "u1 user has expected perms",
-					account:     acct(t, 1, "gitlab", "https://gitlab.mine/", "1", "oauth-u1"),
-					repos: []*types.Repo{
-						repos["u1/repo1"],
-						repos["u2/repo1"],
-						repos["u3/repo1"],
-						repos["internal/repo1"],
-						repos["public/repo1"],
+			privateProjs: map[int][2][]int32{ // private projects
+				10: {
+					{ // guests
+						2,
 					},
-					expPerms: []authz.RepoPerms{
-						{Repo: repos["u1/repo1"], Perms: authz.Read},
-						{Repo: repos["internal/repo1"], Perms: authz.Read},
-						{Repo: repos["public/repo1"], Perms: authz.Read},
+					{ // content ("full access")
+						1,
+						3,
 					},
 				},
-				{
-					description: "u2 user has expected perms",
-					account:     acct(t, 2, "gitlab", "https://gitlab.mine/", "2", "oauth-u2"),
-					repos: []*types.Repo{
-						repos["u1/repo1"],
-						repos["u2/repo1"],
-						repos["u3/repo1"],
-						repos["internal/repo1"],
-						repos["public/repo1"],
+				20: {
+					{
+						3,
 					},
-					expPerms: []authz.RepoPerms{
-						{Repo: repos["u2/repo1"], Perms: authz.Read},
-						{Repo: repos["internal/repo1"], Perms: authz.Read},
-						{Repo: repos["public/repo1"], Perms: authz.Read},
+					{
+						2,
 					},
 				},
-				{
-					description: "other user has expected perms (internal and public)",
-					account:     acct(t, 4, "gitlab", "https://gitlab.mine/", "555", "oauth-other"),
-					repos: []*types.Repo{
-						repos["u1/repo1"],
-						repos["u2/repo1"],
-						repos["u3/repo1"],
-						repos["internal/repo1"],
-						repos["public/repo1"],
-					},
-					expPerms: []authz.RepoPerms{
-						{Repo: repos["internal/repo1"], Perms: authz.Read},
-						{Repo: repos["public/repo1"], Perms: authz.Read},
-					},
+				30: {
+					{},
+					{3},
 				},
-				{
-					description: "no token means only public and internal repos",
-					account:     acct(t, 4, "gitlab", "https://gitlab.mine/", "555", ""),
-					repos: []*types.Repo{
-						repos["u1/repo1"],
-						repos["u2/repo1"],
-						repos["u3/repo1"],
-						repos["internal/repo1"],
-						repos["public/repo1"],
+			},
+			sudoTok: "sudo-token",
+		})
+		gitlab.MockGetProject = gitlabMock.GetProject
+		gitlab.MockListTree = gitlabMock.ListTree
+
+		repos := map[string]*types.Repo{
+			"u1/repo1":       repo(1, "u1/repo1", gitlab.ServiceType, "https://gitlab.mine/", "10"),
+			"u2/repo1":       repo(2, "u2/repo1", gitlab.ServiceType, "https://gitlab.mine/", "20"),
+			"u3/repo1":       repo(3, "u3/repo1", gitlab.ServiceType, "https://gitlab.mine/", "30"),
+			"internal/repo1": repo(4, "internal/repo1", gitlab.ServiceType, "https://gitlab.mine/", "981"),
+			"public/repo1":   repo(5, "public/repo1", gitlab.ServiceType, "https://gitlab.mine/", "991"),
+		}
+		for _, r := range repos {
+			insertRepos(t, db, r)
+		}
+
+		tests := []test{
+			{
+				description: "standard config",
+				op: SudoProviderOp{
+					BaseURL:   mustURL(t, "https://gitlab.mine"),
+					SudoToken: "sudo-token",
+					DB:        db,
+					CacheTTL:  3 * time.Hour,
+				},
+				calls: []call{
+					{
+						description: "u1 user has expected perms",
+						account:     acct(t, 1, "gitlab", "https://gitlab.mine/", "1", "oauth-u1"),
+						repos: []*types.Repo{
+							repos["u1/repo1"],
+							repos["u2/repo1"],
+							repos["u3/repo1"],
+							repos["internal/repo1"],
+							repos["public/repo1"],
+						},
+						expPerms: []authz.RepoPerms{
+							{Repo: repos["u1/repo1"], Perms: authz.Read},
+							{Repo: repos["internal/repo1"], Perms: authz.Read},
+							{Repo: repos["public/repo1"], Perms: authz.Read},
+						},
 					},
-					expPerms: []authz.RepoPerms{
-						{Repo: repos["internal/repo1"], Perms: authz.Read},
-						{Repo: repos["public/repo1"], Perms: authz.Read},
+					{
+						description: "u2 user has expected perms",
+						account:     acct(t, 2, "gitlab", "https://gitlab.mine/", "2", "oauth-u2"),
+						repos: []*types.Repo{
+							repos["u1/repo1"],
+							repos["u2/repo1"],
+							repos["u3/repo1"],
+							repos["internal/repo1"],
+							repos["public/repo1"],
+						},
+						expPerms: []authz.RepoPerms{
+							{Repo: repos["u2/repo1"], Perms: authz.Read},
+							{Repo: repos["internal/repo1"], Perms: authz.Read},
+							{Repo: repos["public/repo1"], Perms: authz.Read},
+						},
 					},
-				},
-				{
-					description: "unauthenticated means only public repos",
-					account:     nil,
-					repos: []*types.Repo{
-						repos["u1/repo1"],
-						repos["u2/repo1"],
-						repos["u3/repo1"],
-						repos["internal/repo1"],
-						repos["public/repo1"],
+					{
+						description: "other user has expected perms (internal and public)",
+						account:     acct(t, 4, "gitlab", "https://gitlab.mine/", "555", "oauth-other"),
+						repos: []*types.Repo{
+							repos["u1/repo1"],
+							repos["u2/repo1"],
+							repos["u3/repo1"],
+							repos["internal/repo1"],
+							repos["public/repo1"],
+						},
+						expPerms: []authz.RepoPerms{
+							{Repo: repos["internal/repo1"], Perms: authz.Read},
+							{Repo: repos["public/repo1"], Perms: authz.Read},
+						},
+					},
+					{
+						description: "no token means only public and internal repos",
+						account:     acct(t, 5, "gitlab", "https://gitlab.mine/", "555", ""),
+						repos: []*types.Repo{
+							repos["u1/repo1"],
+							repos["u2/repo1"],
+							repos["u3/repo1"],
+							repos["internal/repo1"],
+							repos["public/repo1"],
+						},
+						expPerms: []authz.RepoPerms{
+							{Repo: repos["internal/repo1"], Perms: authz.Read},
+							{Repo: repos["public/repo1"], Perms: authz.Read},
+						},
 					},
-					expPerms: []authz.RepoPerms{
-						{Repo: repos["public/repo1"], Perms: authz.Read},
+					{
+						description: "unauthenticated means only public repos",
+						account:     nil,
+						repos: []*types.Repo{
+							repos["u1/repo1"],
+							repos["u2/repo1"],
+							repos["u3/repo1"],
+							repos["internal/repo1"],
+							repos["public/repo1"],
+						},
+						expPerms: []authz.RepoPerms{
+							{Repo: repos["public/repo1"], Perms: authz.Read},
+						},
 					},
 				},
 			},
-		},
-	}
-	for _, test := range tests {
-		t.Run(test.description, func(t *testing.T) {
-			for _, c := range test.calls {
-				t.Logf("Call %q", c.description)
+		}
+		for _, test := range tests {
+			t.Run(test.description, func(t *testing.T) {
+				for _, c := range test.calls {
+					t.Logf("Call %q", c.description)
 
-				// Recreate the authz provider cache every time, before running twice (once uncached, once cached)
-				ctx := context.Background()
-				op := test.op
-				op.MockCache = make(mockCache)
-				authzProvider := NewSudoProvider(op)
+					// Recreate the authz provider every time, before running twice (once fetched, once stored)
+					ctx := context.Background()
+					authzProvider := NewSudoProvider(test.op)
 
-				for i := 0; i < 2; i++ {
-					t.Logf("iter %d", i)
-					perms, err := authzProvider.RepoPerms(ctx, c.account, c.repos)
-					if err != nil {
-						t.Errorf("unexpected error: %v", err)
-						continue
-					}
-					if !reflect.DeepEqual(perms, c.expPerms) {
-						t.Errorf("expected %s, but got %s", asJSON(t, c.expPerms), asJSON(t, perms))
+					for i := 0; i < 2; i++ {
+						t.Logf("iter %d", i)
+						perms, err := authzProvider.RepoPerms(ctx, c.account, c.repos)
+						if err != nil {
+							t.Errorf("unexpected error: %v", err)
+							continue
+						}
+						if !reflect.DeepEqual(perms, c.expPerms) {
+							t.Errorf("expected %s, but got %s", asJSON(t, c.expPerms), asJSON(t, perms))
+						}
 					}
 				}
-			}
-		})
+			})
+		}
 	}
 }
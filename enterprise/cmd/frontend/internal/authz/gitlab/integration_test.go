@@ -0,0 +1,33 @@
+package gitlab
+
+import (
+	"database/sql"
+	"flag"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtest"
+)
+
+var dsn = flag.String("dsn", "", "Database connection string to use in integration tests")
+
+func TestIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		test func(*sql.DB) func(*testing.T)
+	}{
+		{"OAuthProvider/RepoPerms", testOAuthProviderRepoPerms},
+		{"OAuthProvider/RepoPerms/Stored", testOAuthProviderRepoPermsStored},
+		{"SudoProvider/RepoPerms", testSudoProviderRepoPerms},
+	} {
+		// Each test gets its own database, since they share users and repositories.
+		db, cleanup := dbtest.NewDB(t, *dsn)
+		t.Run(tc.name, tc.test(db))
+		cleanup()
+	}
+}
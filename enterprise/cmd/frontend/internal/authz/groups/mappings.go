@@ -0,0 +1,124 @@
+package groups
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// Apply applies the given group mappings of an authentication provider to the user with the
+// given ID, who is a member of the given groups on the identity provider with the given
+// service type and ID. It is called every time the user signs in, so that changes to the
+// user's groups on the identity provider are reflected in Sourcegraph.
+//
+// The user is added to the organizations their groups are mapped to, and removed from the
+// organizations named in the mappings that none of their groups are mapped to. Organizations
+// not named in any mapping are left untouched. Likewise, the user's site admin status is only
+// managed if at least one mapping grants it. The repositories the user's groups are mapped to
+// are stored so that they are granted by the Provider.
+func Apply(ctx context.Context, userID int32, serviceType, serviceID string, userGroups []string, mappings []*schema.AuthProviderGroupMapping) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	member := make(map[string]bool, len(userGroups))
+	for _, g := range userGroups {
+		member[g] = true
+	}
+
+	var (
+		orgs              = map[string]bool{} // org name -> whether the user should be a member
+		repos             []string
+		seenRepos         = map[string]bool{}
+		manageSiteAdmin   bool
+		shouldBeSiteAdmin bool
+	)
+	for _, m := range mappings {
+		for _, org := range m.Orgs {
+			orgs[org] = orgs[org] || member[m.Group]
+		}
+		if m.SiteAdmin {
+			manageSiteAdmin = true
+			shouldBeSiteAdmin = shouldBeSiteAdmin || member[m.Group]
+		}
+		if !member[m.Group] {
+			continue
+		}
+		for _, repo := range m.Repos {
+			if !seenRepos[repo] {
+				seenRepos[repo] = true
+				repos = append(repos, repo)
+			}
+		}
+	}
+
+	if err := applyOrgs(ctx, userID, orgs); err != nil {
+		return err
+	}
+
+	if manageSiteAdmin {
+		user, err := db.Users.GetByID(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "get user")
+		}
+		if user.SiteAdmin != shouldBeSiteAdmin {
+			if err := db.Users.SetIsSiteAdmin(ctx, userID, shouldBeSiteAdmin); err != nil {
+				return errors.Wrap(err, "set site admin status")
+			}
+		}
+	}
+
+	err := NewStore(dbconn.Global, time.Now).SetUserGroups(ctx, userID, serviceType, serviceID, userGroups, repos)
+	return errors.Wrap(err, "store user groups")
+}
+
+// applyOrgs adds the user to or removes them from each of the given organizations.
+func applyOrgs(ctx context.Context, userID int32, orgs map[string]bool) error {
+	if len(orgs) == 0 {
+		return nil
+	}
+
+	memberships, err := db.OrgMembers.GetByUserID(ctx, userID)
+	if err != nil {
+		return errors.Wrap(err, "list organization memberships")
+	}
+	isMember := make(map[int32]bool, len(memberships))
+	for _, m := range memberships {
+		isMember[m.OrgID] = true
+	}
+
+	names := make([]string, 0, len(orgs))
+	for name := range orgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		org, err := db.Orgs.GetByName(ctx, name)
+		if _, ok := err.(*db.OrgNotFoundError); ok {
+			log15.Warn("Organization in group mappings of authentication provider does not exist.", "org", name)
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "get organization %q", name)
+		}
+
+		switch shouldBeMember := orgs[name]; {
+		case shouldBeMember && !isMember[org.ID]:
+			if _, err := db.OrgMembers.Create(ctx, org.ID, userID); err != nil {
+				return errors.Wrapf(err, "add user to organization %q", name)
+			}
+		case !shouldBeMember && isMember[org.ID]:
+			if err := db.OrgMembers.Remove(ctx, org.ID, userID); err != nil {
+				return errors.Wrapf(err, "remove user from organization %q", name)
+			}
+		}
+	}
+
+	return nil
+}
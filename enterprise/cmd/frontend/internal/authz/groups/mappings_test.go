@@ -0,0 +1,119 @@
+package groups
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtesting"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestApply(t *testing.T) {
+	ctx := dbtesting.TestContext(t)
+
+	user := createUsers(ctx, t, "erin")[0]
+
+	orgIDs := map[string]int32{}
+	for _, name := range []string{"eng", "sales", "unmanaged"} {
+		org, err := db.Orgs.Create(ctx, name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		orgIDs[name] = org.ID
+	}
+	if _, err := db.OrgMembers.Create(ctx, orgIDs["unmanaged"], user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	mappings := []*schema.AuthProviderGroupMapping{
+		{Group: "engineering", Orgs: []string{"eng", "missing"}, Repos: []string{"a", "b"}},
+		{Group: "sales", Orgs: []string{"sales"}, Repos: []string{"b", "c"}},
+		{Group: "admins", SiteAdmin: true},
+	}
+
+	orgs := func() []string {
+		t.Helper()
+		ms, err := db.OrgMembers.GetByUserID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, m := range ms {
+			for name, id := range orgIDs {
+				if id == m.OrgID {
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	for _, tc := range []struct {
+		name      string
+		groups    []string
+		orgs      []string
+		siteAdmin bool
+		repos     []string
+	}{
+		{
+			name:      "member of all groups",
+			groups:    []string{"engineering", "sales", "admins"},
+			orgs:      []string{"eng", "sales", "unmanaged"},
+			siteAdmin: true,
+			repos:     []string{"a", "b", "c"},
+		},
+		{
+			name:   "removed from groups",
+			groups: []string{"engineering", "other"},
+			orgs:   []string{"eng", "unmanaged"},
+			repos:  []string{"a", "b"},
+		},
+		{
+			name: "no groups",
+			orgs: []string{"unmanaged"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Apply(ctx, user.ID, "saml", "https://idp.example.com", tc.groups, mappings); err != nil {
+				t.Fatal(err)
+			}
+
+			if have := orgs(); !reflect.DeepEqual(have, tc.orgs) {
+				t.Errorf("got orgs %q, want %q", have, tc.orgs)
+			}
+
+			u, err := db.Users.GetByID(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.SiteAdmin != tc.siteAdmin {
+				t.Errorf("got site admin %v, want %v", u.SiteAdmin, tc.siteAdmin)
+			}
+
+			repos, err := NewStore(dbconn.Global, time.Now).UserRepoNames(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(repos)
+			if !reflect.DeepEqual(repos, tc.repos) {
+				t.Errorf("got repos %q, want %q", repos, tc.repos)
+			}
+		})
+	}
+
+	t.Run("no mappings", func(t *testing.T) {
+		if err := Apply(ctx, user.ID, "saml", "https://idp.example.com", []string{"admins"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if u, err := db.Users.GetByID(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		} else if u.SiteAdmin {
+			t.Error("got site admin, want unchanged")
+		}
+	})
+}
@@ -0,0 +1,84 @@
+// Package groups maps the groups users are members of on SAML and OpenID Connect identity
+// providers to Sourcegraph organizations, site admin status and repository permissions.
+package groups
+
+import (
+	"context"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbutil"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+)
+
+// Provider is an implementation of AuthzProvider that grants users read access to the
+// repositories their identity provider groups are mapped to, in addition to the
+// permissions of the code host provided by the wrapped authz.Provider.
+type Provider struct {
+	authz.Provider
+	store *Store
+}
+
+var _ authz.Provider = ((*Provider)(nil))
+
+// NewProvider returns a new group authorization provider that extends the repository
+// permissions of the given provider with those granted by the groups of users stored
+// in the given database.
+func NewProvider(p authz.Provider, db dbutil.DB) *Provider {
+	return &Provider{
+		Provider: p,
+		store:    NewStore(db, time.Now),
+	}
+}
+
+// RepoPerms returns the permissions the given external account has in relation to the given set of
+// repos, according to the wrapped provider, with read access added to the repos the groups of the
+// account's user are mapped to. Users without an account on the code host are identified by the actor
+// of ctx, since the groups are those of their identity provider account. Anonymous users only have the
+// permissions of the wrapped provider.
+func (p *Provider) RepoPerms(ctx context.Context, acct *extsvc.ExternalAccount, repos []*types.Repo) ([]authz.RepoPerms, error) {
+	perms, err := p.Provider.RepoPerms(ctx, acct, repos)
+	if err != nil {
+		return perms, err
+	}
+
+	userID := actor.FromContext(ctx).UID
+	if acct != nil {
+		userID = acct.UserID
+	}
+
+	if userID == 0 {
+		return perms, nil
+	}
+
+	names, err := p.store.UserRepoNames(ctx, userID)
+	if err != nil || len(names) == 0 {
+		return perms, err
+	}
+
+	granted := make(map[api.RepoName]bool, len(names))
+	for _, name := range names {
+		granted[api.RepoName(name)] = true
+	}
+
+	index := make(map[api.RepoID]int, len(perms))
+	for i, rp := range perms {
+		index[rp.Repo.ID] = i
+	}
+
+	for _, r := range repos {
+		if !granted[r.Name] {
+			continue
+		}
+		if i, ok := index[r.ID]; ok {
+			perms[i].Perms |= authz.Read
+		} else {
+			perms = append(perms, authz.RepoPerms{Repo: r, Perms: authz.Read})
+		}
+	}
+
+	return perms, nil
+}
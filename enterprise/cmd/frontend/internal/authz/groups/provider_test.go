@@ -0,0 +1,112 @@
+package groups
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtesting"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+)
+
+// fakeProvider is an authz.Provider that grants the given permissions on the repos with
+// the given names to any account.
+type fakeProvider map[string]authz.Perms
+
+func (p fakeProvider) RepoPerms(_ context.Context, _ *extsvc.ExternalAccount, repos []*types.Repo) ([]authz.RepoPerms, error) {
+	var perms []authz.RepoPerms
+	for _, r := range repos {
+		if ps, ok := p[string(r.Name)]; ok {
+			perms = append(perms, authz.RepoPerms{Repo: r, Perms: ps})
+		}
+	}
+	return perms, nil
+}
+
+func (fakeProvider) FetchAccount(context.Context, *types.User, []*extsvc.ExternalAccount) (*extsvc.ExternalAccount, error) {
+	return nil, nil
+}
+
+func (fakeProvider) ServiceType() string { return "gitlab" }
+func (fakeProvider) ServiceID() string   { return "https://gitlab.example.com/" }
+func (fakeProvider) Validate() []string  { return nil }
+
+func TestProvider_RepoPerms(t *testing.T) {
+	ctx := dbtesting.TestContext(t)
+
+	users := createUsers(ctx, t, "carol", "dave")
+	carol, dave := users[0], users[1]
+
+	repos := []*types.Repo{
+		{ID: 1, Name: "gitlab.example.com/a"},
+		{ID: 2, Name: "gitlab.example.com/b"},
+		{ID: 3, Name: "gitlab.example.com/c"},
+	}
+
+	p := NewProvider(fakeProvider{"gitlab.example.com/a": authz.Read, "gitlab.example.com/b": authz.None}, dbconn.Global)
+	if err := p.store.SetUserGroups(ctx, carol.ID, "saml", "https://idp.example.com", []string{"engineering"}, []string{"gitlab.example.com/b", "gitlab.example.com/c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	codeHostPerms := []authz.RepoPerms{
+		{Repo: repos[0], Perms: authz.Read},
+		{Repo: repos[1], Perms: authz.None},
+	}
+
+	for _, tc := range []struct {
+		name  string
+		actor *actor.Actor
+		acct  *extsvc.ExternalAccount
+		want  []authz.RepoPerms
+	}{
+		{
+			name: "anonymous user only has the permissions of the code host",
+			want: codeHostPerms,
+		},
+		{
+			name:  "user without an account on the code host can read the repos their groups are mapped to",
+			actor: actor.FromUser(carol.ID),
+			want: []authz.RepoPerms{
+				{Repo: repos[0], Perms: authz.Read},
+				{Repo: repos[1], Perms: authz.Read},
+				{Repo: repos[2], Perms: authz.Read},
+			},
+		},
+		{
+			name: "user can read the repos their groups are mapped to",
+			acct: &extsvc.ExternalAccount{UserID: carol.ID},
+			want: []authz.RepoPerms{
+				{Repo: repos[0], Perms: authz.Read},
+				{Repo: repos[1], Perms: authz.Read},
+				{Repo: repos[2], Perms: authz.Read},
+			},
+		},
+		{
+			name: "user without groups only has the permissions of the code host",
+			acct: &extsvc.ExternalAccount{UserID: dave.ID},
+			want: codeHostPerms,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := tc.actor
+			if a == nil {
+				a = &actor.Actor{} // Anonymous, unless an account is given.
+			}
+			ctx := actor.WithActor(ctx, a)
+
+			have, err := p.RepoPerms(ctx, tc.acct, repos)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(have, tc.want) {
+				t.Error(cmp.Diff(have, tc.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package groups
+
+import (
+	"context"
+	"time"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/lib/pq"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbutil"
+)
+
+// A Store of the groups users are members of on the identity providers they signed in
+// with, and of the repositories these groups grant them access to.
+type Store struct {
+	db    dbutil.DB
+	clock func() time.Time
+}
+
+// NewStore returns a Store of the groups of users in the given database.
+func NewStore(db dbutil.DB, clock func() time.Time) *Store {
+	return &Store{db: db, clock: clock}
+}
+
+// SetUserGroups replaces the groups of the given user on the identity provider with the
+// given service type and ID, and the names of the repositories they grant access to.
+func (s *Store) SetUserGroups(ctx context.Context, userID int32, serviceType, serviceID string, groups, repos []string) error {
+	if groups == nil {
+		groups = []string{}
+	}
+	if repos == nil {
+		repos = []string{}
+	}
+
+	q := sqlf.Sprintf(
+		setUserGroupsQueryFmtStr,
+		userID,
+		serviceType,
+		serviceID,
+		pq.Array(groups),
+		pq.Array(repos),
+		s.clock(),
+	)
+
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+const setUserGroupsQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/groups/store.go:Store.SetUserGroups
+INSERT INTO user_idp_groups
+  (user_id, service_type, service_id, groups, repos, updated_at)
+VALUES
+  (%s, %s, %s, %s, %s, %s)
+ON CONFLICT (user_id, service_type, service_id) DO UPDATE SET
+  groups = excluded.groups,
+  repos = excluded.repos,
+  updated_at = excluded.updated_at
+`
+
+// UserRepoNames returns the names of the repositories the groups of the given user on
+// all identity providers grant them access to.
+func (s *Store) UserRepoNames(ctx context.Context, userID int32) ([]string, error) {
+	q := sqlf.Sprintf(userRepoNamesQueryFmtStr, userID)
+
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+const userRepoNamesQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/groups/store.go:Store.UserRepoNames
+SELECT DISTINCT unnest(repos) FROM user_idp_groups WHERE user_id = %s
+`
@@ -0,0 +1,75 @@
+package groups
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtesting"
+)
+
+func init() {
+	dbtesting.DBNameSuffix = "authzgroups"
+}
+
+func TestStore(t *testing.T) {
+	ctx := dbtesting.TestContext(t)
+
+	users := createUsers(ctx, t, "alice", "bob")
+	alice, bob := users[0].ID, users[1].ID
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	s := NewStore(dbconn.Global, func() time.Time { return now })
+
+	for _, step := range []struct {
+		user      int32
+		serviceID string
+		groups    []string
+		repos     []string
+	}{
+		{alice, "https://idp.example.com", []string{"engineering"}, []string{"a", "b"}},
+		{alice, "https://idp.example.org", []string{"sales"}, []string{"b", "c"}},
+		{bob, "https://idp.example.com", []string{"engineering"}, []string{"a", "b"}},
+		{bob, "https://idp.example.com", nil, nil}, // Replaces the groups of bob.
+	} {
+		if err := s.SetUserGroups(ctx, step.user, "saml", step.serviceID, step.groups, step.repos); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		user int32
+		want []string
+	}{
+		{alice, []string{"a", "b", "c"}},
+		{bob, nil},
+	} {
+		have, err := s.UserRepoNames(ctx, tc.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(have)
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("user %d: got repos %q, want %q", tc.user, have, tc.want)
+		}
+	}
+}
+
+func createUsers(ctx context.Context, t *testing.T, usernames ...string) []*types.User {
+	t.Helper()
+
+	users := make([]*types.User, 0, len(usernames))
+	for _, username := range usernames {
+		u, err := db.Users.Create(ctx, db.NewUser{Username: username})
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, u)
+	}
+	return users
+}
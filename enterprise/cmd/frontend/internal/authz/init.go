@@ -6,15 +6,21 @@ import (
 	"fmt"
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	db_ "github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/schema"
 	log15 "gopkg.in/inconshreveable/log15.v2"
 )
 
 type ExternalServicesStore interface {
-	ListGitLabConnections(context.Context) ([]*schema.GitLabConnection, error)
+	// List is used to list the external services whose authz providers make
+	// requests with the service's own credentials, which must share the rate
+	// limiter of the external service with the given ID.
+	List(context.Context, db_.ExternalServicesListOptions) ([]*types.ExternalService, error)
 	ListGitHubConnections(context.Context) ([]*schema.GitHubConnection, error)
-	ListBitbucketServerConnections(context.Context) ([]*schema.BitbucketServerConnection, error)
+	ListGitoliteConnections(context.Context) ([]*schema.GitoliteConnection, error)
+	ListOtherExternalServicesConnections(context.Context) ([]*schema.OtherExternalServiceConnection, error)
 }
 
 // ProvidersFromConfig returns the set of permission-related providers derived from the site config.
@@ -25,7 +31,7 @@ func ProvidersFromConfig(
 	ctx context.Context,
 	cfg *conf.Unified,
 	s ExternalServicesStore,
-	db *sql.DB, // Needed by Bitbucket Server authz provider
+	db *sql.DB, // Needed by the authz providers to store permissions
 ) (
 	allowAccessByDefault bool,
 	authzProviders []authz.Provider,
@@ -40,10 +46,10 @@ func ProvidersFromConfig(
 		}
 	}()
 
-	if gitlabs, err := s.ListGitLabConnections(ctx); err != nil {
+	if gitlabs, err := s.List(ctx, db_.ExternalServicesListOptions{Kinds: []string{"GITLAB"}}); err != nil {
 		seriousProblems = append(seriousProblems, fmt.Sprintf("Could not load GitLab external service configs: %s", err))
 	} else {
-		glp, glproblems, glwarnings := gitlabProviders(ctx, cfg, gitlabs)
+		glp, glproblems, glwarnings := gitlabProviders(ctx, db, cfg, gitlabs)
 		authzProviders = append(authzProviders, glp...)
 		seriousProblems = append(seriousProblems, glproblems...)
 		warnings = append(warnings, glwarnings...)
@@ -52,13 +58,13 @@ func ProvidersFromConfig(
 	if githubs, err := s.ListGitHubConnections(ctx); err != nil {
 		seriousProblems = append(seriousProblems, fmt.Sprintf("Could not load GitHub external service configs: %s", err))
 	} else {
-		ghp, ghproblems, ghwarnings := githubProviders(ctx, githubs)
+		ghp, ghproblems, ghwarnings := githubProviders(ctx, db, githubs)
 		authzProviders = append(authzProviders, ghp...)
 		seriousProblems = append(seriousProblems, ghproblems...)
 		warnings = append(warnings, ghwarnings...)
 	}
 
-	if bitbucketServers, err := s.ListBitbucketServerConnections(ctx); err != nil {
+	if bitbucketServers, err := s.List(ctx, db_.ExternalServicesListOptions{Kinds: []string{"BITBUCKETSERVER"}}); err != nil {
 		seriousProblems = append(seriousProblems, fmt.Sprintf("Could not load Bitbucket Server external service configs: %s", err))
 	} else {
 		ps, problems, warns := bitbucketServerProviders(ctx, db, cfg, bitbucketServers)
@@ -67,5 +73,27 @@ func ProvidersFromConfig(
 		warnings = append(warnings, warns...)
 	}
 
+	if bitbucketClouds, err := s.List(ctx, db_.ExternalServicesListOptions{Kinds: []string{"BITBUCKETCLOUD"}}); err != nil {
+		seriousProblems = append(seriousProblems, fmt.Sprintf("Could not load Bitbucket Cloud external service configs: %s", err))
+	} else {
+		ps, problems, warns := bitbucketCloudProviders(ctx, db, cfg, bitbucketClouds)
+		authzProviders = append(authzProviders, ps...)
+		seriousProblems = append(seriousProblems, problems...)
+		warnings = append(warnings, warns...)
+	}
+
+	if gitolites, err := s.ListGitoliteConnections(ctx); err != nil {
+		seriousProblems = append(seriousProblems, fmt.Sprintf("Could not load Gitolite external service configs: %s", err))
+	} else if others, err := s.ListOtherExternalServicesConnections(ctx); err != nil {
+		seriousProblems = append(seriousProblems, fmt.Sprintf("Could not load other external service configs: %s", err))
+	} else {
+		ps, problems, warns := explicitProviders(ctx, db, gitolites, others)
+		authzProviders = append(authzProviders, ps...)
+		seriousProblems = append(seriousProblems, problems...)
+		warnings = append(warnings, warns...)
+	}
+
+	authzProviders = groupProviders(db, cfg, authzProviders)
+
 	return allowAccessByDefault, authzProviders, seriousProblems, warnings
 }
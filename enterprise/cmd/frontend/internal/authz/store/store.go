@@ -1,4 +1,6 @@
-package bitbucketserver
+// Package store contains the Postgres-backed store of the repository permissions
+// fetched from code hosts by the authz providers.
+package store
 
 import (
 	"context"
@@ -9,25 +11,27 @@ import (
 
 	"github.com/RoaringBitmap/roaring"
 	"github.com/keegancsmith/sqlf"
+	"github.com/lib/pq"
 	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
 	"github.com/segmentio/fasthash/fnv1"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/db/dbutil"
 	"github.com/sourcegraph/sourcegraph/pkg/trace"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
-// A store of Permissions with in-memory caching, safe for concurrent use.
+// A Store of Permissions with in-memory caching, safe for concurrent use.
 //
 // It leverages Postgres row locking for concurrency control of cache fill events,
-// so that many concurrent requests during an expiration don't overload the Bitbucket Server API.
+// so that many concurrent requests during an expiration don't overload the code host API.
 //
 // The second in-memory read-through layer avoids the round-trip and serialization
 // costs associated with talking to Postgres, further speeding up the steady state
 // operations.
-type store struct {
+type Store struct {
 	db dbutil.DB
 	// Duration after which a given user's cached permissions SHOULD be updated.
 	// Previously cached permissions can still be used.
@@ -35,18 +39,31 @@ type store struct {
 	// Duration after which a given user's cached permissions MUST be updated.
 	// Previously cached permissions can no longer be used.
 	hardTTL time.Duration
-	cache   *cache
+	cache   *Cache
 	clock   func() time.Time
-	block   bool // Perform blocking updates if true.
 	updates chan *Permissions
+
+	// Block makes LoadPermissions wait for expired permissions to be updated
+	// instead of updating them in the background, waiting for a concurrent
+	// update by another process to finish if there is one. Providers which
+	// answer requests from the code host set it, so that a user's first
+	// request doesn't fail with a StalePermissionsError.
+	Block bool
 }
 
-func newStore(db dbutil.DB, ttl, hardTTL time.Duration, clock func() time.Time, cache *cache) *store {
+// Clock returns the current time in UTC, truncated to the precision of Postgres
+// timestamps. It's the clock providers pass to New.
+func Clock() time.Time { return time.Now().UTC().Truncate(time.Microsecond) }
+
+// New returns a Store of the permissions in the given database, which are
+// updated after ttl and must be updated after hardTTL. The given cache, if
+// non-nil, caches them in memory.
+func New(db dbutil.DB, ttl, hardTTL time.Duration, clock func() time.Time, cache *Cache) *Store {
 	if hardTTL < ttl {
 		hardTTL = ttl
 	}
 
-	return &store{
+	return &Store{
 		db:      db,
 		ttl:     ttl,
 		hardTTL: hardTTL,
@@ -55,14 +72,17 @@ func newStore(db dbutil.DB, ttl, hardTTL time.Duration, clock func() time.Time,
 	}
 }
 
-// Permissions of a user to perform an action on the
-// given set of object IDs of the defined type.
+// Permissions of a user to perform an action on the given set of object IDs of
+// the defined type, as determined by the code host with the given service type
+// and ID.
 type Permissions struct {
-	UserID    int32
-	Perm      authz.Perms
-	Type      string
-	IDs       *roaring.Bitmap
-	UpdatedAt time.Time
+	UserID      int32
+	Perm        authz.Perms
+	Type        string
+	ServiceType string
+	ServiceID   string
+	IDs         *roaring.Bitmap
+	UpdatedAt   time.Time
 }
 
 // Expired returns true if these Permissions have elapsed the given ttl.
@@ -95,11 +115,11 @@ type PermissionsUpdateFunc func(context.Context) (ids []uint32, err error)
 // LoadPermissions loads stored permissions into p, calling the given update closure
 // to asynchronously fetch updated permissions when they expire. When there are no
 // valid permissions available (i.e. the first time a user needs them), an error is
-// returned.
+// returned, unless the Store blocks, in which case it waits for them to be fetched.
 //
 // Callers must NOT mutate the resulting Permissions pointer. It's shared across go-routines
 // and it's meant to be read-only. Any write to it is not thread-safe.
-func (s *store) LoadPermissions(
+func (s *Store) LoadPermissions(
 	ctx context.Context,
 	p **Permissions,
 	update PermissionsUpdateFunc,
@@ -111,6 +131,20 @@ func (s *store) LoadPermissions(
 	ctx, save := s.observe(ctx, "LoadPermissions", "")
 	defer func() { save(*p, &err) }()
 
+	if isForcedUpdate(ctx) {
+		updated := **p
+		updated.IDs = nil
+
+		if err = s.update(ctx, &updated, update, true); err != nil {
+			return err
+		}
+
+		s.cache.update(&updated)
+		*p = &updated
+
+		return nil
+	}
+
 	now := s.clock()
 
 	// Do we have valid permissions cached in-memory?
@@ -130,11 +164,11 @@ func (s *store) LoadPermissions(
 	expired := **p
 	expired.IDs = nil
 
-	if !s.block { // Non blocking code path
+	if !s.Block { // Non blocking code path
 		go func(expired *Permissions) {
-			err := s.update(ctx, expired, update)
+			err := s.update(ctx, expired, update, false)
 			if err != nil && err != errLockNotAvailable {
-				log15.Error("bitbucketserver.authz.store.update", "error", err)
+				log15.Error("authz.store.update", "serviceType", expired.ServiceType, "serviceID", expired.ServiceID, "error", err)
 			}
 		}(&expired)
 
@@ -147,7 +181,7 @@ func (s *store) LoadPermissions(
 	}
 
 	// Blocking code path
-	switch err = s.update(ctx, &expired, update); {
+	switch err = s.update(ctx, &expired, update, false); {
 	case err == nil:
 	case err == errLockNotAvailable:
 		if (*p).Expired(s.hardTTL, now) {
@@ -162,6 +196,21 @@ func (s *store) LoadPermissions(
 	return nil
 }
 
+type forcedUpdateKey struct{}
+
+// WithForcedUpdate returns a context which makes LoadPermissions update the
+// permissions from the code host and wait for the update to complete, whether
+// they expired or not. It's used to sync permissions in the background, ahead
+// of the requests that need them.
+func WithForcedUpdate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcedUpdateKey{}, true)
+}
+
+func isForcedUpdate(ctx context.Context) bool {
+	forced, _ := ctx.Value(forcedUpdateKey{}).(bool)
+	return forced
+}
+
 // StalePermissionsError is returned by LoadPermissions when the stored
 // permissions are stale (e.g. the first time a user needs them and they haven't
 // been fetched yet). Callers should pass this error up to the user and show it
@@ -179,8 +228,9 @@ var errLockNotAvailable = errors.New("lock not available")
 
 // lock uses Postgres advisory locks to acquire an exclusive lock over the
 // given Permissions. Concurrent processes that call this method while a lock is
-// already held by another process will have errLockNotAvailable returned.
-func (s *store) lock(ctx context.Context, p *Permissions) (err error) {
+// already held by another process will have errLockNotAvailable returned, unless
+// wait is true, in which case they wait for the lock to be released.
+func (s *Store) lock(ctx context.Context, p *Permissions, wait bool) (err error) {
 	ctx, save := s.observe(ctx, "lock", "")
 	defer save(p, &err)
 
@@ -188,7 +238,7 @@ func (s *store) lock(ctx context.Context, p *Permissions) (err error) {
 		return errors.Errorf("store.lock must be called inside a transaction")
 	}
 
-	q := lockQuery(p)
+	q := lockQuery(p, wait)
 
 	var rows *sql.Rows
 	rows, err = s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
@@ -218,7 +268,7 @@ func (s *store) lock(ctx context.Context, p *Permissions) (err error) {
 
 var lockNamespace = int32(fnv1.HashString32("perms"))
 
-func lockQuery(p *Permissions) *sqlf.Query {
+func lockQuery(p *Permissions, wait bool) *sqlf.Query {
 	// Postgres advisory lock ids are a global namespace within one database.
 	// It's very unlikely that another part of our application uses a lock
 	// namespace identicaly to this one. It's equally unlikely that there are
@@ -226,20 +276,29 @@ func lockQuery(p *Permissions) *sqlf.Query {
 	// guarantees would be violated, since those two different users would simply
 	// have to wait on the other's update to finish, using stale permissions until
 	// it would.
-	lockID := int32(fnv1.HashString32(fmt.Sprintf("%d:%s:%s", p.UserID, p.Perm, p.Type)))
+	lockID := int32(fnv1.HashString32(fmt.Sprintf("%d:%s:%s:%s:%s", p.UserID, p.Perm, p.Type, p.ServiceType, p.ServiceID)))
+	fmtStr := lockQueryFmtStr
+	if wait {
+		fmtStr = waitLockQueryFmtStr
+	}
 	return sqlf.Sprintf(
-		lockQueryFmtStr,
+		fmtStr,
 		lockNamespace,
 		lockID,
 	)
 }
 
 const lockQueryFmtStr = `
--- source: enterprise/cmd/frontend/internal/authz/bitbucketserver/store.go:store.lock
+-- source: enterprise/cmd/frontend/internal/authz/store/store.go:Store.lock
 SELECT pg_try_advisory_xact_lock(%s, %s)
 `
 
-func (s *store) load(ctx context.Context, p *Permissions) (err error) {
+const waitLockQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/store/store.go:Store.lock
+SELECT true FROM pg_advisory_xact_lock(%s, %s)
+`
+
+func (s *Store) load(ctx context.Context, p *Permissions) (err error) {
 	ctx, save := s.observe(ctx, "load", "")
 	defer save(p, &err)
 
@@ -277,17 +336,23 @@ func loadQuery(p *Permissions) *sqlf.Query {
 		p.UserID,
 		p.Perm.String(),
 		p.Type,
+		p.ServiceType,
+		p.ServiceID,
 	)
 }
 
 const loadQueryFmtStr = `
--- source: enterprise/cmd/frontend/internal/authz/bitbucketserver/store.go:store.load
+-- source: enterprise/cmd/frontend/internal/authz/store/store.go:Store.load
 SELECT object_ids, updated_at
 FROM user_permissions
 WHERE user_id = %s AND permission = %s AND object_type = %s
+AND service_type = %s AND service_id = %s
 `
 
-func (s *store) update(ctx context.Context, p *Permissions, update PermissionsUpdateFunc) (err error) {
+// update calls the given update closure and stores the permissions it returns,
+// unless another process updated them in the meantime and they haven't expired
+// yet. A forced update ignores their expiry.
+func (s *Store) update(ctx context.Context, p *Permissions, update PermissionsUpdateFunc, force bool) (err error) {
 	ctx, save := s.observe(ctx, "update", "")
 	defer save(p, &err)
 
@@ -314,7 +379,7 @@ func (s *store) update(ctx context.Context, p *Permissions, update PermissionsUp
 	}()
 
 	// Make another store with this underlying transaction.
-	txs := store{db: tx, clock: s.clock}
+	txs := Store{db: tx, clock: s.clock}
 
 	// We're here because we need to update our permissions. In order
 	// to prevent multiple concurrent (and distributed) cache fills,
@@ -323,8 +388,9 @@ func (s *store) update(ctx context.Context, p *Permissions, update PermissionsUp
 	// automatically released when the transaction finishes.
 	//
 	// If another processes is updating these permissions, we abort and return
-	// stale data.
-	if err = txs.lock(ctx, p); err != nil {
+	// stale data, or wait for it to finish if the store blocks or the update is
+	// forced, since forced updates fail otherwise.
+	if err = txs.lock(ctx, p, s.Block || force); err != nil {
 		return err
 	}
 
@@ -336,7 +402,7 @@ func (s *store) update(ctx context.Context, p *Permissions, update PermissionsUp
 	}
 
 	now := s.clock()
-	if expired = p.Expired(s.ttl, now); !expired { // Valid!
+	if expired = force || p.Expired(s.ttl, now); !expired { // Valid!
 		return nil // Permissions were updated by another process.
 	}
 
@@ -354,7 +420,105 @@ func (s *store) update(ctx context.Context, p *Permissions, update PermissionsUp
 	return txs.upsert(ctx, p)
 }
 
-func (s *store) tx(ctx context.Context) (*sql.Tx, error) {
+// Repos returns all the repositories of the code host with the given service
+// type and ID. Providers check permissions on all of them when updating the
+// Permissions of a user, rather than only on the repositories of the request at
+// hand, since the stored Permissions are used for every later request.
+func (s *Store) Repos(ctx context.Context, serviceType, serviceID string) (repos []*types.Repo, err error) {
+	tr, ctx := trace.New(ctx, "authz.store.Repos", serviceID)
+	defer func() {
+		tr.LogFields(otlog.Int("repos.count", len(repos)))
+		if err != nil {
+			tr.SetError(err)
+		}
+		tr.Finish()
+	}()
+
+	q := sqlf.Sprintf(reposQueryFmtStr, serviceType, serviceID)
+
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &types.Repo{}
+		if err = rows.Scan(&r.ID, &r.Name, &r.ExternalRepo.ID); err != nil {
+			return nil, err
+		}
+		r.ExternalRepo.ServiceType = serviceType
+		r.ExternalRepo.ServiceID = serviceID
+		repos = append(repos, r)
+	}
+
+	return repos, rows.Err()
+}
+
+const reposQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/store/store.go:Store.Repos
+SELECT id, name, external_id
+FROM repo
+WHERE deleted_at IS NULL
+AND external_service_type = %s AND external_service_id = %s
+AND external_id IS NOT NULL
+`
+
+// ReposAddedAfter returns those of the given repositories which were added after
+// the given time, such as the repositories which Permissions updated at that time
+// don't cover.
+func (s *Store) ReposAddedAfter(ctx context.Context, repos []*types.Repo, t time.Time) (added []*types.Repo, err error) {
+	tr, ctx := trace.New(ctx, "authz.store.ReposAddedAfter", "")
+	defer func() {
+		tr.LogFields(otlog.Int("repos.count", len(repos)), otlog.Int("added.count", len(added)))
+		if err != nil {
+			tr.SetError(err)
+		}
+		tr.Finish()
+	}()
+
+	ids := make([]int64, 0, len(repos))
+	for _, r := range repos {
+		ids = append(ids, int64(r.ID))
+	}
+
+	q := sqlf.Sprintf(reposAddedAfterQueryFmtStr, pq.Array(ids), t)
+
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	isAdded := make(map[api.RepoID]bool)
+	for rows.Next() {
+		var id api.RepoID
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		isAdded[id] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range repos {
+		if isAdded[r.ID] {
+			added = append(added, r)
+		}
+	}
+
+	return added, nil
+}
+
+const reposAddedAfterQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/store/store.go:Store.ReposAddedAfter
+SELECT id FROM repo
+WHERE id = ANY(%s) AND created_at > %s
+`
+
+func (s *Store) tx(ctx context.Context) (*sql.Tx, error) {
 	switch t := s.db.(type) {
 	case *sql.Tx:
 		return t, nil
@@ -365,7 +529,7 @@ func (s *store) tx(ctx context.Context) (*sql.Tx, error) {
 	}
 }
 
-func (s *store) upsert(ctx context.Context, p *Permissions) (err error) {
+func (s *Store) upsert(ctx context.Context, p *Permissions) (err error) {
 	ctx, save := s.observe(ctx, "upsert", "")
 	defer save(p, &err)
 
@@ -383,7 +547,7 @@ func (s *store) upsert(ctx context.Context, p *Permissions) (err error) {
 	return rows.Close()
 }
 
-func (s *store) upsertQuery(p *Permissions) (*sqlf.Query, error) {
+func (s *Store) upsertQuery(p *Permissions) (*sqlf.Query, error) {
 	ids, err := p.IDs.ToBytes()
 	if err != nil {
 		return nil, err
@@ -398,17 +562,19 @@ func (s *store) upsertQuery(p *Permissions) (*sqlf.Query, error) {
 		p.UserID,
 		p.Perm.String(),
 		p.Type,
+		p.ServiceType,
+		p.ServiceID,
 		ids,
 		p.UpdatedAt.UTC(),
 	), nil
 }
 
 const upsertQueryFmtStr = `
--- source: enterprise/cmd/frontend/internal/authz/bitbucketserver/store.go:store.upsert
+-- source: enterprise/cmd/frontend/internal/authz/store/store.go:Store.upsert
 INSERT INTO user_permissions
-  (user_id, permission, object_type, object_ids, updated_at)
+  (user_id, permission, object_type, service_type, service_id, object_ids, updated_at)
 VALUES
-  (%s, %s, %s, %s, %s)
+  (%s, %s, %s, %s, %s, %s, %s)
 ON CONFLICT ON CONSTRAINT
   user_permissions_perm_object_unique
 DO UPDATE SET
@@ -416,9 +582,9 @@ DO UPDATE SET
   updated_at = excluded.updated_at
 `
 
-func (s *store) observe(ctx context.Context, family, title string) (context.Context, func(*Permissions, *error)) {
+func (s *Store) observe(ctx context.Context, family, title string) (context.Context, func(*Permissions, *error)) {
 	began := s.clock()
-	tr, ctx := trace.New(ctx, "bitbucket.authz.store."+family, title)
+	tr, ctx := trace.New(ctx, "authz.store."+family, title)
 
 	return ctx, func(ps *Permissions, err *error) {
 		now := s.clock()
@@ -429,6 +595,8 @@ func (s *store) observe(ctx context.Context, family, title string) (context.Cont
 			otlog.Int32("Permissions.UserID", ps.UserID),
 			otlog.String("Permissions.Perm", string(ps.Perm)),
 			otlog.String("Permissions.Type", ps.Type),
+			otlog.String("Permissions.ServiceType", ps.ServiceType),
+			otlog.String("Permissions.ServiceID", ps.ServiceID),
 		}
 
 		if ps.IDs != nil {
@@ -449,26 +617,29 @@ func (s *store) observe(ctx context.Context, family, title string) (context.Cont
 	}
 }
 
-// A store's in-memory read-through cache used in LoadPermissions.
-type cache struct {
+// A Cache is a Store's in-memory read-through cache used in LoadPermissions.
+type Cache struct {
 	mu    sync.RWMutex
 	cache map[cacheKey]*Permissions
 }
 
-func newCache() *cache {
-	return &cache{cache: map[cacheKey]*Permissions{}}
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{cache: map[cacheKey]*Permissions{}}
 }
 
 type cacheKey struct {
-	UserID int32
-	Perm   authz.Perms
-	Type   string
+	UserID      int32
+	Perm        authz.Perms
+	Type        string
+	ServiceType string
+	ServiceID   string
 }
 
 // load sets the given Permissions pointer with a matching cached
 // Permissions. If no cached Permissions are found or if they are
 // now expired,
-func (c *cache) load(p **Permissions) (hit bool) {
+func (c *Cache) load(p **Permissions) (hit bool) {
 	if c == nil {
 		return false
 	}
@@ -486,7 +657,7 @@ func (c *cache) load(p **Permissions) (hit bool) {
 	return hit
 }
 
-func (c *cache) update(p *Permissions) {
+func (c *Cache) update(p *Permissions) {
 	if c == nil {
 		return
 	}
@@ -503,8 +674,10 @@ func newCacheKey(p *Permissions) cacheKey {
 	}
 
 	return cacheKey{
-		UserID: p.UserID,
-		Perm:   p.Perm,
-		Type:   p.Type,
+		UserID:      p.UserID,
+		Perm:        p.Perm,
+		Type:        p.Type,
+		ServiceType: p.ServiceType,
+		ServiceID:   p.ServiceID,
 	}
 }
@@ -1,4 +1,4 @@
-package bitbucketserver
+package store
 
 import (
 	"context"
@@ -35,13 +35,15 @@ func BenchmarkStore(b *testing.B) {
 	ctx := context.Background()
 
 	b.Run("ttl=0", func(b *testing.B) {
-		s := newStore(db, 0, DefaultHardTTL, clock, newCache())
-		s.block = true
+		s := New(db, 0, DefaultHardTTL, Clock, NewCache())
+		s.Block = true
 
 		ps := &Permissions{
-			UserID: 99,
-			Perm:   authz.Read,
-			Type:   "repos",
+			UserID:      99,
+			Perm:        authz.Read,
+			Type:        "repos",
+			ServiceType: "bitbucketServer",
+			ServiceID:   "https://bitbucket.example.com/",
 		}
 
 		for i := 0; i < b.N; i++ {
@@ -53,13 +55,15 @@ func BenchmarkStore(b *testing.B) {
 	})
 
 	b.Run("ttl=60s/no-in-memory-cache", func(b *testing.B) {
-		s := newStore(db, 60*time.Second, DefaultHardTTL, clock, nil)
-		s.block = true
+		s := New(db, 60*time.Second, DefaultHardTTL, Clock, nil)
+		s.Block = true
 
 		ps := &Permissions{
-			UserID: 99,
-			Perm:   authz.Read,
-			Type:   "repos",
+			UserID:      99,
+			Perm:        authz.Read,
+			Type:        "repos",
+			ServiceType: "bitbucketServer",
+			ServiceID:   "https://bitbucket.example.com/",
 		}
 
 		for i := 0; i < b.N; i++ {
@@ -71,13 +75,15 @@ func BenchmarkStore(b *testing.B) {
 	})
 
 	b.Run("ttl=60s/in-memory-cache", func(b *testing.B) {
-		s := newStore(db, 60*time.Second, DefaultHardTTL, clock, newCache())
-		s.block = true
+		s := New(db, 60*time.Second, DefaultHardTTL, Clock, NewCache())
+		s.Block = true
 
 		ps := &Permissions{
-			UserID: 99,
-			Perm:   authz.Read,
-			Type:   "repos",
+			UserID:      99,
+			Perm:        authz.Read,
+			Type:        "repos",
+			ServiceType: "bitbucketServer",
+			ServiceID:   "https://bitbucket.example.com/",
 		}
 
 		for i := 0; i < b.N; i++ {
@@ -106,7 +112,7 @@ func testStore(db *sql.DB) func(*testing.T) {
 			return time.Unix(0, atomic.LoadInt64(&now)).Truncate(time.Microsecond)
 		}
 
-		s := newStore(db, ttl, hardTTL, clock, newCache())
+		s := New(db, ttl, hardTTL, clock, NewCache())
 		s.updates = make(chan *Permissions)
 
 		ids := []uint32{1, 2, 3}
@@ -117,8 +123,14 @@ func testStore(db *sql.DB) func(*testing.T) {
 
 		ctx := context.Background()
 
-		ps := &Permissions{UserID: 42, Perm: authz.Read, Type: "repos"}
-		load := func(s *store) (*Permissions, error) {
+		ps := &Permissions{
+			UserID:      42,
+			Perm:        authz.Read,
+			Type:        "repos",
+			ServiceType: "bitbucketServer",
+			ServiceID:   "https://bitbucket.example.com/",
+		}
+		load := func(s *Store) (*Permissions, error) {
 			ps := *ps
 			p := &ps
 			return p, s.LoadPermissions(ctx, &p, update)
@@ -217,7 +229,7 @@ func testStore(db *sql.DB) func(*testing.T) {
 
 			for i := 0; i < cap(ch); i++ {
 				go func(i int) {
-					s := newStore(db, ttl, hardTTL, clock, newCache())
+					s := New(db, ttl, hardTTL, clock, NewCache())
 					s.updates = updates
 					ps, err := load(s)
 					ch <- op{i, ps, err}
@@ -251,5 +263,39 @@ func testStore(db *sql.DB) func(*testing.T) {
 
 			equal(t, "updates", calls, 1)
 		}
+
+		{
+			// Permissions of the same user on another code host are
+			// stored separately.
+			other := *ps
+			other.ServiceID = "https://bitbucket.other.example.com/"
+			p := &other
+
+			err := s.LoadPermissions(ctx, &p, update)
+			equal(t, "err", err, &StalePermissionsError{Permissions: p})
+			equal(t, "ids", array(p.IDs), []uint32(nil))
+		}
+
+		<-s.updates
+
+		ids = append(ids, 8)
+
+		{
+			// Forced updates fetch the permissions from the source of truth
+			// and wait for them, even though they haven't expired. The
+			// updated permissions are cached.
+			s := New(db, ttl, hardTTL, clock, NewCache())
+
+			p := *ps
+			forced := &p
+			err := s.LoadPermissions(WithForcedUpdate(ctx), &forced, update)
+			equal(t, "err", err, nil)
+			equal(t, "ids", array(forced.IDs), ids)
+			equal(t, "cache", s.cache.cache[newCacheKey(forced)], forced)
+
+			loaded, err := load(s)
+			equal(t, "err", err, nil)
+			equal(t, "ids", array(loaded.IDs), ids)
+		}
 	}
 }
@@ -0,0 +1,581 @@
+// Package syncer contains the background syncer of the repository permissions
+// fetched from code hosts by the authz providers.
+package syncer
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/segmentio/fasthash/fnv1"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
+	"github.com/sourcegraph/sourcegraph/pkg/actor"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbutil"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// DefaultInterval is the default interval between the rounds of a Syncer.
+const DefaultInterval = time.Hour
+
+// A Syncer syncs the repository permissions of recently active users and of
+// recently updated repositories from the code hosts in the background, so that
+// the requests which need them don't have to wait for them to be fetched. The
+// status of each sync is recorded in the database.
+//
+// Syncing the permissions of a repository syncs the permissions of all the users
+// with an external account on its code host, since the code host only answers
+// which repositories a given user can read.
+type Syncer struct {
+	db    dbutil.DB
+	clock func() time.Time
+	// activeUsers returns the IDs of the recently active users.
+	activeUsers func() ([]int32, error)
+	// providers returns the authz providers to sync permissions from.
+	providers func() []authz.Provider
+
+	mu        sync.Mutex
+	users     map[int32]bool
+	repos     map[api.RepoID]bool
+	scheduled chan struct{}
+}
+
+// New returns a Syncer which records the status of the syncs in the given
+// database, and syncs the permissions of the users returned by activeUsers.
+func New(db dbutil.DB, activeUsers func() ([]int32, error), clock func() time.Time) *Syncer {
+	return &Syncer{
+		db:          db,
+		clock:       clock,
+		activeUsers: activeUsers,
+		providers: func() []authz.Provider {
+			_, providers := authz.GetProviders()
+			return providers
+		},
+		users:     make(map[int32]bool),
+		repos:     make(map[api.RepoID]bool),
+		scheduled: make(chan struct{}, 1),
+	}
+}
+
+// Run syncs permissions in rounds, every interval, until ctx is canceled. Each
+// round syncs the permissions of the active users and of the repositories updated
+// since the previous round, unless they were synced less than interval ago.
+// Scheduled syncs are run as soon as possible.
+//
+// Run is called on every frontend replica, but only one of them syncs at a time:
+// a round is skipped while another replica holds the lease (see lease). Its due
+// users and repositories are left to the rounds of the replica holding the lease,
+// or to this replica's next round, and its scheduled syncs are retried after
+// leaseRetryInterval.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	since := s.clock().Add(-interval)
+
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	for {
+		due := false
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.scheduled:
+		case <-t.C:
+			due = true
+			t.Reset(interval)
+		}
+
+		release, err := s.lease(ctx)
+		if err != nil {
+			if err != errLeaseNotAvailable {
+				log15.Error("authz.syncer.lease", "error", err)
+			}
+			if s.pending() {
+				time.AfterFunc(leaseRetryInterval, s.notify)
+			}
+			continue
+		}
+
+		var users []int32
+		var repos []api.RepoID
+
+		if due {
+			now := s.clock()
+			if users, repos, err = s.due(ctx, since, interval); err != nil {
+				log15.Error("authz.syncer.due", "error", err)
+			}
+			since = now
+		}
+
+		scheduledUsers, scheduledRepos := s.dequeue()
+		users = append(users, scheduledUsers...)
+		repos = append(repos, scheduledRepos...)
+
+		if err := s.Sync(ctx, users, repos); err != nil {
+			log15.Error("authz.syncer.Sync", "error", err)
+		}
+
+		release()
+	}
+}
+
+// leaseRetryInterval is the interval after which a Syncer which couldn't take
+// the lease retries its scheduled syncs.
+const leaseRetryInterval = time.Minute
+
+var errLeaseNotAvailable = errors.New("lease not available")
+
+var leaseLockNamespace = int32(fnv1.HashString32("perms_syncer"))
+
+// lease takes the lease of a round of syncs, which one Syncer holds at a time
+// across all the frontend replicas, so that they don't fetch the same permissions
+// from the code hosts concurrently. It's a Postgres advisory lock held by a
+// transaction for the duration of the round, which is released when the returned
+// function is called or when the connection is lost. It returns errLeaseNotAvailable
+// if another Syncer holds the lease.
+func (s *Syncer) lease(ctx context.Context) (release func(), err error) {
+	db, ok := s.db.(*sql.DB)
+	if !ok {
+		return nil, errors.New("syncer lease requires a database connection pool")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := sqlf.Sprintf(leaseQueryFmtStr, leaseLockNamespace, 0)
+
+	var locked bool
+	if err = tx.QueryRowContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...).Scan(&locked); err == nil && !locked {
+		err = errLeaseNotAvailable
+	}
+
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return func() { _ = tx.Rollback() }, nil
+}
+
+const leaseQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/syncer/syncer.go:Syncer.lease
+SELECT pg_try_advisory_xact_lock(%s, %s)
+`
+
+// ScheduleUserPermissionsSync schedules the permissions of the given user to be
+// synced as soon as possible.
+func (s *Syncer) ScheduleUserPermissionsSync(ctx context.Context, userID int32) error {
+	s.mu.Lock()
+	s.users[userID] = true
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// ScheduleRepoPermissionsSync schedules the permissions of the given repository
+// to be synced as soon as possible.
+func (s *Syncer) ScheduleRepoPermissionsSync(ctx context.Context, repoID api.RepoID) error {
+	s.mu.Lock()
+	s.repos[repoID] = true
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+func (s *Syncer) notify() {
+	select {
+	case s.scheduled <- struct{}{}:
+	default: // A sync is already scheduled.
+	}
+}
+
+// pending returns true if there are scheduled syncs.
+func (s *Syncer) pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.users) > 0 || len(s.repos) > 0
+}
+
+// dequeue returns and forgets the scheduled syncs.
+func (s *Syncer) dequeue() (users []int32, repos []api.RepoID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.users {
+		users = append(users, id)
+	}
+	for id := range s.repos {
+		repos = append(repos, id)
+	}
+
+	s.users = make(map[int32]bool)
+	s.repos = make(map[api.RepoID]bool)
+
+	return users, repos
+}
+
+// due returns the active users and the repositories updated since the given time,
+// which weren't synced since interval ago.
+func (s *Syncer) due(ctx context.Context, since time.Time, interval time.Duration) (users []int32, repos []api.RepoID, err error) {
+	active, err := s.activeUsers()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "listing active users")
+	}
+
+	syncedBefore := s.clock().Add(-interval)
+
+	q := sqlf.Sprintf(dueUsersQueryFmtStr, pq.Array(active), syncedBefore)
+	if err = s.query(ctx, q, func(sc scanner) error {
+		var id int32
+		if err := sc.Scan(&id); err != nil {
+			return err
+		}
+		users = append(users, id)
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	q = sqlf.Sprintf(dueReposQueryFmtStr, since, syncedBefore)
+	if err = s.query(ctx, q, func(sc scanner) error {
+		var id api.RepoID
+		if err := sc.Scan(&id); err != nil {
+			return err
+		}
+		repos = append(repos, id)
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return users, repos, nil
+}
+
+const dueUsersQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/syncer/syncer.go:Syncer.due
+SELECT users.id FROM users
+LEFT JOIN user_permissions_sync_status status ON status.user_id = users.id
+WHERE users.id = ANY(%s) AND users.deleted_at IS NULL
+AND (status.last_attempted_at IS NULL OR status.last_attempted_at < %s)
+ORDER BY users.id
+`
+
+const dueReposQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/syncer/syncer.go:Syncer.due
+SELECT repo.id FROM repo
+LEFT JOIN repo_permissions_sync_status status ON status.repo_id = repo.id
+WHERE repo.enabled AND repo.deleted_at IS NULL
+AND COALESCE(repo.updated_at, repo.created_at) >= %s
+AND (status.last_attempted_at IS NULL OR status.last_attempted_at < %s)
+ORDER BY repo.id
+`
+
+// Sync syncs the permissions of the given users and repositories from the code
+// hosts of the authz providers, and records the status of each sync. Syncing a
+// user fetches their permissions on all the repositories of each code host they
+// have an account on, so that the stored permissions are complete.
+func (s *Syncer) Sync(ctx context.Context, userIDs []int32, repoIDs []api.RepoID) error {
+	if len(userIDs) == 0 && len(repoIDs) == 0 {
+		return nil
+	}
+
+	providers := s.providers()
+	if len(providers) == 0 {
+		return nil
+	}
+
+	// 🚨 SECURITY: The syncer fetches the permissions of users from the code
+	// hosts, so it must list all repositories regardless of the permissions of
+	// any user.
+	ctx = actor.WithActor(ctx, &actor.Actor{Internal: true})
+
+	all, err := db.Repos.List(ctx, db.ReposListOptions{Enabled: true})
+	if err != nil {
+		return errors.Wrap(err, "listing repos")
+	}
+
+	repos := make(map[api.RepoID]*types.Repo, len(all))
+	reposByServiceID := make(map[string][]*types.Repo)
+	for _, r := range all {
+		repos[r.ID] = r
+		if id := r.ExternalRepo.ServiceID; id != "" {
+			reposByServiceID[id] = append(reposByServiceID[id], r)
+		}
+	}
+
+	users := make(map[int32]bool, len(userIDs))
+	for _, id := range userIDs {
+		users[id] = true
+	}
+
+	repoUsers := make(map[api.RepoID][]int32, len(repoIDs))
+	repoErrs := make(map[api.RepoID]error)
+	for _, id := range repoIDs {
+		r, ok := repos[id]
+		if !ok {
+			continue // Deleted or disabled since it was scheduled.
+		}
+
+		accts, err := db.ExternalAccounts.List(ctx, db.ExternalAccountsListOptions{
+			ServiceType: r.ExternalRepo.ServiceType,
+			ServiceID:   r.ExternalRepo.ServiceID,
+			AnyClientID: true,
+		})
+		if err != nil {
+			repoErrs[id] = errors.Wrap(err, "listing external accounts")
+			continue
+		}
+
+		repoUsers[id] = []int32{}
+		for _, acct := range accts {
+			users[acct.UserID] = true
+			repoUsers[id] = append(repoUsers[id], acct.UserID)
+		}
+	}
+
+	ids := make([]int32, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	userErrs := make(map[int32]error)
+	authorized := make(map[api.RepoID]int, len(repoUsers))
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		perms, err := s.syncUser(ctx, id, providers, reposByServiceID)
+		if errcode.IsNotFound(err) {
+			continue // Deleted since it was scheduled.
+		} else if err != nil {
+			log15.Warn("Could not sync repository permissions of user", "user", id, "error", err)
+			userErrs[id] = err
+		}
+
+		if err := s.recordUserStatus(ctx, id, len(perms), err); err != nil {
+			return err
+		}
+
+		for _, p := range perms {
+			if _, ok := repoUsers[p.Repo.ID]; ok {
+				authorized[p.Repo.ID]++
+			}
+		}
+	}
+
+	for _, id := range repoIDs {
+		uids, ok := repoUsers[id]
+		if !ok && repoErrs[id] == nil {
+			continue
+		}
+
+		err := repoErrs[id]
+		for _, u := range uids {
+			if err == nil {
+				err = userErrs[u]
+			}
+		}
+
+		if err := s.recordRepoStatus(ctx, id, authorized[id], err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncUser syncs the permissions of the given user from the code hosts they have
+// an external account on, and returns the permissions of the user to read their
+// repositories. It keeps syncing from the remaining code hosts when one fails, and
+// returns the first error.
+func (s *Syncer) syncUser(ctx context.Context, userID int32, providers []authz.Provider, repos map[string][]*types.Repo) (perms []authz.RepoPerms, err error) {
+	user, err := db.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accts, err := db.ExternalAccounts.List(ctx, db.ExternalAccountsListOptions{UserID: user.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	var first error
+	for _, p := range providers {
+		rs := repos[p.ServiceID()]
+		if len(rs) == 0 {
+			continue
+		}
+
+		ps, err := s.syncUserProvider(ctx, user, accts, p, rs)
+		if err != nil && first == nil {
+			first = errors.Wrapf(err, "syncing permissions from %s", p.ServiceID())
+		}
+
+		for _, rp := range ps {
+			if rp.Perms.Include(authz.Read) {
+				perms = append(perms, rp)
+			}
+		}
+	}
+
+	return perms, first
+}
+
+func (s *Syncer) syncUserProvider(ctx context.Context, user *types.User, accts []*extsvc.ExternalAccount, p authz.Provider, repos []*types.Repo) ([]authz.RepoPerms, error) {
+	var acct *extsvc.ExternalAccount
+	for _, a := range accts {
+		if a.ServiceID == p.ServiceID() && a.ServiceType == p.ServiceType() {
+			acct = a
+			break
+		}
+	}
+
+	if acct == nil {
+		var err error
+		if acct, err = p.FetchAccount(ctx, user, accts); err != nil {
+			return nil, err
+		}
+
+		// Users without an account on the code host have the permissions of
+		// anonymous users, which are fetched when first needed.
+		if acct == nil {
+			return nil, nil
+		}
+
+		err = db.ExternalAccounts.AssociateUserAndSave(ctx, user.ID, acct.ExternalAccountSpec, acct.ExternalAccountData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.RepoPerms(store.WithForcedUpdate(ctx), acct, repos)
+}
+
+// UserPermissionsLastSyncedAt returns the last time the permissions of the given
+// user were synced successfully, or nil if they never were.
+func (s *Syncer) UserPermissionsLastSyncedAt(ctx context.Context, userID int32) (*time.Time, error) {
+	return s.lastSyncedAt(ctx, sqlf.Sprintf(userLastSyncedAtQueryFmtStr, userID))
+}
+
+// RepoPermissionsLastSyncedAt returns the last time the permissions of the given
+// repository were synced successfully, or nil if they never were.
+func (s *Syncer) RepoPermissionsLastSyncedAt(ctx context.Context, repoID api.RepoID) (*time.Time, error) {
+	return s.lastSyncedAt(ctx, sqlf.Sprintf(repoLastSyncedAtQueryFmtStr, repoID))
+}
+
+func (s *Syncer) lastSyncedAt(ctx context.Context, q *sqlf.Query) (*time.Time, error) {
+	var syncedAt *time.Time
+	err := s.query(ctx, q, func(sc scanner) error {
+		return sc.Scan(&syncedAt)
+	})
+	return syncedAt, err
+}
+
+const userLastSyncedAtQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/syncer/syncer.go:Syncer.UserPermissionsLastSyncedAt
+SELECT last_synced_at FROM user_permissions_sync_status WHERE user_id = %s
+`
+
+const repoLastSyncedAtQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/syncer/syncer.go:Syncer.RepoPermissionsLastSyncedAt
+SELECT last_synced_at FROM repo_permissions_sync_status WHERE repo_id = %s
+`
+
+// recordUserStatus records a sync of the permissions of the given user, which
+// found they can read count repositories, or failed with syncErr.
+func (s *Syncer) recordUserStatus(ctx context.Context, userID int32, count int, syncErr error) error {
+	now := s.clock()
+	syncedAt, lastErr := syncStatus(now, syncErr)
+	q := sqlf.Sprintf(recordUserStatusQueryFmtStr, userID, now, syncedAt, lastErr, count, now)
+	return s.exec(ctx, q)
+}
+
+const recordUserStatusQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/syncer/syncer.go:Syncer.recordUserStatus
+INSERT INTO user_permissions_sync_status
+  (user_id, last_attempted_at, last_synced_at, last_error, repos_count, updated_at)
+VALUES
+  (%s, %s, %s, %s, %s, %s)
+ON CONFLICT (user_id) DO UPDATE SET
+  last_attempted_at = excluded.last_attempted_at,
+  last_synced_at = COALESCE(excluded.last_synced_at, user_permissions_sync_status.last_synced_at),
+  last_error = excluded.last_error,
+  repos_count = excluded.repos_count,
+  updated_at = excluded.updated_at
+`
+
+// recordRepoStatus records a sync of the permissions of the given repository,
+// which found count users can read it, or failed with syncErr.
+func (s *Syncer) recordRepoStatus(ctx context.Context, repoID api.RepoID, count int, syncErr error) error {
+	now := s.clock()
+	syncedAt, lastErr := syncStatus(now, syncErr)
+	q := sqlf.Sprintf(recordRepoStatusQueryFmtStr, repoID, now, syncedAt, lastErr, count, now)
+	return s.exec(ctx, q)
+}
+
+const recordRepoStatusQueryFmtStr = `
+-- source: enterprise/cmd/frontend/internal/authz/syncer/syncer.go:Syncer.recordRepoStatus
+INSERT INTO repo_permissions_sync_status
+  (repo_id, last_attempted_at, last_synced_at, last_error, users_count, updated_at)
+VALUES
+  (%s, %s, %s, %s, %s, %s)
+ON CONFLICT (repo_id) DO UPDATE SET
+  last_attempted_at = excluded.last_attempted_at,
+  last_synced_at = COALESCE(excluded.last_synced_at, repo_permissions_sync_status.last_synced_at),
+  last_error = excluded.last_error,
+  users_count = excluded.users_count,
+  updated_at = excluded.updated_at
+`
+
+// syncStatus returns the last_synced_at and last_error values of a sync attempted
+// at the given time, which failed if err is non-nil.
+func syncStatus(now time.Time, err error) (syncedAt, lastErr interface{}) {
+	if err != nil {
+		return nil, err.Error()
+	}
+	return now, nil
+}
+
+type scanner interface {
+	Scan(...interface{}) error
+}
+
+func (s *Syncer) query(ctx context.Context, q *sqlf.Query, scan func(scanner) error) error {
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *Syncer) exec(ctx context.Context, q *sqlf.Query) error {
+	rows, err := s.db.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
@@ -0,0 +1,279 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/authz"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/pkg/db/dbtesting"
+	"github.com/sourcegraph/sourcegraph/pkg/extsvc"
+)
+
+func init() {
+	dbtesting.DBNameSuffix = "authzsyncer"
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	ctx := dbtesting.TestContext(t)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	clock := func() time.Time { return now }
+
+	p := &fakeProvider{serviceType: "gitlab", serviceID: "https://gitlab.example.com/"}
+
+	alice, err := db.Users.Create(ctx, db.NewUser{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.Users.Create(ctx, db.NewUser{Username: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.ExternalAccounts.AssociateUserAndSave(ctx, alice.ID, extsvc.ExternalAccountSpec{
+		ServiceType: p.serviceType,
+		ServiceID:   p.serviceID,
+		AccountID:   "1",
+	}, extsvc.ExternalAccountData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos := map[string]*types.Repo{}
+	for _, r := range []struct{ name, serviceType, serviceID string }{
+		{"gitlab.example.com/a", p.serviceType, p.serviceID},
+		{"gitlab.example.com/b", p.serviceType, p.serviceID},
+		{"github.com/c", "github", "https://github.com/"},
+	} {
+		err := db.Repos.Upsert(ctx, api.InsertRepoOp{
+			Name:    api.RepoName(r.name),
+			Enabled: true,
+			ExternalRepo: api.ExternalRepoSpec{
+				ID:          r.name,
+				ServiceType: r.serviceType,
+				ServiceID:   r.serviceID,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repos[r.name], err = db.Repos.GetByName(ctx, api.RepoName(r.name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, b := repos["gitlab.example.com/a"], repos["gitlab.example.com/b"]
+	p.canRead = map[int32][]*types.Repo{alice.ID: {a}}
+
+	s := New(dbconn.Global, func() ([]int32, error) { return nil, nil }, clock)
+	s.providers = func() []authz.Provider { return []authz.Provider{p} }
+
+	t.Run("syncs users from all the repos of the code host", func(t *testing.T) {
+		if err := s.Sync(ctx, []int32{bob.ID}, []api.RepoID{a.ID}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Alice has an account on the code host of the synced repo, and Bob
+		// doesn't have any, so only the permissions of Alice are fetched.
+		want := map[int32][]*types.Repo{alice.ID: {a, b}}
+		if !reflect.DeepEqual(p.synced, want) {
+			t.Error(cmp.Diff(p.synced, want))
+		}
+
+		for _, id := range []int32{alice.ID, bob.ID} {
+			if have, err := s.UserPermissionsLastSyncedAt(ctx, id); err != nil {
+				t.Fatal(err)
+			} else if have == nil || !have.Equal(now) {
+				t.Errorf("user %d: got last synced at %v, want %v", id, have, now)
+			}
+		}
+
+		if have, err := s.RepoPermissionsLastSyncedAt(ctx, a.ID); err != nil {
+			t.Fatal(err)
+		} else if have == nil || !have.Equal(now) {
+			t.Errorf("got repo last synced at %v, want %v", have, now)
+		}
+
+		if have, err := s.RepoPermissionsLastSyncedAt(ctx, b.ID); err != nil {
+			t.Fatal(err)
+		} else if have != nil {
+			t.Errorf("got repo last synced at %v, want nil", have)
+		}
+
+		status := userStatus(ctx, t, alice.ID)
+		if want := (syncStatusRow{count: 1}); status != want {
+			t.Errorf("got status %+v, want %+v", status, want)
+		}
+	})
+
+	t.Run("records failed syncs", func(t *testing.T) {
+		synced := now
+		now = now.Add(time.Hour)
+		p.err = errors.New("boom")
+		defer func() { p.err = nil }()
+
+		if err := s.Sync(ctx, []int32{alice.ID}, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if have, err := s.UserPermissionsLastSyncedAt(ctx, alice.ID); err != nil {
+			t.Fatal(err)
+		} else if have == nil || !have.Equal(synced) {
+			t.Errorf("got last synced at %v, want %v", have, synced)
+		}
+
+		status := userStatus(ctx, t, alice.ID)
+		if want := "syncing permissions from https://gitlab.example.com/: boom"; status.err != want {
+			t.Errorf("got error %q, want %q", status.err, want)
+		}
+	})
+
+	t.Run("due users and repos", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		s.activeUsers = func() ([]int32, error) { return []int32{alice.ID, bob.ID}, nil }
+
+		users, repoIDs, err := s.due(ctx, now.Add(-24*time.Hour), 30*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := []int32{alice.ID, bob.ID}; !reflect.DeepEqual(users, want) {
+			t.Errorf("got users %v, want %v", users, want)
+		}
+
+		// Repo a was synced, but long enough ago.
+		want := []api.RepoID{a.ID, b.ID, repos["github.com/c"].ID}
+		if !reflect.DeepEqual(repoIDs, want) {
+			t.Errorf("got repos %v, want %v", repoIDs, want)
+		}
+
+		users, _, err = s.due(ctx, now, 3*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 0 {
+			t.Errorf("got users %v, want none synced less than 3h ago", users)
+		}
+	})
+}
+
+func TestSyncer_lease(t *testing.T) {
+	ctx := dbtesting.TestContext(t)
+
+	a := New(dbconn.Global, nil, time.Now)
+	b := New(dbconn.Global, nil, time.Now)
+
+	release, err := a.lease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.lease(ctx); err != errLeaseNotAvailable {
+		t.Fatalf("got err %v, want %v while the lease is held", err, errLeaseNotAvailable)
+	}
+
+	release()
+
+	release, err = b.lease(ctx)
+	if err != nil {
+		t.Fatalf("got err %v, want the lease once released", err)
+	}
+	release()
+}
+
+func TestSyncer_Schedule(t *testing.T) {
+	s := New(nil, nil, time.Now)
+
+	ctx := context.Background()
+	for _, id := range []int32{2, 1, 2} {
+		if err := s.ScheduleUserPermissionsSync(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.ScheduleRepoPermissionsSync(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-s.scheduled:
+	default:
+		t.Fatal("expected a sync to be scheduled")
+	}
+
+	users, repos := s.dequeue()
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+
+	if want := []int32{1, 2}; !reflect.DeepEqual(users, want) {
+		t.Errorf("got users %v, want %v", users, want)
+	}
+	if want := []api.RepoID{3}; !reflect.DeepEqual(repos, want) {
+		t.Errorf("got repos %v, want %v", repos, want)
+	}
+
+	if users, repos = s.dequeue(); len(users) != 0 || len(repos) != 0 {
+		t.Errorf("got users %v and repos %v, want none", users, repos)
+	}
+}
+
+type syncStatusRow struct {
+	count int
+	err   string
+}
+
+func userStatus(ctx context.Context, t *testing.T, userID int32) (row syncStatusRow) {
+	t.Helper()
+
+	var lastErr *string
+	q := `SELECT repos_count, last_error FROM user_permissions_sync_status WHERE user_id = $1`
+	if err := dbconn.Global.QueryRowContext(ctx, q, userID).Scan(&row.count, &lastErr); err != nil {
+		t.Fatal(err)
+	}
+	if lastErr != nil {
+		row.err = *lastErr
+	}
+
+	return row
+}
+
+// fakeProvider is an authz.Provider whose users can read the configured repos.
+type fakeProvider struct {
+	serviceType string
+	serviceID   string
+	canRead     map[int32][]*types.Repo
+	err         error
+	synced      map[int32][]*types.Repo
+}
+
+func (p *fakeProvider) RepoPerms(ctx context.Context, acct *extsvc.ExternalAccount, repos []*types.Repo) ([]authz.RepoPerms, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if p.synced == nil {
+		p.synced = map[int32][]*types.Repo{}
+	}
+	p.synced[acct.UserID] = repos
+
+	var perms []authz.RepoPerms
+	for _, r := range p.canRead[acct.UserID] {
+		perms = append(perms, authz.RepoPerms{Repo: r, Perms: authz.Read})
+	}
+	return perms, nil
+}
+
+func (p *fakeProvider) FetchAccount(context.Context, *types.User, []*extsvc.ExternalAccount) (*extsvc.ExternalAccount, error) {
+	return nil, nil
+}
+
+func (p *fakeProvider) ServiceType() string           { return p.serviceType }
+func (p *fakeProvider) ServiceID() string             { return p.serviceID }
+func (p *fakeProvider) Validate() (problems []string) { return nil }
@@ -23,6 +23,9 @@ import (
 	_ "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/auth"
 	edb "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/db"
 	iauthz "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/explicit"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/store"
+	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/authz/syncer"
 	_ "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/graphqlbackend"
 	"github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/licensing"
 	_ "github.com/sourcegraph/sourcegraph/enterprise/cmd/frontend/internal/registry"
@@ -52,6 +55,14 @@ func main() {
 			}
 		}()
 		go licensing.StartMaxUserCount(&usersStore{})
+
+		permsSyncer := syncer.New(authzDB, graphqlbackend.ListUsersActiveToday, store.Clock)
+		graphqlbackend.PermissionsSyncer = permsSyncer
+		// Every replica runs the syncer, but the rounds take a lease in the
+		// database, so only one of them syncs at a time.
+		go permsSyncer.Run(ctx, syncer.DefaultInterval)
+
+		graphqlbackend.ExplicitPermissions = explicit.NewStore(authzDB)
 	}
 
 	debug, _ := strconv.ParseBool(os.Getenv("DEBUG"))
@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS repo_update_schedules (
+    repo_id integer PRIMARY KEY REFERENCES repo(id) ON DELETE CASCADE,
+    interval_seconds integer NOT NULL,
+    due_at timestamp with time zone NOT NULL,
+    last_updated_at timestamp with time zone,
+    last_changed_at timestamp with time zone,
+    last_error text,
+    updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+COMMIT;
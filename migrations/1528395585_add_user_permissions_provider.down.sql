@@ -0,0 +1,16 @@
+BEGIN;
+
+DELETE FROM user_permissions;
+
+ALTER TABLE user_permissions
+DROP CONSTRAINT user_permissions_perm_object_unique;
+
+ALTER TABLE user_permissions
+DROP COLUMN service_type,
+DROP COLUMN service_id;
+
+ALTER TABLE user_permissions
+ADD CONSTRAINT user_permissions_perm_object_unique
+UNIQUE (user_id, permission, object_type);
+
+COMMIT;
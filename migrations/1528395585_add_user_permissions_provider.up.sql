@@ -0,0 +1,18 @@
+BEGIN;
+
+-- Permissions are now stored per code host. The existing rows were fetched
+-- from Bitbucket Server and are fetched again when they're next needed.
+DELETE FROM user_permissions;
+
+ALTER TABLE user_permissions
+DROP CONSTRAINT user_permissions_perm_object_unique;
+
+ALTER TABLE user_permissions
+ADD COLUMN service_type text NOT NULL,
+ADD COLUMN service_id text NOT NULL;
+
+ALTER TABLE user_permissions
+ADD CONSTRAINT user_permissions_perm_object_unique
+UNIQUE (user_id, permission, object_type, service_type, service_id);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS repo_permissions_sync_status;
+DROP TABLE IF EXISTS user_permissions_sync_status;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_permissions_sync_status (
+    user_id integer PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    last_attempted_at timestamp with time zone NOT NULL,
+    last_synced_at timestamp with time zone,
+    last_error text,
+    repos_count integer NOT NULL DEFAULT 0,
+    updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS repo_permissions_sync_status (
+    repo_id integer PRIMARY KEY REFERENCES repo(id) ON DELETE CASCADE,
+    last_attempted_at timestamp with time zone NOT NULL,
+    last_synced_at timestamp with time zone,
+    last_error text,
+    users_count integer NOT NULL DEFAULT 0,
+    updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS explicit_repo_permissions (
+    repo_id integer NOT NULL REFERENCES repo(id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (repo_id, user_id, permission)
+);
+
+CREATE INDEX IF NOT EXISTS explicit_repo_permissions_user_id ON explicit_repo_permissions(user_id);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_idp_groups (
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    service_type text NOT NULL,
+    service_id text NOT NULL,
+    groups text[] NOT NULL DEFAULT '{}',
+    repos text[] NOT NULL DEFAULT '{}',
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, service_type, service_id)
+);
+
+COMMIT;
@@ -229,6 +229,18 @@
 // 1528395582_add_default_repos.up.sql (80B)
 // 1528395583_add_default_repos_primary_key.down.sql (77B)
 // 1528395583_add_default_repos_primary_key.up.sql (67B)
+// 1528395584_add_repo_update_schedules.down.sql (61B)
+// 1528395584_add_repo_update_schedules.up.sql (403B)
+// 1528395585_add_user_permissions_provider.down.sql (334B)
+// 1528395585_add_user_permissions_provider.up.sql (535B)
+// 1528395586_add_permissions_sync_status.down.sql (119B)
+// 1528395586_add_permissions_sync_status.up.sql (743B)
+// 1528395587_add_explicit_repo_permissions.down.sql (65B)
+// 1528395587_add_explicit_repo_permissions.up.sql (454B)
+// 1528395588_add_user_idp_groups.down.sql (55B)
+// 1528395588_add_user_idp_groups.up.sql (393B)
+// 1528395589_add_users_deactivated_at.down.sql (73B)
+// 1528395589_add_users_deactivated_at.up.sql (101B)
 
 package migrations
 
@@ -4877,6 +4889,246 @@ func _1528395583_add_default_repos_primary_keyUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __1528395584_add_repo_update_schedulesDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x3d\x00\xc2\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x72\x65\x70\x6f\x5f\x75\x70\x64\x61\x74\x65\x5f\x73\x63\x68\x65\x64\x75\x6c\x65\x73\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\x65\x61\xdd\x46\x3d\x00\x00\x00")
+
+func _1528395584_add_repo_update_schedulesDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395584_add_repo_update_schedulesDownSql,
+		"1528395584_add_repo_update_schedules.down.sql",
+	)
+}
+
+func _1528395584_add_repo_update_schedulesDownSql() (*asset, error) {
+	bytes, err := _1528395584_add_repo_update_schedulesDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395584_add_repo_update_schedules.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xf2, 0x86, 0x87, 0x2d, 0xb5, 0xe6, 0x6b, 0x25, 0x45, 0xf3, 0x7d, 0x6e, 0x32, 0x4e, 0x8a, 0x2f, 0xca, 0xec, 0xe5, 0xb4, 0xcf, 0x4e, 0x7, 0xdf, 0xe, 0x6e, 0xfd, 0x4e, 0xde, 0xc2, 0x57, 0xbd}}
+	return a, nil
+}
+
+var __1528395584_add_repo_update_schedulesUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x8e\xcf\x6a\xb4\x30\x14\x47\xf7\x79\x8a\xdf\x52\xe1\x7b\x03\x57\x19\xbd\x7e\x48\xfd\x53\x34\x03\x9d\x95\x04\x73\x19\x05\xc7\x48\x12\x3b\xa5\x4f\x5f\x66\x2c\x43\xe9\xa2\xcc\x32\xe4\xfc\xce\x3d\x07\xfa\x5f\xd4\x89\x10\x69\x4b\x52\x11\x94\x3c\x94\x84\x22\x47\xdd\x28\xd0\x5b\xd1\xa9\x0e\x8e\x57\xdb\x6f\xab\xd1\x81\x7b\x3f\x8c\x6c\xb6\x99\x3d\x22\x01\x60\xff\x9b\x0c\xa6\x25\xf0\x99\x1d\x5e\xdb\xa2\x92\xed\x09\x2f\x74\x42\x4b\x39\xb5\x54\xa7\xb4\x2b\xa2\xc9\xc4\x68\x6a\x64\x54\x92\x22\xa4\xb2\x4b\x65\x46\xff\xee\x9a\xdb\xdc\xbd\xeb\xb9\xf7\x3c\xd8\xc5\xf8\x87\xef\x96\x51\x1f\xcb\x72\xc7\xcc\xc6\xbd\x0e\x08\xd3\x85\x7d\xd0\x97\x15\xd7\x29\x8c\xf7\x27\x3e\xed\xc2\xbf\xe8\x59\xfb\xf0\xdd\x6d\xfe\x9a\xfd\xa0\x87\x51\x2f\xe7\xa7\x69\x76\xce\x3a\x04\xfe\x08\xbb\xe2\x89\x5b\x8f\x44\x64\x94\xcb\x63\xa9\xb0\xd8\x6b\x14\x8b\x38\x11\x22\x6d\xaa\xaa\x50\x89\xf8\x1a\x00\xdb\x99\x0d\x70\x93\x01\x00\x00")
+
+func _1528395584_add_repo_update_schedulesUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395584_add_repo_update_schedulesUpSql,
+		"1528395584_add_repo_update_schedules.up.sql",
+	)
+}
+
+func _1528395584_add_repo_update_schedulesUpSql() (*asset, error) {
+	bytes, err := _1528395584_add_repo_update_schedulesUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395584_add_repo_update_schedules.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x23, 0x1b, 0xe3, 0x2a, 0x5d, 0xc3, 0x3f, 0xf4, 0xdb, 0x34, 0xfa, 0x53, 0x2a, 0x10, 0x8a, 0xc8, 0xb7, 0x66, 0x4c, 0xfd, 0xa0, 0xab, 0x3e, 0xb4, 0xd6, 0xb5, 0x67, 0x2f, 0x63, 0xd2, 0xa7, 0x42}}
+	return a, nil
+}
+
+var __1528395585_add_user_permissions_providerDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\xce\xbd\xca\xc2\x30\x14\xc6\xf1\xfd\x5c\xc5\x19\xdf\x17\x7a\x07\x99\xd2\x26\x4a\x20\x1f\x1a\xd3\x39\x60\x7b\x86\x08\xb6\xb5\x69\x05\xef\x5e\x2c\x82\x43\x05\x75\x7e\x7e\xfc\x79\x4a\xb9\x55\x96\x01\x08\xa9\x65\x90\xb8\xf1\xce\xe0\x9c\x69\x8c\x03\x8d\xe7\x94\x73\xea\xbb\xcc\x00\xb8\x0e\xd2\x63\xe0\xa5\x96\xab\x19\x84\x77\x3b\xac\x9c\x3d\x04\xcf\x95\x0d\x2b\xb0\xb4\x62\x7f\x3c\x51\x33\xc5\xb9\x4b\x97\x99\xbe\x4c\xea\xda\x58\xcc\x34\x5e\x53\x43\x71\xba\x0d\x54\xbc\x5d\x52\xfb\xa9\xc7\x85\xf8\xf1\x21\xd4\x56\xed\x6b\x89\x7f\x8b\x4d\x6d\x81\x2f\x5f\xe0\x93\x3e\x2e\xfd\x33\x80\xca\x19\xa3\x02\x83\xfb\x00\xc3\x32\x22\xb6\x4e\x01\x00\x00")
+
+func _1528395585_add_user_permissions_providerDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395585_add_user_permissions_providerDownSql,
+		"1528395585_add_user_permissions_provider.down.sql",
+	)
+}
+
+func _1528395585_add_user_permissions_providerDownSql() (*asset, error) {
+	bytes, err := _1528395585_add_user_permissions_providerDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395585_add_user_permissions_provider.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x33, 0xb0, 0x59, 0xad, 0xd9, 0xa6, 0x47, 0x64, 0x44, 0x52, 0x1b, 0x3e, 0x16, 0x8c, 0x99, 0xcb, 0x7c, 0x51, 0xbb, 0x1c, 0xcb, 0x99, 0x82, 0xa8, 0x5e, 0xdb, 0xff, 0xc, 0xf4, 0x77, 0xa6, 0x93}}
+	return a, nil
+}
+
+var __1528395585_add_user_permissions_providerUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\x8f\xd1\x6e\xb2\x30\x18\x86\xcf\x7b\x15\xef\xd9\xff\x2f\x41\x6f\x80\x23\x94\x6e\x21\x81\xe2\xb0\x1c\x13\xa4\x9f\xd2\x2d\xb6\xae\x2d\xa2\x77\xbf\x60\x96\x10\xe3\x92\x6d\x87\x4d\x9f\x3c\xef\xf3\xad\xf8\x4b\x26\x62\xc6\x16\x0b\x6c\xc8\x1d\xb5\xf7\xda\x1a\x8f\xd6\x11\x8c\x1d\xe1\x83\x75\xa4\x70\x22\x87\xce\x2a\x42\x6f\x7d\x58\x42\xf6\x04\xba\x68\x1f\xb4\x39\xc0\xd9\xd1\x63\x24\x47\xd8\x53\xe8\x7a\x52\x93\x6b\xef\xec\x11\x2b\x1d\x76\x43\xf7\x4e\x01\x5b\x72\x67\x72\x68\x8d\x42\x3b\x83\x68\x0f\xad\x36\x18\x7b\x32\x08\x3d\x5d\xff\x4d\xa3\x74\x09\x30\x44\x8a\xd4\x92\xa5\x3c\xe7\x92\xe3\xb9\x2a\x0b\x0c\x9e\x5c\x73\x9a\x13\x63\xc6\x92\x5c\xf2\x0a\x32\x59\xe5\xfc\xe1\x9b\xa5\x55\xb9\xc1\xba\x14\x5b\x59\x25\x99\x90\x0f\xc0\xcd\xd5\xd8\xdd\x1b\x75\xa1\x19\x8c\xfe\x18\xe8\x27\x65\x92\xa6\x58\x97\x79\x5d\x08\x78\x72\x67\xdd\x51\x13\xae\x27\x42\x98\x9a\x45\x29\x21\xea\x3c\x8f\xbe\xc3\xb4\xba\x87\x7e\xb7\xf4\x97\x76\x56\x8b\xec\xb5\xe6\xf8\x7f\xbb\x53\xab\x08\x33\x1f\xe1\x0b\x9d\x6a\xa3\xbb\xf6\xf9\xa5\xd5\x53\xcc\xd8\xba\x2c\x8a\x4c\xc6\xec\x73\x00\xe1\x33\xbc\xab\x17\x02\x00\x00")
+
+func _1528395585_add_user_permissions_providerUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395585_add_user_permissions_providerUpSql,
+		"1528395585_add_user_permissions_provider.up.sql",
+	)
+}
+
+func _1528395585_add_user_permissions_providerUpSql() (*asset, error) {
+	bytes, err := _1528395585_add_user_permissions_providerUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395585_add_user_permissions_provider.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xe3, 0xa0, 0x43, 0xf7, 0xdb, 0xc8, 0xaa, 0xe9, 0x4c, 0x60, 0x3, 0xac, 0xe8, 0x6c, 0xd9, 0x78, 0x92, 0xb1, 0x84, 0x4e, 0xd0, 0xf9, 0xa8, 0x6, 0x48, 0xe5, 0x11, 0x8d, 0x79, 0x51, 0x73, 0x4d}}
+	return a, nil
+}
+
+var __1528395586_add_permissions_sync_statusDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x77\x00\x88\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x72\x65\x70\x6f\x5f\x70\x65\x72\x6d\x69\x73\x73\x69\x6f\x6e\x73\x5f\x73\x79\x6e\x63\x5f\x73\x74\x61\x74\x75\x73\x3b\x0a\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x75\x73\x65\x72\x5f\x70\x65\x72\x6d\x69\x73\x73\x69\x6f\x6e\x73\x5f\x73\x79\x6e\x63\x5f\x73\x74\x61\x74\x75\x73\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\x8c\x66\x1c\x36\x77\x00\x00\x00")
+
+func _1528395586_add_permissions_sync_statusDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395586_add_permissions_sync_statusDownSql,
+		"1528395586_add_permissions_sync_status.down.sql",
+	)
+}
+
+func _1528395586_add_permissions_sync_statusDownSql() (*asset, error) {
+	bytes, err := _1528395586_add_permissions_sync_statusDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395586_add_permissions_sync_status.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x8d, 0x6e, 0xd6, 0x79, 0xac, 0xb9, 0xb5, 0x94, 0x7f, 0x8, 0x70, 0x6e, 0x2f, 0x69, 0xea, 0xcb, 0x8a, 0x96, 0x90, 0x9, 0xa6, 0x26, 0xae, 0xf3, 0x6a, 0x4d, 0x6f, 0xfb, 0xb7, 0x6c, 0x5f, 0x57}}
+	return a, nil
+}
+
+var __1528395586_add_permissions_sync_statusUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xcc\x92\x3d\x6b\xc3\x30\x10\x86\x77\xff\x8a\x1b\x13\xe8\xd0\x3d\x93\xe2\x9c\x8b\xa9\x3f\x8a\xad\x40\x33\x09\x61\x1f\xad\xa0\x96\x8c\xee\x4c\x9a\xfe\xfa\x12\x9b\x86\x4c\x49\xa7\xd2\x51\xe2\x79\x4f\xaf\x1e\x6e\x8b\x4f\x79\xb5\x49\x92\xb4\x41\xa5\x11\xb4\xda\x16\x08\x79\x06\x55\xad\x01\x5f\xf3\x56\xb7\x30\x31\x45\x33\x52\x1c\x1c\xb3\x0b\x9e\x0d\x9f\x7c\x67\x58\xac\x4c\x0c\xab\x04\x00\x16\xc4\xf5\xe0\xbc\xd0\x1b\x45\x78\x69\xf2\x52\x35\x07\x78\xc6\x03\x34\x98\x61\x83\x55\x8a\xcb\x24\x5e\xb9\x7e\x0d\x75\x05\x3b\x2c\x50\x23\xa4\xaa\x4d\xd5\x0e\x1f\xe6\x39\x1f\x96\xc5\x58\x11\x1a\x46\xa1\xde\x58\x01\x71\x03\xb1\xd8\x61\x84\xa3\x93\xf7\xf9\x08\x5f\xc1\xd3\x5c\xb0\xda\x17\xc5\x55\x90\x4f\xbe\xbb\x9d\xba\x82\x29\xc6\x10\x41\xe8\x53\x96\xcb\x48\x63\x60\xd3\x85\xc9\xcb\xe5\x1b\x3f\x6f\xc0\x0e\x33\xb5\x2f\x34\x3c\x2e\xec\x34\xf6\xf6\xb7\xfd\x2e\x59\x1f\x8e\xab\x75\xb2\xbe\xed\xfa\xdc\xe2\x8e\xeb\x19\xb9\xef\xfa\x8c\xfd\x53\xd5\xf3\x1a\xfc\x91\xea\xba\x2c\x73\xbd\x49\xbe\x07\x00\x16\x71\xe9\x44\xe7\x02\x00\x00")
+
+func _1528395586_add_permissions_sync_statusUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395586_add_permissions_sync_statusUpSql,
+		"1528395586_add_permissions_sync_status.up.sql",
+	)
+}
+
+func _1528395586_add_permissions_sync_statusUpSql() (*asset, error) {
+	bytes, err := _1528395586_add_permissions_sync_statusUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395586_add_permissions_sync_status.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x6c, 0xae, 0xd1, 0x9c, 0xe6, 0x84, 0x59, 0xe4, 0x49, 0xc2, 0x44, 0xca, 0x23, 0xea, 0xb4, 0x68, 0x83, 0x7e, 0x8d, 0x10, 0x24, 0xcd, 0x79, 0xe5, 0x4d, 0xb8, 0x66, 0x3d, 0xe9, 0xba, 0x33, 0xf8}}
+	return a, nil
+}
+
+var __1528395587_add_explicit_repo_permissionsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x41\x00\xbe\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x65\x78\x70\x6c\x69\x63\x69\x74\x5f\x72\x65\x70\x6f\x5f\x70\x65\x72\x6d\x69\x73\x73\x69\x6f\x6e\x73\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\x3f\x46\x96\x67\x41\x00\x00\x00")
+
+func _1528395587_add_explicit_repo_permissionsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395587_add_explicit_repo_permissionsDownSql,
+		"1528395587_add_explicit_repo_permissions.down.sql",
+	)
+}
+
+func _1528395587_add_explicit_repo_permissionsDownSql() (*asset, error) {
+	bytes, err := _1528395587_add_explicit_repo_permissionsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395587_add_explicit_repo_permissions.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xea, 0x8e, 0x80, 0xdb, 0x37, 0x15, 0x3b, 0x42, 0xa, 0xab, 0x69, 0x20, 0x52, 0x73, 0x9, 0xec, 0x13, 0xfd, 0x7b, 0x65, 0x75, 0xa, 0x28, 0x39, 0x78, 0x56, 0x9d, 0x45, 0xdf, 0xf8, 0x98, 0xac}}
+	return a, nil
+}
+
+var __1528395587_add_explicit_repo_permissionsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x90\xcb\x6a\xc3\x30\x10\x45\xf7\xfe\x8a\xbb\xb4\x21\x7f\x90\x95\x62\x8f\x8b\xa8\x2d\x17\x59\x81\x64\x65\x4c\x3c\xb4\x82\xfa\x81\xa4\x92\xd0\xaf\x2f\x55\x92\xba\x9b\x3e\x96\x92\xce\x9c\x3b\xba\x3b\x7a\x90\x6a\x9b\x24\xb9\x26\x61\x08\x46\xec\x2a\x82\x2c\xa1\x1a\x03\x3a\xc8\xd6\xb4\xe0\xcb\xf2\x6a\x4f\x36\x74\x8e\x97\xb9\x5b\xd8\x8d\xd6\x7b\x3b\x4f\x1e\x69\x02\x00\xf1\xda\x0e\xb0\x53\xe0\x67\x76\x71\x54\xed\xab\x0a\x9a\x4a\xd2\xa4\x72\x6a\x23\x93\xda\x21\x43\xa3\x50\x50\x45\x86\x90\x8b\x36\x17\x05\x6d\xa2\xe3\xcd\xb3\xfb\xcb\xf1\xc9\xf8\xdf\x24\xeb\x6a\x08\x7c\x09\x5f\x92\xeb\xeb\xc9\x71\x1f\x78\xe8\xfa\x80\x60\x47\xf6\xa1\x1f\x17\x9c\x6d\x78\x89\x47\xbc\xcf\x13\xaf\xb1\x05\x95\x62\x5f\x19\x4c\xf3\x39\xcd\xae\xf3\x4f\x5a\xd6\x42\x1f\xf1\x48\x47\xa4\xb7\x3f\x6f\xee\x8b\x6f\xbe\x85\x67\x49\xb6\x16\x2a\x55\x41\x87\xff\x16\xda\xdd\x6b\x68\xd4\xcf\x50\x7a\x83\x62\x48\x53\xd7\xd2\x6c\x93\x8f\x01\x00\xf7\xa5\x32\x6c\xc6\x01\x00\x00")
+
+func _1528395587_add_explicit_repo_permissionsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395587_add_explicit_repo_permissionsUpSql,
+		"1528395587_add_explicit_repo_permissions.up.sql",
+	)
+}
+
+func _1528395587_add_explicit_repo_permissionsUpSql() (*asset, error) {
+	bytes, err := _1528395587_add_explicit_repo_permissionsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395587_add_explicit_repo_permissions.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x51, 0x4b, 0x5, 0x81, 0x74, 0x9d, 0xf2, 0xc6, 0x31, 0x7d, 0x7c, 0xf6, 0xb, 0x62, 0xc3, 0xb6, 0x55, 0x80, 0x98, 0x7f, 0xab, 0x4d, 0x9b, 0x2f, 0x6b, 0xf4, 0x6c, 0x1b, 0xaa, 0xbf, 0xca, 0x96}}
+	return a, nil
+}
+
+var __1528395588_add_user_idp_groupsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x37\x00\xc8\xff\x42\x45\x47\x49\x4e\x3b\x0a\x0a\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x75\x73\x65\x72\x5f\x69\x64\x70\x5f\x67\x72\x6f\x75\x70\x73\x3b\x0a\x0a\x43\x4f\x4d\x4d\x49\x54\x3b\x0a\x03\x00\x94\x0c\x70\x89\x37\x00\x00\x00")
+
+func _1528395588_add_user_idp_groupsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395588_add_user_idp_groupsDownSql,
+		"1528395588_add_user_idp_groups.down.sql",
+	)
+}
+
+func _1528395588_add_user_idp_groupsDownSql() (*asset, error) {
+	bytes, err := _1528395588_add_user_idp_groupsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395588_add_user_idp_groups.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x4, 0xf0, 0xe5, 0x41, 0x4f, 0x91, 0xa, 0xd7, 0x53, 0xc3, 0x1c, 0xf6, 0x3b, 0xd2, 0x5f, 0x9f, 0x30, 0xc8, 0x22, 0x6f, 0x74, 0xd8, 0x7, 0x41, 0x15, 0xdb, 0x18, 0xd2, 0xcb, 0xb0, 0xc, 0x1a}}
+	return a, nil
+}
+
+var __1528395588_add_user_idp_groupsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x8c\x90\xcb\x6e\xb3\x30\x10\x85\xf7\x7e\x8a\xb3\x0b\x48\x79\x03\x56\x0e\x0c\xbf\xd0\xcf\xa5\x02\x47\x6a\x54\x55\x08\xd5\xa3\xd4\x8b\x80\x85\x4d\xd3\x8b\xfa\xee\x55\x20\xa5\xaa\xda\x45\x97\xe3\x6f\xbe\x23\x9f\xd9\xd1\xbf\xac\x8c\x84\x88\x6b\x92\x8a\xa0\xe4\x2e\x27\x64\x29\xca\x4a\x81\x6e\xb3\x46\x35\x98\x1c\x8f\xad\xd1\xb6\x3d\x8e\xc3\x64\x1d\x02\x01\xe0\xf3\x15\xa6\xf7\x7c\xe4\x71\x16\xca\x7d\x9e\xa3\xa6\x94\x6a\x2a\x63\x5a\x4c\x17\x18\x1d\xa2\x2a\x91\x50\x4e\x8a\x10\xcb\x26\x96\x09\x6d\xe7\x10\xc7\xe3\x93\x79\xe0\xd6\xbf\x58\x86\xe7\x67\xbf\xc6\x7c\xe7\x46\xff\x46\xaf\xff\xb9\x90\xbb\xfb\x95\x21\xa1\x54\xee\x73\x85\xcd\xdb\xfb\x66\x59\x1c\xd9\x0e\x7f\xd9\x9b\xac\xee\x3c\xeb\xb6\xf3\xf0\xe6\xc4\xce\x77\x27\x8b\xb3\xf1\x8f\xf3\x88\xd7\xa1\xe7\x9f\x7a\x3f\x9c\x83\x70\xf1\x6f\xea\xac\x90\xf5\x01\xff\xe9\x80\xe0\x7a\xa0\xed\x5a\xe2\x52\xf2\x6b\x32\x3a\x14\x61\x24\x44\x5c\x15\x45\xa6\x22\xf1\x31\x00\xaa\x95\xbd\xf2\x89\x01\x00\x00")
+
+func _1528395588_add_user_idp_groupsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395588_add_user_idp_groupsUpSql,
+		"1528395588_add_user_idp_groups.up.sql",
+	)
+}
+
+func _1528395588_add_user_idp_groupsUpSql() (*asset, error) {
+	bytes, err := _1528395588_add_user_idp_groupsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395588_add_user_idp_groups.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x46, 0x7f, 0x8a, 0x7c, 0xf3, 0x9d, 0x9b, 0x28, 0xd, 0xa3, 0x2, 0x59, 0xf3, 0xa8, 0x41, 0xa5, 0x7a, 0x4a, 0xdf, 0x24, 0x4, 0x31, 0xf8, 0x97, 0x56, 0xf5, 0x8a, 0x11, 0xfb, 0x46, 0x1c, 0x53}}
+	return a, nil
+}
+
+var __1528395589_add_users_deactivated_atDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x73\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x2d\x4e\x2d\x2a\x56\x70\x09\xf2\x0f\x50\x70\xf6\xf7\x09\xf5\xf5\x53\xf0\x74\x53\x70\x8d\xf0\x0c\x0e\x09\x56\x48\x49\x4d\x4c\x2e\xc9\x2c\x4b\x2c\x49\x4d\x89\x4f\x2c\x01\x6a\x74\xf6\xf7\xf5\xf5\x0c\xb1\xe6\x02\x00\xc1\x00\x0b\x10\x49\x00\x00\x00")
+
+func _1528395589_add_users_deactivated_atDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395589_add_users_deactivated_atDownSql,
+		"1528395589_add_users_deactivated_at.down.sql",
+	)
+}
+
+func _1528395589_add_users_deactivated_atDownSql() (*asset, error) {
+	bytes, err := _1528395589_add_users_deactivated_atDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395589_add_users_deactivated_at.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x8d, 0xeb, 0x49, 0x57, 0xab, 0x77, 0x2, 0x2d, 0xa9, 0xf5, 0x8a, 0x7d, 0xbb, 0xa7, 0x13, 0x8e, 0xfc, 0xd3, 0x37, 0x6c, 0x59, 0xd9, 0xe8, 0x80, 0x9a, 0xc7, 0x62, 0xf7, 0x31, 0xbc, 0x13, 0x48}}
+	return a, nil
+}
+
+var __1528395589_add_users_deactivated_atUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x73\x72\x75\xf7\xf4\xb3\xe6\xe2\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x55\x28\x2d\x4e\x2d\x2a\x56\x70\x74\x71\x51\x70\xf6\xf7\x09\xf5\xf5\x53\xf0\x74\x53\xf0\xf3\x0f\x51\x70\x8d\xf0\x0c\x0e\x09\x56\x48\x49\x4d\x4c\x2e\xc9\x2c\x4b\x2c\x49\x4d\x89\x4f\x2c\x51\x28\xc9\xcc\x4d\x2d\x2e\x49\xcc\x2d\x50\x28\xcf\x2c\xc9\x00\x73\x15\xaa\xf2\xf3\x52\x81\x86\x3a\xfb\xfb\xfa\x7a\x86\x58\x73\x01\x00\x4a\xe4\xdd\x48\x65\x00\x00\x00")
+
+func _1528395589_add_users_deactivated_atUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395589_add_users_deactivated_atUpSql,
+		"1528395589_add_users_deactivated_at.up.sql",
+	)
+}
+
+func _1528395589_add_users_deactivated_atUpSql() (*asset, error) {
+	bytes, err := _1528395589_add_users_deactivated_atUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395589_add_users_deactivated_at.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x90, 0xad, 0x4c, 0xfe, 0x1, 0xa4, 0xc4, 0x12, 0xdc, 0x47, 0xa, 0xc6, 0xe3, 0xd1, 0xde, 0x7a, 0xfe, 0xab, 0xb1, 0x1d, 0xe5, 0x4c, 0xc9, 0x4, 0xb5, 0xdb, 0xba, 0x80, 0xda, 0x6a, 0xd, 0x95}}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -5425,17 +5677,43 @@ var _bindata = map[string]func() (*asset, error){
 	"1528395583_add_default_repos_primary_key.down.sql": _1528395583_add_default_repos_primary_keyDownSql,
 
 	"1528395583_add_default_repos_primary_key.up.sql": _1528395583_add_default_repos_primary_keyUpSql,
+
+	"1528395584_add_repo_update_schedules.down.sql": _1528395584_add_repo_update_schedulesDownSql,
+
+	"1528395584_add_repo_update_schedules.up.sql": _1528395584_add_repo_update_schedulesUpSql,
+
+	"1528395585_add_user_permissions_provider.down.sql": _1528395585_add_user_permissions_providerDownSql,
+
+	"1528395585_add_user_permissions_provider.up.sql": _1528395585_add_user_permissions_providerUpSql,
+
+	"1528395586_add_permissions_sync_status.down.sql": _1528395586_add_permissions_sync_statusDownSql,
+
+	"1528395586_add_permissions_sync_status.up.sql": _1528395586_add_permissions_sync_statusUpSql,
+
+	"1528395587_add_explicit_repo_permissions.down.sql": _1528395587_add_explicit_repo_permissionsDownSql,
+
+	"1528395587_add_explicit_repo_permissions.up.sql": _1528395587_add_explicit_repo_permissionsUpSql,
+
+	"1528395588_add_user_idp_groups.down.sql": _1528395588_add_user_idp_groupsDownSql,
+
+	"1528395588_add_user_idp_groups.up.sql": _1528395588_add_user_idp_groupsUpSql,
+
+	"1528395589_add_users_deactivated_at.down.sql": _1528395589_add_users_deactivated_atDownSql,
+
+	"1528395589_add_users_deactivated_at.up.sql": _1528395589_add_users_deactivated_atUpSql,
 }
 
 // AssetDir returns the file names below a certain
 // directory embedded in the file by go-bindata.
 // For example if you run go-bindata on data/... and data contains the
 // following hierarchy:
-//     data/
-//       foo.txt
-//       img/
-//         a.png
-//         b.png
+//
+//	data/
+//	  foo.txt
+//	  img/
+//	    a.png
+//	    b.png
+//
 // then AssetDir("data") would return []string{"foo.txt", "img"},
 // AssetDir("data/img") would return []string{"a.png", "b.png"},
 // AssetDir("foo.txt") and AssetDir("notexist") would return an error, and
@@ -5697,6 +5975,18 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"1528395582_add_default_repos.up.sql":                         {_1528395582_add_default_reposUpSql, map[string]*bintree{}},
 	"1528395583_add_default_repos_primary_key.down.sql":           {_1528395583_add_default_repos_primary_keyDownSql, map[string]*bintree{}},
 	"1528395583_add_default_repos_primary_key.up.sql":             {_1528395583_add_default_repos_primary_keyUpSql, map[string]*bintree{}},
+	"1528395584_add_repo_update_schedules.down.sql":               {_1528395584_add_repo_update_schedulesDownSql, map[string]*bintree{}},
+	"1528395584_add_repo_update_schedules.up.sql":                 {_1528395584_add_repo_update_schedulesUpSql, map[string]*bintree{}},
+	"1528395585_add_user_permissions_provider.down.sql":           {_1528395585_add_user_permissions_providerDownSql, map[string]*bintree{}},
+	"1528395585_add_user_permissions_provider.up.sql":             {_1528395585_add_user_permissions_providerUpSql, map[string]*bintree{}},
+	"1528395586_add_permissions_sync_status.down.sql":             {_1528395586_add_permissions_sync_statusDownSql, map[string]*bintree{}},
+	"1528395586_add_permissions_sync_status.up.sql":               {_1528395586_add_permissions_sync_statusUpSql, map[string]*bintree{}},
+	"1528395587_add_explicit_repo_permissions.down.sql":           {_1528395587_add_explicit_repo_permissionsDownSql, map[string]*bintree{}},
+	"1528395587_add_explicit_repo_permissions.up.sql":             {_1528395587_add_explicit_repo_permissionsUpSql, map[string]*bintree{}},
+	"1528395588_add_user_idp_groups.down.sql":                     {_1528395588_add_user_idp_groupsDownSql, map[string]*bintree{}},
+	"1528395588_add_user_idp_groups.up.sql":                       {_1528395588_add_user_idp_groupsUpSql, map[string]*bintree{}},
+	"1528395589_add_users_deactivated_at.down.sql":                {_1528395589_add_users_deactivated_atDownSql, map[string]*bintree{}},
+	"1528395589_add_users_deactivated_at.up.sql":                  {_1528395589_add_users_deactivated_atUpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory.
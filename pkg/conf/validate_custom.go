@@ -2,6 +2,7 @@ package conf
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/sourcegraph/sourcegraph/pkg/conf/conftypes"
@@ -51,6 +52,20 @@ func validateCustom(cfg Unified) (problems []string) {
 		}
 	}
 
+	if cfg.SymbolsCtags != nil {
+		for _, lang := range cfg.SymbolsCtags.Languages {
+			kinds := map[string]bool{}
+			for _, kind := range lang.Kinds {
+				kinds[kind.Letter] = true
+			}
+			for _, re := range lang.Regexes {
+				if !kinds[re.Kind] {
+					invalid(fmt.Sprintf("symbols.ctags language %q has a regex with kind %q, which is not one of the language's kinds", lang.Name, re.Kind))
+				}
+			}
+		}
+	}
+
 	for _, f := range contributedValidators {
 		problems = append(problems, f(cfg)...)
 	}
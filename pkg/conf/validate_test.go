@@ -41,6 +41,11 @@ func TestValidateCustom(t *testing.T) {
 			rawSite:     "{}",
 			wantErr:     "tagged union type must have a",
 		},
+		"symbols.ctags regex with undefined kind": {
+			rawCritical: "{}",
+			rawSite:     `{"symbols.ctags":{"languages":[{"name":"MyDSL","extensions":["mydsl"],"regexes":[{"regex":"^rule ([a-z]+)","name":"\\1","kind":"r"}]}]}}`,
+			wantProblem: "which is not one of the language's kinds",
+		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -9,12 +9,18 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/codecommit"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/rcache"
+	"golang.org/x/time/rate"
 )
 
 // Client is a AWS CodeCommit API client.
 type Client struct {
 	aws       aws.Config
 	repoCache *rcache.Cache
+
+	// RateLimiter is the self-imposed budget of requests to the AWS CodeCommit
+	// API. It doesn't limit requests unless it is set to the rate limiter of
+	// an external service.
+	RateLimiter *rate.Limiter
 }
 
 // NewClient creates a new AWS CodeCommit API client.
@@ -25,8 +31,9 @@ func NewClient(config aws.Config) *Client {
 	repoCache := rcache.NewWithTTL("cc_repo:", 60 /* seconds */)
 
 	return &Client{
-		aws:       config,
-		repoCache: repoCache,
+		aws:         config,
+		repoCache:   repoCache,
+		RateLimiter: rate.NewLimiter(rate.Inf, 1),
 	}
 }
 
@@ -95,6 +95,42 @@ func (c *Client) Repos(ctx context.Context, pageToken *PageToken, accountName st
 	return repos, next, err
 }
 
+// User returns the Bitbucket Cloud user with the given username.
+func (c *Client) User(ctx context.Context, username string) (*User, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("/2.0/users/%s", url.PathEscape(username)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err = c.do(ctx, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// TeamRepositoryPermissions returns a list of the explicit repository permissions of
+// the members of the given team, optionally filtered by the given query (e.g.
+// `user.uuid="{...}"`). The app password's user must be an administrator of the team.
+// If the argument pageToken.Next is not empty, it will be used directly as the URL to
+// make the request. The PageToken it returns may also contain the URL to the next page
+// for succeeding requests if any.
+func (c *Client) TeamRepositoryPermissions(ctx context.Context, pageToken *PageToken, team, q string) ([]*RepositoryPermission, *PageToken, error) {
+	var perms []*RepositoryPermission
+	var next *PageToken
+	var err error
+	if pageToken.HasMore() {
+		next, err = c.reqPage(ctx, pageToken.Next, &perms)
+	} else {
+		qry := make(url.Values)
+		if q != "" {
+			qry.Set("q", q)
+		}
+		next, err = c.page(ctx, fmt.Sprintf("/2.0/teams/%s/permissions/repositories", team), qry, pageToken, &perms)
+	}
+	return perms, next, err
+}
+
 func (c *Client) page(ctx context.Context, path string, qry url.Values, token *PageToken, results interface{}) (*PageToken, error) {
 	if qry == nil {
 		qry = make(url.Values)
@@ -227,6 +263,22 @@ type Repo struct {
 	Links       Links  `json:"links"`
 }
 
+// User is a Bitbucket Cloud user account.
+type User struct {
+	UUID        string `json:"uuid"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AccountID   string `json:"account_id"`
+}
+
+// RepositoryPermission is the permission of a user on a repository.
+type RepositoryPermission struct {
+	// Permission is one of "read", "write" or "admin".
+	Permission string `json:"permission"`
+	User       *User  `json:"user"`
+	Repository *Repo  `json:"repository"`
+}
+
 type Links struct {
 	Clone CloneLinks `json:"clone"`
 	HTML  Link       `json:"html"`
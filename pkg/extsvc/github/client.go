@@ -23,6 +23,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/metrics"
 	"github.com/sourcegraph/sourcegraph/pkg/ratelimit"
 	"github.com/sourcegraph/sourcegraph/pkg/rcache"
+	"golang.org/x/time/rate"
 	log15 "gopkg.in/inconshreveable/log15.v2"
 )
 
@@ -76,6 +77,11 @@ type Client struct {
 
 	// RateLimit is the API rate limit monitor.
 	RateLimit *ratelimit.Monitor
+
+	// RateLimiter is the self-imposed budget of requests to the GitHub API. It
+	// doesn't limit requests unless it is set to the rate limiter of an
+	// external service.
+	RateLimiter *rate.Limiter
 }
 
 // APIError is an error type returned by Client when the GitHub API responds with
@@ -156,6 +162,7 @@ func NewClient(apiURL *url.URL, defaultToken string, cli httpcli.Doer) *Client {
 		defaultToken: defaultToken,
 		httpClient:   cli,
 		RateLimit:    &ratelimit.Monitor{HeaderPrefix: "X-"},
+		RateLimiter:  rate.NewLimiter(rate.Inf, 1),
 		repoCache:    map[string]*rcache.Cache{},
 	}
 }
@@ -209,6 +216,10 @@ func (c *Client) do(ctx context.Context, token string, req *http.Request, result
 		span.Finish()
 	}()
 
+	if err = c.RateLimiter.Wait(ctx); err != nil {
+		return err
+	}
+
 	resp, err = c.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
@@ -17,6 +17,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/httpcli"
 	"github.com/sourcegraph/sourcegraph/pkg/ratelimit"
 	"github.com/sourcegraph/sourcegraph/pkg/rcache"
+	"golang.org/x/time/rate"
 )
 
 func TestSplitRepositoryNameWithOwner(t *testing.T) {
@@ -75,6 +76,7 @@ func newTestClient(t *testing.T, cli httpcli.Doer) *Client {
 		apiURL:          &url.URL{Scheme: "https", Host: "example.com", Path: "/"},
 		httpClient:      cli,
 		RateLimit:       &ratelimit.Monitor{},
+		RateLimiter:     rate.NewLimiter(rate.Inf, 1),
 		repoCache:       map[string]*rcache.Cache{},
 		repoCachePrefix: "__test__gh_repo",
 		repoCacheTTL:    1000,
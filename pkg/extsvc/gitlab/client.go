@@ -19,6 +19,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/metrics"
 	"github.com/sourcegraph/sourcegraph/pkg/ratelimit"
 	"github.com/sourcegraph/sourcegraph/pkg/rcache"
+	"golang.org/x/time/rate"
 )
 
 var requestCounter = metrics.NewRequestMeter("gitlab", "Total number of requests sent to the GitLab API.")
@@ -37,6 +38,11 @@ type ClientProvider struct {
 	gitlabClientsMu sync.Mutex
 
 	RateLimit *ratelimit.Monitor // the API rate limit monitor
+
+	// RateLimiter is the self-imposed budget of requests to the GitLab API,
+	// shared by the clients of the ClientProvider. It doesn't limit requests
+	// unless it is set to the rate limiter of an external service.
+	RateLimiter *rate.Limiter
 }
 
 type CommonOp struct {
@@ -63,6 +69,7 @@ func NewClientProvider(baseURL *url.URL, cli httpcli.Doer) *ClientProvider {
 		httpClient:    cli,
 		gitlabClients: make(map[string]*Client),
 		RateLimit:     &ratelimit.Monitor{},
+		RateLimiter:   rate.NewLimiter(rate.Inf, 1),
 	}
 }
 
@@ -110,7 +117,7 @@ func (p *ClientProvider) getClient(op getClientOp) *Client {
 		return c
 	}
 
-	c := p.newClient(p.baseURL, op, p.httpClient, p.RateLimit)
+	c := p.newClient(p.baseURL, op, p.httpClient, p.RateLimit, p.RateLimiter)
 	p.gitlabClients[key] = c
 	return c
 }
@@ -134,6 +141,7 @@ type Client struct {
 	OAuthToken          string // an OAuth bearer token, if set
 	Sudo                string // Sudo user value, if set
 	RateLimit           *ratelimit.Monitor
+	RateLimiter         *rate.Limiter // the self-imposed API rate limit, shared by the clients of a ClientProvider
 }
 
 // newClient creates a new GitLab API client with an optional personal access token to authenticate requests.
@@ -142,7 +150,7 @@ type Client struct {
 // http[s]://[gitlab-hostname] for self-hosted GitLab instances.
 //
 // See the docstring of Client for the meaning of the parameters.
-func (p *ClientProvider) newClient(baseURL *url.URL, op getClientOp, httpClient httpcli.Doer, rateLimit *ratelimit.Monitor) *Client {
+func (p *ClientProvider) newClient(baseURL *url.URL, op getClientOp, httpClient httpcli.Doer, rateLimit *ratelimit.Monitor, rateLimiter *rate.Limiter) *Client {
 	// Cache for GitLab project metadata.
 	var cacheTTL time.Duration
 	if isGitLabDotComURL(baseURL) && op.personalAccessToken == "" && op.oauthToken == "" {
@@ -161,6 +169,7 @@ func (p *ClientProvider) newClient(baseURL *url.URL, op getClientOp, httpClient
 		OAuthToken:          op.oauthToken,
 		Sudo:                op.sudo,
 		RateLimit:           rateLimit,
+		RateLimiter:         rateLimiter,
 	}
 }
 
@@ -196,6 +205,10 @@ func (c *Client) do(ctx context.Context, req *http.Request, result interface{})
 		span.Finish()
 	}()
 
+	if err = c.RateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
 	resp, err = c.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
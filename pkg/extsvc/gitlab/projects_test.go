@@ -12,6 +12,7 @@ import (
 
 	"github.com/sourcegraph/sourcegraph/pkg/ratelimit"
 	"github.com/sourcegraph/sourcegraph/pkg/rcache"
+	"golang.org/x/time/rate"
 )
 
 type mockHTTPResponseBody struct {
@@ -43,10 +44,11 @@ func (s mockHTTPEmptyResponse) Do(req *http.Request) (*http.Response, error) {
 func newTestClient(t *testing.T) *Client {
 	rcache.SetupForTest(t)
 	return &Client{
-		baseURL:    &url.URL{Scheme: "https", Host: "example.com", Path: "/"},
-		httpClient: &http.Client{},
-		RateLimit:  &ratelimit.Monitor{},
-		projCache:  rcache.NewWithTTL("__test__gl_proj", 1000),
+		baseURL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/"},
+		httpClient:  &http.Client{},
+		RateLimit:   &ratelimit.Monitor{},
+		RateLimiter: rate.NewLimiter(rate.Inf, 1),
+		projCache:   rcache.NewWithTTL("__test__gl_proj", 1000),
 	}
 }
 
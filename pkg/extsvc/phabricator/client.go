@@ -13,11 +13,17 @@ import (
 	"github.com/uber/gonduit"
 	"github.com/uber/gonduit/core"
 	"github.com/uber/gonduit/requests"
+	"golang.org/x/time/rate"
 )
 
 // A Client provides high level methods to a Phabricator Conduit API.
 type Client struct {
 	conn *gonduit.Conn
+
+	// RateLimiter is the self-imposed budget of requests to the Conduit API. It
+	// doesn't limit requests unless it is set to the rate limiter of an
+	// external service.
+	RateLimiter *rate.Limiter
 }
 
 // NewClient returns an authenticated Client, using the given URL and
@@ -33,7 +39,15 @@ func NewClient(ctx context.Context, url, token string, cli httpcli.Doer) (*Clien
 		return nil, err
 	}
 
-	return &Client{conn: conn}, nil
+	return &Client{conn: conn, RateLimiter: rate.NewLimiter(rate.Inf, 1)}, nil
+}
+
+// call calls the given Conduit API method once the rate limiter permits it.
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	if err := c.RateLimiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.conn.CallContext(ctx, method, params, result)
 }
 
 // Repo represents a single code repository.
@@ -228,7 +242,7 @@ func (c *Client) ListRepos(ctx context.Context, args ListReposArgs) ([]*Repo, *C
 		Cursor Cursor  `json:"cursor"`
 	}
 
-	err := c.conn.CallContext(ctx, "diffusion.repository.search", &req, &res)
+	err := c.call(ctx, "diffusion.repository.search", &req, &res)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -244,7 +258,7 @@ func (c *Client) GetRawDiff(ctx context.Context, diffID int) (diff string, err e
 	}
 
 	req := request{DiffID: diffID}
-	err = c.conn.CallContext(ctx, "differential.getrawdiff", &req, &diff)
+	err = c.call(ctx, "differential.getrawdiff", &req, &diff)
 	if err != nil {
 		return "", err
 	}
@@ -271,7 +285,7 @@ func (c *Client) GetDiffInfo(ctx context.Context, diffID int) (*DiffInfo, error)
 	req := request{IDs: []int{diffID}}
 
 	var res map[string]*DiffInfo
-	err := c.conn.CallContext(ctx, "differential.querydiffs", &req, &res)
+	err := c.call(ctx, "differential.querydiffs", &req, &res)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,91 @@
+// Package ratelimits applies the rateLimit options of external services to
+// their rate limiters in ratelimit.DefaultRegistry.
+//
+// Each external service has its own budget of requests, shared by all the API
+// clients which use its credentials.
+package ratelimits
+
+import (
+	"github.com/sourcegraph/sourcegraph/pkg/ratelimit"
+	"github.com/sourcegraph/sourcegraph/schema"
+	"golang.org/x/time/rate"
+)
+
+// The default budgets of API requests per hour to each kind of code host, used
+// when the rateLimit option of an external service is not set. They must match
+// the defaults in the external service JSON schemas. GitHub enforces its own
+// rate limit per token, so GitHub external services aren't limited unless they
+// set rateLimit.
+const (
+	DefaultGitLabRequestsPerHour          = 36000
+	DefaultBitbucketServerRequestsPerHour = 7200
+	DefaultBitbucketCloudRequestsPerHour  = 7200
+	DefaultAWSCodeCommitRequestsPerHour   = 7200
+	DefaultPhabricatorRequestsPerHour     = 7200
+)
+
+// A Limit is the budget of API requests which an external service configures.
+type Limit struct {
+	Enabled         bool
+	RequestsPerHour float64
+}
+
+// Configure sets the rate limiter of the external service with the given ID to
+// l and returns it.
+func (l *Limit) Configure(id int64) *rate.Limiter {
+	return ratelimit.DefaultRegistry.Configure(id, l.Enabled, l.RequestsPerHour)
+}
+
+// GitHub returns the rate limit of the GitHub connection c.
+func GitHub(c *schema.GitHubConnection) *Limit {
+	l := &Limit{}
+	if c.RateLimit != nil {
+		l.Enabled, l.RequestsPerHour = c.RateLimit.Enabled, c.RateLimit.RequestsPerHour
+	}
+	return l
+}
+
+// GitLab returns the rate limit of the GitLab connection c.
+func GitLab(c *schema.GitLabConnection) *Limit {
+	l := &Limit{Enabled: true, RequestsPerHour: DefaultGitLabRequestsPerHour}
+	if c.RateLimit != nil {
+		l.Enabled, l.RequestsPerHour = c.RateLimit.Enabled, c.RateLimit.RequestsPerHour
+	}
+	return l
+}
+
+// BitbucketServer returns the rate limit of the Bitbucket Server connection c.
+func BitbucketServer(c *schema.BitbucketServerConnection) *Limit {
+	l := &Limit{Enabled: true, RequestsPerHour: DefaultBitbucketServerRequestsPerHour}
+	if c.RateLimit != nil {
+		l.Enabled, l.RequestsPerHour = c.RateLimit.Enabled, c.RateLimit.RequestsPerHour
+	}
+	return l
+}
+
+// BitbucketCloud returns the rate limit of the Bitbucket Cloud connection c.
+func BitbucketCloud(c *schema.BitbucketCloudConnection) *Limit {
+	l := &Limit{Enabled: true, RequestsPerHour: DefaultBitbucketCloudRequestsPerHour}
+	if c.RateLimit != nil {
+		l.Enabled, l.RequestsPerHour = c.RateLimit.Enabled, c.RateLimit.RequestsPerHour
+	}
+	return l
+}
+
+// AWSCodeCommit returns the rate limit of the AWS CodeCommit connection c.
+func AWSCodeCommit(c *schema.AWSCodeCommitConnection) *Limit {
+	l := &Limit{Enabled: true, RequestsPerHour: DefaultAWSCodeCommitRequestsPerHour}
+	if c.RateLimit != nil {
+		l.Enabled, l.RequestsPerHour = c.RateLimit.Enabled, c.RateLimit.RequestsPerHour
+	}
+	return l
+}
+
+// Phabricator returns the rate limit of the Phabricator connection c.
+func Phabricator(c *schema.PhabricatorConnection) *Limit {
+	l := &Limit{Enabled: true, RequestsPerHour: DefaultPhabricatorRequestsPerHour}
+	if c.RateLimit != nil {
+		l.Enabled, l.RequestsPerHour = c.RateLimit.Enabled, c.RateLimit.RequestsPerHour
+	}
+	return l
+}
@@ -0,0 +1,42 @@
+package ratelimits
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestDefaults(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		have *Limit
+		want Limit
+	}{
+		{
+			name: "GitHub is not limited unless configured",
+			have: GitHub(&schema.GitHubConnection{}),
+			want: Limit{},
+		},
+		{
+			name: "configured GitHub",
+			have: GitHub(&schema.GitHubConnection{RateLimit: &schema.GitHubRateLimit{Enabled: true, RequestsPerHour: 1000}}),
+			want: Limit{Enabled: true, RequestsPerHour: 1000},
+		},
+		{
+			name: "GitLab",
+			have: GitLab(&schema.GitLabConnection{}),
+			want: Limit{Enabled: true, RequestsPerHour: DefaultGitLabRequestsPerHour},
+		},
+		{
+			name: "disabled GitLab",
+			have: GitLab(&schema.GitLabConnection{RateLimit: &schema.GitLabRateLimit{RequestsPerHour: 1}}),
+			want: Limit{RequestsPerHour: 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if *tc.have != tc.want {
+				t.Errorf("got %+v, want %+v", *tc.have, tc.want)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -59,6 +60,9 @@ func NewClient(cli httpcli.Doer) *Client {
 		Addrs: func(ctx context.Context) []string {
 			return conf.Get().ServiceConnections.GitServers
 		},
+		ReplicationFactor: func() int {
+			return conf.Get().GitReplicationFactor
+		},
 		HTTPClient:  cli,
 		HTTPLimiter: parallel.NewRun(500),
 		// Use the binary name for UserAgent. This should effectively identify
@@ -81,6 +85,12 @@ type Client struct {
 	// concurrent use. It may return different results at different times.
 	Addrs func(ctx context.Context) []string
 
+	// ReplicationFactor, if set, is a function which returns the number of
+	// gitservers which have a copy of each repository (see
+	// ReplicaAddrs). Reads fail over to the replicas of a repository if its
+	// primary gitserver can't be reached.
+	ReplicationFactor func() int
+
 	// UserAgent is a string identifing who the client is. It will be logged in
 	// the telemetry in gitserver.
 	UserAgent string
@@ -92,6 +102,20 @@ func (c *Client) addrForRepo(ctx context.Context, repo api.RepoName) string {
 	return c.addrForKey(ctx, string(repo))
 }
 
+// addrsForRepo returns the addresses of the gitservers which have a copy of
+// the given repo, starting with its primary gitserver.
+func (c *Client) addrsForRepo(ctx context.Context, repo api.RepoName) []string {
+	addrs := c.Addrs(ctx)
+	if len(addrs) == 0 {
+		panic("unexpected state: no gitserver addresses")
+	}
+	replicationFactor := 1
+	if c.ReplicationFactor != nil {
+		replicationFactor = c.ReplicationFactor()
+	}
+	return ReplicaAddrs(string(protocol.NormalizeRepo(repo)), addrs, replicationFactor)
+}
+
 // addrForKey returns the gitserver address to use for the given string key,
 // which is hashed for sharding purposes.
 func (c *Client) addrForKey(ctx context.Context, key string) string {
@@ -99,11 +123,73 @@ func (c *Client) addrForKey(ctx context.Context, key string) string {
 	if len(addrs) == 0 {
 		panic("unexpected state: no gitserver addresses")
 	}
+	return AddrForKey(key, addrs)
+}
+
+// AddrForKey returns the address in addrs which owns the given key. Keys are
+// assigned to addresses with rendezvous hashing: adding an address only moves
+// the keys the new address owns, and removing an address only moves the keys
+// it owned. The order of addrs does not matter.
+func AddrForKey(key string, addrs []string) string {
+	var (
+		best       string
+		bestWeight uint64
+	)
+	for i, addr := range addrs {
+		if w := rendezvousWeight(key, addr); i == 0 || w > bestWeight {
+			best, bestWeight = addr, w
+		}
+	}
+	return best
+}
+
+// RankedAddrs returns addrs ordered by descending rendezvous hashing weight
+// for the given key. The first address owns the key. If it was added to addrs
+// recently, the second address owned the key before.
+func RankedAddrs(key string, addrs []string) []string {
+	ranked := append([]string{}, addrs...)
+	weights := make(map[string]uint64, len(ranked))
+	for _, addr := range ranked {
+		weights[addr] = rendezvousWeight(key, addr)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return weights[ranked[i]] > weights[ranked[j]]
+	})
+	return ranked
+}
+
+// ReplicaAddrs returns the addresses in addrs which have a copy of the given
+// key when each key is replicated to replicationFactor addresses. The first
+// address is the primary, which owns the key (see AddrForKey). The others are
+// the replicas, which are the addresses next in the rendezvous hashing order
+// (see RankedAddrs).
+func ReplicaAddrs(key string, addrs []string, replicationFactor int) []string {
+	ranked := RankedAddrs(key, addrs)
+	if replicationFactor < 1 {
+		replicationFactor = 1
+	}
+	if len(ranked) > replicationFactor {
+		ranked = ranked[:replicationFactor]
+	}
+	return ranked
+}
+
+// LegacyAddrForKey returns the address in addrs which owned the given key
+// before keys were assigned with rendezvous hashing (see AddrForKey).
+func LegacyAddrForKey(key string, addrs []string) string {
+	if len(addrs) == 0 {
+		return ""
+	}
 	sum := md5.Sum([]byte(key))
 	serverIndex := binary.BigEndian.Uint64(sum[:]) % uint64(len(addrs))
 	return addrs[serverIndex]
 }
 
+func rendezvousWeight(key, addr string) uint64 {
+	sum := md5.Sum([]byte(addr + "\x00" + key))
+	return binary.BigEndian.Uint64(sum[:])
+}
+
 // ArchiveOptions contains options for the Archive func.
 type ArchiveOptions struct {
 	Treeish string   // the tree or commit to produce an archive for
@@ -157,6 +243,22 @@ func (c *Client) ArchiveURL(ctx context.Context, repo Repo, opt ArchiveOptions)
 	}
 }
 
+// GitHTTPURL returns the URL of the read-only git smart HTTP endpoint for the
+// given repository on its gitserver, against which `git clone` and `git fetch`
+// can be run.
+//
+// 🚨 SECURITY: gitserver does no authorization, so this URL must only be
+// exposed to users through a proxy which checks that they can access the
+// repository.
+func (c *Client) GitHTTPURL(ctx context.Context, repo api.RepoName) *url.URL {
+	repo = protocol.NormalizeRepo(repo)
+	return &url.URL{
+		Scheme: "http",
+		Host:   c.addrForRepo(ctx, repo),
+		Path:   "/git/" + string(repo),
+	}
+}
+
 // Archive produces an archive from a Git repository.
 func (c *Client) Archive(ctx context.Context, repo Repo, opt ArchiveOptions) (_ io.ReadCloser, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: Archive")
@@ -177,7 +279,7 @@ func (c *Client) Archive(ctx context.Context, repo Repo, opt ArchiveOptions) (_
 	}
 
 	u := c.ArchiveURL(ctx, repo, opt)
-	resp, err := c.do(ctx, repo.Name, "GET", u.String(), nil)
+	resp, err := c.doRead(ctx, repo.Name, "GET", "archive?"+u.RawQuery, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -244,7 +346,7 @@ func (c *Cmd) sendExec(ctx context.Context) (_ io.ReadCloser, _ http.Header, err
 		EnsureRevision: c.EnsureRevision,
 		Args:           c.Args[1:],
 	}
-	resp, err := c.client.httpPost(ctx, repoName, "exec", req)
+	resp, err := c.client.doRead(ctx, repoName, "POST", "exec", req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -275,8 +377,16 @@ var deadlineExceededCounter = prometheus.NewCounter(prometheus.CounterOpts{
 	Help:      "Times that Client.sendExec() returned context.DeadlineExceeded",
 })
 
+var failoverCounter = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "client_failover",
+	Help:      "Times that a read request was retried on a replica because a gitserver was unreachable, failed or did not have the repository cloned",
+})
+
 func init() {
 	prometheus.MustRegister(deadlineExceededCounter)
+	prometheus.MustRegister(failoverCounter)
 }
 
 // Cmd represents a command to be executed remotely.
@@ -725,12 +835,22 @@ func (c *Client) RepoInfo(ctx context.Context, repos ...api.RepoName) (*protocol
 	return &res, err.ErrorOrNil()
 }
 
-// Remove removes the repository clone from gitserver.
+// Remove removes the repository clone from gitserver, including the copies on
+// its replicas.
 func (c *Client) Remove(ctx context.Context, repo api.RepoName) error {
 	req := &protocol.RepoDeleteRequest{
 		Repo: repo,
 	}
-	resp, err := c.httpPost(ctx, repo, "delete", req)
+	for _, addr := range c.addrsForRepo(ctx, repo) {
+		if err := c.remove(ctx, repo, "http://"+addr+"/delete", req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) remove(ctx context.Context, repo api.RepoName, uri string, req *protocol.RepoDeleteRequest) error {
+	resp, err := c.httpPost(ctx, repo, uri, req)
 	if err != nil {
 		return err
 	}
@@ -747,8 +867,61 @@ func (c *Client) httpPost(ctx context.Context, repo api.RepoName, op string, pay
 	return c.do(ctx, repo, "POST", op, payload)
 }
 
+// doRead is like do, but if the primary gitserver of repo can't be reached,
+// fails with a server error or doesn't have repo cloned, it retries the request
+// on the replicas of repo. It must only be used for requests which don't modify
+// the repository, and op must not be a URL.
+//
+// If no gitserver has repo cloned, the not found response of the primary
+// gitserver is returned, because it reports whether repo is being cloned.
+func (c *Client) doRead(ctx context.Context, repo api.RepoName, method, op string, payload interface{}) (resp *http.Response, err error) {
+	var notFound *http.Response
+	addrs := c.addrsForRepo(ctx, repo)
+	for i, addr := range addrs {
+		resp, err = c.do(ctx, repo, method, "http://"+addr+"/"+op, payload)
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		if err == nil && resp.StatusCode != http.StatusNotFound && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if err == nil && resp.StatusCode == http.StatusNotFound && notFound == nil {
+			// Buffer the small JSON body, so that the response can still be
+			// returned after trying the replicas.
+			body, readErr := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				return nil, readErr
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			notFound = resp
+		}
+		if i == len(addrs)-1 {
+			break
+		}
+
+		if err != nil {
+			log15.Warn("gitserver unreachable, failing over to next replica", "repo", repo, "addr", addr, "error", err)
+		} else {
+			log15.Warn("gitserver request failed, failing over to next replica", "repo", repo, "addr", addr, "status", resp.StatusCode)
+			if resp != notFound {
+				resp.Body.Close()
+			}
+		}
+		failoverCounter.Inc()
+	}
+	if notFound != nil {
+		if err == nil && resp != notFound {
+			resp.Body.Close()
+		}
+		return notFound, nil
+	}
+	return resp, err
+}
+
 // do performs a request to a gitserver, sharding based on the given
-// repo name (the repo name is otherwise not used).
+// repo name (the repo name is otherwise not used). If op is a URL, it is used
+// as is instead.
 func (c *Client) do(ctx context.Context, repo api.RepoName, method, op string, payload interface{}) (resp *http.Response, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Client.do")
 	defer func() {
@@ -811,3 +984,59 @@ func (c *Client) CreateCommitFromPatch(ctx context.Context, req protocol.CreateC
 
 	return res.Rev, json.NewDecoder(resp.Body).Decode(&res)
 }
+
+// Commits returns the commits matching req, or only their number if
+// req.CountOnly is set. It is answered from the repository's commit-graph on
+// gitserver.
+func (c *Client) Commits(ctx context.Context, req *protocol.CommitsRequest) (*protocol.CommitsResponse, error) {
+	var res protocol.CommitsResponse
+	if err := c.commitGraphRead(ctx, req.Repo, "commits", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// MergeBase returns the best common ancestor of the commits in req.
+func (c *Client) MergeBase(ctx context.Context, req *protocol.MergeBaseRequest) (*protocol.MergeBaseResponse, error) {
+	var res protocol.MergeBaseResponse
+	if err := c.commitGraphRead(ctx, req.Repo, "merge-base", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// IsAncestor returns whether req.Ancestor is an ancestor of req.Descendant.
+func (c *Client) IsAncestor(ctx context.Context, req *protocol.IsAncestorRequest) (*protocol.IsAncestorResponse, error) {
+	var res protocol.IsAncestorResponse
+	if err := c.commitGraphRead(ctx, req.Repo, "is-ancestor", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// commitGraphRead sends req to the commit graph endpoint op of gitserver and
+// decodes the response into res.
+func (c *Client) commitGraphRead(ctx context.Context, repo api.RepoName, op string, req, res interface{}) error {
+	repo = protocol.NormalizeRepo(repo)
+	resp, err := c.doRead(ctx, repo, "POST", op, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(resp.Body).Decode(res)
+
+	case http.StatusNotFound:
+		var payload protocol.NotFoundPayload
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			return err
+		}
+		return &vcs.RepoNotExistError{Repo: repo, CloneInProgress: payload.CloneInProgress, CloneProgress: payload.CloneProgress}
+
+	default:
+		b, _ := ioutil.ReadAll(resp.Body)
+		return &url.Error{URL: resp.Request.URL.String(), Op: op, Err: fmt.Errorf("%s: http status %d %s", op, resp.StatusCode, string(b))}
+	}
+}
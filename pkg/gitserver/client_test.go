@@ -175,3 +175,102 @@ filemode=true
 	}
 	return nil
 }
+
+func TestAddrForKey(t *testing.T) {
+	addrs := []string{"gitserver-0:3178", "gitserver-1:3178", "gitserver-2:3178"}
+	added := append(addrs, "gitserver-3:3178")
+
+	owners := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("github.com/foo/repo%d", i)
+		before := gitserver.AddrForKey(key, addrs)
+		after := gitserver.AddrForKey(key, added)
+		owners[after]++
+
+		// Adding a gitserver only moves repos to the new gitserver, and the
+		// previous owner is ranked second.
+		if after != before {
+			if after != "gitserver-3:3178" {
+				t.Fatalf("%s moved from %s to %s", key, before, after)
+			}
+			if ranked := gitserver.RankedAddrs(key, added); ranked[1] != before {
+				t.Fatalf("%s: got ranked addrs %v, want %s second", key, ranked, before)
+			}
+		}
+
+		// The order of the addresses doesn't matter.
+		if got := gitserver.AddrForKey(key, []string{added[3], added[1], added[0], added[2]}); got != after {
+			t.Fatalf("%s: got %s for reordered addrs, want %s", key, got, after)
+		}
+	}
+
+	// Repos are spread over all gitservers.
+	for _, addr := range added {
+		if owners[addr] < 150 {
+			t.Errorf("%s owns only %d of 1000 repos", addr, owners[addr])
+		}
+	}
+}
+
+func TestClient_failover(t *testing.T) {
+	// A gitserver which answers every exec request successfully.
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Exec-Exit-Status")
+		fmt.Fprint(w, "ok")
+		w.Header().Set("X-Exec-Exit-Status", "0")
+	}))
+	defer live.Close()
+
+	// An unreachable gitserver.
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	// A gitserver which fails every request.
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	// A gitserver which doesn't have any repository cloned.
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"cloneInProgress":false}`)
+	}))
+	defer empty.Close()
+
+	hostOf := func(rawurl string) string {
+		u, _ := url.Parse(rawurl)
+		return u.Host
+	}
+
+	for _, unhealthy := range []string{dead.URL, broken.URL, empty.URL} {
+		addrs := []string{hostOf(live.URL), hostOf(unhealthy)}
+
+		cli := gitserver.NewClient(&http.Client{})
+		cli.Addrs = func(context.Context) []string { return addrs }
+
+		for _, replicationFactor := range []int{1, 2} {
+			cli.ReplicationFactor = func() int { return replicationFactor }
+			var failed int
+			for i := 0; i < 20; i++ {
+				cmd := cli.Command("git", "rev-parse", "HEAD")
+				cmd.Repo = gitserver.Repo{Name: api.RepoName(fmt.Sprintf("github.com/foo/repo%d", i))}
+				out, err := cmd.Output(context.Background())
+				if err != nil {
+					failed++
+					continue
+				}
+				if string(out) != "ok" {
+					t.Fatalf("got output %q, want %q", out, "ok")
+				}
+			}
+
+			if replicationFactor == 1 && failed == 0 {
+				t.Errorf("%s: expected requests for repos on the unhealthy gitserver to fail without replication", unhealthy)
+			}
+			if replicationFactor == 2 && failed > 0 {
+				t.Errorf("%s: %d requests failed despite a healthy replica", unhealthy, failed)
+			}
+		}
+	}
+}
@@ -44,6 +44,19 @@ type RepoUpdateRequest struct {
 	Repo  api.RepoName  `json:"repo"`  // identifying URL for repo
 	URL   string        `json:"url"`   // repo's remote URL
 	Since time.Duration `json:"since"` // debounce interval for queries, used only with request-repo-update
+
+	// CloneOptions, if set, are used instead of the clone policies in the
+	// site configuration if the repo is cloned. The primary gitserver of a
+	// repo sends its options to its replicas.
+	CloneOptions *CloneOptions `json:"cloneOptions,omitempty"`
+}
+
+// CloneOptions configure a partial or shallow clone of a repo. They are set
+// by the gitClonePolicies site configuration.
+type CloneOptions struct {
+	Filter string   `json:"filter,omitempty"` // partial clone filter, e.g. "blob:limit=1m"
+	Depth  int      `json:"depth,omitempty"`  // number of commits of history to keep (0 means all)
+	Refs   []string `json:"refs,omitempty"`   // patterns of the refs to mirror (empty means the default refs)
 }
 
 // RepoUpdateResponse returns meta information of the repo enqueued for
@@ -115,6 +128,15 @@ type DeprecatedRepoInfoResponse struct {
 	// recloned automatically, so this time is likely to move forward
 	// periodically.
 	CloneTime *time.Time
+
+	// Size is the size of the repository on disk in bytes, as of its last
+	// clone or update. For a repository which is too large, it is the size
+	// it had when it was found to be too large.
+	Size int64
+
+	// TooLarge is whether the repository is not cloned because it is larger
+	// than the maximum repository size.
+	TooLarge bool
 }
 
 // RepoInfoRequest is a request for information about multiple repositories on gitserver.
@@ -129,6 +151,17 @@ type RepoDeleteRequest struct {
 	Repo api.RepoName
 }
 
+// RepoHandoffRequest is a request for a tar archive of a repository's bare
+// clone. It is sent by the gitserver which now owns the repository to the
+// gitserver which owned it before, so that the repository is copied instead of
+// cloned from the code host. Once the copy is in place, the same request is
+// sent to the gitserver's /repo-handoff-done endpoint so that it removes its
+// clone.
+type RepoHandoffRequest struct {
+	// Repo is the repository to hand off.
+	Repo api.RepoName
+}
+
 // RepoInfo is the information requests about a single repository
 // via a RepoInfoRequest.
 type RepoInfo struct {
@@ -143,6 +176,15 @@ type RepoInfo struct {
 	// recloned automatically, so this time is likely to move forward
 	// periodically.
 	CloneTime *time.Time
+
+	// Size is the size of the repository on disk in bytes, as of its last
+	// clone or update. For a repository which is too large, it is the size
+	// it had when it was found to be too large.
+	Size int64
+
+	// TooLarge is whether the repository is not cloned because it is larger
+	// than the maximum repository size.
+	TooLarge bool
 }
 
 // RepoInfoResponse is the response to a repository information request
@@ -181,3 +223,83 @@ type CreatePatchFromPatchResponse struct {
 	// Rev is the tag that the staging object can be found at
 	Rev string
 }
+
+// CommitsRequest is a request for the commits in a range of a repository's
+// history, or for the number of them.
+type CommitsRequest struct {
+	Repo api.RepoName
+
+	// URL and EnsureRevision are like in ExecRequest. If EnsureRevision
+	// doesn't exist, the repository is updated before the request is served.
+	URL            string `json:",omitempty"`
+	EnsureRevision string `json:",omitempty"`
+
+	Range        string // commit range (revspec, "A..B", "A...B", etc.)
+	N            uint   // limit the number of commits to this many (0 means no limit)
+	Skip         uint   // skip this many commits at the beginning
+	MessageQuery string // include only commits whose commit message contains this substring
+	Author       string // include only commits whose author matches this
+	After        string // include only commits after this date
+	Path         string // include only commits modifying this path
+
+	// CountOnly is whether only the number of commits is returned.
+	CountOnly bool
+}
+
+// CommitsResponse is the response to a CommitsRequest.
+type CommitsResponse struct {
+	Commits []*Commit `json:",omitempty"`
+	Count   uint
+
+	// RevisionNotFound is whether a revision in the range doesn't exist.
+	RevisionNotFound bool `json:",omitempty"`
+}
+
+// Commit is a commit returned by gitserver's commit graph endpoints.
+type Commit struct {
+	ID        api.CommitID
+	Author    Signature
+	Committer Signature
+	Message   string
+	Parents   []api.CommitID `json:",omitempty"`
+}
+
+// Signature is the author or committer of a Commit.
+type Signature struct {
+	Name  string
+	Email string
+	Date  time.Time
+}
+
+// MergeBaseRequest is a request for the best common ancestor of two commits.
+type MergeBaseRequest struct {
+	Repo api.RepoName
+	A, B api.CommitID
+}
+
+// MergeBaseResponse is the response to a MergeBaseRequest.
+type MergeBaseResponse struct {
+	// MergeBase is empty if the commits have no common ancestor.
+	MergeBase api.CommitID
+
+	// RevisionNotFound is whether A or B doesn't exist.
+	RevisionNotFound bool `json:",omitempty"`
+}
+
+// IsAncestorRequest is a request to determine if a commit is an ancestor of
+// another commit.
+type IsAncestorRequest struct {
+	Repo       api.RepoName
+	Ancestor   api.CommitID
+	Descendant api.CommitID
+}
+
+// IsAncestorResponse is the response to an IsAncestorRequest.
+type IsAncestorResponse struct {
+	// IsAncestor is true if Ancestor is an ancestor of Descendant or the same
+	// commit.
+	IsAncestor bool
+
+	// RevisionNotFound is whether Ancestor or Descendant doesn't exist.
+	RevisionNotFound bool `json:",omitempty"`
+}
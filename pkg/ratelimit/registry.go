@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+var requestsPerHourGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "src",
+	Subsystem: "extsvc",
+	Name:      "rate_limit_requests_per_hour",
+	Help:      "The configured number of API requests per hour made with the credentials of an external service, +Inf if unlimited.",
+}, []string{"external_service_id"})
+
+func init() {
+	prometheus.MustRegister(requestsPerHourGauge)
+}
+
+// DefaultRegistry is the Registry of the rate limiters of the external
+// services used by this process.
+var DefaultRegistry = NewRegistry()
+
+// Registry holds one rate limiter per external service, so that all the API
+// clients which use the credentials of an external service share its budget
+// of requests, no matter how many code paths use them.
+type Registry struct {
+	mu sync.Mutex
+	// rateLimiters is keyed by the ID of the external service.
+	rateLimiters map[int64]*rate.Limiter
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{rateLimiters: make(map[int64]*rate.Limiter)}
+}
+
+// Get returns the rate limiter of the external service with the given ID. If
+// there is none yet, it returns a new one which doesn't limit requests until
+// it is configured.
+func (r *Registry) Get(id int64) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.rateLimiters[id]; ok {
+		return l
+	}
+	l := rate.NewLimiter(rate.Inf, 1)
+	r.rateLimiters[id] = l
+	requestsPerHourGauge.WithLabelValues(strconv.FormatInt(id, 10)).Set(math.Inf(1))
+	return l
+}
+
+// Configure sets the limit of the rate limiter of the external service with
+// the given ID to requestsPerHour if enabled is true, and lifts it otherwise.
+// Up to a minute's worth of requests may be made at once. It returns the rate
+// limiter, which is updated in place so that the clients already using it
+// observe the new limit.
+func (r *Registry) Configure(id int64, enabled bool, requestsPerHour float64) *rate.Limiter {
+	l := r.Get(id)
+	limit, burst := rate.Inf, 1
+	if enabled {
+		limit = rate.Limit(requestsPerHour / 3600)
+		if burst = int(requestsPerHour / 60); burst < 1 {
+			burst = 1
+		}
+	}
+	l.SetBurst(burst)
+	l.SetLimit(limit)
+	requestsPerHourGauge.WithLabelValues(strconv.FormatInt(id, 10)).Set(requestsPerHourOf(limit))
+	return l
+}
+
+// requestsPerHourOf returns the number of requests per hour allowed by the given
+// limit, or +Inf if it is unlimited.
+func requestsPerHourOf(limit rate.Limit) float64 {
+	if limit == rate.Inf {
+		return math.Inf(1)
+	}
+	return float64(limit) * 3600
+}
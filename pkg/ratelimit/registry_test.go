@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	l := r.Get(1)
+	if l.Limit() != rate.Inf {
+		t.Fatalf("got limit %v, want unlimited", l.Limit())
+	}
+	if got := r.Get(1); got != l {
+		t.Fatal("expected the same rate limiter for the same external service")
+	}
+	if got := r.Get(2); got == l {
+		t.Fatal("expected different rate limiters for different external services")
+	}
+
+	if got := r.Configure(1, true, 7200); got != l {
+		t.Fatal("expected Configure to update the rate limiter in place")
+	}
+	if want := rate.Limit(2); l.Limit() != want {
+		t.Errorf("got limit %v, want %v", l.Limit(), want)
+	}
+	if want := 120; l.Burst() != want {
+		t.Errorf("got burst %d, want %d", l.Burst(), want)
+	}
+	if r.Get(2).Limit() != rate.Inf {
+		t.Error("expected the rate limiters of other external services to be left as is")
+	}
+
+	r.Configure(1, false, 7200)
+	if l.Limit() != rate.Inf {
+		t.Errorf("got limit %v, want unlimited", l.Limit())
+	}
+}
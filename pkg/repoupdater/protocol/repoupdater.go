@@ -26,6 +26,9 @@ type RepoScheduleState struct {
 	Total           int
 	IntervalSeconds int
 	Due             time.Time
+	LastUpdated     *time.Time `json:",omitempty"` // when the last update finished, if any
+	LastChanged     *time.Time `json:",omitempty"` // when the repo last changed, if known
+	LastError       string     `json:",omitempty"` // the error of the last update, if it failed
 }
 
 type RepoQueueState struct {
@@ -0,0 +1,62 @@
+package structural
+
+import "bytes"
+
+// ReplaceAll returns a copy of buf in which every match of p is replaced
+// by the result of substituting the holes of the match into template. n is
+// the number of matches replaced. limitHit is true if matching gave up
+// before the end of buf, in which case later matches are left as is.
+func (p *Pattern) ReplaceAll(buf []byte, template string) (out []byte, n int, limitHit bool) {
+	matches, limitHit := p.FindAll(buf, -1)
+	if len(matches) == 0 {
+		return buf, 0, limitHit
+	}
+
+	out = make([]byte, 0, len(buf))
+	last := 0
+	for _, m := range matches {
+		out = append(out, buf[last:m.Start]...)
+		out = append(out, Substitute(template, m.Holes)...)
+		last = m.End
+	}
+	out = append(out, buf[last:]...)
+	return out, len(matches), limitHit
+}
+
+// Substitute returns template with every hole of the form :[name] in it
+// replaced by holes[name]. Holes which are not in holes, such as :[_], are
+// replaced by the empty string. Text which does not form a valid hole is
+// copied as is.
+func Substitute(template string, holes map[string][]byte) []byte {
+	t := []byte(template)
+	out := make([]byte, 0, len(t))
+	for i := 0; i < len(t); {
+		if name, end, ok := parseHole(t[i:]); ok {
+			out = append(out, holes[string(name)]...)
+			i += end
+			continue
+		}
+		out = append(out, t[i])
+		i++
+	}
+	return out
+}
+
+// parseHole parses the hole at the start of b. It returns the name of the
+// hole and the length of the hole in bytes.
+func parseHole(b []byte) (name []byte, end int, ok bool) {
+	if !bytes.HasPrefix(b, []byte(":[")) {
+		return nil, 0, false
+	}
+	j := bytes.IndexByte(b, ']')
+	if j < 0 {
+		return nil, 0, false
+	}
+	name = b[2:j]
+	for _, c := range name {
+		if !isWordByte(c) {
+			return nil, 0, false
+		}
+	}
+	return name, j + 1, true
+}
@@ -0,0 +1,55 @@
+package structural
+
+import "testing"
+
+func TestReplaceAll(t *testing.T) {
+	cases := []struct {
+		pattern, template string
+		src, want         string
+		n                 int
+	}{
+		{
+			pattern:  "fmt.Sprintf(:[x])",
+			template: "fmt.Sprint(:[x])",
+			src:      "a := fmt.Sprintf(\"%d\", f(1, 2))\nb := fmt.Sprintf(y)\n",
+			want:     "a := fmt.Sprint(\"%d\", f(1, 2))\nb := fmt.Sprint(y)\n",
+			n:        2,
+		},
+		{
+			pattern:  "if :[a] == :[b] {:[body]}",
+			template: "if :[b] == :[a] {:[body]}",
+			src:      "if x == f(y) { return }",
+			want:     "if f(y) == x { return }",
+			n:        1,
+		},
+		{
+			pattern:  "foo(:[_], :[y])",
+			template: "bar(:[_]:[y], :[z)",
+			src:      "foo(a, b)",
+			want:     "bar(b, :[z)",
+			n:        1,
+		},
+		{
+			pattern:  "nope(:[x])",
+			template: ":[x]",
+			src:      "foo(a, b)",
+			want:     "foo(a, b)",
+			n:        0,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			p, err := Compile(tc.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, n, limitHit := p.ReplaceAll([]byte(tc.src), tc.template)
+			if limitHit {
+				t.Error("unexpected limitHit")
+			}
+			if string(got) != tc.want || n != tc.n {
+				t.Errorf("got %q (%d replacements), want %q (%d replacements)", got, n, tc.want, tc.n)
+			}
+		})
+	}
+}
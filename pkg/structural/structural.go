@@ -0,0 +1,354 @@
+// Package structural implements matching and rewriting of structural
+// patterns. A structural pattern is code with holes of the form :[name] in
+// it, which match any code with balanced delimiters.
+package structural
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSteps bounds the amount of backtracking done when matching a
+// structural pattern against a single file. Patterns with many holes can
+// otherwise take time exponential in the size of the file.
+const maxSteps = 1000000
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+const (
+	// tokLiteral matches its text exactly.
+	tokLiteral tokenKind = iota
+
+	// tokSpace matches a run of whitespace.
+	tokSpace
+
+	// tokHole matches a (possibly empty) sequence of balanced text.
+	tokHole
+)
+
+type token struct {
+	kind tokenKind
+
+	// text is the literal text for tokLiteral, and the name of the hole for
+	// tokHole.
+	text []byte
+
+	// optional is true for tokSpace if the whitespace may be omitted in the
+	// input. This is the case when it is next to punctuation, so that
+	// "foo(a, b)" matches "foo(a,b)".
+	optional bool
+
+	// multiline is true for tokHole if the hole appears inside delimiters in
+	// the pattern. Only such holes may match across lines, otherwise a hole
+	// at the top level of a pattern could match most of a file.
+	multiline bool
+}
+
+// Pattern is a compiled structural pattern.
+//
+// In a structural pattern, holes of the form :[name] match any text in
+// which parentheses, brackets and braces are balanced and string literals
+// are closed. Holes which share a name (other than "_") must match the same
+// text. Whitespace in the pattern matches any whitespace in the input. All
+// other text is matched literally.
+type Pattern struct {
+	tokens []token
+
+	// literal is the longest literal in the pattern. It appears in every
+	// match, so it is used to quickly skip files.
+	literal []byte
+}
+
+// Literal returns the longest literal text in p. Every match of p contains
+// it, so buffers which don't can be skipped.
+func (p *Pattern) Literal() []byte {
+	return p.literal
+}
+
+// Match is a match of a Pattern.
+type Match struct {
+	// Start and End are the byte offsets of the match.
+	Start, End int
+
+	// Holes maps the names of the named holes in the pattern to the text
+	// they matched. Anonymous holes (:[_] and :[]) are not included.
+	Holes map[string][]byte
+}
+
+var (
+	openDelims  = map[byte]byte{'(': ')', '[': ']', '{': '}'}
+	closeDelims = map[byte]bool{')': true, ']': true, '}': true}
+)
+
+// Compile parses a structural pattern.
+func Compile(pattern string) (*Pattern, error) {
+	var (
+		p     Pattern
+		lit   []byte
+		depth int
+	)
+	flushLiteral := func() {
+		if len(lit) == 0 {
+			return
+		}
+		p.tokens = append(p.tokens, token{kind: tokLiteral, text: lit})
+		if len(lit) > len(p.literal) {
+			p.literal = lit
+		}
+		lit = nil
+	}
+
+	b := []byte(strings.TrimSpace(pattern))
+	for i := 0; i < len(b); {
+		switch {
+		case isSpace(b[i]):
+			flushLiteral()
+			j := i
+			for j < len(b) && isSpace(b[j]) {
+				j++
+			}
+			optional := i == 0 || j == len(b) || !isWordByte(b[i-1]) || !isWordByte(b[j])
+			p.tokens = append(p.tokens, token{kind: tokSpace, optional: optional})
+			i = j
+
+		case bytes.HasPrefix(b[i:], []byte(":[")):
+			end := bytes.IndexByte(b[i:], ']')
+			if end < 0 {
+				return nil, errors.New("structural pattern has an unterminated hole (holes look like :[name])")
+			}
+			name := b[i+2 : i+end]
+			for _, c := range name {
+				if !isWordByte(c) {
+					return nil, errors.New("structural pattern hole names may only contain letters, digits and underscores")
+				}
+			}
+			flushLiteral()
+			if n := len(p.tokens); n > 0 && p.tokens[n-1].kind == tokHole {
+				return nil, errors.New("structural pattern holes must be separated by other text")
+			}
+			p.tokens = append(p.tokens, token{kind: tokHole, text: name, multiline: depth > 0})
+			i += end + 1
+
+		default:
+			if _, ok := openDelims[b[i]]; ok {
+				depth++
+			} else if closeDelims[b[i]] && depth > 0 {
+				depth--
+			}
+			lit = append(lit, b[i])
+			i++
+		}
+	}
+	flushLiteral()
+
+	if len(p.literal) == 0 {
+		return nil, errors.New("structural pattern must contain text other than holes and whitespace")
+	}
+	return &p, nil
+}
+
+// matcher holds the state for matching a Pattern against a
+// single buffer.
+type matcher struct {
+	p        *Pattern
+	buf      []byte
+	bindings map[string][]byte
+	steps    int
+}
+
+// FindAll returns up to limit non-overlapping matches of p in buf. If limit
+// is negative there is no limit on the number of matches. limitHit is true if
+// there may be more matches.
+func (p *Pattern) FindAll(buf []byte, limit int) (matches []Match, limitHit bool) {
+	m := &matcher{p: p, buf: buf, bindings: map[string][]byte{}}
+
+	// Matches may only start at the first literal of the pattern, so we skip
+	// straight to its occurrences. If the pattern starts with a hole, we
+	// still need to consider every offset.
+	var first []byte
+	if tok := p.tokens[0]; tok.kind == tokLiteral {
+		first = tok.text
+	}
+
+	for start := 0; start <= len(buf); {
+		if first != nil {
+			idx := bytes.Index(buf[start:], first)
+			if idx < 0 {
+				break
+			}
+			start += idx
+		}
+
+		for k := range m.bindings {
+			delete(m.bindings, k)
+		}
+		end, ok := m.match(0, start)
+		if m.steps > maxSteps {
+			return matches, true
+		}
+		if !ok {
+			start++
+			continue
+		}
+		if len(matches) == limit {
+			return matches, true
+		}
+		holes := make(map[string][]byte, len(m.bindings))
+		for k, v := range m.bindings {
+			holes[k] = v
+		}
+		matches = append(matches, Match{Start: start, End: end, Holes: holes})
+		if end > start {
+			start = end
+		} else {
+			start++
+		}
+	}
+	return matches, false
+}
+
+// match reports whether the tokens from ti onwards match buf at offset i. If
+// so, the offset where the match ends is returned.
+func (m *matcher) match(ti, i int) (int, bool) {
+	m.steps++
+	if m.steps > maxSteps {
+		return 0, false
+	}
+	if ti == len(m.p.tokens) {
+		return i, true
+	}
+
+	tok := m.p.tokens[ti]
+	switch tok.kind {
+	case tokLiteral:
+		if !bytes.HasPrefix(m.buf[i:], tok.text) {
+			return 0, false
+		}
+		return m.match(ti+1, i+len(tok.text))
+
+	case tokSpace:
+		j := i
+		for j < len(m.buf) && isSpace(m.buf[j]) {
+			j++
+		}
+		if j == i && !tok.optional {
+			return 0, false
+		}
+		return m.match(ti+1, j)
+
+	case tokHole:
+		name := string(tok.text)
+		if bound, ok := m.bindings[name]; ok {
+			if !bytes.HasPrefix(m.buf[i:], bound) {
+				return 0, false
+			}
+			return m.match(ti+1, i+len(bound))
+		}
+
+		// Holes are lazy: we try the shortest extension first and grow the
+		// hole one balanced unit at a time.
+		for j := i; ; {
+			if name != "_" && name != "" {
+				m.bindings[name] = m.buf[i:j]
+			}
+			if end, ok := m.match(ti+1, j); ok {
+				return end, true
+			}
+			next, ok := m.nextUnit(j, tok.multiline)
+			if !ok {
+				break
+			}
+			j = next
+		}
+		delete(m.bindings, name)
+		return 0, false
+	}
+	return 0, false
+}
+
+// nextUnit returns the offset after the balanced unit that starts at offset
+// i. A unit is a delimited group including its contents, a string literal, or
+// a single other character. ok is false if a hole may not be extended past i.
+func (m *matcher) nextUnit(i int, multiline bool) (next int, ok bool) {
+	if i >= len(m.buf) {
+		return 0, false
+	}
+	c := m.buf[i]
+	switch {
+	case closeDelims[c]:
+		return 0, false
+	case c == '\n' && !multiline:
+		return 0, false
+	case openDelims[c] != 0:
+		return m.skipDelimited(i)
+	case c == '"' || c == '\'' || c == '`':
+		if end, ok := m.skipString(i); ok {
+			return end, true
+		}
+	}
+	_, size := utf8.DecodeRune(m.buf[i:])
+	return i + size, true
+}
+
+// skipDelimited returns the offset after the delimiter which closes the one
+// at offset i. ok is false if it is never closed.
+func (m *matcher) skipDelimited(i int) (end int, ok bool) {
+	stack := []byte{openDelims[m.buf[i]]}
+	for j := i + 1; j < len(m.buf); {
+		m.steps++
+		c := m.buf[j]
+		switch {
+		case c == stack[len(stack)-1]:
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return j + 1, true
+			}
+		case closeDelims[c]:
+			// Mismatched closing delimiter.
+			return 0, false
+		case openDelims[c] != 0:
+			stack = append(stack, openDelims[c])
+		case c == '"' || c == '\'' || c == '`':
+			if end, ok := m.skipString(j); ok {
+				j = end
+				continue
+			}
+		}
+		j++
+	}
+	return 0, false
+}
+
+// skipString returns the offset after the string literal which starts with
+// the quote at offset i. Only backtick strings may span lines, so that
+// apostrophes in comments are not treated as the start of a string.
+func (m *matcher) skipString(i int) (end int, ok bool) {
+	quote := m.buf[i]
+	for j := i + 1; j < len(m.buf); j++ {
+		switch m.buf[j] {
+		case quote:
+			return j + 1, true
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		case '\n':
+			if quote != '`' {
+				return 0, false
+			}
+		}
+	}
+	return 0, false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c < utf8.RuneSelf && (unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)))
+}
@@ -0,0 +1,73 @@
+package structural
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindAll(t *testing.T) {
+	src := `x := foo(a, b(c, d), nil)
+foo(
+	x,
+	nil)
+foo(bar("),"), nil)
+foo(a, b)
+// don't foo(x)
+if a == a { return }
+if a == b {}
+`
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{"foo(:[args], nil)", []string{
+			"foo(a, b(c, d), nil)",
+			"foo(\n\tx,\n\tnil)",
+			`foo(bar("),"), nil)`,
+		}},
+		{"foo(:[x])", []string{
+			"foo(a, b(c, d), nil)",
+			"foo(\n\tx,\n\tnil)",
+			`foo(bar("),"), nil)`,
+			"foo(a, b)",
+			"foo(x)",
+		}},
+		{"foo( a , b )", []string{"foo(a, b)"}},
+		{"if :[a] == :[a] {:[_]}", []string{"if a == a { return }"}},
+		{"bar(:[x]", []string{"bar("}},
+		{"nope(:[x])", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			p, err := Compile(tc.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matches, limitHit := p.FindAll([]byte(src), -1)
+			if limitHit {
+				t.Error("unexpected limitHit")
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, src[m.Start:m.End])
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompile_invalid(t *testing.T) {
+	for _, pattern := range []string{
+		"",
+		":[x]",
+		"foo(:[x",
+		"foo(:[x-y])",
+		"foo(:[x]:[y])",
+	} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("%q: expected error", pattern)
+		}
+	}
+}
@@ -56,3 +56,56 @@ type Symbol struct {
 
 	FileLimited bool
 }
+
+// ReferencesArgs are the arguments to find the references to a symbol on the
+// symbols service.
+type ReferencesArgs struct {
+	// Repo is the name of the repository to search in.
+	Repo api.RepoName `json:"repo"`
+
+	// CommitID is the commit to search in.
+	CommitID api.CommitID `json:"commitID"`
+
+	// Symbol is the name of the symbol whose references to find. It is
+	// matched case sensitively and as a whole word.
+	Symbol string
+
+	// First indicates that only the first n references should be returned.
+	First int
+}
+
+// ReferencesResult is the result of finding the references to a symbol on the
+// symbols service.
+type ReferencesResult struct {
+	// Definitions are the symbols with the given name.
+	Definitions []Symbol
+
+	// References are the candidate references to the symbol, most likely
+	// references first. They are found by text search, so some of them may
+	// refer to a different symbol with the same name.
+	References []Reference
+
+	// LimitHit is true if References may not include all references.
+	LimitHit bool
+}
+
+// Reference is a candidate reference to a symbol.
+type Reference struct {
+	Path string
+
+	// Line is the 1-based line number, like Symbol.Line.
+	Line int
+
+	// Character is the 0-based character offset of the reference in the line.
+	Character int
+
+	// Preview is the line containing the reference.
+	Preview string
+
+	// Language is the language of the file, if known.
+	Language string
+
+	// Score is how likely this is a reference to one of the definitions.
+	// Higher is more likely.
+	Score int
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs"
 )
 
 type Commit struct {
@@ -97,11 +99,64 @@ func Commits(ctx context.Context, repo gitserver.Repo, opt CommitsOptions) ([]*C
 	span.SetTag("Opt", opt)
 	defer span.Finish()
 
-	if err := checkSpecArgSafety(string(opt.Range)); err != nil {
+	res, err := commits(ctx, repo, opt, false)
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]*Commit, len(res.Commits))
+	for i, c := range res.Commits {
+		committer := Signature(c.Committer)
+		commits[i] = &Commit{
+			ID:        c.ID,
+			Author:    Signature(c.Author),
+			Committer: &committer,
+			Message:   c.Message,
+			Parents:   c.Parents,
+		}
+	}
+	return commits, nil
+}
+
+// commits sends a commits request for opt to gitserver, which answers it from
+// the repository's commit-graph. Like commandRetryer, it retries the request
+// with the repository's remote URL if the repository or a revision in
+// opt.Range doesn't exist, so that gitserver clones or fetches it.
+func commits(ctx context.Context, repo gitserver.Repo, opt CommitsOptions, countOnly bool) (*protocol.CommitsResponse, error) {
+	if err := checkSpecArgSafety(opt.Range); err != nil {
 		return nil, err
 	}
 
-	return commitLog(ctx, repo, opt)
+	req := &protocol.CommitsRequest{
+		Repo:         repo.Name,
+		Range:        opt.Range,
+		N:            opt.N,
+		Skip:         opt.Skip,
+		MessageQuery: opt.MessageQuery,
+		Author:       opt.Author,
+		After:        opt.After,
+		Path:         opt.Path,
+		CountOnly:    countOnly,
+	}
+	res, err := gitserver.DefaultClient.Commits(ctx, req)
+
+	notFound := vcs.IsRepoNotExist(err) || (err == nil && res.RevisionNotFound)
+	if notFound && opt.Range != "" && opt.Range != "HEAD" && (repo.URL != "" || opt.RemoteURLFunc != nil) {
+		req.URL = repo.URL
+		if req.URL == "" {
+			if req.URL, err = opt.RemoteURLFunc(); err != nil {
+				return nil, err
+			}
+		}
+		req.EnsureRevision = opt.Range
+		res, err = gitserver.DefaultClient.Commits(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.RevisionNotFound {
+		return nil, &gitserver.RevisionNotFoundError{Repo: repo.Name, Spec: opt.Range}
+	}
+	return res, nil
 }
 
 // HasCommitAfter indicates the staleness of a repository. It returns a boolean indicating if a repository
@@ -139,7 +194,10 @@ func HasCommitAfter(ctx context.Context, repo gitserver.Repo, date string, revsp
 		return false, err
 	}
 
+	// Only one commit is needed to know that there is one, so gitserver can
+	// stop walking the history at the first commit after date.
 	n, err := CommitCount(ctx, repo, CommitsOptions{
+		N:     1,
 		After: date,
 		Range: string(commitid),
 	})
@@ -246,24 +304,11 @@ func CommitCount(ctx context.Context, repo gitserver.Repo, opt CommitsOptions) (
 	span.SetTag("Opt", opt)
 	defer span.Finish()
 
-	args, err := commitLogArgs([]string{"rev-list", "--count"}, opt)
+	res, err := commits(ctx, repo, opt, true)
 	if err != nil {
 		return 0, err
 	}
-
-	cmd := gitserver.DefaultClient.Command("git", args...)
-	cmd.Repo = repo
-	if opt.Path != "" {
-		// This doesn't include --follow flag because rev-list doesn't support it, so the number may be slightly off.
-		cmd.Args = append(cmd.Args, "--", opt.Path)
-	}
-	out, err := cmd.CombinedOutput(ctx)
-	if err != nil {
-		return 0, errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, out))
-	}
-	out = bytes.TrimSpace(out)
-	n, err := strconv.ParseUint(string(out), 10, 64)
-	return uint(n), err
+	return res.Count, nil
 }
 
 const (
@@ -1,14 +1,13 @@
 package git
 
 import (
-	"bytes"
 	"context"
-	"fmt"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
 )
 
 // MergeBase returns the merge base commit for the specified commits.
@@ -18,11 +17,33 @@ func MergeBase(ctx context.Context, repo gitserver.Repo, a, b api.CommitID) (api
 	span.SetTag("B", b)
 	defer span.Finish()
 
-	cmd := gitserver.DefaultClient.Command("git", "merge-base", "--", string(a), string(b))
-	cmd.Repo = repo
-	out, err := cmd.CombinedOutput(ctx)
+	res, err := gitserver.DefaultClient.MergeBase(ctx, &protocol.MergeBaseRequest{Repo: repo.Name, A: a, B: b})
 	if err != nil {
-		return "", errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, out))
+		return "", err
 	}
-	return api.CommitID(bytes.TrimSpace(out)), nil
+	if res.RevisionNotFound {
+		return "", &gitserver.RevisionNotFoundError{Repo: repo.Name, Spec: string(a) + "..." + string(b)}
+	}
+	if res.MergeBase == "" {
+		return "", errors.Errorf("no merge base for %s and %s", a, b)
+	}
+	return res.MergeBase, nil
+}
+
+// IsAncestor returns whether commit ancestor is an ancestor of commit
+// descendant (or the same commit).
+func IsAncestor(ctx context.Context, repo gitserver.Repo, ancestor, descendant api.CommitID) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: IsAncestor")
+	span.SetTag("Ancestor", ancestor)
+	span.SetTag("Descendant", descendant)
+	defer span.Finish()
+
+	res, err := gitserver.DefaultClient.IsAncestor(ctx, &protocol.IsAncestorRequest{Repo: repo.Name, Ancestor: ancestor, Descendant: descendant})
+	if err != nil {
+		return false, err
+	}
+	if res.RevisionNotFound {
+		return false, &gitserver.RevisionNotFoundError{Repo: repo.Name, Spec: string(ancestor) + "..." + string(descendant)}
+	}
+	return res.IsAncestor, nil
 }
@@ -70,5 +70,12 @@ func TestMerger_MergeBase(t *testing.T) {
 			t.Errorf("%s: MergeBase(%s, %s): got %q, want %q", label, a, b, mb, want)
 			continue
 		}
+
+		if ok, err := git.IsAncestor(ctx, test.repo, mb, a); err != nil || !ok {
+			t.Errorf("%s: IsAncestor(%s, %s): got %v, %v, want true", label, mb, a, ok, err)
+		}
+		if ok, err := git.IsAncestor(ctx, test.repo, a, b); err != nil || ok {
+			t.Errorf("%s: IsAncestor(%s, %s): got %v, %v, want false", label, a, b, ok, err)
+		}
 	}
 }
@@ -44,6 +44,35 @@ const AWSCodeCommitSchemaJSON = `{
       "description": "The AWS secret access key (that corresponds to the AWS access key ID set in ` + "`" + `accessKeyID` + "`" + `).",
       "type": "string"
     },
+    "rateLimit": {
+      "description": "The budget of API requests Sourcegraph makes to AWS CodeCommit. It is shared by all of Sourcegraph's requests made with the credentials of this external service.",
+      "title": "AWSCodeCommitRateLimit",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["enabled", "requestsPerHour"],
+      "properties": {
+        "enabled": {
+          "description": "Whether Sourcegraph limits the number of requests it makes to AWS CodeCommit.",
+          "type": "boolean",
+          "default": true
+        },
+        "requestsPerHour": {
+          "description": "The number of requests per hour that Sourcegraph makes at most to AWS CodeCommit. Up to a minute's worth of requests can be made at once.",
+          "type": "number",
+          "minimum": 1
+        }
+      },
+      "default": {
+        "enabled": true,
+        "requestsPerHour": 7200
+      },
+      "examples": [
+        {
+          "enabled": true,
+          "requestsPerHour": 3600
+        }
+      ]
+    },
     "gitCredentials": {
       "title": "AWSCodeCommitGitCredentials",
       "description": "The Git credentials used for authentication when cloning an AWS CodeCommit repository over HTTPS.\n\nSee the AWS CodeCommit documentation on Git credentials for CodeCommit: https://docs.aws.amazon.com/IAM/latest/UserGuide/id_credentials_ssh-keys.html#git-credentials-code-commit.\nFor detailed instructions on how to create the credentials in IAM, see this page: https://docs.aws.amazon.com/codecommit/latest/userguide/setting-up-gc.html",
@@ -39,6 +39,35 @@ const BitbucketCloudSchemaJSON = `{
       "default": "http",
       "examples": ["ssh"]
     },
+    "rateLimit": {
+      "description": "The budget of API requests Sourcegraph makes to Bitbucket Cloud. It is shared by all of Sourcegraph's requests made with the credentials of this external service.",
+      "title": "BitbucketCloudRateLimit",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["enabled", "requestsPerHour"],
+      "properties": {
+        "enabled": {
+          "description": "Whether Sourcegraph limits the number of requests it makes to Bitbucket Cloud.",
+          "type": "boolean",
+          "default": true
+        },
+        "requestsPerHour": {
+          "description": "The number of requests per hour that Sourcegraph makes at most to Bitbucket Cloud. Up to a minute's worth of requests can be made at once.",
+          "type": "number",
+          "minimum": 1
+        }
+      },
+      "default": {
+        "enabled": true,
+        "requestsPerHour": 7200
+      },
+      "examples": [
+        {
+          "enabled": true,
+          "requestsPerHour": 3600
+        }
+      ]
+    },
     "repositoryPathPattern": {
       "description": "The pattern used to generate the corresponding Sourcegraph repository name for a Bitbucket Cloud repository.\n\n - \"{host}\" is replaced with the Bitbucket Cloud URL's host (such as bitbucket.org),  and \"{nameWithOwner}\" is replaced with the Bitbucket Cloud repository's \"owner/path\" (such as \"myorg/myrepo\").\n\nFor example, if your Bitbucket Cloud is https://bitbucket.org and your Sourcegraph is https://src.example.com, then a repositoryPathPattern of \"{host}/{nameWithOwner}\" would mean that a Bitbucket Cloud repository at https://bitbucket.org/alice/my-repo is available on Sourcegraph at https://src.example.com/bitbucket.org/alice/my-repo.\n\nIt is important that the Sourcegraph repository name generated with this pattern be unique to this code host. If different code hosts generate repository names that collide, Sourcegraph's behavior is undefined.",
       "type": "string",
@@ -49,6 +78,55 @@ const BitbucketCloudSchemaJSON = `{
       "type": "array",
       "items": { "type": "string", "pattern": "^\\w+$" },
       "examples": [["name"], ["kubernetes", "golang", "facebook"]]
+    },
+    "authorization": {
+      "title": "BitbucketCloudAuthorization",
+      "description": "If non-null, enforces Bitbucket Cloud repository permissions. The configured username must be an administrator of the configured teams.",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["identityProvider"],
+      "properties": {
+        "identityProvider": {
+          "description": "The source of identity to use when computing permissions. This defines how to compute the Bitbucket Cloud identity to use for a given Sourcegraph user. When 'username' is used, Sourcegraph assumes usernames are identical in Sourcegraph and Bitbucket Cloud accounts and ` + "`" + `auth.enableUsernameChanges` + "`" + ` must be set to false for security reasons.",
+          "title": "BitbucketCloudIdentityProvider",
+          "type": "object",
+          "required": ["type"],
+          "properties": {
+            "type": {
+              "type": "string",
+              "enum": ["username"]
+            }
+          },
+          "oneOf": [{ "$ref": "#/definitions/UsernameIdentity" }],
+          "!go": {
+            "taggedUnionType": true
+          }
+        },
+        "ttl": {
+          "description": "Duration after which a user's cached permissions will be updated in the background (during which time the previously cached permissions will be used). This is 3 hours by default.\n\nDecreasing the TTL will increase the load on the code host API. If you have X repos on your instance, it will take ~X/100 API requests to fetch the complete list for 1 user.  If you have Y users, you will incur X*Y/100 API requests per cache refresh period.\n\nIf set to zero, Sourcegraph will sync a user's entire accessible repository list on every request (NOT recommended).",
+          "type": "string",
+          "default": "3h"
+        },
+        "hardTTL": {
+          "description": "Duration after which a user's cached permissions must be updated before authorizing any user actions. This is 3 days by default.",
+          "type": "string",
+          "default": "72h"
+        }
+      }
+    }
+  },
+  "definitions": {
+    "UsernameIdentity": {
+      "title": "BitbucketCloudUsernameIdentity",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["type"],
+      "properties": {
+        "type": {
+          "type": "string",
+          "const": "username"
+        }
+      }
     }
   }
 }
@@ -64,6 +64,40 @@ const BitbucketServerSchemaJSON = `{
       "pattern": "^-----BEGIN CERTIFICATE-----\n",
       "examples": ["-----BEGIN CERTIFICATE-----\n..."]
     },
+    "webhookSecret": {
+      "description": "The secret used to sign the payloads of Bitbucket Server webhooks. If set, a Bitbucket Server webhook for repository push events can be configured to post to the concatenation of your Sourcegraph instance URL and \"/.api/webhooks/bitbucket-server\" with this secret. Sourcegraph then updates a repository as soon as it is pushed to, instead of waiting for its next scheduled update.",
+      "type": "string",
+      "minLength": 1
+    },
+    "rateLimit": {
+      "description": "The budget of API requests Sourcegraph makes to Bitbucket Server. It is shared by all of Sourcegraph's requests made with the credentials of this external service.",
+      "title": "BitbucketServerRateLimit",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["enabled", "requestsPerHour"],
+      "properties": {
+        "enabled": {
+          "description": "Whether Sourcegraph limits the number of requests it makes to Bitbucket Server.",
+          "type": "boolean",
+          "default": true
+        },
+        "requestsPerHour": {
+          "description": "The number of requests per hour that Sourcegraph makes at most to Bitbucket Server. Up to a minute's worth of requests can be made at once.",
+          "type": "number",
+          "minimum": 1
+        }
+      },
+      "default": {
+        "enabled": true,
+        "requestsPerHour": 7200
+      },
+      "examples": [
+        {
+          "enabled": true,
+          "requestsPerHour": 3600
+        }
+      ]
+    },
     "repositoryPathPattern": {
       "description": "The pattern used to generate the corresponding Sourcegraph repository name for a Bitbucket Server repository.\n\n - \"{host}\" is replaced with the Bitbucket Server URL's host (such as bitbucket.example.com)\n - \"{projectKey}\" is replaced with the Bitbucket repository's parent project key (such as \"PRJ\")\n - \"{repositorySlug}\" is replaced with the Bitbucket repository's slug key (such as \"my-repo\").\n\nFor example, if your Bitbucket Server is https://bitbucket.example.com and your Sourcegraph is https://src.example.com, then a repositoryPathPattern of \"{host}/{projectKey}/{repositorySlug}\" would mean that a Bitbucket Server repository at https://bitbucket.example.com/projects/PRJ/repos/my-repo is available on Sourcegraph at https://src.example.com/bitbucket.example.com/PRJ/my-repo.\n\nIt is important that the Sourcegraph repository name generated with this pattern be unique to this code host. If different code hosts generate repository names that collide, Sourcegraph's behavior is undefined.",
       "type": "string",
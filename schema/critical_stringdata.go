@@ -202,6 +202,16 @@ const CriticalSchemaJSON = `{
           "description": "Only allow users to authenticate if their email domain is equal to this value (example: mycompany.com). Do not include a leading \"@\". If not set, all users on this OpenID Connect provider can authenticate to Sourcegraph.",
           "type": "string",
           "pattern": "^[^<@]"
+        },
+        "groupsClaimName": {
+          "description": "The name of the claim of the ID token or user info that lists the groups of a user, used by ` + "`" + `groupMappings` + "`" + `.",
+          "type": "string",
+          "default": "groups"
+        },
+        "groupMappings": {
+          "description": "Maps groups of the identity provider to Sourcegraph organizations, site admin status and repositories.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/AuthProviderGroupMapping" }
         }
       }
     },
@@ -277,6 +287,16 @@ const CriticalSchemaJSON = `{
           "description": "Whether the Service Provider should (insecurely) accept assertions from the Identity Provider without a valid signature.",
           "type": "boolean",
           "default": false
+        },
+        "groupsAttributeName": {
+          "description": "The name of the SAML assertion attribute that lists the groups of a user, used by ` + "`" + `groupMappings` + "`" + `. All the values of all the attributes with this name or friendly name are groups.",
+          "type": "string",
+          "default": "groups"
+        },
+        "groupMappings": {
+          "description": "Maps groups of the identity provider to Sourcegraph organizations, site admin status and repositories.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/AuthProviderGroupMapping" }
         }
       }
     },
@@ -359,6 +379,35 @@ const CriticalSchemaJSON = `{
         "displayName": { "$ref": "#/definitions/AuthProviderCommon/properties/displayName" }
       }
     },
+    "AuthProviderGroupMapping": {
+      "description": "Maps the members of a group of the identity provider to Sourcegraph organizations, site admin status and repositories. Group mappings are re-evaluated every time a user signs in.",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["group"],
+      "properties": {
+        "group": {
+          "description": "The name of the group, as listed in the groups of a user sent by the identity provider.",
+          "type": "string",
+          "minLength": 1
+        },
+        "orgs": {
+          "description": "The names of the organizations the members of the group are added to. Users who are no longer members of any group mapped to an organization are removed from it when they sign in.",
+          "type": "array",
+          "items": { "type": "string", "minLength": 1 }
+        },
+        "siteAdmin": {
+          "description": "Whether the members of the group are site admins. If any group mapping of an authentication provider sets this, the users of that provider are made site admins when they sign in if and only if they are members of such a group.",
+          "type": "boolean",
+          "default": false
+        },
+        "repos": {
+          "description": "The names of the repositories the members of the group can read, in addition to those they can read according to the permissions of the code host. It only has an effect on the repositories of code hosts whose repository permissions are enforced.",
+          "type": "array",
+          "items": { "type": "string", "minLength": 1 },
+          "examples": [["gitolite.example.com/my/repo"]]
+        }
+      }
+    },
     "AuthProviderCommon": {
       "$comment": "This schema is not used directly. The *AuthProvider schemas refer to its properties directly.",
       "description": "Common properties for authentication providers.",
@@ -41,6 +41,36 @@ const GitHubSchemaJSON = `{
       "pattern": "^-----BEGIN CERTIFICATE-----\n",
       "examples": ["-----BEGIN CERTIFICATE-----\n..."]
     },
+    "webhookSecret": {
+      "description": "The secret used to sign the payloads of GitHub webhooks. If set, a GitHub webhook for push events can be configured to post to the concatenation of your Sourcegraph instance URL and \"/.api/webhooks/github\" with this secret. Sourcegraph then updates a repository as soon as it is pushed to, instead of waiting for its next scheduled update.",
+      "type": "string",
+      "minLength": 1
+    },
+    "rateLimit": {
+      "description": "The budget of API requests Sourcegraph makes to GitHub. It is shared by all of Sourcegraph's requests made with the credentials of this external service. GitHub limits the requests of each token itself, so Sourcegraph doesn't limit its requests to GitHub unless this is set.",
+      "title": "GitHubRateLimit",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["enabled", "requestsPerHour"],
+      "properties": {
+        "enabled": {
+          "description": "Whether Sourcegraph limits the number of requests it makes to GitHub.",
+          "type": "boolean",
+          "default": true
+        },
+        "requestsPerHour": {
+          "description": "The number of requests per hour that Sourcegraph makes at most to GitHub. Up to a minute's worth of requests can be made at once.",
+          "type": "number",
+          "minimum": 1
+        }
+      },
+      "examples": [
+        {
+          "enabled": true,
+          "requestsPerHour": 2500
+        }
+      ]
+    },
     "repos": {
       "description": "An array of repository \"owner/name\" strings specifying which GitHub or GitHub Enterprise repositories to mirror on Sourcegraph.",
       "type": "array",
@@ -113,6 +143,11 @@ const GitHubSchemaJSON = `{
           "description": "The TTL of how long to cache permissions data. This is 3 hours by default.\n\nDecreasing the TTL will increase the load on the code host API. If you have X repositories on your instance, it will take ~X/100 API requests to fetch the complete list for 1 user.  If you have Y users, you will incur X*Y/100 API requests per cache refresh period.\n\nIf set to zero, Sourcegraph will sync a user's entire accessible repository list on every request (NOT recommended).",
           "type": "string",
           "default": "3h"
+        },
+        "hardTTL": {
+          "description": "Duration after which a user's cached permissions must be updated before authorizing any user actions. This is 3 days by default.",
+          "type": "string",
+          "default": "72h"
         }
       }
     }
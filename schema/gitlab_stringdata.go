@@ -41,6 +41,40 @@ const GitLabSchemaJSON = `{
       "pattern": "^-----BEGIN CERTIFICATE-----\n",
       "examples": ["-----BEGIN CERTIFICATE-----\n..."]
     },
+    "webhookSecret": {
+      "description": "The secret token of GitLab system hooks. If set, a GitLab system hook for push, tag push and repository update events can be configured to post to the concatenation of your Sourcegraph instance URL and \"/.api/webhooks/gitlab\" with this secret token. Sourcegraph then updates a repository as soon as it is pushed to, instead of waiting for its next scheduled update.",
+      "type": "string",
+      "minLength": 1
+    },
+    "rateLimit": {
+      "description": "The budget of API requests Sourcegraph makes to GitLab. It is shared by all of Sourcegraph's requests made with the credentials of this external service.",
+      "title": "GitLabRateLimit",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["enabled", "requestsPerHour"],
+      "properties": {
+        "enabled": {
+          "description": "Whether Sourcegraph limits the number of requests it makes to GitLab.",
+          "type": "boolean",
+          "default": true
+        },
+        "requestsPerHour": {
+          "description": "The number of requests per hour that Sourcegraph makes at most to GitLab. Up to a minute's worth of requests can be made at once.",
+          "type": "number",
+          "minimum": 1
+        }
+      },
+      "default": {
+        "enabled": true,
+        "requestsPerHour": 36000
+      },
+      "examples": [
+        {
+          "enabled": true,
+          "requestsPerHour": 18000
+        }
+      ]
+    },
     "projects": {
       "description": "A list of projects to mirror from this GitLab instance. Supports including by name ({\"name\": \"group/name\"}) or by ID ({\"id\": 42}).",
       "type": "array",
@@ -143,6 +177,11 @@ const GitLabSchemaJSON = `{
           "description": "The TTL of how long to cache permissions data. This is 3 hours by default.\n\nDecreasing the TTL will increase the load on the code host API. If you have X repos on your instance, it will take ~X/100 API requests to fetch the complete list for 1 user.  If you have Y users, you will incur X*Y/100 API requests per cache refresh period.\n\nIf set to zero, Sourcegraph will sync a user's entire accessible repository list on every request (NOT recommended).",
           "type": "string",
           "default": "3h"
+        },
+        "hardTTL": {
+          "description": "Duration after which a user's cached permissions must be updated before authorizing any user actions. This is 3 days by default.",
+          "type": "string",
+          "default": "72h"
         }
       }
     }
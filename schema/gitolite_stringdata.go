@@ -55,6 +55,13 @@ const GitoliteSchemaJSON = `{
       },
       "examples": [[{ "name": "myrepo" }]]
     },
+    "authorization": {
+      "title": "ExplicitAuthorization",
+      "description": "If non-null, enforces the repository permissions set explicitly by site admins with the ` + "`" + `setRepositoryPermissionsForUsers` + "`" + ` GraphQL mutation on the repositories of this connection. Users can only read the repositories they were explicitly granted access to.",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {}
+    },
     "phabricatorMetadataCommand": {
       "description": "This is DEPRECATED. Use the ` + "`" + `phabricator` + "`" + ` field instead.",
       "type": "string"
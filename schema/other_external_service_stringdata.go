@@ -34,6 +34,13 @@ const OtherExternalServiceSchemaJSON = `{
         "examples": ["path/to/my/repo", "path/to/my/repo.git/"]
       }
     },
+    "authorization": {
+      "title": "ExplicitAuthorization",
+      "description": "If non-null, enforces the repository permissions set explicitly by site admins with the ` + "`" + `setRepositoryPermissionsForUsers` + "`" + ` GraphQL mutation on the repositories of this connection. Users can only read the repositories they were explicitly granted access to.",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {}
+    },
     "repositoryPathPattern": {
       "description": "The pattern used to generate the corresponding Sourcegraph repository name for the repositories. In the pattern, the variable \"{base}\" is replaced with the Git clone base URL host and path, and \"{repo}\" is replaced with the repository path taken from the ` + "`" + `repos` + "`" + ` field.\n\nFor example, if your Git clone base URL is https://git.example.com/repos and ` + "`" + `repos` + "`" + ` contains the value \"my/repo\", then a repositoryPathPattern of \"{base}/{repo}\" would mean that a repository at https://git.example.com/repos/my/repo is available on Sourcegraph at https://sourcegraph.example.com/git.example.com/repos/my/repo.\n\nIt is important that the Sourcegraph repository name generated with this pattern be unique to this code host. If different code hosts generate repository names that collide, Sourcegraph's behavior is undefined.",
       "type": "string",
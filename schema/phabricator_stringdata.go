@@ -23,6 +23,35 @@ const PhabricatorSchemaJSON = `{
       "type": "string",
       "minLength": 1
     },
+    "rateLimit": {
+      "description": "The budget of API requests Sourcegraph makes to Phabricator. It is shared by all of Sourcegraph's requests made with the credentials of this external service.",
+      "title": "PhabricatorRateLimit",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["enabled", "requestsPerHour"],
+      "properties": {
+        "enabled": {
+          "description": "Whether Sourcegraph limits the number of requests it makes to Phabricator.",
+          "type": "boolean",
+          "default": true
+        },
+        "requestsPerHour": {
+          "description": "The number of requests per hour that Sourcegraph makes at most to Phabricator. Up to a minute's worth of requests can be made at once.",
+          "type": "number",
+          "minimum": 1
+        }
+      },
+      "default": {
+        "enabled": true,
+        "requestsPerHour": 7200
+      },
+      "examples": [
+        {
+          "enabled": true,
+          "requestsPerHour": 3600
+        }
+      ]
+    },
     "repos": {
       "description": "The list of repositories available on Phabricator.",
       "type": "array",
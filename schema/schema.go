@@ -14,6 +14,7 @@ type AWSCodeCommitConnection struct {
 	Exclude                     []*ExcludedAWSCodeCommitRepo `json:"exclude,omitempty"`
 	GitCredentials              AWSCodeCommitGitCredentials  `json:"gitCredentials"`
 	InitialRepositoryEnablement bool                         `json:"initialRepositoryEnablement,omitempty"`
+	RateLimit                   *AWSCodeCommitRateLimit      `json:"rateLimit,omitempty"`
 	Region                      string                       `json:"region"`
 	RepositoryPathPattern       string                       `json:"repositoryPathPattern,omitempty"`
 	SecretAccessKey             string                       `json:"secretAccessKey"`
@@ -28,6 +29,12 @@ type AWSCodeCommitGitCredentials struct {
 	Username string `json:"username"`
 }
 
+// AWSCodeCommitRateLimit description: The budget of API requests Sourcegraph makes to AWS CodeCommit. It is shared by all of Sourcegraph's requests made with the credentials of this external service.
+type AWSCodeCommitRateLimit struct {
+	Enabled         bool    `json:"enabled"`
+	RequestsPerHour float64 `json:"requestsPerHour"`
+}
+
 // AuthAccessTokens description: Settings for access tokens, which enable external tools to access the Sourcegraph API with the privileges of the user.
 type AuthAccessTokens struct {
 	Allow string `json:"allow,omitempty"`
@@ -37,6 +44,14 @@ type AuthAccessTokens struct {
 type AuthProviderCommon struct {
 	DisplayName string `json:"displayName,omitempty"`
 }
+
+// AuthProviderGroupMapping description: Maps the members of a group of the identity provider to Sourcegraph organizations, site admin status and repositories. Group mappings are re-evaluated every time a user signs in.
+type AuthProviderGroupMapping struct {
+	Group     string   `json:"group"`
+	Orgs      []string `json:"orgs,omitempty"`
+	Repos     []string `json:"repos,omitempty"`
+	SiteAdmin bool     `json:"siteAdmin,omitempty"`
+}
 type AuthProviders struct {
 	Builtin       *BuiltinAuthProvider
 	Saml          *SAMLAuthProvider
@@ -91,14 +106,57 @@ func (v *AuthProviders) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("tagged union type must have a %q property whose value is one of %s", "type", []string{"builtin", "saml", "openidconnect", "http-header", "github", "gitlab"})
 }
 
+// BitbucketCloudAuthorization description: If non-null, enforces Bitbucket Cloud repository permissions. The configured username must be an administrator of the configured teams.
+type BitbucketCloudAuthorization struct {
+	HardTTL          string                         `json:"hardTTL,omitempty"`
+	IdentityProvider BitbucketCloudIdentityProvider `json:"identityProvider"`
+	Ttl              string                         `json:"ttl,omitempty"`
+}
+
 // BitbucketCloudConnection description: Configuration for a connection to Bitbucket Cloud.
 type BitbucketCloudConnection struct {
-	AppPassword           string   `json:"appPassword"`
-	GitURLType            string   `json:"gitURLType,omitempty"`
-	RepositoryPathPattern string   `json:"repositoryPathPattern,omitempty"`
-	Teams                 []string `json:"teams,omitempty"`
-	Url                   string   `json:"url"`
-	Username              string   `json:"username"`
+	AppPassword           string                       `json:"appPassword"`
+	Authorization         *BitbucketCloudAuthorization `json:"authorization,omitempty"`
+	GitURLType            string                       `json:"gitURLType,omitempty"`
+	RateLimit             *BitbucketCloudRateLimit     `json:"rateLimit,omitempty"`
+	RepositoryPathPattern string                       `json:"repositoryPathPattern,omitempty"`
+	Teams                 []string                     `json:"teams,omitempty"`
+	Url                   string                       `json:"url"`
+	Username              string                       `json:"username"`
+}
+
+// BitbucketCloudIdentityProvider description: The source of identity to use when computing permissions. This defines how to compute the Bitbucket Cloud identity to use for a given Sourcegraph user. When 'username' is used, Sourcegraph assumes usernames are identical in Sourcegraph and Bitbucket Cloud accounts and `auth.enableUsernameChanges` must be set to false for security reasons.
+type BitbucketCloudIdentityProvider struct {
+	Username *BitbucketCloudUsernameIdentity
+}
+
+func (v BitbucketCloudIdentityProvider) MarshalJSON() ([]byte, error) {
+	if v.Username != nil {
+		return json.Marshal(v.Username)
+	}
+	return nil, errors.New("tagged union type must have exactly 1 non-nil field value")
+}
+func (v *BitbucketCloudIdentityProvider) UnmarshalJSON(data []byte) error {
+	var d struct {
+		DiscriminantProperty string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	switch d.DiscriminantProperty {
+	case "username":
+		return json.Unmarshal(data, &v.Username)
+	}
+	return fmt.Errorf("tagged union type must have a %q property whose value is one of %s", "type", []string{"username"})
+}
+
+// BitbucketCloudRateLimit description: The budget of API requests Sourcegraph makes to Bitbucket Cloud. It is shared by all of Sourcegraph's requests made with the credentials of this external service.
+type BitbucketCloudRateLimit struct {
+	Enabled         bool    `json:"enabled"`
+	RequestsPerHour float64 `json:"requestsPerHour"`
+}
+type BitbucketCloudUsernameIdentity struct {
+	Type string `json:"type"`
 }
 
 // BitbucketServerAuthorization description: If non-null, enforces Bitbucket Server repository permissions.
@@ -118,12 +176,14 @@ type BitbucketServerConnection struct {
 	GitURLType                  string                         `json:"gitURLType,omitempty"`
 	InitialRepositoryEnablement bool                           `json:"initialRepositoryEnablement,omitempty"`
 	Password                    string                         `json:"password,omitempty"`
+	RateLimit                   *BitbucketServerRateLimit      `json:"rateLimit,omitempty"`
 	Repos                       []string                       `json:"repos,omitempty"`
 	RepositoryPathPattern       string                         `json:"repositoryPathPattern,omitempty"`
 	RepositoryQuery             []string                       `json:"repositoryQuery,omitempty"`
 	Token                       string                         `json:"token,omitempty"`
 	Url                         string                         `json:"url"`
 	Username                    string                         `json:"username"`
+	WebhookSecret               string                         `json:"webhookSecret,omitempty"`
 }
 
 // BitbucketServerIdentityProvider description: The source of identity to use when computing permissions. This defines how to compute the Bitbucket Server identity to use for a given Sourcegraph user. When 'username' is used, Sourcegraph assumes usernames are identical in Sourcegraph and Bitbucket Server accounts and `auth.enableUsernameChanges` must be set to false for security reasons.
@@ -156,6 +216,12 @@ type BitbucketServerOAuth struct {
 	ConsumerKey string `json:"consumerKey"`
 	SigningKey  string `json:"signingKey"`
 }
+
+// BitbucketServerRateLimit description: The budget of API requests Sourcegraph makes to Bitbucket Server. It is shared by all of Sourcegraph's requests made with the credentials of this external service.
+type BitbucketServerRateLimit struct {
+	Enabled         bool    `json:"enabled"`
+	RequestsPerHour float64 `json:"requestsPerHour"`
+}
 type BitbucketServerUsernameIdentity struct {
 	Type string `json:"type"`
 }
@@ -208,6 +274,34 @@ type CriticalConfiguration struct {
 	UseJaeger                  bool                `json:"useJaeger,omitempty"`
 }
 
+// CtagsDisabledLanguages description: Languages whose symbols are not indexed in some repositories.
+type CtagsDisabledLanguages struct {
+	Languages []string `json:"languages"`
+	Repos     string   `json:"repos"`
+}
+
+// CtagsKind description: A kind of symbol in a language defined in the site configuration.
+type CtagsKind struct {
+	Description string `json:"description,omitempty"`
+	Letter      string `json:"letter"`
+	Name        string `json:"name"`
+}
+
+// CtagsLanguage description: A language whose symbols are found with regular expressions by universal-ctags.
+type CtagsLanguage struct {
+	Extensions []string      `json:"extensions"`
+	Kinds      []*CtagsKind  `json:"kinds,omitempty"`
+	Name       string        `json:"name"`
+	Regexes    []*CtagsRegex `json:"regexes"`
+}
+
+// CtagsRegex description: A regular expression which matches the definition of a symbol in a language defined in the site configuration.
+type CtagsRegex struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+}
+
 // Discussions description: Configures Sourcegraph code discussions.
 type Discussions struct {
 	AbuseEmails     []string `json:"abuseEmails,omitempty"`
@@ -241,6 +335,10 @@ type ExperimentalFeatures struct {
 	StatusIndicator string `json:"statusIndicator,omitempty"`
 }
 
+// ExplicitAuthorization description: If non-null, enforces the repository permissions set explicitly by site admins with the `setRepositoryPermissionsForUsers` GraphQL mutation on the repositories of this connection. Users can only read the repositories they were explicitly granted access to.
+type ExplicitAuthorization struct {
+}
+
 // Extensions description: Configures Sourcegraph extensions.
 type Extensions struct {
 	AllowRemoteExtensions []string    `json:"allowRemoteExtensions,omitempty"`
@@ -254,6 +352,15 @@ type ExternalIdentity struct {
 	Type             string `json:"type"`
 }
 
+// GitClonePolicy description: How gitserver clones and updates the repositories which match the policy. A policy with neither pattern nor externalServiceURL matches all repositories.
+type GitClonePolicy struct {
+	Depth              int      `json:"depth,omitempty"`
+	ExternalServiceURL string   `json:"externalServiceURL,omitempty"`
+	Filter             string   `json:"filter,omitempty"`
+	Pattern            string   `json:"pattern,omitempty"`
+	Refs               []string `json:"refs,omitempty"`
+}
+
 // GitHubAuthProvider description: Configures the GitHub (or GitHub Enterprise) OAuth authentication provider for SSO. In addition to specifying this configuration object, you must also create a OAuth App on your GitHub instance: https://developer.github.com/apps/building-oauth-apps/creating-an-oauth-app/. When a user signs into Sourcegraph or links their GitHub account to their existing Sourcegraph account, GitHub will prompt the user for the repo scope.
 type GitHubAuthProvider struct {
 	AllowSignup  bool   `json:"allowSignup,omitempty"`
@@ -266,7 +373,8 @@ type GitHubAuthProvider struct {
 
 // GitHubAuthorization description: If non-null, enforces GitHub repository permissions. This requires that there is an item in the `auth.providers` field of type "github" with the same `url` field as specified in this `GitHubConnection`.
 type GitHubAuthorization struct {
-	Ttl string `json:"ttl,omitempty"`
+	HardTTL string `json:"hardTTL,omitempty"`
+	Ttl     string `json:"ttl,omitempty"`
 }
 
 // GitHubConnection description: Configuration for a connection to GitHub or GitHub Enterprise.
@@ -277,11 +385,19 @@ type GitHubConnection struct {
 	GitURLType                  string                `json:"gitURLType,omitempty"`
 	InitialRepositoryEnablement bool                  `json:"initialRepositoryEnablement,omitempty"`
 	Orgs                        []string              `json:"orgs,omitempty"`
+	RateLimit                   *GitHubRateLimit      `json:"rateLimit,omitempty"`
 	Repos                       []string              `json:"repos,omitempty"`
 	RepositoryPathPattern       string                `json:"repositoryPathPattern,omitempty"`
 	RepositoryQuery             []string              `json:"repositoryQuery,omitempty"`
 	Token                       string                `json:"token"`
 	Url                         string                `json:"url"`
+	WebhookSecret               string                `json:"webhookSecret,omitempty"`
+}
+
+// GitHubRateLimit description: The budget of API requests Sourcegraph makes to GitHub. It is shared by all of Sourcegraph's requests made with the credentials of this external service. GitHub limits the requests of each token itself, so Sourcegraph doesn't limit its requests to GitHub unless this is set.
+type GitHubRateLimit struct {
+	Enabled         bool    `json:"enabled"`
+	RequestsPerHour float64 `json:"requestsPerHour"`
 }
 
 // GitLabAuthProvider description: Configures the GitLab OAuth authentication provider for SSO. In addition to specifying this configuration object, you must also create a OAuth App on your GitLab instance: https://docs.gitlab.com/ee/integration/oauth_provider.html. The application should have `api` and `read_user` scopes and the callback URL set to the concatenation of your Sourcegraph instance URL and "/.auth/gitlab/callback".
@@ -295,6 +411,7 @@ type GitLabAuthProvider struct {
 
 // GitLabAuthorization description: If non-null, enforces GitLab repository permissions. This requires that there be an item in the `auth.providers` field of type "gitlab" with the same `url` field as specified in this `GitLabConnection`.
 type GitLabAuthorization struct {
+	HardTTL          string           `json:"hardTTL,omitempty"`
 	IdentityProvider IdentityProvider `json:"identityProvider"`
 	Ttl              string           `json:"ttl,omitempty"`
 }
@@ -308,17 +425,26 @@ type GitLabConnection struct {
 	InitialRepositoryEnablement bool                     `json:"initialRepositoryEnablement,omitempty"`
 	ProjectQuery                []string                 `json:"projectQuery"`
 	Projects                    []*GitLabProject         `json:"projects,omitempty"`
+	RateLimit                   *GitLabRateLimit         `json:"rateLimit,omitempty"`
 	RepositoryPathPattern       string                   `json:"repositoryPathPattern,omitempty"`
 	Token                       string                   `json:"token"`
 	Url                         string                   `json:"url"`
+	WebhookSecret               string                   `json:"webhookSecret,omitempty"`
 }
 type GitLabProject struct {
 	Id   int    `json:"id,omitempty"`
 	Name string `json:"name,omitempty"`
 }
 
+// GitLabRateLimit description: The budget of API requests Sourcegraph makes to GitLab. It is shared by all of Sourcegraph's requests made with the credentials of this external service.
+type GitLabRateLimit struct {
+	Enabled         bool    `json:"enabled"`
+	RequestsPerHour float64 `json:"requestsPerHour"`
+}
+
 // GitoliteConnection description: Configuration for a connection to Gitolite.
 type GitoliteConnection struct {
+	Authorization              *ExplicitAuthorization  `json:"authorization,omitempty"`
 	Blacklist                  string                  `json:"blacklist,omitempty"`
 	Exclude                    []*ExcludedGitoliteRepo `json:"exclude,omitempty"`
 	Host                       string                  `json:"host"`
@@ -394,20 +520,23 @@ type OAuthIdentity struct {
 
 // OpenIDConnectAuthProvider description: Configures the OpenID Connect authentication provider for SSO.
 type OpenIDConnectAuthProvider struct {
-	ClientID           string `json:"clientID"`
-	ClientSecret       string `json:"clientSecret"`
-	ConfigID           string `json:"configID,omitempty"`
-	DisplayName        string `json:"displayName,omitempty"`
-	Issuer             string `json:"issuer"`
-	RequireEmailDomain string `json:"requireEmailDomain,omitempty"`
-	Type               string `json:"type"`
+	ClientID           string                      `json:"clientID"`
+	ClientSecret       string                      `json:"clientSecret"`
+	ConfigID           string                      `json:"configID,omitempty"`
+	DisplayName        string                      `json:"displayName,omitempty"`
+	GroupMappings      []*AuthProviderGroupMapping `json:"groupMappings,omitempty"`
+	GroupsClaimName    string                      `json:"groupsClaimName,omitempty"`
+	Issuer             string                      `json:"issuer"`
+	RequireEmailDomain string                      `json:"requireEmailDomain,omitempty"`
+	Type               string                      `json:"type"`
 }
 
 // OtherExternalServiceConnection description: Configuration for a Connection to Git repositories for which an external service integration isn't yet available.
 type OtherExternalServiceConnection struct {
-	Repos                 []string `json:"repos"`
-	RepositoryPathPattern string   `json:"repositoryPathPattern,omitempty"`
-	Url                   string   `json:"url,omitempty"`
+	Authorization         *ExplicitAuthorization `json:"authorization,omitempty"`
+	Repos                 []string               `json:"repos"`
+	RepositoryPathPattern string                 `json:"repositoryPathPattern,omitempty"`
+	Url                   string                 `json:"url,omitempty"`
 }
 
 // ParentSourcegraph description: URL to fetch unreachable repository details from. Defaults to "https://sourcegraph.com"
@@ -423,9 +552,16 @@ type Phabricator struct {
 
 // PhabricatorConnection description: Configuration for a connection to Phabricator.
 type PhabricatorConnection struct {
-	Repos []*Repos `json:"repos,omitempty"`
-	Token string   `json:"token,omitempty"`
-	Url   string   `json:"url,omitempty"`
+	RateLimit *PhabricatorRateLimit `json:"rateLimit,omitempty"`
+	Repos     []*Repos              `json:"repos,omitempty"`
+	Token     string                `json:"token,omitempty"`
+	Url       string                `json:"url,omitempty"`
+}
+
+// PhabricatorRateLimit description: The budget of API requests Sourcegraph makes to Phabricator. It is shared by all of Sourcegraph's requests made with the credentials of this external service.
+type PhabricatorRateLimit struct {
+	Enabled         bool    `json:"enabled"`
+	RequestsPerHour float64 `json:"requestsPerHour"`
 }
 type QuickLink struct {
 	Description string `json:"description,omitempty"`
@@ -441,17 +577,19 @@ type Repos struct {
 //
 // Note: if you are using IdP-initiated login, you must have *at most one* SAMLAuthProvider in the `auth.providers` array.
 type SAMLAuthProvider struct {
-	ConfigID                                 string `json:"configID,omitempty"`
-	DisplayName                              string `json:"displayName,omitempty"`
-	IdentityProviderMetadata                 string `json:"identityProviderMetadata,omitempty"`
-	IdentityProviderMetadataURL              string `json:"identityProviderMetadataURL,omitempty"`
-	InsecureSkipAssertionSignatureValidation bool   `json:"insecureSkipAssertionSignatureValidation,omitempty"`
-	NameIDFormat                             string `json:"nameIDFormat,omitempty"`
-	ServiceProviderCertificate               string `json:"serviceProviderCertificate,omitempty"`
-	ServiceProviderIssuer                    string `json:"serviceProviderIssuer,omitempty"`
-	ServiceProviderPrivateKey                string `json:"serviceProviderPrivateKey,omitempty"`
-	SignRequests                             *bool  `json:"signRequests,omitempty"`
-	Type                                     string `json:"type"`
+	ConfigID                                 string                      `json:"configID,omitempty"`
+	DisplayName                              string                      `json:"displayName,omitempty"`
+	GroupMappings                            []*AuthProviderGroupMapping `json:"groupMappings,omitempty"`
+	GroupsAttributeName                      string                      `json:"groupsAttributeName,omitempty"`
+	IdentityProviderMetadata                 string                      `json:"identityProviderMetadata,omitempty"`
+	IdentityProviderMetadataURL              string                      `json:"identityProviderMetadataURL,omitempty"`
+	InsecureSkipAssertionSignatureValidation bool                        `json:"insecureSkipAssertionSignatureValidation,omitempty"`
+	NameIDFormat                             string                      `json:"nameIDFormat,omitempty"`
+	ServiceProviderCertificate               string                      `json:"serviceProviderCertificate,omitempty"`
+	ServiceProviderIssuer                    string                      `json:"serviceProviderIssuer,omitempty"`
+	ServiceProviderPrivateKey                string                      `json:"serviceProviderPrivateKey,omitempty"`
+	SignRequests                             *bool                       `json:"signRequests,omitempty"`
+	Type                                     string                      `json:"type"`
 }
 
 // SMTPServerConfig description: The SMTP server used to send transactional emails (such as email verifications, reset-password emails, and notifications).
@@ -513,8 +651,11 @@ type SiteConfiguration struct {
 	EmailSmtp                         *SMTPServerConfig           `json:"email.smtp,omitempty"`
 	ExperimentalFeatures              *ExperimentalFeatures       `json:"experimentalFeatures,omitempty"`
 	Extensions                        *Extensions                 `json:"extensions,omitempty"`
+	GitClonePolicies                  []*GitClonePolicy           `json:"gitClonePolicies,omitempty"`
 	GitCloneURLToRepositoryName       []*CloneURLToRepositoryName `json:"git.cloneURLToRepositoryName,omitempty"`
 	GitMaxConcurrentClones            int                         `json:"gitMaxConcurrentClones,omitempty"`
+	GitMaxRepoSizeMB                  int                         `json:"gitMaxRepoSizeMB,omitempty"`
+	GitReplicationFactor              int                         `json:"gitReplicationFactor,omitempty"`
 	GithubClientID                    string                      `json:"githubClientID,omitempty"`
 	GithubClientSecret                string                      `json:"githubClientSecret,omitempty"`
 	LsifUploadSecret                  string                      `json:"lsifUploadSecret,omitempty"`
@@ -525,6 +666,14 @@ type SiteConfiguration struct {
 	SearchIndexEnabled                *bool                       `json:"search.index.enabled,omitempty"`
 	SearchIndexSymbolsEnabled         *bool                       `json:"search.index.symbols.enabled,omitempty"`
 	SearchLargeFiles                  []string                    `json:"search.largeFiles,omitempty"`
+	SymbolsCtags                      *SymbolsCtags               `json:"symbols.ctags,omitempty"`
+}
+
+// SymbolsCtags description: Configures how the symbols service finds symbols with universal-ctags. It can add regular expression based parsers for languages ctags doesn't support (such as internal DSLs), rename the kinds of symbols, and disable languages in some repositories. After this is changed, the symbols of a commit are parsed again the next time they are searched.
+type SymbolsCtags struct {
+	DisabledLanguages []*CtagsDisabledLanguages    `json:"disabledLanguages,omitempty"`
+	KindMap           map[string]map[string]string `json:"kindMap,omitempty"`
+	Languages         []*CtagsLanguage             `json:"languages,omitempty"`
 }
 type UsernameIdentity struct {
 	Type string `json:"type"`
@@ -43,6 +43,47 @@ const SiteSchemaJSON = `{
       "group": "Search",
       "examples": [["go.sum", "package-lock.json", "*.thrift"]]
     },
+    "symbols.ctags": {
+      "description": "Configures how the symbols service finds symbols with universal-ctags. It can add regular expression based parsers for languages ctags doesn't support (such as internal DSLs), rename the kinds of symbols, and disable languages in some repositories. After this is changed, the symbols of a commit are parsed again the next time they are searched.",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "languages": {
+          "description": "Additional languages whose symbols are found with regular expressions. Each is defined as a regex parser in universal-ctags (see https://docs.ctags.io/en/latest/optlib.html).",
+          "type": "array",
+          "items": { "$ref": "#/definitions/CtagsLanguage" }
+        },
+        "kindMap": {
+          "description": "Renames the kinds of symbols found by ctags. The keys are ctags language names, and the values map the kind ctags reports to the kind to use instead. Kinds such as \"function\", \"class\" or \"variable\" determine the icon shown next to a symbol.",
+          "type": "object",
+          "additionalProperties": {
+            "type": "object",
+            "additionalProperties": { "type": "string" }
+          },
+          "examples": [{ "MyDSL": { "rule": "function" }, "Make": { "target": "function" } }]
+        },
+        "disabledLanguages": {
+          "description": "Languages whose symbols are not indexed in the repositories matching a pattern.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/CtagsDisabledLanguages" }
+        }
+      },
+      "examples": [
+        {
+          "languages": [
+            {
+              "name": "MyDSL",
+              "extensions": ["mydsl"],
+              "kinds": [{ "letter": "r", "name": "rule", "description": "rules" }],
+              "regexes": [{ "regex": "^rule[ \\t]+([a-zA-Z_]+)", "name": "\\1", "kind": "r" }]
+            }
+          ],
+          "kindMap": { "MyDSL": { "rule": "function" } },
+          "disabledLanguages": [{ "repos": "^github\\.com/myorg/generated-", "languages": ["JavaScript"] }]
+        }
+      ],
+      "group": "Search"
+    },
     "debug.search.symbolsParallelism": {
       "description": "(debug) controls the amount of symbol search parallelism. Defaults to 20. It is not recommended to change this outside of debugging scenarios. This option will be removed in a future version.",
       "type": "integer",
@@ -119,6 +160,25 @@ const SiteSchemaJSON = `{
       },
       "group": "External services"
     },
+    "gitClonePolicies": {
+      "description": "Policies for how gitserver clones and updates repositories. The first policy which matches a repository applies to it. Repositories which match no policy are mirrored in full. A partial clone filter omits large blobs, which gitserver fetches on demand when they are needed (e.g. to search a commit). A depth limits the history which is kept. A repository keeps the policy it was cloned with, so changes only apply to repositories cloned afterwards (e.g. after a reclone).",
+      "type": "array",
+      "items": { "$ref": "#/definitions/GitClonePolicy" },
+      "group": "External services",
+      "examples": [
+        [
+          {
+            "pattern": "^github\\.example\\.com/acme/monorepo$",
+            "filter": "blob:limit=1m",
+            "refs": ["refs/heads/*", "refs/tags/*"]
+          },
+          {
+            "externalServiceURL": "https://gitlab.example.com",
+            "depth": 100
+          }
+        ]
+      ]
+    },
     "githubClientID": {
       "description": "Client ID for GitHub.",
       "type": "string",
@@ -137,6 +197,21 @@ const SiteSchemaJSON = `{
       "default": 5,
       "group": "External services"
     },
+    "gitMaxRepoSizeMB": {
+      "description": "The maximum size of a repository on gitserver's disk, in megabytes (1 MB = 1,048,576 bytes). Clones that grow larger are aborted, and repositories that grow larger when they are updated are removed. Such repositories are reported as too large instead of filling up gitserver's disk. A value of 0 (the default) means there is no limit.",
+      "type": "integer",
+      "minimum": 0,
+      "default": 0,
+      "group": "External services",
+      "examples": [10240]
+    },
+    "gitReplicationFactor": {
+      "description": "Number of gitserver replicas which keep a copy of each repository. The primary gitserver of a repository clones and updates it from the code host, and the other gitservers keep mirrors fetched from the primary. Reads (such as searches, archives and blame) fail over to a replica if the primary gitserver is unavailable. It is capped at the number of gitserver replicas.",
+      "type": "integer",
+      "minimum": 1,
+      "default": 1,
+      "group": "External services"
+    },
     "repoListUpdateInterval": {
       "description": "Interval (in minutes) for checking code hosts (such as GitHub, Gitolite, etc.) for new repositories.",
       "type": "integer",
@@ -368,6 +443,132 @@ const SiteSchemaJSON = `{
     }
   },
   "definitions": {
+    "GitClonePolicy": {
+      "description": "How gitserver clones and updates the repositories which match the policy. A policy with neither pattern nor externalServiceURL matches all repositories.",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "pattern": {
+          "description": "Regular expression which the names of matching repositories match.",
+          "type": "string",
+          "format": "regex"
+        },
+        "externalServiceURL": {
+          "description": "The URL of the code host of the external service whose repositories match (e.g. https://github.example.com). It is compared with the host of each repository's clone URL.",
+          "type": "string",
+          "pattern": "^[a-zA-Z][a-zA-Z0-9+.-]*://"
+        },
+        "filter": {
+          "description": "Partial clone filter (as in git clone --filter). With blob:limit=<size> (e.g. blob:limit=1m) blobs larger than the size are omitted, and with blob:none all blobs are omitted. Omitted blobs are fetched from the code host on demand. The code host must support partial clones.",
+          "type": "string",
+          "pattern": "^(blob:none|blob:limit=[0-9]+[kmg]?)$"
+        },
+        "depth": {
+          "description": "Number of commits of history to keep from the tip of each mirrored ref (as in git clone --depth). The full history is kept if unset.",
+          "type": "integer",
+          "minimum": 1
+        },
+        "refs": {
+          "description": "Patterns of the refs to mirror, e.g. refs/heads/* and refs/tags/*. All refs are mirrored if unset.",
+          "type": "array",
+          "items": { "type": "string", "pattern": "^refs/[^:\\s]*$" },
+          "minItems": 1
+        }
+      }
+    },
+    "CtagsLanguage": {
+      "description": "A language whose symbols are found with regular expressions by universal-ctags.",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["name", "extensions", "regexes"],
+      "properties": {
+        "name": {
+          "description": "The name of the language. It must not be the name of a language ctags already supports.",
+          "type": "string",
+          "pattern": "^[a-zA-Z][a-zA-Z0-9]*$"
+        },
+        "extensions": {
+          "description": "The file extensions of the language, without the leading dot.",
+          "type": "array",
+          "items": { "type": "string", "pattern": "^[^.,/\\s][^,/\\s]*$" },
+          "minItems": 1
+        },
+        "kinds": {
+          "description": "The kinds of symbols in the language.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/CtagsKind" }
+        },
+        "regexes": {
+          "description": "The regular expressions which match the definitions of symbols.",
+          "type": "array",
+          "items": { "$ref": "#/definitions/CtagsRegex" },
+          "minItems": 1
+        }
+      }
+    },
+    "CtagsKind": {
+      "description": "A kind of symbol in a language defined in the site configuration.",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["letter", "name"],
+      "properties": {
+        "letter": {
+          "description": "The letter which refers to the kind in regexes.",
+          "type": "string",
+          "pattern": "^[a-zA-Z]$"
+        },
+        "name": {
+          "description": "The name of the kind, which is reported as the kind of the symbols.",
+          "type": "string",
+          "pattern": "^[a-zA-Z][a-zA-Z0-9]*$"
+        },
+        "description": {
+          "description": "A description of the kind. Defaults to the name.",
+          "type": "string"
+        }
+      }
+    },
+    "CtagsRegex": {
+      "description": "A regular expression which matches the definition of a symbol in a language defined in the site configuration.",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["regex", "name", "kind"],
+      "properties": {
+        "regex": {
+          "description": "A POSIX extended regular expression which is matched against each line of a file.",
+          "type": "string",
+          "minLength": 1
+        },
+        "name": {
+          "description": "The name of the symbol, which may refer to groups of the regex, e.g. \"\\1\".",
+          "type": "string",
+          "minLength": 1
+        },
+        "kind": {
+          "description": "The letter of the kind of the symbol, which must be defined in the language's kinds.",
+          "type": "string",
+          "pattern": "^[a-zA-Z]$"
+        }
+      }
+    },
+    "CtagsDisabledLanguages": {
+      "description": "Languages whose symbols are not indexed in some repositories.",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["repos", "languages"],
+      "properties": {
+        "repos": {
+          "description": "A regular expression which matches the names of the repositories, e.g. \"^github\\.com/myorg/\".",
+          "type": "string",
+          "format": "regex"
+        },
+        "languages": {
+          "description": "The ctags names of the languages, e.g. \"JavaScript\". They are matched case insensitively.",
+          "type": "array",
+          "items": { "type": "string" }
+        }
+      }
+    },
     "BrandAssets": {
       "type": "object",
       "properties": {
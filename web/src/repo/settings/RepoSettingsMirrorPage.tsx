@@ -2,6 +2,7 @@ import { LoadingSpinner } from '@sourcegraph/react-loading-spinner'
 import { upperFirst } from 'lodash'
 import CheckIcon from 'mdi-react/CheckIcon'
 import LockIcon from 'mdi-react/LockIcon'
+import prettyBytes from 'pretty-bytes'
 import * as React from 'react'
 import { RouteComponentProps } from 'react-router'
 import { Link } from 'react-router-dom'
@@ -74,12 +75,21 @@ class UpdateMirrorRepositoryActionContainer extends React.PureComponent<UpdateMi
                             'unknown'
                         )}{' '}
                     </div>
+                    <div>Size on disk: {prettyBytes(Number(this.props.repo.mirrorInfo.byteSize))}</div>
                     {updateSchedule && (
                         <div>
                             Next scheduled update <Timestamp date={updateSchedule.due} /> (position{' '}
                             {updateSchedule.index + 1} out of {updateSchedule.total} in the schedule)
                         </div>
                     )}
+                    {updateSchedule && updateSchedule.lastChanged && (
+                        <div>
+                            Last changed: <Timestamp date={updateSchedule.lastChanged} />
+                        </div>
+                    )}
+                    {updateSchedule && updateSchedule.lastError && (
+                        <div className="text-danger">Last update failed: {updateSchedule.lastError}</div>
+                    )}
                     {this.props.repo.mirrorInfo.updateQueue && !this.props.repo.mirrorInfo.updateQueue.updating && (
                         <div>
                             Queued for update (position {this.props.repo.mirrorInfo.updateQueue.index + 1} out of{' '}
@@ -95,6 +105,17 @@ class UpdateMirrorRepositoryActionContainer extends React.PureComponent<UpdateMi
                     'This repository is automatically updated from its remote repository periodically and when accessed by a user.'
             }
             buttonLabel = 'Refresh now'
+        } else if (this.props.repo.mirrorInfo.tooLarge) {
+            title = 'Repository too large'
+            description = (
+                <>
+                    This repository ({prettyBytes(Number(this.props.repo.mirrorInfo.byteSize))} or more) is larger than
+                    the maximum repository size set by the <code>gitMaxRepoSizeMB</code> site configuration option, so
+                    it is not cloned.
+                </>
+            )
+            buttonLabel = 'Clone now'
+            buttonDisabled = true
         } else {
             title = 'Clone this repository'
             description = 'This repository has not yet been cloned from its remote repository.'
@@ -22,10 +22,14 @@ export function fetchRepository(name: string): Observable<GQL.IRepository> {
                         cloneProgress
                         cloned
                         updatedAt
+                        byteSize
+                        tooLarge
                         updateSchedule {
                             due
                             index
                             total
+                            lastChanged
+                            lastError
                         }
                         updateQueue {
                             updating
@@ -2,6 +2,8 @@ import { LoadingSpinner } from '@sourcegraph/react-loading-spinner'
 import CloudDownloadIcon from 'mdi-react/CloudDownloadIcon'
 import CloudOutlineIcon from 'mdi-react/CloudOutlineIcon'
 import SettingsIcon from 'mdi-react/SettingsIcon'
+import WarningIcon from 'mdi-react/WarningIcon'
+import prettyBytes from 'pretty-bytes'
 import * as React from 'react'
 import { RouteComponentProps } from 'react-router'
 import { Link } from 'react-router-dom'
@@ -46,21 +48,39 @@ class RepositoryNode extends React.PureComponent<RepositoryNodeProps, Repository
                                 <LoadingSpinner className="icon-inline" /> Cloning
                             </small>
                         )}
-                        {!this.props.node.mirrorInfo.cloneInProgress && !this.props.node.mirrorInfo.cloned && (
+                        {this.props.node.mirrorInfo.cloned && (
+                            <small className="ml-2 text-muted">
+                                {prettyBytes(Number(this.props.node.mirrorInfo.byteSize))}
+                            </small>
+                        )}
+                        {this.props.node.mirrorInfo.tooLarge && (
                             <small
-                                className="ml-2 text-muted"
-                                data-tooltip="Visit the repository to clone it. See its mirroring settings for diagnostics."
+                                className="ml-2 text-warning"
+                                data-tooltip="The repository is larger than the gitMaxRepoSizeMB site configuration option allows."
                             >
-                                <CloudOutlineIcon className="icon-inline" /> Not yet cloned
+                                <WarningIcon className="icon-inline" /> Too large (
+                                {prettyBytes(Number(this.props.node.mirrorInfo.byteSize))} or more)
                             </small>
                         )}
+                        {!this.props.node.mirrorInfo.cloneInProgress &&
+                            !this.props.node.mirrorInfo.cloned &&
+                            !this.props.node.mirrorInfo.tooLarge && (
+                                <small
+                                    className="ml-2 text-muted"
+                                    data-tooltip="Visit the repository to clone it. See its mirroring settings for diagnostics."
+                                >
+                                    <CloudOutlineIcon className="icon-inline" /> Not yet cloned
+                                </small>
+                            )}
                     </div>
                     <div className="repository-node__actions">
-                        {!this.props.node.mirrorInfo.cloneInProgress && !this.props.node.mirrorInfo.cloned && (
-                            <Link className="btn btn-sm btn-secondary" to={this.props.node.url}>
-                                <CloudDownloadIcon className="icon-inline" /> Clone now
-                            </Link>
-                        )}{' '}
+                        {!this.props.node.mirrorInfo.cloneInProgress &&
+                            !this.props.node.mirrorInfo.cloned &&
+                            !this.props.node.mirrorInfo.tooLarge && (
+                                <Link className="btn btn-sm btn-secondary" to={this.props.node.url}>
+                                    <CloudDownloadIcon className="icon-inline" /> Clone now
+                                </Link>
+                            )}{' '}
                         {
                             <Link
                                 className="btn btn-secondary btn-sm"
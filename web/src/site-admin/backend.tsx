@@ -132,6 +132,8 @@ function fetchAllRepositories(args: RepositoryArgs): Observable<GQL.IRepositoryC
                             cloned
                             cloneInProgress
                             updatedAt
+                            byteSize
+                            tooLarge
                         }
                     }
                     totalCount(precise: true)
@@ -143,7 +143,7 @@ export class UserSettingsCreateAccessTokenPage extends React.PureComponent<Props
                                 className="form-check-input"
                                 type="checkbox"
                                 id="user-settings-create-access-token-page__scope-user:all"
-                                checked={true}
+                                checked={this.state.scopes.includes(AccessTokenScopes.UserAll)}
                                 value={AccessTokenScopes.UserAll}
                                 onChange={this.onScopesChange}
                                 disabled={true}
@@ -165,6 +165,7 @@ export class UserSettingsCreateAccessTokenPage extends React.PureComponent<Props
                                     checked={this.state.scopes.includes(AccessTokenScopes.SiteAdminSudo)}
                                     value={AccessTokenScopes.SiteAdminSudo}
                                     onChange={this.onScopesChange}
+                                    disabled={this.state.scopes.includes(AccessTokenScopes.SiteAdminSCIM)}
                                 />
                                 <label
                                     className="form-check-label"
@@ -175,6 +176,25 @@ export class UserSettingsCreateAccessTokenPage extends React.PureComponent<Props
                                 </label>
                             </div>
                         )}
+                        {this.props.user.siteAdmin && (
+                            <div className="form-check">
+                                <input
+                                    className="form-check-input"
+                                    type="checkbox"
+                                    id="user-settings-create-access-token-page__scope-site-admin:scim"
+                                    checked={this.state.scopes.includes(AccessTokenScopes.SiteAdminSCIM)}
+                                    value={AccessTokenScopes.SiteAdminSCIM}
+                                    onChange={this.onScopesChange}
+                                />
+                                <label
+                                    className="form-check-label"
+                                    htmlFor="user-settings-create-access-token-page__scope-site-admin:scim"
+                                >
+                                    <strong>{AccessTokenScopes.SiteAdminSCIM}</strong> — Ability to provision users and
+                                    organizations with the SCIM API (the token can only be used with the SCIM API)
+                                </label>
+                            </div>
+                        )}
                     </div>
                     <button
                         type="submit"
@@ -210,6 +230,11 @@ export class UserSettingsCreateAccessTokenPage extends React.PureComponent<Props
     private onScopesChange: React.ChangeEventHandler<HTMLInputElement> = e => {
         const checked = e.currentTarget.checked
         const value = e.currentTarget.value
+        if (value === AccessTokenScopes.SiteAdminSCIM) {
+            // SCIM tokens have no other scope, so that they can only be used with the SCIM API.
+            this.setState({ scopes: checked ? [AccessTokenScopes.SiteAdminSCIM] : [AccessTokenScopes.UserAll] })
+            return
+        }
         this.setState(prevState => ({
             scopes: checked ? [...prevState.scopes, value] : prevState.scopes.filter(s => s !== value),
         }))